@@ -1,5 +1,7 @@
 // musicsync scans the music directory and synchronises it with the music
-// database. Run from the repository root:
+// database. Playlist files (.m3u, .m3u8, .pls, .xspf) dropped into the music
+// directory are imported as playlists named after the file. Run from the
+// repository root:
 //
 //	go run ./cmd/musicsync/
 package main
@@ -20,6 +22,9 @@ func main() {
 	force := flag.Bool("force", false, "re-read metadata for all files, ignoring cached mtime")
 	rename := flag.Bool("rename", false, "reorganise music directory into [artist]/[album]/... structure")
 	lookup := flag.Bool("lookup", false, "enrich missing metadata via AcoustID + MusicBrainz (requires music.acoustidKey in config)")
+	loudness := flag.Bool("loudness", false, "only measure ReplayGain loudness for songs not yet analysed (all songs with -force), skipping the file scan")
+	fingerprint := flag.Bool("fingerprint", false, "only compute fingerprints (for duplicate detection) for songs without one (all songs with -force), skipping the file scan")
+	lyrics := flag.Bool("lyrics", false, "fetch synced lyrics from music.lyricsURL for songs without any (retry misses with -force)")
 	flag.Parse()
 
 	result := config.Load()
@@ -39,6 +44,18 @@ func main() {
 		Rename: *rename,
 	}
 	syncer := music.NewSyncer(db, cfg.Music, cfg.Storage.Music, cfg.Storage.Backup, opts)
+	if *loudness {
+		if err := syncer.MeasureLoudness(ctx, *force); err != nil {
+			log.Fatal("musicsync --loudness:", err)
+		}
+		return
+	}
+	if *fingerprint {
+		if err := syncer.FingerprintSongs(ctx, *force); err != nil {
+			log.Fatal("musicsync --fingerprint:", err)
+		}
+		return
+	}
 	if err := syncer.Run(ctx); err != nil {
 		log.Fatal("musicsync:", err)
 	}
@@ -52,4 +69,11 @@ func main() {
 			log.Fatal("musicsync --lookup:", err)
 		}
 	}
+
+	if *lyrics {
+		lyr := music.NewLyricsLookup(db, cfg.Music, music.LookupOptions{Force: *force})
+		if err := lyr.Run(ctx); err != nil {
+			log.Fatal("musicsync --lyrics:", err)
+		}
+	}
 }
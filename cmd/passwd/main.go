@@ -0,0 +1,52 @@
+// passwd hashes a PIN or password for a user in the auth.users config list.
+// It reads the secret from stdin (or prompts for it on a terminal) and
+// prints the hash to paste into config.yaml.
+//
+// Usage:
+//
+//	passwd [--name <user>] [--role viewer|passenger|pilot|admin]
+//
+// With --name, prints a complete users entry instead of just the hash.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/vincent99/velocipi/server/auth"
+)
+
+func main() {
+	name := flag.String("name", "", "user name (prints a full config entry)")
+	role := flag.String("role", "pilot", "role for the config entry")
+	flag.Parse()
+
+	if !auth.Role(*role).Valid() {
+		log.Fatalf("unknown role %q", *role)
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "PIN or password: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal("no PIN or password given")
+	}
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		log.Fatal("no PIN or password given")
+	}
+
+	hash, err := auth.HashPassword(secret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *name == "" {
+		fmt.Println(hash)
+		return
+	}
+	fmt.Printf("    - { name: %q, role: %s, hash: %q }\n", *name, *role, hash)
+}
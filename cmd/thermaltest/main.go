@@ -2,7 +2,13 @@
 //
 // Usage:
 //
-//	go run ./cmd/thermaltest/ -dev /dev/ttyAMA4 [-verbose] [-brightness]
+//	go run ./cmd/thermaltest/ -dev /dev/ttyAMA4 [-verbose] [-brightness] [-record session.cap]
+//	go run ./cmd/thermaltest/ -sim
+//
+// -record saves the serial session as a capture file. Copying it to
+// server/hardware/thermalcam/testdata, with the expected ReadState result
+// alongside as <name>.json (see the existing files), makes it a replay
+// regression test.
 package main
 
 import (
@@ -15,6 +21,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/vincent99/velocipi/server/hardware/serial"
 	"github.com/vincent99/velocipi/server/hardware/thermalcam"
 )
 
@@ -29,6 +36,8 @@ func main() {
 	timeout := flag.Duration("timeout", 30*time.Second, "max time to wait for camera ready")
 	verbose := flag.Bool("verbose", false, "log every sent/received byte")
 	brightness := flag.Bool("brightness", false, "send SetBrightness(100) and show raw response instead of waiting for ready")
+	record := flag.String("record", "", "write the serial session to this capture file")
+	sim := flag.Bool("sim", false, "talk to the built-in simulator instead of a device")
 	flag.Parse()
 
 	// --- Checksum / packet-building verification (no device needed) ---
@@ -46,11 +55,28 @@ func main() {
 	log.Printf("expected RX         — RX: % X", wantRX)
 
 	// --- Device probe ---
-	log.Printf("opening %s at %d baud", *dev, *baud)
-	cam, err := thermalcam.NewBaud(*dev, *baud)
-	if err != nil {
-		log.Fatalf("open error: %v", err)
+	var conn io.ReadWriter
+	if *sim {
+		log.Println("using the simulator")
+		conn = thermalcam.NewSim()
+	} else {
+		log.Printf("opening %s at %d baud", *dev, *baud)
+		f, err := serial.Open(*dev, *baud)
+		if err != nil {
+			log.Fatalf("open error: %v", err)
+		}
+		conn = f
+	}
+	if *record != "" {
+		out, err := os.Create(*record)
+		if err != nil {
+			log.Fatalf("record: %v", err)
+		}
+		defer out.Close()
+		fmt.Fprintf(out, "# thermaltest %s\n", time.Now().Format(time.RFC3339))
+		conn = thermalcam.NewRecorder(conn, out)
 	}
+	cam := thermalcam.NewConn(conn)
 	defer cam.Close()
 	cam.Verbose = *verbose
 
@@ -78,6 +104,7 @@ func main() {
 	log.Printf("waiting for ready (timeout %s)…", *timeout)
 	state, errs := cam.ReadState(*timeout)
 	if state == nil {
+		cam.Close() // flush the recording
 		log.Fatalf("failed: %v", errs[0])
 	}
 
@@ -90,6 +117,7 @@ func main() {
 		for _, e := range errs {
 			fmt.Fprintf(os.Stderr, "  %v\n", e)
 		}
+		cam.Close()
 		os.Exit(1)
 	}
 }
@@ -18,7 +18,9 @@ import (
 
 	"github.com/vincent99/liveatc/internal/api"
 	"github.com/vincent99/liveatc/internal/audio"
+	"github.com/vincent99/liveatc/internal/auth"
 	"github.com/vincent99/liveatc/internal/config"
+	"github.com/vincent99/liveatc/internal/duck"
 	"github.com/vincent99/liveatc/internal/gps"
 	"github.com/vincent99/liveatc/internal/pipeline"
 	"github.com/vincent99/liveatc/internal/ptt"
@@ -126,12 +128,23 @@ func main() {
 		}
 	}
 
+	// Music ducking signal to velocipi (optional; disabled when duckURL is empty).
+	ducker := duck.New(cfg.LiveATC.DuckURL, log)
+	duckDone := make(chan struct{})
+	go func() { ducker.Run(ctx); close(duckDone) }()
+
 	// PTT monitor (optional; disabled when pttPin is empty or non-linux).
+	// Keying the radio pauses the music.
 	pttMon := newPTT(cfg, log)
 	defer pttMon.Close()
+	pttMon.OnChange(func(active bool) { ducker.Set(duck.PTT, active) })
 
 	// API server.
-	apiSrv := api.New(cfg.LiveATC.Addr, cfg.Storage.LiveATC, cfg.LiveATC.UIDir, store, writer, gpsStore, sess, log)
+	authn, err := auth.New(cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
+	apiSrv := api.New(cfg.LiveATC.Addr, cfg.Storage.LiveATC, cfg.LiveATC.UIDir, store, writer, gpsStore, sess, authn, log)
 	go func() {
 		if err := apiSrv.Start(); err != nil {
 			log.Error("api server", "err", err)
@@ -150,6 +163,7 @@ func main() {
 		Writer:      writer,
 		GPS:         gpsStore,
 		PTT:         pttMon,
+		Duck:        ducker,
 		// A file source is bounded, so block on a full STT queue (backpressure)
 		// instead of dropping segments; live sources drop to avoid wedging.
 		LiveSource: *filePath == "",
@@ -159,6 +173,9 @@ func main() {
 		log.Error("pipeline exited", "err", err)
 	}
 
+	stop()     // end of input (file mode) doesn't cancel ctx by itself
+	<-duckDone // release any duck still held
+
 	// Shut the API down cleanly.
 	shutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
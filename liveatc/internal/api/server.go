@@ -16,6 +16,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/vincent99/liveatc/internal/auth"
 	"github.com/vincent99/liveatc/internal/gps"
 	"github.com/vincent99/liveatc/internal/session"
 	"github.com/vincent99/liveatc/internal/transcript"
@@ -36,8 +37,9 @@ type Server struct {
 
 // New builds the API server bound to addr. root is the storage root; writer is
 // the live session's transcript writer (for corrections); uiDir is the built
-// SPA directory (empty to disable UI serving).
-func New(addr, root, uiDir string, store *transcript.Store, writer *transcript.Writer, gpsStore *gps.Store, sess *session.Session, log *slog.Logger) *Server {
+// SPA directory (empty to disable UI serving). Every route except /healthz
+// and the UI requires a velocipi session with access allowed by authn.
+func New(addr, root, uiDir string, store *transcript.Store, writer *transcript.Writer, gpsStore *gps.Store, sess *session.Session, authn *auth.Verifier, log *slog.Logger) *Server {
 	s := &Server{
 		store:  store,
 		writer: writer,
@@ -52,16 +54,16 @@ func New(addr, root, uiDir string, store *transcript.Store, writer *transcript.W
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /healthz", s.handleHealth)
-	mux.HandleFunc("GET /api/session", s.handleSession)
-	mux.HandleFunc("GET /api/sessions", s.handleSessions)
-	mux.HandleFunc("GET /api/transcripts/session/{id}", s.handleBySession)
-	mux.HandleFunc("GET /api/transcripts/recent", s.handleRecent)
-	mux.HandleFunc("PUT /api/transcripts/session/{sid}/{id}/correction", s.handleCorrection)
-	mux.HandleFunc("PUT /api/transcripts/session/{sid}/{id}/reviewed", s.handleReviewed)
-	mux.HandleFunc("GET /api/media/{path...}", s.handleMedia)
-	mux.HandleFunc("POST /api/gps", s.handlePostGPS)
-	mux.HandleFunc("/ws/transcripts", s.handleWSTranscripts)
-	mux.HandleFunc("/ws/gps", s.handleWSGPS)
+	mux.HandleFunc("GET /api/session", authn.Require(s.handleSession))
+	mux.HandleFunc("GET /api/sessions", authn.Require(s.handleSessions))
+	mux.HandleFunc("GET /api/transcripts/session/{id}", authn.Require(s.handleBySession))
+	mux.HandleFunc("GET /api/transcripts/recent", authn.Require(s.handleRecent))
+	mux.HandleFunc("PUT /api/transcripts/session/{sid}/{id}/correction", authn.Require(s.handleCorrection))
+	mux.HandleFunc("PUT /api/transcripts/session/{sid}/{id}/reviewed", authn.Require(s.handleReviewed))
+	mux.HandleFunc("GET /api/media/{path...}", authn.Require(s.handleMedia))
+	mux.HandleFunc("POST /api/gps", authn.Require(s.handlePostGPS))
+	mux.HandleFunc("/ws/transcripts", authn.Require(s.handleWSTranscripts))
+	mux.HandleFunc("/ws/gps", authn.Require(s.handleWSGPS))
 	if uiDir != "" {
 		mux.Handle("/", s.spaHandler())
 	}
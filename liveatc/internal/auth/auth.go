@@ -0,0 +1,179 @@
+// Package auth checks velocipi login sessions on the transcript API. Users
+// log in on velocipi, whose session cookie the browser also sends here
+// (cookies aren't port-specific); the token is verified with the signing key
+// both processes share through auth.keyFile. This mirrors velocipi's
+// server/auth token format.
+//
+// Transcripts are readable by the viewer, pilot and admin roles and editable
+// by pilot and admin. Passengers (music only) have no access.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vincent99/liveatc/internal/config"
+)
+
+// CookieName is velocipi's session cookie.
+const CookieName = "velocipi_session"
+
+const keySize = 32
+
+// Verifier identifies requests from their velocipi session.
+type Verifier struct {
+	cfg config.AuthConfig
+	key []byte
+}
+
+// New loads (or creates) the shared signing key from cfg.KeyFile.
+func New(cfg config.AuthConfig) (*Verifier, error) {
+	key, err := loadKey(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{cfg: cfg, key: key}, nil
+}
+
+// loadKey reads the hex-encoded signing key, creating it if velocipi hasn't
+// yet.
+func loadKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("auth: keyFile is not set in config")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key := make([]byte, keySize)
+		rand.Read(key)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			defer f.Close()
+			if _, err := fmt.Fprintf(f, "%x\n", key); err != nil {
+				return nil, fmt.Errorf("auth: write key: %w", err)
+			}
+			return key, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("auth: create key: %w", err)
+		}
+		// velocipi created it in the meantime.
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: read key: %w", err)
+	}
+	var key []byte
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%x", &key); err != nil || len(key) < 16 {
+		return nil, errors.New("auth: key file is not a hex key of at least 16 bytes")
+	}
+	return key, nil
+}
+
+// Role returns the velocipi role of whoever made r: the session's user,
+// else the local role for unproxied requests from this machine, else the
+// anonymous role. "" means none.
+func (v *Verifier) Role(r *http.Request) string {
+	tok := ""
+	if c, err := r.Cookie(CookieName); err == nil {
+		tok = c.Value
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		tok = strings.TrimPrefix(h, "Bearer ")
+	}
+	if name := v.verify(tok, time.Now()); name != "" {
+		for _, u := range v.cfg.Users {
+			if u.Name == name {
+				return u.Role
+			}
+		}
+	}
+	if isLocal(r) {
+		return v.cfg.LocalRole
+	}
+	return v.cfg.AnonymousRole
+}
+
+// isLocal reports whether r came from this machine, as velocipi's
+// server/auth decides it: a loopback request that a proxy forwarded carries
+// the real client in its headers, so it isn't local.
+func isLocal(r *http.Request) bool {
+	for _, h := range []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"} {
+		if r.Header.Get(h) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// verify returns the user a token was issued to, or "" if it's invalid or
+// expired.
+func (v *Verifier) verify(tok string, now time.Time) string {
+	body, sig, ok := strings.Cut(tok, ".")
+	if !ok {
+		return ""
+	}
+	m := hmac.New(sha256.New, v.key)
+	m.Write([]byte(body))
+	if !hmac.Equal([]byte(sig), []byte(base64.RawURLEncoding.EncodeToString(m.Sum(nil)))) {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return ""
+	}
+	var t struct {
+		User    string `json:"u"`
+		Expires int64  `json:"exp"`
+	}
+	if json.Unmarshal(payload, &t) != nil || now.Unix() >= t.Expires {
+		return ""
+	}
+	return t.User
+}
+
+// canRead and canWrite are the roles allowed to read and change transcripts.
+var (
+	canRead  = map[string]bool{"viewer": true, "pilot": true, "admin": true}
+	canWrite = map[string]bool{"pilot": true, "admin": true}
+)
+
+// Allowed reports whether r's role may make this request: GET/HEAD need
+// read access, anything else write access.
+func (v *Verifier) Allowed(r *http.Request) bool {
+	role := v.Role(r)
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return canRead[role]
+	}
+	return canWrite[role]
+}
+
+// Require wraps an API handler, refusing requests whose role isn't allowed:
+// 401 if there's no role at all, 403 otherwise.
+func (v *Verifier) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !v.Allowed(r) {
+			if v.Role(r) == "" {
+				http.Error(w, "login required", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "forbidden", http.StatusForbidden)
+			}
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent99/liveatc/internal/config"
+)
+
+// velocipiToken builds a session token the way velocipi's server/auth does.
+func velocipiToken(key []byte, user string, exp time.Time) string {
+	body := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"u":%q,"exp":%d}`, user, exp.Unix())))
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func TestVerifier(t *testing.T) {
+	v, err := New(config.AuthConfig{
+		Users:     []config.UserConfig{{Name: "pilot", Role: "pilot"}, {Name: "pax", Role: "passenger"}},
+		LocalRole: "pilot",
+		KeyFile:   filepath.Join(t.TempDir(), "session.key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name, method, remote, tok string
+		want                      int
+	}{
+		{"pilot reads", http.MethodGet, "10.0.0.5:1", velocipiToken(v.key, "pilot", hour), http.StatusOK},
+		{"pilot corrects", http.MethodPut, "10.0.0.5:1", velocipiToken(v.key, "pilot", hour), http.StatusOK},
+		{"passenger", http.MethodGet, "10.0.0.5:1", velocipiToken(v.key, "pax", hour), http.StatusForbidden},
+		{"expired", http.MethodGet, "10.0.0.5:1", velocipiToken(v.key, "pilot", time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+		{"wrong key", http.MethodGet, "10.0.0.5:1", velocipiToken([]byte("some other key.."), "pilot", hour), http.StatusUnauthorized},
+		{"anonymous", http.MethodGet, "10.0.0.5:1", "", http.StatusUnauthorized},
+		{"local GPS feed", http.MethodPost, "127.0.0.1:1", "", http.StatusOK},
+		{"proxied from elsewhere", http.MethodPost, "127.0.0.1:1 proxied", "", http.StatusUnauthorized},
+	}
+	h := v.Require(func(http.ResponseWriter, *http.Request) {})
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/api/transcripts/recent", nil)
+		var proxied bool
+		r.RemoteAddr, proxied = strings.CutSuffix(c.remote, " proxied")
+		if proxied {
+			r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		}
+		if c.tok != "" {
+			r.AddCookie(&http.Cookie{Name: CookieName, Value: c.tok})
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
@@ -72,6 +72,9 @@ type LiveATCConfig struct {
 	// UIDir is the built Vue SPA directory served at "/"; empty disables UI
 	// serving. A relative path is resolved against the process working directory.
 	UIDir string `yaml:"uiDir" json:"uiDir"`
+	// DuckURL is the velocipi endpoint told when radio traffic starts/stops
+	// or PTT is keyed, so music can be ducked; empty disables.
+	DuckURL string `yaml:"duckURL" json:"duckURL"`
 
 	Audio   AudioConfig   `yaml:"audio"   json:"audio"`
 	VAD     VADConfig     `yaml:"vad"     json:"vad"`
@@ -83,6 +86,22 @@ type LiveATCConfig struct {
 	TxRMSThresh  int    `yaml:"txRmsThreshold" json:"txRmsThreshold"`
 }
 
+// AuthConfig is the part of velocipi's auth section needed to check its
+// session tokens: the users (for their current role), the fallback roles and
+// the shared signing key.
+type AuthConfig struct {
+	Users         []UserConfig `yaml:"users"         json:"users"`
+	AnonymousRole string       `yaml:"anonymousRole" json:"anonymousRole"`
+	LocalRole     string       `yaml:"localRole"     json:"localRole"`
+	KeyFile       string       `yaml:"keyFile"       json:"keyFile"` // relative to the config dir
+}
+
+// UserConfig is one velocipi login; the PIN hash isn't needed here.
+type UserConfig struct {
+	Name string `yaml:"name" json:"name"`
+	Role string `yaml:"role" json:"role"`
+}
+
 // StorageConfig holds filesystem roots.
 type StorageConfig struct {
 	LiveATC string `yaml:"liveatc" json:"liveatc"`
@@ -100,6 +119,7 @@ type Config struct {
 	TailType string        `yaml:"tailType" json:"tailType"`
 	LiveATC  LiveATCConfig `yaml:"liveatc"  json:"liveatc"`
 	Storage  StorageConfig `yaml:"storage"  json:"storage"`
+	Auth     AuthConfig    `yaml:"auth"     json:"auth"`
 }
 
 // Derived, non-serialized durations populated by Load().
@@ -149,6 +169,7 @@ func Load(dir string) (*Config, error) {
 		cfgDir = dir
 	}
 	cfg.resolveStoragePaths(cfgDir)
+	cfg.Auth.KeyFile = resolveUnder(cfgDir, cfg.Auth.KeyFile)
 
 	cfg.resolvePaths()
 	cfg.expandPrompt()
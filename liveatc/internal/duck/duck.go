@@ -0,0 +1,143 @@
+// Package duck tells the velocipi server when cockpit audio needs the
+// pilot's attention -- a transmission is being received, or we are keying
+// the radio -- so it can duck or pause the music.
+//
+// The signal is an HTTP POST of {"source":"atc"|"ptt","active":bool} to the
+// configured URL (velocipi's /music/duck). Active sources are re-sent every
+// refreshEvery; velocipi drops a duck that isn't refreshed, so a crash here
+// can't leave the music ducked.
+package duck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Source is what is making the cockpit busy.
+type Source string
+
+const (
+	ATC Source = "atc" // received speech (VAD-confirmed transmission)
+	PTT Source = "ptt" // our own push-to-talk is keyed
+)
+
+const (
+	refreshEvery = 5 * time.Second
+	postTimeout  = 2 * time.Second
+)
+
+// Notifier posts duck state changes. A nil *Notifier is valid and does
+// nothing, so callers needn't check whether ducking is configured.
+type Notifier struct {
+	url    string
+	client *http.Client
+	log    *slog.Logger
+
+	mu     sync.Mutex
+	active map[Source]bool
+	dirty  map[Source]bool // changed since the last post
+	kick   chan struct{}
+}
+
+// New returns a Notifier posting to url, or nil if url is empty.
+func New(url string, log *slog.Logger) *Notifier {
+	if url == "" {
+		return nil
+	}
+	return &Notifier{
+		url:    url,
+		client: &http.Client{Timeout: postTimeout},
+		log:    log,
+		active: make(map[Source]bool),
+		dirty:  make(map[Source]bool),
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// Set records src as active or idle. It never blocks; the change is posted
+// by Run.
+func (n *Notifier) Set(src Source, active bool) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if n.active[src] != active {
+		n.active[src] = active
+		n.dirty[src] = true
+	}
+	n.mu.Unlock()
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Run posts changes as they happen and refreshes active sources until ctx
+// is cancelled, then releases anything still active.
+func (n *Notifier) Run(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	t := time.NewTicker(refreshEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			for src, on := range n.active {
+				if on {
+					n.active[src] = false
+					n.dirty[src] = true
+				}
+			}
+			n.mu.Unlock()
+			n.flush(false)
+			return
+		case <-n.kick:
+			n.flush(false)
+		case <-t.C:
+			n.flush(true)
+		}
+	}
+}
+
+// flush posts every changed source, plus every active one if refresh is set.
+func (n *Notifier) flush(refresh bool) {
+	n.mu.Lock()
+	send := make(map[Source]bool)
+	for src, on := range n.active {
+		if n.dirty[src] || (refresh && on) {
+			send[src] = on
+		}
+	}
+	clear(n.dirty)
+	n.mu.Unlock()
+
+	for src, on := range send {
+		if err := n.post(src, on); err != nil {
+			n.log.Debug("duck notify failed", "source", src, "active", on, "err", err)
+		}
+	}
+}
+
+func (n *Notifier) post(src Source, active bool) error {
+	body, err := json.Marshal(map[string]any{"source": src, "active": active})
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("duck: %s", resp.Status)
+	}
+	return nil
+}
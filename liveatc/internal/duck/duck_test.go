@@ -0,0 +1,75 @@
+package duck
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type signal struct {
+	Source Source `json:"source"`
+	Active bool   `json:"active"`
+}
+
+func TestNotifierPostsChangesAndReleasesOnShutdown(t *testing.T) {
+	got := make(chan signal, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s signal
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got <- s
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := New(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { n.Run(ctx); close(done) }()
+
+	next := func() signal {
+		t.Helper()
+		select {
+		case s := <-got:
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("no signal posted")
+			return signal{}
+		}
+	}
+
+	n.Set(ATC, true)
+	if s := next(); s != (signal{ATC, true}) {
+		t.Errorf("got %+v, want atc active", s)
+	}
+	n.Set(ATC, true) // no change: nothing posted
+	n.Set(PTT, true)
+	if s := next(); s != (signal{PTT, true}) {
+		t.Errorf("got %+v, want ptt active", s)
+	}
+	n.Set(PTT, false)
+	if s := next(); s != (signal{PTT, false}) {
+		t.Errorf("got %+v, want ptt released", s)
+	}
+
+	cancel()
+	<-done
+	if s := next(); s != (signal{ATC, false}) {
+		t.Errorf("on shutdown got %+v, want atc released", s)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	n := New("", nil)
+	if n != nil {
+		t.Fatal("empty URL should disable the notifier")
+	}
+	n.Set(ATC, true) // must not panic
+	n.Run(context.Background())
+}
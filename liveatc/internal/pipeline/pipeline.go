@@ -14,6 +14,7 @@ import (
 
 	"github.com/vincent99/liveatc/internal/audio"
 	"github.com/vincent99/liveatc/internal/config"
+	"github.com/vincent99/liveatc/internal/duck"
 	"github.com/vincent99/liveatc/internal/gps"
 	"github.com/vincent99/liveatc/internal/ptt"
 	"github.com/vincent99/liveatc/internal/session"
@@ -34,6 +35,7 @@ type Deps struct {
 	Writer      *transcript.Writer
 	GPS         *gps.Store
 	PTT         ptt.Monitor
+	Duck        *duck.Notifier // may be nil (ducking disabled)
 	// LiveSource is true for unbounded live capture (ALSA / network stream),
 	// where a full STT queue must be dropped rather than block capture. For a
 	// bounded file source it is false, so onSegment applies backpressure and no
@@ -184,13 +186,16 @@ func (p *Pipeline) feed(frame []int16) {
 	p.seg.Feed(frame, score)
 }
 
-// onSpeechStart snapshots GPS at the start of a confirmed transmission.
+// onSpeechStart snapshots GPS at the start of a confirmed transmission and
+// asks velocipi to duck the music until it ends.
 func (p *Pipeline) onSpeechStart(time.Time) {
 	p.pendingGPSStart = p.GPS.Snapshot()
+	p.Duck.Set(duck.ATC, true)
 }
 
 // onSegment persists the WAV + metadata and enqueues STT.
 func (p *Pipeline) onSegment(seg vad.Segment) {
+	p.Duck.Set(duck.ATC, false)
 	id := uuid.NewString()
 	gpsStart := p.pendingGPSStart
 	gpsEnd := p.GPS.Snapshot()
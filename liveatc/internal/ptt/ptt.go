@@ -20,6 +20,9 @@ type Monitor interface {
 	// ActiveSince reports whether PTT was asserted at or after t (i.e. during a
 	// transmission that started at t).
 	ActiveSince(t time.Time) bool
+	// OnChange registers fn to be called (from the GPIO event goroutine) on
+	// every assert/deassert. Only one callback is kept.
+	OnChange(fn func(active bool))
 	// Close releases the GPIO line.
 	Close() error
 }
@@ -28,6 +31,7 @@ type disabled struct{}
 
 func (disabled) Enabled() bool              { return false }
 func (disabled) ActiveSince(time.Time) bool { return false }
+func (disabled) OnChange(func(bool))        {}
 func (disabled) Close() error               { return nil }
 
 // Disabled returns a no-op monitor (used when no PTT pin is configured or on
@@ -20,6 +20,7 @@ type lineMonitor struct {
 	mu       sync.Mutex
 	active   bool      // currently asserted
 	lastFall time.Time // wall-clock time of the most recent deassert
+	onChange func(active bool)
 }
 
 // New requests the PTT line with both-edge event reporting.
@@ -57,6 +58,16 @@ func (m *lineMonitor) handle(evt gpiocdev.LineEvent) {
 		m.active = false
 		m.lastFall = time.Now()
 	}
+	active, fn := m.active, m.onChange
+	m.mu.Unlock()
+	if fn != nil {
+		fn(active)
+	}
+}
+
+func (m *lineMonitor) OnChange(fn func(active bool)) {
+	m.mu.Lock()
+	m.onChange = fn
 	m.mu.Unlock()
 }
 
@@ -14,10 +14,21 @@ export default defineConfig({
   },
   server: {
     port: 8091,
+    // xfwd adds X-Forwarded-For, without which the backend would give every
+    // dev client the local role.
     proxy: {
-      '/api': { target: `http://${backend}`, changeOrigin: false },
-      '/ws': { target: `ws://${backend}`, ws: true, changeOrigin: false },
-      '/healthz': { target: `http://${backend}`, changeOrigin: false },
+      '/api': { target: `http://${backend}`, changeOrigin: false, xfwd: true },
+      '/ws': {
+        target: `ws://${backend}`,
+        ws: true,
+        changeOrigin: false,
+        xfwd: true,
+      },
+      '/healthz': {
+        target: `http://${backend}`,
+        changeOrigin: false,
+        xfwd: true,
+      },
     },
   },
 });
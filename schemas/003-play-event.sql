@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS play_event (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    songId    INTEGER  NOT NULL REFERENCES song(id) ON DELETE CASCADE,
+    started   DATETIME NOT NULL,
+    ended     DATETIME NOT NULL,
+    playedSec REAL     NOT NULL DEFAULT 0,
+    percent   REAL     NOT NULL DEFAULT 0,
+    skipped   INTEGER  NOT NULL DEFAULT 0,
+    flight    TEXT     NOT NULL DEFAULT '',
+    session   TEXT     NOT NULL DEFAULT '',
+    scrobble  INTEGER  NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS play_event_started ON play_event(started);
+CREATE INDEX IF NOT EXISTS play_event_song    ON play_event(songId);
+CREATE INDEX IF NOT EXISTS play_event_scrobble ON play_event(scrobble) WHERE scrobble = 1;
@@ -0,0 +1,8 @@
+-- EBU R128 loudness measured by the syncer (NULL = not measured yet).
+-- Gains are ReplayGain 2.0 style: dB to reach -18 LUFS. Peaks are linear
+-- true-peak amplitudes (1.0 = full scale).
+ALTER TABLE song ADD COLUMN loudness  REAL;
+ALTER TABLE song ADD COLUMN trackGain REAL;
+ALTER TABLE song ADD COLUMN trackPeak REAL;
+ALTER TABLE song ADD COLUMN albumGain REAL;
+ALTER TABLE song ADD COLUMN albumPeak REAL;
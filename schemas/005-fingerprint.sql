@@ -0,0 +1,3 @@
+-- Raw Chromaprint fingerprint from fpcalc -raw, stored as little-endian
+-- uint32s (NULL = not fingerprinted yet). Used to find duplicate recordings.
+ALTER TABLE song ADD COLUMN fingerprint BLOB;
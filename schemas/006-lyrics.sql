@@ -0,0 +1,14 @@
+-- Lyrics found for a song: embedded in its tags by sync, fetched from an
+-- LRCLIB-compatible API, or edited by hand. A row with source 'none'
+-- records a lookup that found nothing, so it isn't repeated; 'offset'
+-- holds only an offset set before any lyrics were found. offsetMs shifts
+-- the timestamps of whatever lyrics are shown, including a companion .lrc
+-- file.
+CREATE TABLE IF NOT EXISTS lyrics (
+    songId   INTEGER PRIMARY KEY REFERENCES song(id) ON DELETE CASCADE,
+    source   TEXT     NOT NULL,           -- embedded | lrclib | edited | none | offset
+    synced   INTEGER  NOT NULL DEFAULT 0, -- text is LRC with timestamps
+    text     TEXT     NOT NULL DEFAULT '',
+    offsetMs INTEGER  NOT NULL DEFAULT 0,
+    updated  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,176 @@
+// Package auth implements login sessions and the role-based permissions that
+// gate the HTTP API and inbound websocket messages. Sessions are signed
+// tokens carried in a cookie (or an Authorization: Bearer header); the role
+// is looked up from the configured users on every request, so removing a
+// user or changing their role takes effect immediately.
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Role is what a user may do.
+type Role string
+
+const (
+	RoleNone      Role = ""          // not logged in and no anonymous access
+	RoleViewer    Role = "viewer"    // read-only everything
+	RolePassenger Role = "passenger" // music only
+	RolePilot     Role = "pilot"     // everything except config and deletions
+	RoleAdmin     Role = "admin"     // everything
+)
+
+// Valid reports whether r is a known role other than RoleNone.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleViewer, RolePassenger, RolePilot, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Group is a set of routes and websocket messages sharing a permission.
+type Group string
+
+const (
+	GroupMusic      Group = "music"      // /music/*, musicControl
+	GroupDVR        Group = "dvr"        // /dvr/*
+	GroupRecordings Group = "recordings" // /recordings/*
+	GroupConfig     Group = "config"     // /config
+	GroupSiyi       Group = "siyi"       // /siyi/*
+	GroupAirCon     Group = "aircon"     // /aircon/*
+	GroupCamera     Group = "camera"     // /camera/*, /cameras, /thermal/*, /mpegts/*, /snapshot/*, /snaps/*, cameraControl, setLocalCamera
+	GroupPanel      Group = "panel"      // /screen, key, led, navigate and reload messages
+)
+
+// Access is a level of access to a group.
+type Access int
+
+const (
+	AccessNone  Access = iota
+	AccessRead         // GET and HEAD
+	AccessWrite        // any method
+)
+
+// permissions is what each role may do to each group. Groups not listed are
+// AccessNone. Individual handlers may still require RoleAdmin for
+// destructive actions (deleting recordings, merging songs).
+var permissions = map[Role]map[Group]Access{
+	RoleNone: {
+		GroupConfig: AccessRead, // UI colours for the login page; full config is admin-only
+	},
+	RoleViewer: {
+		GroupMusic:      AccessRead,
+		GroupDVR:        AccessRead,
+		GroupRecordings: AccessRead,
+		GroupConfig:     AccessRead,
+		GroupSiyi:       AccessRead,
+		GroupAirCon:     AccessRead,
+		GroupCamera:     AccessRead,
+		GroupPanel:      AccessRead,
+	},
+	RolePassenger: {
+		GroupMusic:  AccessWrite,
+		GroupConfig: AccessRead,
+	},
+	RolePilot: {
+		GroupMusic:      AccessWrite,
+		GroupDVR:        AccessWrite,
+		GroupRecordings: AccessWrite,
+		GroupConfig:     AccessRead,
+		GroupSiyi:       AccessWrite,
+		GroupAirCon:     AccessWrite,
+		GroupCamera:     AccessWrite,
+		GroupPanel:      AccessWrite,
+	},
+	RoleAdmin: {
+		GroupMusic:      AccessWrite,
+		GroupDVR:        AccessWrite,
+		GroupRecordings: AccessWrite,
+		GroupConfig:     AccessWrite,
+		GroupSiyi:       AccessWrite,
+		GroupAirCon:     AccessWrite,
+		GroupCamera:     AccessWrite,
+		GroupPanel:      AccessWrite,
+	},
+}
+
+// Can reports whether r has at least access a to group g.
+func (r Role) Can(g Group, a Access) bool {
+	return permissions[r][g] >= a
+}
+
+// Permissions returns the role's access to every group it can reach, for
+// the UI to decide what to show.
+func (r Role) Permissions() map[Group]Access {
+	out := make(map[Group]Access, len(permissions[r]))
+	for g, a := range permissions[r] {
+		out[g] = a
+	}
+	return out
+}
+
+// routeGroups maps URL path prefixes to groups. A prefix ending in "/"
+// matches everything under it; one without matches the exact path and
+// anything under it.
+var routeGroups = []struct {
+	prefix string
+	group  Group
+}{
+	{"/music/", GroupMusic},
+	{"/dvr/", GroupDVR},
+	{"/recordings", GroupRecordings},
+	{"/config", GroupConfig},
+	{"/siyi/", GroupSiyi},
+	{"/aircon/", GroupAirCon},
+	{"/cameras", GroupCamera},
+	{"/camera/", GroupCamera},
+	{"/thermal/", GroupCamera},
+	{"/mpegts/", GroupCamera},
+	{"/snapshot/", GroupCamera},
+	{"/snaps", GroupCamera},
+	{"/screen", GroupPanel},
+}
+
+// RouteGroup returns the group a URL path belongs to. Paths outside every
+// group (the UI, /auth/*, /ws) report false and aren't gated by role.
+func RouteGroup(path string) (Group, bool) {
+	for _, rg := range routeGroups {
+		if strings.HasSuffix(rg.prefix, "/") {
+			if strings.HasPrefix(path, rg.prefix) {
+				return rg.group, true
+			}
+		} else if path == rg.prefix || strings.HasPrefix(path, rg.prefix+"/") {
+			return rg.group, true
+		}
+	}
+	return "", false
+}
+
+// MethodAccess is the access an HTTP method needs.
+func MethodAccess(method string) Access {
+	if method == http.MethodGet || method == http.MethodHead {
+		return AccessRead
+	}
+	return AccessWrite
+}
+
+// messageGroups maps inbound websocket message types to the group they
+// need write access to.
+var messageGroups = map[string]Group{
+	"musicControl":   GroupMusic,
+	"cameraControl":  GroupCamera,
+	"setLocalCamera": GroupCamera,
+	"key":            GroupPanel,
+	"led":            GroupPanel,
+	"navigate":       GroupPanel,
+	"reload":         GroupPanel,
+}
+
+// CanSend reports whether r may send an inbound websocket message of the
+// given type. Unknown types are refused.
+func (r Role) CanSend(msgType string) bool {
+	g, ok := messageGroups[msgType]
+	return ok && r.Can(g, AccessWrite)
+}
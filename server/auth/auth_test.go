@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+	pilotHash, err := HashPassword("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paxHash, _ := HashPassword("0000")
+	a, err := New(config.AuthConfig{
+		Users: []config.UserConfig{
+			{Name: "pilot", Role: "pilot", Hash: pilotHash},
+			{Name: "pax", Role: "passenger", Hash: paxHash},
+		},
+		AnonymousRole: "viewer",
+		LocalRole:     "admin",
+		SessionHours:  1,
+		KeyFile:       filepath.Join(t.TempDir(), "session.key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestPasswordHash(t *testing.T) {
+	h, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(h, "s3cret") {
+		t.Error("right password rejected")
+	}
+	if CheckPassword(h, "s3creT") || CheckPassword("", "") || CheckPassword("plain", "plain") {
+		t.Error("wrong password or malformed hash accepted")
+	}
+}
+
+func TestLoadKeyReusesExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "k")
+	k1, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(k1) != string(k2) || len(k1) != keySize {
+		t.Errorf("key not reused: %x vs %x", k1, k2)
+	}
+}
+
+func TestLoginAndIdentify(t *testing.T) {
+	a := newTestAuth(t)
+	now := time.Now()
+
+	if _, _, err := a.Login("pilot", "9999", "10.0.0.5:1000", now); err != ErrBadLogin {
+		t.Fatalf("wrong PIN: %v", err)
+	}
+	tok, _, err := a.Login("pilot", "1234", "10.0.0.5:1000", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := func(remote, tok string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/music/songs", nil)
+		r.RemoteAddr, _ = strings.CutSuffix(remote, " proxied")
+		if r.RemoteAddr != remote {
+			r.Header.Set("X-Forwarded-For", "10.0.0.5")
+		}
+		if tok != "" {
+			r.AddCookie(&http.Cookie{Name: CookieName, Value: tok})
+		}
+		return r
+	}
+	cases := []struct {
+		name, remote, tok string
+		user              string
+		role              Role
+	}{
+		{"session", "10.0.0.5:1000", tok, "pilot", RolePilot},
+		{"anonymous", "10.0.0.5:1000", "", "", RoleViewer},
+		{"local", "127.0.0.1:1000", "", "", RoleAdmin},
+		{"proxied", "127.0.0.1:1000 proxied", "", "", RoleViewer},
+		{"tampered", "10.0.0.5:1000", tok + "x", "", RoleViewer},
+		{"expired", "10.0.0.5:1000", a.sign("pilot", now.Add(-time.Minute)), "", RoleViewer},
+		{"removed user", "10.0.0.5:1000", a.sign("gone", now.Add(time.Hour)), "", RoleViewer},
+	}
+	for _, c := range cases {
+		user, role := a.Identify(req(c.remote, c.tok))
+		if user != c.user || role != c.role {
+			t.Errorf("%s: got %q/%q, want %q/%q", c.name, user, role, c.user, c.role)
+		}
+	}
+
+	bearer := req("10.0.0.5:1000", "")
+	bearer.Header.Set("Authorization", "Bearer "+tok)
+	if _, role := a.Identify(bearer); role != RolePilot {
+		t.Errorf("bearer token: got %q", role)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	a := newTestAuth(t)
+	now := time.Now()
+	for i := 0; i < maxFailures; i++ {
+		a.Login("pilot", "bad", "10.0.0.9:1", now)
+	}
+	if _, _, err := a.Login("pilot", "1234", "10.0.0.9:1", now); err != ErrLockedOut {
+		t.Errorf("during lockout: %v", err)
+	}
+	if _, _, err := a.Login("pilot", "1234", "10.0.0.8:1", now); err != nil {
+		t.Errorf("other address: %v", err)
+	}
+	if _, _, err := a.Login("pilot", "1234", "10.0.0.9:1", now.Add(lockout)); err != nil {
+		t.Errorf("after lockout: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuth(t)
+	pax, _, _ := a.Login("pax", "0000", "10.0.0.5:1", time.Now())
+	var seen Role
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RoleFrom(r)
+	}))
+
+	cases := []struct {
+		method, path, tok string
+		want              int
+	}{
+		{http.MethodGet, "/music/songs", "", http.StatusOK},           // viewer reads
+		{http.MethodPost, "/music/control", "", http.StatusForbidden}, // viewer can't write
+		{http.MethodPost, "/music/control", pax, http.StatusOK},
+		{http.MethodGet, "/recordings", pax, http.StatusForbidden}, // passenger is music only
+		{http.MethodPut, "/dvr/state", pax, http.StatusForbidden},
+		{http.MethodPost, "/config", pax, http.StatusForbidden},
+		{http.MethodGet, "/config", pax, http.StatusOK},
+		{http.MethodGet, "/remote/home", pax, http.StatusOK}, // UI isn't gated
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		r.RemoteAddr = "10.0.0.5:1"
+		if c.tok != "" {
+			r.AddCookie(&http.Cookie{Name: CookieName, Value: c.tok})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s %s: got %d, want %d", c.method, c.path, w.Code, c.want)
+		}
+	}
+	if seen != RolePassenger {
+		t.Errorf("handler saw role %q", seen)
+	}
+
+	cfg := a.conf()
+	cfg.AnonymousRole = ""
+	a.Reconfigure(cfg)
+	r := httptest.NewRequest(http.MethodGet, "/music/songs", nil)
+	r.RemoteAddr = "10.0.0.5:1"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("no anonymous access: got %d, want 401", w.Code)
+	}
+}
+
+func TestCanSend(t *testing.T) {
+	if !RolePassenger.CanSend("musicControl") || RolePassenger.CanSend("key") || RolePassenger.CanSend("cameraControl") {
+		t.Error("passenger message permissions")
+	}
+	if RoleViewer.CanSend("musicControl") || !RolePilot.CanSend("key") || RoleAdmin.CanSend("bogus") {
+		t.Error("viewer/pilot/unknown message permissions")
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// MeResponse describes the caller: who they are and what they may do.
+type MeResponse struct {
+	Name        string           `json:"name"` // "" if not logged in
+	Role        Role             `json:"role"`
+	Permissions map[Group]Access `json:"permissions"` // 1 = read, 2 = write
+}
+
+// RegisterRoutes registers the /auth/* handlers on mux. They sit outside
+// every route group, so Middleware lets anyone reach them.
+func (a *Auth) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/auth/login", a.handleLogin)   // POST {name, password} — start a session
+	mux.HandleFunc("/auth/logout", a.handleLogout) // POST — end the session
+	mux.HandleFunc("/auth/me", a.handleMe)         // GET — the caller's name, role and permissions
+	mux.HandleFunc("/auth/users", a.handleUsers)   // GET — user names for the login page
+}
+
+func (a *Auth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tok, expires, err := a.Login(body.Name, body.Password, r.RemoteAddr, time.Now())
+	if errors.Is(err, ErrLockedOut) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    tok,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	u, _ := a.user(body.Name)
+	role := Role(u.Role)
+	writeJSON(w, MeResponse{Name: u.Name, Role: role, Permissions: role.Permissions()})
+}
+
+func (a *Auth) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Auth) handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, role := a.Identify(r)
+	writeJSON(w, MeResponse{Name: user, Role: role, Permissions: role.Permissions()})
+}
+
+func (a *Auth) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, a.Users())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+// CookieName is the session cookie. Cookies aren't port-specific, so the
+// intercom-stt API on the same host sees it too.
+const CookieName = "velocipi_session"
+
+const (
+	hashPrefix     = "pbkdf2-sha256"
+	hashIterations = 100_000
+	keySize        = 32
+
+	maxFailures = 5                // failed logins from one address before a lockout
+	lockout     = 30 * time.Second // how long the lockout lasts
+)
+
+// ErrLockedOut is returned by Login after too many failures.
+var ErrLockedOut = errors.New("too many failed logins, try again shortly")
+
+// ErrBadLogin is returned by Login for an unknown user or wrong PIN/password.
+var ErrBadLogin = errors.New("wrong name or PIN")
+
+// Auth issues and checks sessions.
+type Auth struct {
+	key []byte
+
+	cfgMu sync.RWMutex
+	cfg   config.AuthConfig
+
+	mu       sync.Mutex
+	failures map[string]*failure // remote IP → recent failed logins
+}
+
+type failure struct {
+	count int
+	until time.Time // locked out until
+}
+
+// New creates an Auth, loading the signing key from cfg.KeyFile (relative
+// to the config dir) or creating it if it doesn't exist yet.
+func New(cfg config.AuthConfig) (*Auth, error) {
+	key, err := LoadKey(config.Path(cfg.KeyFile))
+	if err != nil {
+		return nil, err
+	}
+	a := &Auth{key: key, failures: make(map[string]*failure)}
+	a.Reconfigure(cfg)
+	return a, nil
+}
+
+// Reconfigure applies changed users, roles and session length. Sessions
+// already issued stay valid; a changed keyFile needs a restart, so it
+// returns config.ErrRestartRequired.
+func (a *Auth) Reconfigure(cfg config.AuthConfig) error {
+	if cfg.SessionHours <= 0 {
+		cfg.SessionHours = 24
+	}
+	a.cfgMu.Lock()
+	defer a.cfgMu.Unlock()
+	keyChanged := a.cfg.KeyFile != "" && a.cfg.KeyFile != cfg.KeyFile
+	a.cfg = cfg
+	if keyChanged {
+		return config.ErrRestartRequired
+	}
+	return nil
+}
+
+// conf returns the current auth settings.
+func (a *Auth) conf() config.AuthConfig {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+	return a.cfg
+}
+
+// LoadKey reads a session signing key, creating a random one if path
+// doesn't exist. The key is stored hex-encoded.
+func LoadKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("auth: keyFile is not set in config")
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		return decodeKey(data)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("auth: read key: %w", err)
+	}
+	key := make([]byte, keySize)
+	rand.Read(key)
+	// O_EXCL: if another process (intercom-stt) created it first, use theirs.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: read key: %w", err)
+		}
+		return decodeKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: create key: %w", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "%x\n", key); err != nil {
+		return nil, fmt.Errorf("auth: write key: %w", err)
+	}
+	return key, nil
+}
+
+func decodeKey(data []byte) ([]byte, error) {
+	var key []byte
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%x", &key); err != nil || len(key) < 16 {
+		return nil, errors.New("auth: key file is not a hex key of at least 16 bytes")
+	}
+	return key, nil
+}
+
+// HashPassword hashes a PIN or password for UserConfig.Hash.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	dk, err := pbkdf2.Key(sha256.New, password, salt, hashIterations, keySize)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", hashPrefix, hashIterations, enc.EncodeToString(salt), enc.EncodeToString(dk)), nil
+}
+
+// CheckPassword reports whether password matches a HashPassword hash.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashPrefix {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[2])
+	want, err2 := enc.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// token is the signed payload of a session.
+type token struct {
+	User    string `json:"u"`
+	Expires int64  `json:"exp"` // unix seconds
+}
+
+// sign returns a session token for user valid until expires.
+func (a *Auth) sign(user string, expires time.Time) string {
+	payload, _ := json.Marshal(token{User: user, Expires: expires.Unix()})
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + a.mac(body)
+}
+
+func (a *Auth) mac(body string) string {
+	m := hmac.New(sha256.New, a.key)
+	m.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// verify returns the user a token was issued to, or "" if it's invalid or
+// expired.
+func (a *Auth) verify(tok string, now time.Time) string {
+	body, sig, ok := strings.Cut(tok, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.mac(body))) {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return ""
+	}
+	var t token
+	if json.Unmarshal(payload, &t) != nil || now.Unix() >= t.Expires {
+		return ""
+	}
+	return t.User
+}
+
+// user returns the configured user with the given name.
+func (a *Auth) user(name string) (config.UserConfig, bool) {
+	for _, u := range a.conf().Users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return config.UserConfig{}, false
+}
+
+// Identify returns who made a request and their role: the session's user,
+// else LocalRole for requests from this machine, else AnonymousRole.
+func (a *Auth) Identify(r *http.Request) (user string, role Role) {
+	tok := ""
+	if c, err := r.Cookie(CookieName); err == nil {
+		tok = c.Value
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		tok = strings.TrimPrefix(h, "Bearer ")
+	}
+	if name := a.verify(tok, time.Now()); name != "" {
+		if u, ok := a.user(name); ok && Role(u.Role).Valid() {
+			return u.Name, Role(u.Role)
+		}
+	}
+	cfg := a.conf()
+	if isLocal(r) {
+		return "", Role(cfg.LocalRole)
+	}
+	return "", Role(cfg.AnonymousRole)
+}
+
+// isLocal reports whether r came from this machine. A loopback request
+// that a proxy forwarded (the vite dev server, or a reverse proxy on the
+// box) carries the real client in its headers, so it isn't local.
+func isLocal(r *http.Request) bool {
+	for _, h := range []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"} {
+		if r.Header.Get(h) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Login checks a name and PIN/password from remote address addr and
+// returns a session token and its expiry.
+func (a *Auth) Login(name, password, addr string, now time.Time) (string, time.Time, error) {
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		ip = addr
+	}
+	a.mu.Lock()
+	f := a.failures[ip]
+	if f != nil && now.Before(f.until) {
+		a.mu.Unlock()
+		return "", time.Time{}, ErrLockedOut
+	}
+	a.mu.Unlock()
+
+	u, ok := a.user(name)
+	if !ok || !Role(u.Role).Valid() || !CheckPassword(u.Hash, password) {
+		a.mu.Lock()
+		if f == nil {
+			f = &failure{}
+			a.failures[ip] = f
+		}
+		f.count++
+		if f.count >= maxFailures {
+			f.count = 0
+			f.until = now.Add(lockout)
+		}
+		a.mu.Unlock()
+		return "", time.Time{}, ErrBadLogin
+	}
+	a.mu.Lock()
+	delete(a.failures, ip)
+	a.mu.Unlock()
+	expires := now.Add(time.Duration(a.conf().SessionHours) * time.Hour)
+	return a.sign(u.Name, expires), expires, nil
+}
+
+// Users returns the names of every configured user, for the login page.
+func (a *Auth) Users() []string {
+	users := a.conf().Users
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+type ctxKey struct{}
+
+// identity is stored in the request context by Middleware.
+type identity struct {
+	user string
+	role Role
+}
+
+// Middleware identifies each request, stores the result for RoleFrom and
+// UserFrom, and refuses requests to a route group the role can't access:
+// 401 if not logged in, 403 otherwise.
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, role := a.Identify(r)
+		if g, ok := RouteGroup(r.URL.Path); ok && r.Method != http.MethodOptions && !role.Can(g, MethodAccess(r.Method)) {
+			if role == RoleNone {
+				http.Error(w, "login required", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "forbidden", http.StatusForbidden)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, identity{user, role})))
+	})
+}
+
+// RoleFrom returns the role Middleware identified for r.
+func RoleFrom(r *http.Request) Role {
+	id, _ := r.Context().Value(ctxKey{}).(identity)
+	return id.role
+}
+
+// UserFrom returns the logged-in user Middleware identified for r, or "".
+func UserFrom(r *http.Request) string {
+	id, _ := r.Context().Value(ctxKey{}).(identity)
+	return id.user
+}
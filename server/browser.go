@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"net/url"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -157,3 +158,34 @@ func (h *Hub) navigate(path string) {
 		log.Println("navigate error:", err)
 	}
 }
+
+// trackPanelRoute follows the page the panel browser shows, relative to
+// the app URL (e.g. "music/songs"), for route-specific gesture bindings.
+// The app's router changes pages within the document, so both kinds of
+// navigation are watched.
+func (h *Hub) trackPanelRoute(browserCtx context.Context) {
+	base, err := url.Parse(h.cfg.AppURL)
+	if err != nil {
+		return
+	}
+	set := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return
+		}
+		route := strings.Trim(strings.TrimPrefix(u.Path, base.Path), "/")
+		h.mu.Lock()
+		h.panelRoute = route
+		h.mu.Unlock()
+	}
+	chromedp.ListenTarget(browserCtx, func(ev any) {
+		switch e := ev.(type) {
+		case *page.EventFrameNavigated:
+			if e.Frame.ParentID == "" {
+				set(e.Frame.URL)
+			}
+		case *page.EventNavigatedWithinDocument:
+			set(e.URL)
+		}
+	})
+}
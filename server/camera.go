@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincent99/velocipi/server/camera"
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/hardware/axis"
+	"github.com/vincent99/velocipi/server/hardware/onvif"
+)
+
+// discoveredProfile is one media profile of a discovered camera, with the
+// RTSP URI it would be recorded from.
+type discoveredProfile struct {
+	onvif.Profile
+	StreamURI string `json:"streamUri,omitempty"`
+}
+
+// discoveredCamera is one entry in the /camera/discover response.
+type discoveredCamera struct {
+	onvif.Device
+	Profiles   []discoveredProfile `json:"profiles"`
+	Configured bool                `json:"configured"`      // a dvr camera already uses this host
+	Error      string              `json:"error,omitempty"` // profile lookup failure (e.g. bad credentials)
+}
+
+// lookupCameraController returns the control interface for the named camera,
+// or nil if it has no controllable driver.
+func (h *Hub) lookupCameraController(name string) camera.Controller {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cameraControllers[name]
+}
+
+// registerCameraRoutes registers driver-neutral camera control routes plus
+// ONVIF discovery and the add-camera API, which writes to config.yaml.
+func registerCameraRoutes(mux *http.ServeMux, cfg *config.Config, bus *config.Bus) {
+	// /camera/discover — POST {"username","password","timeoutMs"} runs ONVIF
+	// WS-Discovery on the LAN and lists each camera's profiles and RTSP URIs.
+	// Credentials are only used to query profiles (admin only).
+	mux.HandleFunc("/camera/discover", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Username  string `json:"username"`
+			Password  string `json:"password"`
+			TimeoutMs int    `json:"timeoutMs"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		timeout := 3 * time.Second
+		if body.TimeoutMs > 0 {
+			timeout = time.Duration(body.TimeoutMs) * time.Millisecond
+		}
+
+		devices, err := onvif.Discover(r.Context(), timeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		configured := make(map[string]bool)
+		for _, c := range cfg.DVR.Cameras {
+			configured[c.Host] = true
+		}
+
+		out := make([]discoveredCamera, 0, len(devices))
+		for _, d := range devices {
+			dc := discoveredCamera{Device: d, Profiles: []discoveredProfile{}, Configured: configured[d.Host]}
+			if len(d.XAddrs) > 0 {
+				profiles, err := onvifProfiles(r.Context(), onvif.New(d.XAddrs[0], body.Username, body.Password))
+				if err != nil {
+					dc.Error = err.Error()
+				}
+				dc.Profiles = append(dc.Profiles, profiles...)
+			}
+			out = append(out, dc)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+
+	// /camera/add — POST {"name","xaddr","username","password","profile","audio"}
+	// adds an ONVIF camera to dvr.cameras in config.yaml, recording from the
+	// chosen profile's RTSP URI (first profile if empty). Recording starts
+	// straight away unless there were no cameras at startup (admin only).
+	mux.HandleFunc("/camera/add", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Name     string `json:"name"`
+			XAddr    string `json:"xaddr"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Profile  string `json:"profile"`
+			Audio    bool   `json:"audio"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" || body.XAddr == "" {
+			http.Error(w, "name and xaddr required", http.StatusBadRequest)
+			return
+		}
+		for _, c := range cfg.DVR.Cameras {
+			if strings.EqualFold(c.Name, body.Name) {
+				http.Error(w, "a camera named "+strconv.Quote(body.Name)+" already exists", http.StatusConflict)
+				return
+			}
+		}
+
+		cam, err := onvifCameraConfig(r.Context(), body.Name, body.XAddr, body.Username, body.Password, body.Profile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		cam.Audio = body.Audio
+
+		updated := *cfg
+		updated.DVR.Cameras = append(append([]config.CameraConfig(nil), cfg.DVR.Cameras...), cam)
+		res, err := bus.Update(updated)
+		if err != nil {
+			http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			Camera          config.CameraConfig `json:"camera"`
+			RestartRequired bool                `json:"restartRequired"`
+		}{cam, len(res.Restart) > 0})
+	})
+
+	// /camera/{name}/{action} — driver-neutral control for any camera with a
+	// controller (siyi or onvif).
+	//   GET  capabilities        → {"capabilities": {...}, "attitude": {...}|null, "lookAt": {...}|null}
+	//   POST move   {"pan": -1..1, "tilt": -1..1}
+	//   POST point  {"yaw": deg, "pitch": deg}
+	//   POST zoom   {"rate": -1..1}       zoomTo {"level": 1..maxZoomLevel}
+	//   POST focus  {"direction": -1|0|1} autoFocus
+	//   POST mode   {"mode": "lock"|"follow"|"fpv"}
+	//   POST preset {"preset": "gear"}    recall a stored preset
+	//   POST lookAt {"lat", "lon", "altFt"} keep a ground point in frame until
+	//        the next move/point/preset/stop/home
+	//   POST photo, record, stop, home  (no body)
+	// Actions outside the camera's capabilities return 501.
+	//
+	// /camera/{name}/presets — GET lists presets; POST {"name", "yaw"?,
+	// "pitch"?, "zoom"?} stores one (current attitude if yaw/pitch omitted);
+	// DELETE /camera/{name}/presets/{preset} removes one (admin only).
+	mux.HandleFunc("/camera/", func(w http.ResponseWriter, r *http.Request) {
+		rest := r.URL.Path[len("/camera/"):]
+		slashIdx := strings.IndexByte(rest, '/')
+		if slashIdx < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		cameraName, _ := url.PathUnescape(rest[:slashIdx])
+		action := rest[slashIdx+1:]
+
+		ctl := hub.lookupCameraController(cameraName)
+		if ctl == nil {
+			http.Error(w, "camera not found or not controllable", http.StatusNotFound)
+			return
+		}
+
+		if action == "presets" || strings.HasPrefix(action, "presets/") {
+			handleCameraPresets(w, r, cfg, bus, cameraName, ctl, strings.TrimPrefix(action[len("presets"):], "/"))
+			return
+		}
+
+		if action == "capabilities" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			resp := struct {
+				Capabilities camera.Capabilities `json:"capabilities"`
+				Attitude     *camera.Attitude    `json:"attitude"`
+				LookAt       *camera.GeoTarget   `json:"lookAt"`
+			}{Capabilities: ctl.Capabilities(), LookAt: hub.cameraLookAtTarget(cameraName)}
+			if att, ok := ctl.Attitude(); ok {
+				resp.Attitude = &att
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var cmd camera.Command
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil && err != io.EOF {
+				http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		cmd.Action = action
+
+		err := hub.controlCamera(cameraName, cmd)
+		switch {
+		case errors.Is(err, camera.ErrUnknownAction):
+			http.NotFound(w, r)
+		case errors.Is(err, errUnknownPreset):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, camera.ErrUnsupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// handleCameraPresets serves /camera/{name}/presets[/{preset}].
+func handleCameraPresets(w http.ResponseWriter, r *http.Request, cfg *config.Config, bus *config.Bus, cameraName string, ctl camera.Controller, presetName string) {
+	idx := slices.IndexFunc(cfg.DVR.Cameras, func(c config.CameraConfig) bool { return c.Name == cameraName })
+	if idx < 0 {
+		http.Error(w, "camera not found", http.StatusNotFound)
+		return
+	}
+	presets := cfg.DVR.Cameras[idx].Presets
+
+	switch r.Method {
+	case http.MethodGet:
+		if presets == nil {
+			presets = []config.CameraPreset{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(presets)
+		return
+	case http.MethodPost, http.MethodDelete:
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		name, _ := url.PathUnescape(presetName)
+		updated := slices.DeleteFunc(slices.Clone(presets), func(p config.CameraPreset) bool { return p.Name == name })
+		if len(updated) == len(presets) {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		if err := saveCameraPresets(cfg, bus, idx, updated); err != nil {
+			http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var body struct {
+		Name  string   `json:"name"`
+		Yaw   *float64 `json:"yaw"`
+		Pitch *float64 `json:"pitch"`
+		Zoom  float64  `json:"zoom"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return
+	}
+	preset := config.CameraPreset{Name: body.Name, Zoom: body.Zoom}
+	if body.Yaw != nil && body.Pitch != nil {
+		preset.Yaw, preset.Pitch = *body.Yaw, *body.Pitch
+	} else {
+		att, ok := ctl.Attitude()
+		if !ok {
+			http.Error(w, "no attitude from camera; give yaw and pitch explicitly", http.StatusConflict)
+			return
+		}
+		preset.Yaw, preset.Pitch = math.Round(att.Yaw*10)/10, math.Round(att.Pitch*10)/10
+	}
+
+	updated := slices.Clone(presets)
+	if i := slices.IndexFunc(updated, func(p config.CameraPreset) bool { return p.Name == preset.Name }); i >= 0 {
+		updated[i] = preset
+	} else {
+		updated = append(updated, preset)
+	}
+	if err := saveCameraPresets(cfg, bus, idx, updated); err != nil {
+		http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preset)
+}
+
+// saveCameraPresets replaces camera idx's presets and writes config.yaml.
+func saveCameraPresets(cfg *config.Config, bus *config.Bus, idx int, presets []config.CameraPreset) error {
+	updated := *cfg
+	updated.DVR.Cameras = slices.Clone(cfg.DVR.Cameras)
+	updated.DVR.Cameras[idx].Presets = presets
+	_, err := bus.Update(updated)
+	return err
+}
+
+// errUnknownPreset is returned by controlCamera for a preset that isn't stored.
+var errUnknownPreset = errors.New("unknown preset")
+
+// controlCamera runs cmd on the named camera, resolving the "preset" and
+// "lookAt" actions that need server state. Any other pointing command
+// cancels look-at tracking.
+func (h *Hub) controlCamera(name string, cmd camera.Command) error {
+	ctl := h.lookupCameraController(name)
+	if ctl == nil {
+		return fmt.Errorf("camera %q not found or not controllable", name)
+	}
+	switch cmd.Action {
+	case "preset":
+		var preset *config.CameraPreset
+		for _, c := range h.cfg.DVR.Cameras {
+			if c.Name == name {
+				if i := slices.IndexFunc(c.Presets, func(p config.CameraPreset) bool { return p.Name == cmd.Preset }); i >= 0 {
+					preset = &c.Presets[i]
+				}
+			}
+		}
+		if preset == nil {
+			return fmt.Errorf("%w %q", errUnknownPreset, cmd.Preset)
+		}
+		h.setCameraLookAt(name, nil)
+		if err := camera.Execute(ctl, camera.Command{Action: "point", Yaw: preset.Yaw, Pitch: preset.Pitch}); err != nil {
+			return err
+		}
+		if preset.Zoom > 0 && ctl.Capabilities().ZoomTo {
+			return ctl.ZoomTo(preset.Zoom)
+		}
+		return nil
+	case "lookAt":
+		if !ctl.Capabilities().Point {
+			return fmt.Errorf("%w: lookAt", camera.ErrUnsupported)
+		}
+		target := cmd.GeoTarget
+		yaw, pitch := camera.LookAngles(hardware.Axis().State(), target)
+		if err := ctl.Point(yaw, pitch); err != nil {
+			return err
+		}
+		h.setCameraLookAt(name, &target)
+		return nil
+	case "move", "point", "stop", "home":
+		h.setCameraLookAt(name, nil)
+	}
+	return camera.Execute(ctl, cmd)
+}
+
+// setCameraLookAt starts (target non-nil) or stops look-at tracking.
+func (h *Hub) setCameraLookAt(name string, target *camera.GeoTarget) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if target == nil {
+		delete(h.cameraLookAt, name)
+		return
+	}
+	if h.cameraLookAt == nil {
+		h.cameraLookAt = make(map[string]camera.GeoTarget)
+	}
+	h.cameraLookAt[name] = *target
+}
+
+// cameraLookAtTarget returns the camera's look-at target, or nil if it isn't tracking.
+func (h *Hub) cameraLookAtTarget(name string) *camera.GeoTarget {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if t, ok := h.cameraLookAt[name]; ok {
+		return &t
+	}
+	return nil
+}
+
+// updateLookAt re-aims every tracking camera from the latest aircraft state.
+// Called at the attitude rate from runAxisLoop.
+func (h *Hub) updateLookAt(s axis.State) {
+	h.mu.RLock()
+	type aim struct {
+		name   string
+		ctl    camera.Controller
+		target camera.GeoTarget
+	}
+	aims := make([]aim, 0, len(h.cameraLookAt))
+	for name, t := range h.cameraLookAt {
+		aims = append(aims, aim{name, h.cameraControllers[name], t})
+	}
+	h.mu.RUnlock()
+	for _, a := range aims {
+		if a.ctl == nil {
+			continue
+		}
+		yaw, pitch := camera.LookAngles(s, a.target)
+		if err := a.ctl.Point(yaw, pitch); err != nil {
+			log.Printf("camera %s: lookAt: %v", a.name, err)
+			h.setCameraLookAt(a.name, nil)
+		}
+	}
+}
+
+// sendCameraCapabilities sends the capabilities of every controllable camera
+// to a single client.
+func (h *Hub) sendCameraCapabilities(c *client) {
+	h.mu.RLock()
+	msg := CameraCapabilitiesMsg{Type: "cameraCapabilities", Cameras: make(map[string]camera.Capabilities, len(h.cameraControllers))}
+	for name, ctl := range h.cameraControllers {
+		msg.Cameras[name] = ctl.Capabilities()
+	}
+	h.mu.RUnlock()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// handleCameraControl runs a websocket cameraControl command. Errors are
+// logged; there is no reply channel.
+func (h *Hub) handleCameraControl(m inboundCameraControlMsg) {
+	if err := h.controlCamera(m.Camera, m.Command); err != nil {
+		log.Printf("camera %s: %s: %v", m.Camera, m.Action, err)
+	}
+}
+
+// onvifProfiles lists a device's profiles with their stream URIs. Profiles
+// whose URI lookup fails are still returned, without a URI.
+func onvifProfiles(ctx context.Context, c *onvif.Client) ([]discoveredProfile, error) {
+	profiles, err := c.Profiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]discoveredProfile, 0, len(profiles))
+	for _, p := range profiles {
+		dp := discoveredProfile{Profile: p}
+		if uri, err := c.StreamURI(ctx, p.Token); err == nil {
+			dp.StreamURI = uri
+		}
+		out = append(out, dp)
+	}
+	return out, nil
+}
+
+// onvifCameraConfig builds a driver "onvif" CameraConfig by resolving the
+// profile's RTSP URI into host, port and path.
+func onvifCameraConfig(ctx context.Context, name, xaddr, username, password, profile string) (config.CameraConfig, error) {
+	c := onvif.New(xaddr, username, password)
+	if profile == "" {
+		profiles, err := c.Profiles(ctx)
+		if err != nil {
+			return config.CameraConfig{}, err
+		}
+		if len(profiles) == 0 {
+			return config.CameraConfig{}, fmt.Errorf("onvif: %s has no media profiles", xaddr)
+		}
+		profile = profiles[0].Token
+	}
+	uri, err := c.StreamURI(ctx, profile)
+	if err != nil {
+		return config.CameraConfig{}, err
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return config.CameraConfig{}, fmt.Errorf("onvif: bad stream URI %q: %w", uri, err)
+	}
+	port := 0
+	if p := u.Port(); p != "" {
+		port, _ = strconv.Atoi(p)
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return config.CameraConfig{
+		Name:         name,
+		Driver:       "onvif",
+		Host:         u.Hostname(),
+		Port:         port,
+		Path:         path,
+		Username:     username,
+		Password:     password,
+		ONVIFURL:     xaddr,
+		ONVIFProfile: profile,
+	}, nil
+}
@@ -0,0 +1,110 @@
+// Package camera defines a driver-neutral control interface for pan/tilt/zoom
+// cameras, so HTTP routes and the UI don't depend on any one vendor protocol.
+// Each driver (siyi, onvif) provides its own Controller implementation, and
+// Sim provides a simulated gimbal for tests.
+package camera
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// commandTimeout bounds a single network command to a camera.
+const commandTimeout = 5 * time.Second
+
+// ErrUnsupported is returned by a Controller for an action its capabilities
+// don't include.
+var ErrUnsupported = errors.New("camera: action not supported")
+
+// FollowMode is a gimbal stabilisation mode.
+type FollowMode string
+
+const (
+	ModeLock   FollowMode = "lock"   // hold a fixed heading regardless of aircraft yaw
+	ModeFollow FollowMode = "follow" // follow aircraft yaw, stabilise pitch/roll
+	ModeFPV    FollowMode = "fpv"    // follow aircraft yaw, pitch and roll
+)
+
+// Capabilities describes which actions a camera supports. The UI uses it to
+// decide which controls to show.
+type Capabilities struct {
+	Move         bool         `json:"move"`            // rate pan/tilt
+	Point        bool         `json:"point"`           // absolute yaw/pitch in degrees
+	Zoom         bool         `json:"zoom"`            // rate zoom
+	ZoomTo       bool         `json:"zoomTo"`          // absolute zoom factor
+	Focus        bool         `json:"focus"`           // manual focus and auto-focus
+	Photo        bool         `json:"photo"`           // take a still on the camera's own storage
+	Record       bool         `json:"record"`          // toggle recording on the camera's own storage
+	Home         bool         `json:"home"`            // return to centre/home
+	Attitude     bool         `json:"attitude"`        // reports gimbal attitude
+	Modes        []FollowMode `json:"modes,omitempty"` // supported stabilisation modes
+	MinPitch     float64      `json:"minPitch,omitempty"`
+	MaxPitch     float64      `json:"maxPitch,omitempty"`
+	MaxYaw       float64      `json:"maxYaw,omitempty"` // yaw range is ±MaxYaw
+	MaxZoomLevel float64      `json:"maxZoomLevel,omitempty"`
+}
+
+// Attitude is a gimbal's orientation in degrees (relative to the airframe)
+// and its rates in degrees/second.
+type Attitude struct {
+	Yaw       float64 `json:"yaw"`
+	Pitch     float64 `json:"pitch"`
+	Roll      float64 `json:"roll"`
+	YawRate   float64 `json:"yawRate"`
+	PitchRate float64 `json:"pitchRate"`
+	RollRate  float64 `json:"rollRate"`
+}
+
+// Controller moves and zooms a single camera. Methods for actions missing
+// from Capabilities return ErrUnsupported.
+type Controller interface {
+	Capabilities() Capabilities
+	// Move starts panning/tilting at normalized rates in -1..+1 (positive =
+	// right/up). Move(0, 0) stops pan/tilt.
+	Move(pan, tilt float64) error
+	// Point turns to an absolute yaw/pitch in degrees.
+	Point(yaw, pitch float64) error
+	// Zoom starts zooming at a normalized rate in -1..+1 (positive = in).
+	// Zoom(0) stops zooming.
+	Zoom(rate float64) error
+	// ZoomTo sets an absolute zoom factor (1 = widest).
+	ZoomTo(level float64) error
+	// Focus drives manual focus: positive = far, negative = near, 0 = stop.
+	Focus(direction int) error
+	// AutoFocus triggers a single auto-focus.
+	AutoFocus() error
+	// Photo takes a still.
+	Photo() error
+	// ToggleRecord starts or stops recording.
+	ToggleRecord() error
+	// SetMode sets the stabilisation mode.
+	SetMode(mode FollowMode) error
+	// Attitude returns the last known attitude; ok is false if none has
+	// been received yet.
+	Attitude() (att Attitude, ok bool)
+	// Stop halts all pan/tilt and zoom movement.
+	Stop() error
+	// Home returns the camera to its centre/home position.
+	Home() error
+}
+
+// clamp limits v to -1..+1.
+func clamp(v float64) float64 {
+	switch {
+	case v > 1:
+		return 1
+	case v < -1:
+		return -1
+	}
+	return v
+}
+
+// resolveEnv expands a credential that may be an env-var reference ("$NAME"),
+// matching how the DVR resolves camera credentials.
+func resolveEnv(v string) string {
+	if len(v) > 1 && v[0] == '$' {
+		return os.Getenv(v[1:])
+	}
+	return v
+}
@@ -0,0 +1,88 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrUnknownAction is returned by Execute for an unrecognised action name.
+var ErrUnknownAction = errors.New("camera: unknown action")
+
+// Command is one control request, as received from the /camera/ HTTP routes
+// or a "cameraControl" websocket message. Only the fields relevant to Action
+// are used. The "preset" and "lookAt" actions need server state (stored
+// presets, aircraft position) and are resolved by the caller before Execute.
+type Command struct {
+	Action    string     `json:"action"` // move|point|zoom|zoomTo|focus|autoFocus|photo|record|mode|stop|home|preset|lookAt
+	Pan       float64    `json:"pan,omitempty"`
+	Tilt      float64    `json:"tilt,omitempty"`
+	Yaw       float64    `json:"yaw,omitempty"`
+	Pitch     float64    `json:"pitch,omitempty"`
+	Rate      float64    `json:"rate,omitempty"`
+	Level     float64    `json:"level,omitempty"`
+	Direction int        `json:"direction,omitempty"`
+	Mode      FollowMode `json:"mode,omitempty"`
+	Preset    string     `json:"preset,omitempty"` // preset: name
+	GeoTarget            // lookAt: lat, lon, altFt
+}
+
+// Execute dispatches cmd to c, checking its capabilities first so drivers
+// never see an action they didn't advertise.
+func Execute(c Controller, cmd Command) error {
+	caps := c.Capabilities()
+	need := func(ok bool) error {
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupported, cmd.Action)
+		}
+		return nil
+	}
+	var err error
+	switch cmd.Action {
+	case "move":
+		if err = need(caps.Move); err == nil {
+			err = c.Move(cmd.Pan, cmd.Tilt)
+		}
+	case "point":
+		if err = need(caps.Point); err == nil {
+			err = c.Point(cmd.Yaw, cmd.Pitch)
+		}
+	case "zoom":
+		if err = need(caps.Zoom); err == nil {
+			err = c.Zoom(cmd.Rate)
+		}
+	case "zoomTo":
+		if err = need(caps.ZoomTo); err == nil {
+			err = c.ZoomTo(cmd.Level)
+		}
+	case "focus":
+		if err = need(caps.Focus); err == nil {
+			err = c.Focus(cmd.Direction)
+		}
+	case "autoFocus":
+		if err = need(caps.Focus); err == nil {
+			err = c.AutoFocus()
+		}
+	case "photo":
+		if err = need(caps.Photo); err == nil {
+			err = c.Photo()
+		}
+	case "record":
+		if err = need(caps.Record); err == nil {
+			err = c.ToggleRecord()
+		}
+	case "mode":
+		if err = need(slices.Contains(caps.Modes, cmd.Mode)); err == nil {
+			err = c.SetMode(cmd.Mode)
+		}
+	case "stop":
+		err = c.Stop()
+	case "home":
+		if err = need(caps.Home); err == nil {
+			err = c.Home()
+		}
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownAction, cmd.Action)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/vincent99/velocipi/server/hardware/axis"
+)
+
+const (
+	earthRadiusM = 6371000.0
+	feetToMeters = 0.3048
+)
+
+// GeoTarget is a fixed point on or above the ground for look-at tracking.
+type GeoTarget struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	AltFt float64 `json:"altFt"` // feet MSL
+}
+
+// LookAngles returns the gimbal yaw and pitch in degrees, relative to the
+// airframe (positive = right/up), that point at t from an aircraft in state
+// s. The offset to the target uses a local flat-earth approximation, which
+// is accurate to well under a degree within the tens of miles a camera can
+// usefully see.
+func LookAngles(s axis.State, t GeoTarget) (yaw, pitch float64) {
+	rad := math.Pi / 180
+	lat0 := s.Lat * rad
+
+	// Target offset in north/east/down metres.
+	n := (t.Lat - s.Lat) * rad * earthRadiusM
+	e := (t.Lon - s.Lon) * rad * earthRadiusM * math.Cos(lat0)
+	d := (s.AltFt - t.AltFt) * feetToMeters
+
+	// Rotate into the body frame: undo heading, then pitch, then roll.
+	psi, theta, phi := s.Heading*rad, s.Pitch*rad, s.Roll*rad
+	x1 := n*math.Cos(psi) + e*math.Sin(psi)
+	y1 := -n*math.Sin(psi) + e*math.Cos(psi)
+	z1 := d
+	x2 := x1*math.Cos(theta) - z1*math.Sin(theta)
+	z2 := x1*math.Sin(theta) + z1*math.Cos(theta)
+	y3 := y1*math.Cos(phi) + z2*math.Sin(phi)
+	z3 := -y1*math.Sin(phi) + z2*math.Cos(phi)
+
+	yaw = math.Atan2(y3, x2) / rad
+	pitch = math.Atan2(-z3, math.Hypot(x2, y3)) / rad
+	return yaw, pitch
+}
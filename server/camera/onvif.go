@@ -0,0 +1,100 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/hardware/onvif"
+)
+
+// ONVIF controls a generic camera through ONVIF PTZ.
+type ONVIF struct {
+	client *onvif.Client
+
+	mu      sync.Mutex
+	profile string
+}
+
+// NewONVIF creates a controller for a camera configured with driver "onvif".
+// The profile token comes from cam.ONVIFProfile; if empty, the first profile
+// with a PTZ configuration is looked up on first use.
+func NewONVIF(cam config.CameraConfig) *ONVIF {
+	return &ONVIF{
+		client:  onvif.New(cam.ONVIFURL, resolveEnv(cam.Username), resolveEnv(cam.Password)),
+		profile: cam.ONVIFProfile,
+	}
+}
+
+// profileToken returns the configured profile, discovering one if needed.
+func (o *ONVIF) profileToken(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.profile != "" {
+		return o.profile, nil
+	}
+	profiles, err := o.client.Profiles(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range profiles {
+		if p.PTZ {
+			o.profile = p.Token
+			return p.Token, nil
+		}
+	}
+	return "", fmt.Errorf("onvif: %s has no PTZ profile", o.client.XAddr())
+}
+
+// do runs fn with a bounded context and the resolved profile token.
+func (o *ONVIF) do(fn func(ctx context.Context, profile string) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	profile, err := o.profileToken(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, profile)
+}
+
+// Capabilities reports rate pan/tilt/zoom and home. ONVIF absolute moves use
+// a per-camera normalized space rather than degrees, so Point isn't offered.
+func (o *ONVIF) Capabilities() Capabilities {
+	return Capabilities{Move: true, Zoom: true, Home: true}
+}
+
+func (o *ONVIF) Move(pan, tilt float64) error {
+	return o.do(func(ctx context.Context, p string) error {
+		if pan == 0 && tilt == 0 {
+			return o.client.Stop(ctx, p)
+		}
+		return o.client.ContinuousMove(ctx, p, clamp(pan), clamp(tilt), 0)
+	})
+}
+
+func (o *ONVIF) Zoom(rate float64) error {
+	return o.do(func(ctx context.Context, p string) error {
+		if rate == 0 {
+			return o.client.Stop(ctx, p)
+		}
+		return o.client.ContinuousMove(ctx, p, 0, 0, clamp(rate))
+	})
+}
+
+func (o *ONVIF) Stop() error {
+	return o.do(o.client.Stop)
+}
+
+func (o *ONVIF) Home() error {
+	return o.do(o.client.GotoHome)
+}
+
+func (o *ONVIF) Point(yaw, pitch float64) error { return ErrUnsupported }
+func (o *ONVIF) ZoomTo(level float64) error     { return ErrUnsupported }
+func (o *ONVIF) Focus(direction int) error      { return ErrUnsupported }
+func (o *ONVIF) AutoFocus() error               { return ErrUnsupported }
+func (o *ONVIF) Photo() error                   { return ErrUnsupported }
+func (o *ONVIF) ToggleRecord() error            { return ErrUnsupported }
+func (o *ONVIF) SetMode(mode FollowMode) error  { return ErrUnsupported }
+func (o *ONVIF) Attitude() (Attitude, bool)     { return Attitude{}, false }
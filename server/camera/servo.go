@@ -0,0 +1,158 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Closed-loop pointing tuning. Rates are normalized (-1..+1) Move rates.
+const (
+	servoInterval  = 100 * time.Millisecond // matches the siyi attitude poll rate
+	servoTolerance = 0.5                    // degrees; converged when both axes are within this
+	servoFullRate  = 30.0                   // degrees of error that command full rate
+	servoMinRate   = 0.04                   // smallest rate that still moves the gimbal
+	servoTimeout   = 15 * time.Second       // give up if not converged this long after the last aim
+)
+
+var (
+	errNoAttitude   = errors.New("no attitude feedback from gimbal")
+	errPointTimeout = errors.New("did not converge on target")
+)
+
+// servo points a rate-controlled gimbal at an absolute yaw/pitch by closing
+// the loop on its attitude feedback: each tick it commands a pan/tilt rate
+// proportional to the remaining error, and stops once within tolerance.
+// Re-aiming while running just moves the target, so a caller can retarget
+// at the attitude rate (e.g. look-at tracking) without restarting the loop.
+type servo struct {
+	name     string // for log messages
+	move     func(pan, tilt float64) error
+	attitude func() (Attitude, bool)
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc // nil when idle
+	yaw     float64
+	pitch   float64
+	aimedAt time.Time
+}
+
+// aim sets the target and starts the control loop if it isn't running.
+func (s *servo) aim(yaw, pitch float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.yaw, s.pitch, s.aimedAt = yaw, pitch, time.Now()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go func() {
+		t := time.NewTicker(servoInterval)
+		defer t.Stop()
+		if err := s.run(ctx, t.C); err != nil {
+			log.Printf("camera %s: point: %v", s.name, err)
+		}
+	}()
+}
+
+// halt stops the control loop (if running) without sending a stop command;
+// callers follow up with their own Move or Stop.
+func (s *servo) halt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// target returns the current target and when it was last set.
+func (s *servo) target() (yaw, pitch float64, aimedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.yaw, s.pitch, s.aimedAt
+}
+
+// run drives the gimbal once per tick until converged, cancelled, or timed
+// out, then stops it. While the target keeps moving (re-aimed since the
+// previous tick, as look-at tracking does) it holds still on target instead
+// of finishing. When cancelled it sends nothing: the canceller owns the next
+// command.
+func (s *servo) run(ctx context.Context, tick <-chan time.Time) error {
+	_, _, prevAim := s.target()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		}
+		att, ok := s.attitude()
+		if !ok {
+			return s.finish(ctx, errNoAttitude)
+		}
+		yaw, pitch, aimedAt := s.target()
+		pan, tilt, done := servoStep(att, yaw, pitch)
+		if done {
+			s.mu.Lock()
+			tracking := !s.aimedAt.Equal(prevAim)
+			s.mu.Unlock()
+			if !tracking {
+				return s.finish(ctx, nil)
+			}
+			pan, tilt = 0, 0 // don't coast past the target on the last rate
+		} else if time.Since(aimedAt) > servoTimeout {
+			return s.finish(ctx, errPointTimeout)
+		}
+		prevAim = aimedAt
+		if err := s.move(pan, tilt); err != nil {
+			return s.finish(ctx, err)
+		}
+	}
+}
+
+// finish marks the loop idle and stops the gimbal, unless the loop was
+// cancelled in the meantime.
+func (s *servo) finish(ctx context.Context, err error) error {
+	s.mu.Lock()
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.mu.Unlock()
+	if stopErr := s.move(0, 0); err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// servoStep returns the normalized pan/tilt rates that drive att toward
+// yaw/pitch, and whether both axes are already within tolerance.
+func servoStep(att Attitude, yaw, pitch float64) (pan, tilt float64, done bool) {
+	dy := yaw - att.Yaw
+	dp := pitch - att.Pitch
+	if math.Abs(dy) <= servoTolerance && math.Abs(dp) <= servoTolerance {
+		return 0, 0, true
+	}
+	return servoRate(dy), servoRate(dp), false
+}
+
+// servoRate is the proportional rate for one axis, with a floor so small
+// errors still overcome the gimbal's deadband.
+func servoRate(errDeg float64) float64 {
+	if math.Abs(errDeg) <= servoTolerance {
+		return 0
+	}
+	r := clamp(errDeg / servoFullRate)
+	if math.Abs(r) < servoMinRate {
+		r = math.Copysign(servoMinRate, r)
+	}
+	return r
+}
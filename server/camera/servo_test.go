@@ -0,0 +1,162 @@
+package camera
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/axis"
+)
+
+// TestServoConvergesOnSim closes the loop against the simulated gimbal's
+// rate control, stepping the simulation once per servo tick.
+func TestServoConvergesOnSim(t *testing.T) {
+	sim := NewSim()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv := &servo{name: "sim", move: sim.Move, attitude: sim.Attitude, cancel: cancel}
+	sv.yaw, sv.pitch, sv.aimedAt = -72.5, -30, time.Now()
+
+	tick := make(chan time.Time)
+	done := make(chan error, 1)
+	go func() { done <- sv.run(ctx, tick) }()
+
+	for i := 0; ; i++ {
+		if i > 200 {
+			t.Fatal("servo did not converge within 20 simulated seconds")
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			att, _ := sim.Attitude()
+			if math.Abs(att.Yaw+72.5) > servoTolerance || math.Abs(att.Pitch+30) > servoTolerance {
+				t.Fatalf("converged at %.2f/%.2f, want -72.5/-30", att.Yaw, att.Pitch)
+			}
+			if st := sim.State(); st.PanRate != 0 || st.TiltRate != 0 {
+				t.Errorf("gimbal left moving after convergence: %+v", st)
+			}
+			return
+		case tick <- time.Now():
+			sim.Step(servoInterval)
+		}
+	}
+}
+
+// TestServoStopsWhileTracking re-aims every tick, as look-at tracking does,
+// and checks the gimbal stops once on target instead of coasting.
+func TestServoStopsWhileTracking(t *testing.T) {
+	sim := NewSim()
+	ctx, cancel := context.WithCancel(context.Background())
+	sv := &servo{name: "sim", move: sim.Move, cancel: cancel}
+	// Re-aim as each tick reads the attitude, so every tick sees a new aim
+	// regardless of goroutine scheduling.
+	sv.attitude = func() (Attitude, bool) {
+		sv.aim(10, 5)
+		return sim.Attitude()
+	}
+
+	tick := make(chan time.Time)
+	done := make(chan error, 1)
+	go func() { done <- sv.run(ctx, tick) }()
+
+	for i := range 100 {
+		select {
+		case tick <- time.Now():
+			sim.Step(servoInterval)
+		case err := <-done:
+			t.Fatalf("loop ended while tracking, at tick %d: %v", i, err)
+		}
+	}
+	// The loop finishes a tick before taking the next, so once this one is
+	// taken the last one has been handled.
+	tick <- time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	att, _ := sim.Attitude()
+	if math.Abs(att.Yaw-10) > servoTolerance || math.Abs(att.Pitch-5) > servoTolerance {
+		t.Fatalf("tracking settled at %.2f/%.2f, want 10/5", att.Yaw, att.Pitch)
+	}
+	if st := sim.State(); st.PanRate != 0 || st.TiltRate != 0 {
+		t.Errorf("gimbal left moving on target: %+v", st)
+	}
+}
+
+func TestServoStep(t *testing.T) {
+	if _, _, done := servoStep(Attitude{Yaw: 10.2, Pitch: -4.8}, 10, -5); !done {
+		t.Errorf("within tolerance should be done")
+	}
+	pan, tilt, done := servoStep(Attitude{}, 60, -1)
+	if done || pan != 1 || tilt != -servoMinRate {
+		t.Errorf("got pan=%v tilt=%v done=%v, want full pan and minimum tilt", pan, tilt, done)
+	}
+	pan, _, _ = servoStep(Attitude{}, -15, 0)
+	if pan != -0.5 {
+		t.Errorf("proportional pan = %v, want -0.5", pan)
+	}
+}
+
+func TestLookAngles(t *testing.T) {
+	base := axis.State{Lat: 33.3, Lon: -111.8, AltFt: 5000}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 0.5 }
+
+	cases := []struct {
+		name             string
+		state            func(s axis.State) axis.State
+		target           GeoTarget
+		wantYaw, wantPit float64
+	}{
+		{
+			name:   "dead ahead, same altitude",
+			state:  func(s axis.State) axis.State { s.Heading = 0; return s },
+			target: GeoTarget{Lat: 33.4, Lon: -111.8, AltFt: 5000},
+		},
+		{
+			name:    "due east while heading north is right",
+			state:   func(s axis.State) axis.State { s.Heading = 0; return s },
+			target:  GeoTarget{Lat: 33.3, Lon: -111.7, AltFt: 5000},
+			wantYaw: 90,
+		},
+		{
+			name:    "directly behind",
+			state:   func(s axis.State) axis.State { s.Heading = 90; return s },
+			target:  GeoTarget{Lat: 33.3, Lon: -111.9, AltFt: 5000},
+			wantYaw: 180,
+		},
+		{
+			name:  "ground point 45 degrees down",
+			state: func(s axis.State) axis.State { s.Heading = 0; return s },
+			// 5000 ft below, 5000 ft (1524 m) north.
+			target:  GeoTarget{Lat: 33.3 + 1524/earthRadiusM*180/math.Pi, Lon: -111.8, AltFt: 0},
+			wantPit: -45,
+			wantYaw: 0,
+		},
+		{
+			name:    "nose up lowers a level target",
+			state:   func(s axis.State) axis.State { s.Heading = 0; s.Pitch = 10; return s },
+			target:  GeoTarget{Lat: 33.4, Lon: -111.8, AltFt: 5000},
+			wantPit: -10,
+		},
+		{
+			name:    "right bank raises a level target off the right wing",
+			state:   func(s axis.State) axis.State { s.Heading = 0; s.Roll = 30; return s },
+			target:  GeoTarget{Lat: 33.3, Lon: -111.7, AltFt: 5000},
+			wantYaw: 90,
+			wantPit: 30,
+		},
+	}
+	for _, c := range cases {
+		yaw, pitch := LookAngles(c.state(base), c.target)
+		if math.Abs(c.wantYaw) == 180 {
+			yaw = math.Abs(yaw)
+		}
+		if !near(yaw, c.wantYaw) || !near(pitch, c.wantPit) {
+			t.Errorf("%s: got yaw=%.2f pitch=%.2f, want %.0f/%.0f", c.name, yaw, pitch, c.wantYaw, c.wantPit)
+		}
+	}
+}
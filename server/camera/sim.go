@@ -0,0 +1,222 @@
+package camera
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Simulated gimbal limits and speeds, modelled on the Siyi A8 mini.
+const (
+	simMaxYaw    = 135
+	simMinPitch  = -90
+	simMaxPitch  = 25
+	simMaxZoom   = 6
+	simSlewRate  = 90.0 // degrees/second at full rate
+	simZoomSpeed = 2.0  // zoom factor/second at full rate
+)
+
+// SimState is the non-attitude state of a Sim, for assertions in tests.
+type SimState struct {
+	Zoom       float64    `json:"zoom"`
+	Focus      int        `json:"focus"` // current manual focus direction
+	AutoFocus  int        `json:"autoFocus"`
+	Photos     int        `json:"photos"`
+	Recording  bool       `json:"recording"`
+	Mode       FollowMode `json:"mode"`
+	Pointing   bool       `json:"pointing"` // slewing to an absolute target
+	PanRate    float64    `json:"panRate"`
+	TiltRate   float64    `json:"tiltRate"`
+	ZoomRate   float64    `json:"zoomRate"`
+	TargetYaw  float64    `json:"targetYaw"`
+	TargetTilt float64    `json:"targetTilt"`
+}
+
+// Sim is a simulated gimbal camera implementing every Controller action. Time
+// only advances when Step is called (or while Run is running), so tests are
+// deterministic.
+type Sim struct {
+	mu         sync.Mutex
+	att        Attitude
+	st         SimState
+	onAttitude func(Attitude)
+}
+
+// NewSim returns a centred simulated gimbal at 1× zoom in follow mode.
+func NewSim() *Sim {
+	return &Sim{st: SimState{Zoom: 1, Mode: ModeFollow}}
+}
+
+// OnAttitude registers fn to be called with the new attitude after each Step.
+func (s *Sim) OnAttitude(fn func(Attitude)) {
+	s.mu.Lock()
+	s.onAttitude = fn
+	s.mu.Unlock()
+}
+
+// State returns a snapshot of the simulated camera state.
+func (s *Sim) State() SimState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.st
+}
+
+// Run steps the simulation every interval until ctx is cancelled.
+func (s *Sim) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Step(interval)
+		}
+	}
+}
+
+// Step advances the simulation by dt: slews toward an absolute target at
+// full rate, or applies the current pan/tilt rates, and integrates zoom.
+func (s *Sim) Step(dt time.Duration) {
+	sec := dt.Seconds()
+	s.mu.Lock()
+	prevYaw, prevPitch := s.att.Yaw, s.att.Pitch
+	if s.st.Pointing {
+		s.att.Yaw = approach(s.att.Yaw, s.st.TargetYaw, simSlewRate*sec)
+		s.att.Pitch = approach(s.att.Pitch, s.st.TargetTilt, simSlewRate*sec)
+		if s.att.Yaw == s.st.TargetYaw && s.att.Pitch == s.st.TargetTilt {
+			s.st.Pointing = false
+		}
+	} else {
+		s.att.Yaw = limit(s.att.Yaw+s.st.PanRate*simSlewRate*sec, -simMaxYaw, simMaxYaw)
+		s.att.Pitch = limit(s.att.Pitch+s.st.TiltRate*simSlewRate*sec, simMinPitch, simMaxPitch)
+	}
+	if sec > 0 {
+		s.att.YawRate = (s.att.Yaw - prevYaw) / sec
+		s.att.PitchRate = (s.att.Pitch - prevPitch) / sec
+	}
+	s.st.Zoom = limit(s.st.Zoom+s.st.ZoomRate*simZoomSpeed*sec, 1, simMaxZoom)
+	att, cb := s.att, s.onAttitude
+	s.mu.Unlock()
+	if cb != nil {
+		cb(att)
+	}
+}
+
+func (s *Sim) Capabilities() Capabilities {
+	return Capabilities{
+		Move: true, Point: true, Zoom: true, ZoomTo: true, Focus: true,
+		Photo: true, Record: true, Home: true, Attitude: true,
+		Modes:    []FollowMode{ModeLock, ModeFollow, ModeFPV},
+		MinPitch: simMinPitch, MaxPitch: simMaxPitch, MaxYaw: simMaxYaw,
+		MaxZoomLevel: simMaxZoom,
+	}
+}
+
+func (s *Sim) Move(pan, tilt float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Pointing = false
+	s.st.PanRate, s.st.TiltRate = clamp(pan), clamp(tilt)
+	return nil
+}
+
+func (s *Sim) Point(yaw, pitch float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.PanRate, s.st.TiltRate = 0, 0
+	s.st.TargetYaw = limit(yaw, -simMaxYaw, simMaxYaw)
+	s.st.TargetTilt = limit(pitch, simMinPitch, simMaxPitch)
+	s.st.Pointing = true
+	return nil
+}
+
+func (s *Sim) Zoom(rate float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.ZoomRate = clamp(rate)
+	return nil
+}
+
+func (s *Sim) ZoomTo(level float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.ZoomRate = 0
+	s.st.Zoom = limit(level, 1, simMaxZoom)
+	return nil
+}
+
+func (s *Sim) Focus(direction int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Focus = int(sign(float64(direction)))
+	return nil
+}
+
+func (s *Sim) AutoFocus() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.AutoFocus++
+	return nil
+}
+
+func (s *Sim) Photo() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Photos++
+	return nil
+}
+
+func (s *Sim) ToggleRecord() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Recording = !s.st.Recording
+	return nil
+}
+
+func (s *Sim) SetMode(mode FollowMode) error {
+	switch mode {
+	case ModeLock, ModeFollow, ModeFPV:
+	default:
+		return ErrUnsupported
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Mode = mode
+	return nil
+}
+
+func (s *Sim) Attitude() (Attitude, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.att, true
+}
+
+func (s *Sim) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.st.Pointing = false
+	s.st.PanRate, s.st.TiltRate, s.st.ZoomRate = 0, 0, 0
+	return nil
+}
+
+func (s *Sim) Home() error {
+	return s.Point(0, 0)
+}
+
+// approach moves v toward target by at most step.
+func approach(v, target, step float64) float64 {
+	if math.Abs(target-v) <= step {
+		return target
+	}
+	if target > v {
+		return v + step
+	}
+	return v - step
+}
+
+// limit clamps v to lo..hi.
+func limit(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
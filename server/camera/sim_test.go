@@ -0,0 +1,119 @@
+package camera
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+func TestSimMoveRespectsLimits(t *testing.T) {
+	s := NewSim()
+	if err := Execute(s, Command{Action: "move", Pan: 1, Tilt: -0.5}); err != nil {
+		t.Fatal(err)
+	}
+	s.Step(time.Second)
+	att, ok := s.Attitude()
+	if !ok || att.Yaw != 90 || att.Pitch != -45 {
+		t.Fatalf("after 1s: yaw=%v pitch=%v, want 90 -45", att.Yaw, att.Pitch)
+	}
+	if att.YawRate != 90 {
+		t.Errorf("yawRate = %v, want 90", att.YawRate)
+	}
+	for range 5 {
+		s.Step(time.Second)
+	}
+	att, _ = s.Attitude()
+	if att.Yaw != simMaxYaw || att.Pitch != simMinPitch {
+		t.Errorf("expected clamp at %v/%v, got %v/%v", simMaxYaw, simMinPitch, att.Yaw, att.Pitch)
+	}
+
+	if err := Execute(s, Command{Action: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Step(time.Second)
+	if att2, _ := s.Attitude(); att2.Yaw != att.Yaw || att2.YawRate != 0 {
+		t.Errorf("gimbal kept moving after stop: %+v", att2)
+	}
+}
+
+func TestSimPointAndHome(t *testing.T) {
+	s := NewSim()
+	var reported []Attitude
+	s.OnAttitude(func(a Attitude) { reported = append(reported, a) })
+
+	if err := Execute(s, Command{Action: "point", Yaw: -60, Pitch: 40}); err != nil {
+		t.Fatal(err)
+	}
+	// Pitch target is clamped to the +25° limit; 60° of yaw takes 2/3 s.
+	for range 10 {
+		s.Step(100 * time.Millisecond)
+	}
+	att, _ := s.Attitude()
+	if att.Yaw != -60 || att.Pitch != simMaxPitch {
+		t.Fatalf("point: got yaw=%v pitch=%v", att.Yaw, att.Pitch)
+	}
+	if s.State().Pointing {
+		t.Errorf("still pointing after reaching target")
+	}
+	if len(reported) != 10 {
+		t.Errorf("OnAttitude called %d times, want 10", len(reported))
+	}
+
+	if err := Execute(s, Command{Action: "home"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Step(time.Second)
+	if att, _ := s.Attitude(); att.Yaw != 0 || att.Pitch != 0 {
+		t.Errorf("home: got %+v", att)
+	}
+}
+
+func TestSimZoomFocusPhotoRecordMode(t *testing.T) {
+	s := NewSim()
+	cmds := []Command{
+		{Action: "zoom", Rate: 1},
+		{Action: "focus", Direction: -3},
+		{Action: "autoFocus"},
+		{Action: "photo"},
+		{Action: "photo"},
+		{Action: "record"},
+		{Action: "mode", Mode: ModeLock},
+	}
+	for _, c := range cmds {
+		if err := Execute(s, c); err != nil {
+			t.Fatalf("%s: %v", c.Action, err)
+		}
+	}
+	s.Step(time.Second)
+	st := s.State()
+	if st.Zoom != 3 || st.Focus != -1 || st.AutoFocus != 1 || st.Photos != 2 || !st.Recording || st.Mode != ModeLock {
+		t.Errorf("unexpected state: %+v", st)
+	}
+
+	if err := Execute(s, Command{Action: "zoomTo", Level: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.State(); st.Zoom != simMaxZoom || st.ZoomRate != 0 {
+		t.Errorf("zoomTo should clamp to %v and stop rate zoom: %+v", simMaxZoom, st)
+	}
+}
+
+func TestExecuteChecksCapabilities(t *testing.T) {
+	// An ONVIF controller never reaches the network for unsupported actions.
+	o := NewONVIF(config.CameraConfig{ONVIFURL: "http://192.0.2.1/onvif/device_service"})
+	for _, action := range []string{"point", "zoomTo", "focus", "autoFocus", "photo", "record", "mode"} {
+		if err := Execute(o, Command{Action: action, Mode: ModeLock}); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: got %v, want ErrUnsupported", action, err)
+		}
+	}
+
+	s := NewSim()
+	if err := Execute(s, Command{Action: "mode", Mode: "spin"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unknown mode: got %v, want ErrUnsupported", err)
+	}
+	if err := Execute(s, Command{Action: "selfDestruct"}); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("unknown action: got %v, want ErrUnknownAction", err)
+	}
+}
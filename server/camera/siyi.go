@@ -0,0 +1,136 @@
+package camera
+
+import "github.com/vincent99/velocipi/server/hardware/siyi"
+
+// Siyi gimbal limits (A8 mini).
+const (
+	siyiMaxYaw   = 135
+	siyiMinPitch = -90
+	siyiMaxPitch = 25
+	siyiMaxZoom  = 6
+)
+
+// Siyi adapts a siyi.Manager gimbal to the Controller interface. Absolute
+// pointing is closed-loop: rate commands corrected against the attitude the
+// gimbal reports, so it converges regardless of firmware or mount.
+type Siyi struct {
+	m     *siyi.Manager
+	servo *servo
+}
+
+// NewSiyi wraps a running siyi.Manager.
+func NewSiyi(m *siyi.Manager) *Siyi {
+	s := &Siyi{m: m}
+	s.servo = &servo{name: m.Name(), move: s.rotate, attitude: s.Attitude}
+	return s
+}
+
+// Manager returns the underlying siyi.Manager for vendor-specific actions.
+func (s *Siyi) Manager() *siyi.Manager {
+	return s.m
+}
+
+func (s *Siyi) Capabilities() Capabilities {
+	return Capabilities{
+		Move: true, Point: true, Zoom: true, ZoomTo: true, Focus: true,
+		Photo: true, Record: true, Home: true, Attitude: true,
+		Modes:    []FollowMode{ModeLock, ModeFollow, ModeFPV},
+		MinPitch: siyiMinPitch, MaxPitch: siyiMaxPitch, MaxYaw: siyiMaxYaw,
+		MaxZoomLevel: siyiMaxZoom,
+	}
+}
+
+// Move cancels any absolute pointing in progress and sets pan/tilt rates.
+func (s *Siyi) Move(pan, tilt float64) error {
+	s.servo.halt()
+	return s.rotate(pan, tilt)
+}
+
+// rotate maps normalized rates onto the gimbal's -100..+100 rate command.
+func (s *Siyi) rotate(pan, tilt float64) error {
+	return s.m.GimbalRotate(int8(clamp(pan)*100), int8(clamp(tilt)*100))
+}
+
+// Point clamps to the gimbal's mechanical range and starts converging on it.
+// It returns immediately; calling it again while converging retargets.
+func (s *Siyi) Point(yaw, pitch float64) error {
+	if _, ok := s.m.LastAttitude(); !ok {
+		return errNoAttitude
+	}
+	s.servo.aim(limit(yaw, -siyiMaxYaw, siyiMaxYaw), limit(pitch, siyiMinPitch, siyiMaxPitch))
+	return nil
+}
+
+// Zoom uses the manual zoom command, which only has a direction.
+func (s *Siyi) Zoom(rate float64) error {
+	return s.m.ZoomRate(sign(rate))
+}
+
+func (s *Siyi) ZoomTo(level float64) error {
+	return s.m.AbsoluteZoom(float32(limit(level, 1, siyiMaxZoom)))
+}
+
+func (s *Siyi) Focus(direction int) error {
+	return s.m.ManualFocus(sign(float64(direction)))
+}
+
+func (s *Siyi) AutoFocus() error {
+	return s.m.AutoFocus()
+}
+
+func (s *Siyi) Photo() error {
+	return s.m.TakePhoto()
+}
+
+func (s *Siyi) ToggleRecord() error {
+	return s.m.ToggleVideo()
+}
+
+func (s *Siyi) SetMode(mode FollowMode) error {
+	switch mode {
+	case ModeLock:
+		return s.m.SetMode(siyi.ModeLock)
+	case ModeFollow:
+		return s.m.SetMode(siyi.ModeFollow)
+	case ModeFPV:
+		return s.m.SetMode(siyi.ModeFPV)
+	}
+	return ErrUnsupported
+}
+
+func (s *Siyi) Attitude() (Attitude, bool) {
+	a, ok := s.m.LastAttitude()
+	return SiyiAttitude(a), ok
+}
+
+// SiyiAttitude converts a siyi attitude report.
+func SiyiAttitude(a siyi.GimbalAttitude) Attitude {
+	return Attitude{
+		Yaw: float64(a.Yaw), Pitch: float64(a.Pitch), Roll: float64(a.Roll),
+		YawRate: float64(a.YawRate), PitchRate: float64(a.PitchRate), RollRate: float64(a.RollRate),
+	}
+}
+
+func (s *Siyi) Stop() error {
+	s.servo.halt()
+	if err := s.m.GimbalRotate(0, 0); err != nil {
+		return err
+	}
+	return s.m.ZoomRate(0)
+}
+
+func (s *Siyi) Home() error {
+	s.servo.halt()
+	return s.m.Center()
+}
+
+// sign maps v to -1, 0 or +1 for the siyi direction-only commands.
+func sign(v float64) int8 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
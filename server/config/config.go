@@ -2,14 +2,17 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/vincent99/velocipi/server/gesture"
 	"gopkg.in/yaml.v3"
 	"periph.io/x/conn/v3/physic"
 )
@@ -30,28 +33,100 @@ func SetDir(dir string) {
 // CameraConfig holds connection parameters for a single IP camera.
 type CameraConfig struct {
 	Name       string `yaml:"name"       json:"name"`
-	Driver     string `yaml:"driver"     json:"driver"` // "rtsp" (default/empty) or "siyi"
+	Driver     string `yaml:"driver"     json:"driver"` // "rtsp" (default/empty), "siyi", or "onvif"
 	Host       string `yaml:"host"       json:"host"`
 	Port       int    `yaml:"port"       json:"port"`
+	Path       string `yaml:"path"       json:"path"` // RTSP path, e.g. "/live/main"; empty = "/"
 	Username   string `yaml:"username"   json:"username"`
 	Password   string `yaml:"password"   json:"password"`
 	Audio      bool   `yaml:"audio"      json:"audio"`                  // record and stream audio (default false)
 	Record     *bool  `yaml:"record,omitempty" json:"record,omitempty"` // nil or true = record; false = skip
 	Sort       *int   `yaml:"sort,omitempty"   json:"sort,omitempty"`
 	SiyiAIHost string `yaml:"siyiAIHost" json:"siyiAIHost"` // IP of AI tracking module; empty = disabled
+
+	// ONVIF device service URL and media profile token for driver "onvif"
+	// (PTZ control); filled in by the /camera/add API from discovery.
+	ONVIFURL     string `yaml:"onvifURL,omitempty"     json:"onvifURL,omitempty"`
+	ONVIFProfile string `yaml:"onvifProfile,omitempty" json:"onvifProfile,omitempty"`
+
+	Retention *RetentionConfig `yaml:"retention,omitempty" json:"retention,omitempty"` // nil = use dvr.retention
+
+	Presets []CameraPreset `yaml:"presets,omitempty" json:"presets,omitempty"` // named gimbal positions
+}
+
+// CameraPreset is a named gimbal position, e.g. "left wing", "gear" or "tail".
+type CameraPreset struct {
+	Name  string  `yaml:"name"           json:"name"`
+	Yaw   float64 `yaml:"yaw"            json:"yaw"`            // degrees relative to the airframe, positive = right
+	Pitch float64 `yaml:"pitch"          json:"pitch"`          // degrees, positive = up
+	Zoom  float64 `yaml:"zoom,omitempty" json:"zoom,omitempty"` // zoom factor; 0 = leave unchanged
 }
 
 // MusicConfig holds settings for the music player subsystem.
 type MusicConfig struct {
-	Volume                int     `yaml:"volume"               json:"volume"`
-	AudioDevice           string  `yaml:"audioDevice"          json:"audioDevice"` // mpv --audio-device value; "auto" = let mpv choose
-	AlbumRequiredPercent  int     `yaml:"albumRequiredPercent" json:"albumRequiredPercent"`
-	MinDbVersion          int     `yaml:"minDbVersion"         json:"minDbVersion"`
-	MaxBitrate            int     `yaml:"maxBitrate"            json:"maxBitrate"`            // kbps; 0 = no limit
-	TranscodeFormat       string  `yaml:"transcodeFormat"       json:"transcodeFormat"`       // e.g. "aac", "mp3"
-	PlayedRequiredPercent int     `yaml:"playedRequiredPercent" json:"playedRequiredPercent"` // % elapsed before a skip counts as a play
-	AcoustIDKey           string  `yaml:"acoustidKey"           json:"acoustidKey"`           // AcoustID API key (register free at acoustid.org)
-	AcoustIDMinScore      float64 `yaml:"acoustidMinScore"      json:"acoustidMinScore"`      // minimum AcoustID match score (0.0–1.0) to accept a result
+	Volume                int          `yaml:"volume"               json:"volume"`
+	AudioDevice           string       `yaml:"audioDevice"          json:"audioDevice"`      // mpv --audio-device value; "auto" = let mpv choose
+	Transition            string       `yaml:"transition"           json:"transition"`       // "gapless" (default) or "crossfade"
+	CrossfadeSec          float64      `yaml:"crossfadeSec"         json:"crossfadeSec"`     // crossfade overlap; only used when transition is "crossfade"
+	ReplayGain            string       `yaml:"replayGain"           json:"replayGain"`       // loudness normalisation: "track", "album" or "off"
+	ReplayGainPreamp      float64      `yaml:"replayGainPreamp"     json:"replayGainPreamp"` // dB added to the ReplayGain adjustment (reference is -18 LUFS)
+	AlbumRequiredPercent  int          `yaml:"albumRequiredPercent" json:"albumRequiredPercent"`
+	MinDbVersion          int          `yaml:"minDbVersion"         json:"minDbVersion"`
+	MaxBitrate            int          `yaml:"maxBitrate"            json:"maxBitrate"`            // kbps; 0 = no limit
+	TranscodeFormat       string       `yaml:"transcodeFormat"       json:"transcodeFormat"`       // e.g. "aac", "mp3"
+	PlayedRequiredPercent int          `yaml:"playedRequiredPercent" json:"playedRequiredPercent"` // % elapsed before a skip counts as a play
+	AcoustIDKey           string       `yaml:"acoustidKey"           json:"acoustidKey"`           // AcoustID API key (register free at acoustid.org)
+	AcoustIDMinScore      float64      `yaml:"acoustidMinScore"      json:"acoustidMinScore"`      // minimum AcoustID match score (0.0–1.0) to accept a result
+	Duck                  DuckConfig   `yaml:"duck"                  json:"duck"`
+	Scrobble              bool         `yaml:"scrobble"              json:"scrobble"` // queue plays for export via /music/scrobbles
+	AutoDJ                AutoDJConfig `yaml:"autoDJ"                json:"autoDJ"`
+	LyricsURL             string       `yaml:"lyricsURL"             json:"lyricsURL"` // LRCLIB-compatible lyrics API used by musicsync -lyrics; "" = off
+	Zones                 []ZoneConfig `yaml:"zones"                 json:"zones"`     // independent outputs; empty = one "main" zone on audioDevice
+}
+
+// ZoneConfig is one audio zone: an output with its own queue, mpv instance
+// and volume, e.g. the pilot's intercom input and a cabin Bluetooth speaker.
+type ZoneConfig struct {
+	ID          string `yaml:"id"          json:"id"`          // used in the API and state keys
+	Name        string `yaml:"name"        json:"name"`        // display name; defaults to ID
+	AudioDevice string `yaml:"audioDevice" json:"audioDevice"` // mpv --audio-device value; "" = music.audioDevice
+	Volume      int    `yaml:"volume"      json:"volume"`      // initial volume; 0 = music.volume
+	IgnoreDuck  bool   `yaml:"ignoreDuck"  json:"ignoreDuck"`  // keep playing through radio traffic and PTT
+}
+
+// AutoDJConfig tunes auto-DJ, which keeps the queue topped up from a seed
+// (artist, genre, decade, smart search or playlist) once turned on.
+type AutoDJConfig struct {
+	MinQueue   int     `yaml:"minQueue"   json:"minQueue"`   // top up when fewer songs than this are left
+	Batch      int     `yaml:"batch"      json:"batch"`      // songs appended per top-up
+	AvoidHours float64 `yaml:"avoidHours" json:"avoidHours"` // don't repeat songs played this recently (unless nothing else is left)
+}
+
+// DuckConfig controls how music reacts to radio traffic reported by the
+// intercom-stt process. Our own PTT always pauses playback.
+type DuckConfig struct {
+	Mode        string  `yaml:"mode"        json:"mode"`        // "volume" (lower to Level), "pause", or "off"
+	Level       int     `yaml:"level"       json:"level"`       // % of the normal volume while ducked
+	HangoverSec float64 `yaml:"hangoverSec" json:"hangoverSec"` // hold the duck this long after traffic stops
+	RampSec     float64 `yaml:"rampSec"     json:"rampSec"`     // volume ramp time in and out of the duck
+}
+
+// AuthConfig controls login and the roles that gate the API. Roles are
+// "viewer" (read-only), "passenger" (music only), "pilot" and "admin".
+type AuthConfig struct {
+	Users         []UserConfig `yaml:"users"         json:"users"`
+	AnonymousRole string       `yaml:"anonymousRole" json:"anonymousRole"` // role without a session; "" = none (login required)
+	LocalRole     string       `yaml:"localRole"     json:"localRole"`     // role for unproxied loopback requests (panel, intercom-stt /music/duck)
+	SessionHours  int          `yaml:"sessionHours"  json:"sessionHours"`  // how long a login lasts
+	KeyFile       string       `yaml:"keyFile"       json:"keyFile"`       // session signing key, created if missing; relative to the config dir
+}
+
+// UserConfig is one login. Hash is a PIN or password hashed with
+// `go run ./cmd/passwd`.
+type UserConfig struct {
+	Name string `yaml:"name" json:"name"`
+	Role string `yaml:"role" json:"role"`
+	Hash string `yaml:"hash" json:"hash"`
 }
 
 // StorageConfig holds filesystem directory paths for all subsystems.
@@ -63,15 +138,28 @@ type StorageConfig struct {
 	LiveATC string `yaml:"liveatc" json:"liveatc"` // liveatc audio/transcripts root (used by the intercom-stt process)
 }
 
+// RetentionConfig holds the DVR retention policy applied to each camera's
+// recordings. Every rule is disabled by its zero value. Protected sessions and
+// locked recordings are never touched.
+type RetentionConfig struct {
+	MaxAgeDays     int     `yaml:"maxAgeDays"     json:"maxAgeDays"`     // delete recordings older than this
+	MaxSizeGB      float64 `yaml:"maxSizeGB"      json:"maxSizeGB"`      // per-camera cap on total size in GiB (1024³ bytes); oldest deleted first
+	ThumbsOnlyDays int     `yaml:"thumbsOnlyDays" json:"thumbsOnlyDays"` // delete the video but keep thumbnails after this many days
+	ShrinkDays     int     `yaml:"shrinkDays"     json:"shrinkDays"`     // transcode to shrinkHeight/shrinkBitrate after this many days
+	ShrinkHeight   int     `yaml:"shrinkHeight"   json:"shrinkHeight"`   // px; 0 = keep resolution
+	ShrinkBitrate  int     `yaml:"shrinkBitrate"  json:"shrinkBitrate"`  // video kbps; 0 = constant-quality encode
+}
+
 // DVRConfig holds settings for the DVR recording subsystem.
 type DVRConfig struct {
-	SegmentDuration int            `yaml:"segmentDuration" json:"segmentDuration"` // seconds
-	ThumbnailHeight int            `yaml:"thumbnailHeight" json:"thumbnailHeight"` // px height for snapshot + segment thumbnails
-	FFmpegLog       bool           `yaml:"ffmpegLog"       json:"ffmpegLog"`       // pipe ffmpeg stderr to server log
-	Record          bool           `yaml:"record"          json:"record"`          // enable recording on startup (default true)
-	MinFreeDisk     float64        `yaml:"minFreeDisk"     json:"minFreeDisk"`     // minimum free disk space in GB; 0 = disabled
-	DiskSpacePoll   string         `yaml:"diskSpacePoll"   json:"diskSpacePoll"`   // how often to poll disk space, e.g. "1m"
-	Cameras         []CameraConfig `yaml:"cameras"         json:"cameras"`
+	SegmentDuration int             `yaml:"segmentDuration" json:"segmentDuration"` // seconds
+	ThumbnailHeight int             `yaml:"thumbnailHeight" json:"thumbnailHeight"` // px height for snapshot + segment thumbnails
+	FFmpegLog       bool            `yaml:"ffmpegLog"       json:"ffmpegLog"`       // pipe ffmpeg stderr to server log
+	Record          bool            `yaml:"record"          json:"record"`          // enable recording on startup (default true)
+	MinFreeDisk     float64         `yaml:"minFreeDisk"     json:"minFreeDisk"`     // minimum free disk space in GiB (1024³ bytes); 0 = disabled
+	DiskSpacePoll   string          `yaml:"diskSpacePoll"   json:"diskSpacePoll"`   // how often to poll disk space (and apply retention), e.g. "1m"
+	Retention       RetentionConfig `yaml:"retention"       json:"retention"`       // default policy; cameras may override
+	Cameras         []CameraConfig  `yaml:"cameras"         json:"cameras"`
 }
 
 // NavMenuConfig holds display settings for the panel navigation menu.
@@ -96,6 +184,26 @@ type KeyMapConfig struct {
 	OuterRight string `yaml:"outerRight" json:"outerRight"`
 }
 
+// GestureConfig binds panel input gestures to actions. Buttons and knobs
+// that aren't part of a bound gesture keep sending their keyMap keys.
+type GestureConfig struct {
+	LongPressMs   int              `yaml:"longPressMs"   json:"longPressMs"`   // ms hold for a long press
+	DoublePressMs int              `yaml:"doublePressMs" json:"doublePressMs"` // ms allowed between the taps of a double press
+	Bindings      []GestureBinding `yaml:"bindings"      json:"bindings"`
+}
+
+// GestureBinding binds one gesture to an action, on every panel page or
+// only on the pages under Route. A binding for a Route replaces the
+// everywhere binding of the same gesture.
+type GestureBinding struct {
+	Gesture string  `yaml:"gesture" json:"gesture"` // e.g. "enter.long", "up.double", "up+left", "enter+inner-right", "outer-left"
+	Route   string  `yaml:"route"   json:"route"`   // panel page relative to /panel/, e.g. "music"; "" = every page
+	Action  string  `yaml:"action"  json:"action"`  // what the gesture does
+	Target  string  `yaml:"target"  json:"target"`  // key name, music command, LED channel (r/w/b/y), camera name or "next"/"prev", page path, or "setpoint"
+	Value   float64 `yaml:"value"   json:"value"`   // music volume or skip step, setpoint step, or LED pattern rate in ms
+	Mode    string  `yaml:"mode"    json:"mode"`    // LED pattern to show, "toggle", or "clear"
+}
+
 // PanelConfig holds the physical dimensions and color scheme of the OLED panel display.
 type PanelConfig struct {
 	Width              int    `yaml:"width"               json:"width"`
@@ -121,6 +229,7 @@ type UIConfig struct {
 	Panel            PanelConfig   `yaml:"panel"            json:"panel"`
 	NavMenu          NavMenuConfig `yaml:"navMenu"          json:"navMenu"`
 	KeyMap           KeyMapConfig  `yaml:"keyMap"           json:"keyMap"`
+	Gestures         GestureConfig `yaml:"gestures"         json:"gestures"`
 }
 
 // StringSlice is a []string that unmarshals from either a YAML scalar ("abc")
@@ -150,6 +259,8 @@ type TireAddresses struct {
 	Right StringSlice `yaml:"right" json:"right"`
 }
 
+// ExpanderBits maps each panel input and LED to its pin (0-15) on the I/O
+// expander.
 type ExpanderBits struct {
 	KnobCenter uint `yaml:"knobCenter" json:"knobCenter"`
 	KnobInnerA uint `yaml:"knobInnerA" json:"knobInnerA"` // quadrature A
@@ -169,37 +280,82 @@ type ExpanderBits struct {
 	JoyKnobB   uint `yaml:"joyKnobB"   json:"joyKnobB"` // quadrature B
 }
 
+// ExpanderConfig holds settings for the panel's I/O expander.
 type ExpanderConfig struct {
-	Address  uint8        `yaml:"address"  json:"address"`
-	Interval string       `yaml:"interval" json:"interval"`
-	Bits     ExpanderBits `yaml:"bits"     json:"bits"`
+	Address      uint8          `yaml:"address"      json:"address"`      // I2C address
+	Interval     string         `yaml:"interval"     json:"interval"`     // input poll interval when interrupts aren't wired, e.g. "2ms"
+	InterruptPin int            `yaml:"interruptPin" json:"interruptPin"` // GPIO pin wired to the expander's INTA/INTB; 0 = poll instead
+	GPIOChip     string         `yaml:"gpioChip"     json:"gpioChip"`     // gpiochip device for the interrupt pin
+	Bits         ExpanderBits   `yaml:"bits"         json:"bits"`
+	Encoders     EncodersConfig `yaml:"encoders"     json:"encoders"`
 }
 
+// EncodersConfig tunes the panel's rotary encoders.
+type EncodersConfig struct {
+	Inner EncoderConfig `yaml:"inner" json:"inner"`
+	Outer EncoderConfig `yaml:"outer" json:"outer"`
+	Joy   EncoderConfig `yaml:"joy"   json:"joy"`
+}
+
+// EncoderConfig tunes how one encoder's quadrature turns into steps.
+type EncoderConfig struct {
+	TransitionsPerDetent int          `yaml:"transitionsPerDetent" json:"transitionsPerDetent"` // quadrature transitions between detents: 1, 2 or 4; 0 = 2
+	Invert               bool         `yaml:"invert"               json:"invert"`               // swap left and right
+	Acceleration         []AccelPoint `yaml:"acceleration"         json:"acceleration"`         // steps per detent by speed, interpolated; empty = always 1
+}
+
+// AccelPoint is one point of an encoder's acceleration curve.
+type AccelPoint struct {
+	Speed float64 `yaml:"speed" json:"speed"` // detents per second
+	Steps float64 `yaml:"steps" json:"steps"` // steps per detent at that speed
+}
+
+// SensorConfig holds settings for an I2C sensor.
 type SensorConfig struct {
-	Address  uint8  `yaml:"address"  json:"address"`
-	Interval string `yaml:"interval" json:"interval"`
+	Address  uint8  `yaml:"address"  json:"address"`  // I2C address
+	Interval string `yaml:"interval" json:"interval"` // sample interval, e.g. "1s"
 }
 
+// ScreenConfig holds settings for rendering the panel UI to the OLED.
 type ScreenConfig struct {
-	SplashImage    string `yaml:"splashImage"    json:"splashImage"`
-	SplashDuration string `yaml:"splashDuration" json:"splashDuration"`
-	FPS            int    `yaml:"fps"            json:"fps"`
+	SplashImage    string `yaml:"splashImage"    json:"splashImage"`    // shown on the OLED at startup
+	SplashDuration string `yaml:"splashDuration" json:"splashDuration"` // how long the splash image stays up
+	FPS            int    `yaml:"fps"            json:"fps"`            // screencast frame rate
 }
 
+// OLEDConfig holds settings for the panel's OLED display.
 type OLEDConfig struct {
-	Driver    string `yaml:"driver"    json:"driver"` // "ssd1327" or "ge256x64b"
-	SPISpeed  string `yaml:"spiSpeed"  json:"spiSpeed"`
-	GPIOChip  string `yaml:"gpioChip"  json:"gpioChip"`
-	StatusPin int    `yaml:"statusPin" json:"statusPin"`
-	ResetPin  int    `yaml:"resetPin"  json:"resetPin"`
-	Flip      bool   `yaml:"flip"      json:"flip"`
+	Driver    string `yaml:"driver"    json:"driver"`    // "ssd1327" or "ge256x64b"
+	SPISpeed  string `yaml:"spiSpeed"  json:"spiSpeed"`  // SPI clock, e.g. "2.40MHz"
+	GPIOChip  string `yaml:"gpioChip"  json:"gpioChip"`  // gpiochip device for the status and reset pins
+	StatusPin int    `yaml:"statusPin" json:"statusPin"` // data/command (SSD1327) or busy (GE256X64B) GPIO pin
+	ResetPin  int    `yaml:"resetPin"  json:"resetPin"`  // display reset GPIO pin; 0 = none
+	Flip      bool   `yaml:"flip"      json:"flip"`      // rotate the image 180°
 }
 
 // ThermalConfig holds settings for the thermal camera serial interface.
 type ThermalConfig struct {
 	// Device is the path to the serial device (e.g. "/dev/ttyUSB0").
 	// If empty, the thermal camera subsystem is disabled.
-	Device string `yaml:"device" json:"device"`
+	Device       string          `yaml:"device"            json:"device"`
+	PollInterval string          `yaml:"pollInterval"      json:"pollInterval"`      // how often the camera's state is re-read, e.g. "30s"
+	FFCTempDelta float64         `yaml:"ffcTempDelta"      json:"ffcTempDelta"`      // run a flat-field calibration when the air temperature moves this many °C; 0 = off
+	Presets      []ThermalPreset `yaml:"presets,omitempty" json:"presets,omitempty"` // named image settings
+}
+
+// ThermalPreset is a named set of thermal camera image settings, e.g.
+// "night" or "ground".
+type ThermalPreset struct {
+	Name               string `yaml:"name"               json:"name"`
+	Brightness         uint8  `yaml:"brightness"         json:"brightness"`
+	Contrast           uint8  `yaml:"contrast"           json:"contrast"`
+	DetailEnhancement  uint8  `yaml:"detailEnhancement"  json:"detailEnhancement"`
+	StaticDenoising    uint8  `yaml:"staticDenoising"    json:"staticDenoising"`
+	DynamicDenoising   uint8  `yaml:"dynamicDenoising"   json:"dynamicDenoising"`
+	Palette            string `yaml:"palette"            json:"palette"`            // e.g. "white-hot", "iron-red-1"
+	Mirroring          string `yaml:"mirroring"          json:"mirroring"`          // "none", "center", "left-right" or "up-down"
+	ShutterMode        string `yaml:"shutterMode"        json:"shutterMode"`        // "off", "timing", "temp-delta" or "full-auto"
+	ShutterIntervalMin uint16 `yaml:"shutterIntervalMin" json:"shutterIntervalMin"` // minutes between automatic FFCs
 }
 
 // AirConConfig holds settings for the aircon state/command relay
@@ -247,9 +403,9 @@ type KnobConfig struct {
 // HardwareConfig groups all the physical-hardware wiring config: bus devices,
 // the shared reset pin, and each attached peripheral.
 type HardwareConfig struct {
-	I2CDevice   string         `yaml:"i2cDevice"   json:"i2cDevice"`
-	SPIDevice   string         `yaml:"spiDevice"   json:"spiDevice"`
-	ResetPin    int            `yaml:"resetPin"    json:"resetPin"` // shared hardware reset GPIO pin; 0 = disabled
+	I2CDevice   string         `yaml:"i2cDevice"   json:"i2cDevice"` // I2C bus for the sensors and expander
+	SPIDevice   string         `yaml:"spiDevice"   json:"spiDevice"` // SPI bus for the OLED
+	ResetPin    int            `yaml:"resetPin"    json:"resetPin"`  // shared hardware reset GPIO pin; 0 = disabled
 	AirSensor   SensorConfig   `yaml:"airSensor"   json:"airSensor"`
 	Expander    ExpanderConfig `yaml:"expander"    json:"expander"`
 	LightSensor SensorConfig   `yaml:"lightSensor" json:"lightSensor"`
@@ -263,9 +419,9 @@ type HardwareConfig struct {
 
 // Config holds all runtime configuration.
 type Config struct {
-	Addr         string `yaml:"addr"         json:"addr"`
-	TailNumber   string `yaml:"tailNumber"   json:"tailNumber"`
-	PingInterval string `yaml:"pingInterval" json:"pingInterval"`
+	Addr         string `yaml:"addr"         json:"addr"`         // HTTP listen address
+	TailNumber   string `yaml:"tailNumber"   json:"tailNumber"`   // aircraft identifier shown in the UI
+	PingInterval string `yaml:"pingInterval" json:"pingInterval"` // websocket ping interval
 
 	Hardware   HardwareConfig   `yaml:"hardware"    json:"hardware"`
 	Storage    StorageConfig    `yaml:"storage"     json:"storage"`
@@ -275,6 +431,7 @@ type Config struct {
 	UI         UIConfig         `yaml:"ui"          json:"ui"`
 	AirCon     AirConConfig     `yaml:"airCon"      json:"airCon"`
 	Brightness BrightnessConfig `yaml:"brightness"  json:"brightness"`
+	Auth       AuthConfig       `yaml:"auth"        json:"auth"`
 
 	// Parsed values — not serialized, populated by Load()
 	AppURL                 string           `yaml:"-" json:"-"` // http://localhost:<VELOCIPI_PORT>/panel/
@@ -286,6 +443,7 @@ type Config struct {
 	DVRDiskSpacePollDur    time.Duration    `yaml:"-" json:"-"`
 	BrightnessDelayDur     time.Duration    `yaml:"-" json:"-"`
 	BrightnessSpeedDur     time.Duration    `yaml:"-" json:"-"`
+	ThermalPollIntervalDur time.Duration    `yaml:"-" json:"-"`
 	OLEDSPIFreq            physic.Frequency `yaml:"-" json:"-"`
 }
 
@@ -296,7 +454,11 @@ type LoadResult struct {
 }
 
 // Load reads config.default.yaml as the baseline, then applies any overrides
-// from config.yaml (if it exists and is valid).
+// from config.yaml (if it exists and is valid). Unknown keys are logged. A
+// config.yaml that is malformed or has invalid values is logged, with each
+// problem's path, and ignored; `velocipi config check` fails on it instead,
+// and saving refuses to overwrite it. An invalid config.default.yaml is
+// fatal.
 func Load() *LoadResult {
 	var defaults Config
 
@@ -304,21 +466,37 @@ func Load() *LoadResult {
 	if err != nil {
 		log.Fatal("config: read error: ", err)
 	}
+	for _, p := range CheckYAML("config.default.yaml", data) {
+		log.Println("config:", p)
+	}
 	if err := yaml.Unmarshal(data, &defaults); err != nil {
 		log.Fatal("config: parse error: ", err)
 	}
 
+	if problems := validate(&defaults); len(problems) > 0 {
+		for _, p := range problems {
+			log.Println("config: config.default.yaml:", p)
+		}
+		log.Fatal("config: invalid config.default.yaml")
+	}
+
 	// Start with a copy of defaults, then layer overrides on top.
 	cfg := defaults
 	if ovData, err := os.ReadFile(filepath.Join(configDir, "config.yaml")); err == nil {
-		if err := yaml.Unmarshal(ovData, &cfg); err != nil {
-			log.Println("config: ignoring malformed config.yaml:", err)
+		for _, p := range CheckYAML("config.yaml", ovData) {
+			log.Println("config:", p)
+		}
+		merged, problems := mergeOverrides(defaults, ovData)
+		if len(problems) == 0 {
+			cfg = merged
+		} else {
+			for _, p := range problems {
+				log.Println("config: config.yaml:", p)
+			}
+			log.Println("config: ignoring invalid config.yaml; changes can't be saved until it's fixed")
 		}
 	}
 
-	parseDurations(&cfg)
-	parseDurations(&defaults)
-
 	// Storage roots are interpreted relative to the config file's directory (made
 	// absolute) so the data tree travels with the config regardless of the
 	// process working directory. Resolve both cfg and defaults identically so
@@ -338,6 +516,47 @@ func Load() *LoadResult {
 	return &LoadResult{Config: &cfg, Defaults: &defaults}
 }
 
+// Check reads the config files like Load, but returns every problem it
+// finds -- unknown keys, values of the wrong type and invalid values, each
+// with its path -- instead of logging, exiting or skipping a malformed
+// config.yaml. The result is nil if config.default.yaml can't be decoded.
+func Check() (*LoadResult, []error) {
+	var defaults Config
+	data, err := os.ReadFile(filepath.Join(configDir, "config.default.yaml"))
+	if err != nil {
+		return nil, []error{err}
+	}
+	problems := CheckYAML("config.default.yaml", data)
+	if err := yaml.Unmarshal(data, &defaults); err != nil {
+		return nil, append(problems, fmt.Errorf("config.default.yaml: %w", err))
+	}
+	defaultProblems := validate(&defaults)
+	for _, p := range defaultProblems {
+		problems = append(problems, fmt.Errorf("config.default.yaml: %w", p))
+	}
+
+	cfg := defaults
+	ovData, err := os.ReadFile(filepath.Join(configDir, "config.yaml"))
+	switch {
+	case err == nil:
+		problems = append(problems, CheckYAML("config.yaml", ovData)...)
+		if err := yaml.Unmarshal(ovData, &cfg); err != nil {
+			problems = append(problems, fmt.Errorf("config.yaml: %w", err))
+		} else if len(defaultProblems) == 0 {
+			for _, p := range validate(&cfg) {
+				problems = append(problems, fmt.Errorf("config.yaml: %w", p))
+			}
+		}
+	case !os.IsNotExist(err):
+		problems = append(problems, err)
+	}
+
+	absDir := storageDirAbs()
+	resolveStoragePaths(&cfg, absDir)
+	resolveStoragePaths(&defaults, absDir)
+	return &LoadResult{Config: &cfg, Defaults: &defaults}, problems
+}
+
 // storageDirAbs returns the absolute config directory (falling back to the raw
 // configDir if it can't be resolved).
 func storageDirAbs() string {
@@ -347,6 +566,15 @@ func storageDirAbs() string {
 	return configDir
 }
 
+// Path resolves a path given relative to the config directory, leaving
+// absolute and empty paths unchanged.
+func Path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(storageDirAbs(), p)
+}
+
 // ResolveStorage rewrites cfg's storage paths to absolute under the config
 // directory. Use it on a Config received from an external source (e.g. the admin
 // UI, which is shown storage relative to the config -- see RelativizeStorage)
@@ -396,68 +624,164 @@ func resolveStoragePaths(cfg *Config, cfgDir string) {
 	}
 }
 
-func parseDurations(cfg *Config) {
-	cfg.ExpanderIntervalDur = parseDuration(cfg.Hardware.Expander.Interval, "hardware.expander.interval")
-	cfg.AirSensorIntervalDur = parseDuration(cfg.Hardware.AirSensor.Interval, "hardware.airSensor.interval")
-	cfg.LightSensorIntervalDur = parseDuration(cfg.Hardware.LightSensor.Interval, "hardware.lightSensor.interval")
-	cfg.PingIntervalDur = parseDuration(cfg.PingInterval, "pingInterval")
-	cfg.SplashDurationDur = parseDuration(cfg.Hardware.Screen.SplashDuration, "hardware.screen.splashDuration")
-	cfg.DVRDiskSpacePollDur = parseDuration(cfg.DVR.DiskSpacePoll, "dvr.diskSpacePoll")
-	cfg.BrightnessDelayDur = parseDuration(cfg.Brightness.Delay, "brightness.delay")
-	cfg.BrightnessSpeedDur = parseDuration(cfg.Brightness.Speed, "brightness.speed")
+// Validate checks cfg against the schema and fills in its parsed
+// (non-serialized) fields. It's run on every config Load and on every
+// change through a Bus. The error lists every problem, one per line.
+func Validate(cfg *Config) error {
+	return errors.Join(validate(cfg)...)
+}
+
+func validate(cfg *Config) []error {
+	var problems []error
+	// Intervals that drive a time.Ticker must be positive.
+	durations := []struct {
+		s        string
+		field    string
+		dst      *time.Duration
+		positive bool
+	}{
+		{cfg.Hardware.Expander.Interval, "hardware.expander.interval", &cfg.ExpanderIntervalDur, true},
+		{cfg.Hardware.AirSensor.Interval, "hardware.airSensor.interval", &cfg.AirSensorIntervalDur, true},
+		{cfg.Hardware.LightSensor.Interval, "hardware.lightSensor.interval", &cfg.LightSensorIntervalDur, true},
+		{cfg.PingInterval, "pingInterval", &cfg.PingIntervalDur, true},
+		{cfg.Hardware.Screen.SplashDuration, "hardware.screen.splashDuration", &cfg.SplashDurationDur, false},
+		{cfg.DVR.DiskSpacePoll, "dvr.diskSpacePoll", &cfg.DVRDiskSpacePollDur, false},
+		{cfg.Brightness.Delay, "brightness.delay", &cfg.BrightnessDelayDur, false},
+		{cfg.Brightness.Speed, "brightness.speed", &cfg.BrightnessSpeedDur, false},
+		{cfg.Hardware.Thermal.PollInterval, "hardware.thermal.pollInterval", &cfg.ThermalPollIntervalDur, true},
+	}
+	for _, d := range durations {
+		v, err := time.ParseDuration(d.s)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: invalid duration %q", d.field, d.s))
+			continue
+		}
+		if d.positive && v <= 0 {
+			problems = append(problems, fmt.Errorf("%s: %q must be positive", d.field, d.s))
+			continue
+		}
+		*d.dst = v
+	}
 
 	if err := cfg.OLEDSPIFreq.Set(cfg.Hardware.OLED.SPISpeed); err != nil {
-		log.Fatalf("config: invalid hardware.oled.spiSpeed %q: %v", cfg.Hardware.OLED.SPISpeed, err)
+		problems = append(problems, fmt.Errorf("hardware.oled.spiSpeed: invalid frequency %q", cfg.Hardware.OLED.SPISpeed))
 	}
+
+	checkValues(reflect.ValueOf(cfg).Elem(), rootSchema(), "", &problems)
+
+	problems = append(problems, validateGestures(cfg.UI.Gestures.Bindings)...)
+
+	encoders := cfg.Hardware.Expander.Encoders
+	for _, enc := range []struct {
+		name string
+		e    EncoderConfig
+	}{{"inner", encoders.Inner}, {"outer", encoders.Outer}, {"joy", encoders.Joy}} {
+		path, e := "hardware.expander.encoders."+enc.name, enc.e
+		if !slices.Contains([]int{0, 1, 2, 4}, e.TransitionsPerDetent) {
+			problems = append(problems, fmt.Errorf("%s.transitionsPerDetent: %d is not one of 1, 2, 4", path, e.TransitionsPerDetent))
+		}
+		for i := 1; i < len(e.Acceleration); i++ {
+			if e.Acceleration[i].Speed <= e.Acceleration[i-1].Speed {
+				problems = append(problems, fmt.Errorf("%s.acceleration[%d].speed: must be above the previous point's", path, i))
+			}
+		}
+	}
+
+	// Cameras are keyed by name (DVR loops, controllers, URLs).
+	seen := make(map[string]bool)
+	for i, cam := range cfg.DVR.Cameras {
+		key := strings.ToLower(strings.TrimSpace(cam.Name))
+		if key == "" {
+			problems = append(problems, fmt.Errorf("dvr.cameras[%d].name: required", i))
+			continue
+		}
+		if seen[key] {
+			problems = append(problems, fmt.Errorf("dvr.cameras[%d].name: duplicate camera name %q", i, cam.Name))
+		}
+		seen[key] = true
+	}
+	return problems
+}
+
+// gestureTargets lists the valid targets of the actions that take a fixed
+// set; other actions only need one.
+var gestureTargets = map[string][]string{
+	"key":    append(slices.Clone(gesture.Buttons), gesture.Turns...),
+	"music":  {"play", "pause", "playPause", "stop", "next", "prev", "skipForward", "skipBack", "volume"},
+	"led":    {"r", "w", "b", "y"},
+	"aircon": {"setpoint"},
+}
+
+func validateGestures(bindings []GestureBinding) []error {
+	var problems []error
+	seen := make(map[[2]string]bool)
+	for i, b := range bindings {
+		path := fmt.Sprintf("ui.gestures.bindings[%d]", i)
+		name, err := gesture.Normalize(b.Gesture)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s.gesture: %w", path, err))
+			continue
+		}
+		key := [2]string{name, strings.Trim(b.Route, "/")}
+		if seen[key] {
+			problems = append(problems, fmt.Errorf("%s.gesture: %q is already bound on this route", path, b.Gesture))
+		}
+		seen[key] = true
+
+		switch targets := gestureTargets[b.Action]; {
+		case b.Action == "bookmark":
+		case b.Target == "":
+			problems = append(problems, fmt.Errorf("%s.target: required for %s", path, b.Action))
+		case targets != nil && !slices.Contains(targets, b.Target):
+			problems = append(problems, fmt.Errorf("%s.target: %q is not one of %s", path, b.Target, strings.Join(targets, ", ")))
+		}
+		if b.Action == "led" && b.Mode == "" {
+			problems = append(problems, fmt.Errorf("%s.mode: required for led", path))
+		}
+	}
+	return problems
 }
 
 // SaveOverrides writes only the fields that differ from defaults to config.yaml.
+// Prefer Bus.Update, which also applies the change to running subsystems.
 func SaveOverrides(updated, defaults Config) error {
-	uMap := toMap(updated)
-	dMap := toMap(defaults)
-	diff := diffMaps(uMap, dMap)
-	data, err := yaml.Marshal(diff)
+	data, err := overridesYAML(updated, defaults)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(configDir, "config.yaml"), data, 0644)
+	return writeOverrides(data, defaults)
 }
 
-func toMap(v any) map[string]any {
-	b, _ := json.Marshal(v)
-	var m map[string]any
-	_ = json.Unmarshal(b, &m)
-	return m
+// ErrInvalidOverrides is returned by a save while config.yaml on disk is
+// invalid. Load ran on the defaults instead, so writing would replace the
+// user's settings with overrides built from them.
+var ErrInvalidOverrides = errors.New("config.yaml is invalid; fix it before saving changes")
+
+// mergeOverrides layers config.yaml's contents over defaults, returning the
+// result and any parse or validation problems with it.
+func mergeOverrides(defaults Config, data []byte) (Config, []error) {
+	cfg := defaults
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return defaults, []error{err}
+	}
+	return cfg, validate(&cfg)
 }
 
-func diffMaps(override, defaults map[string]any) map[string]any {
-	result := map[string]any{}
-	for k, ov := range override {
-		dv, ok := defaults[k]
-		if !ok {
-			result[k] = ov
-			continue
-		}
-		if om, ok2 := ov.(map[string]any); ok2 {
-			if dm, ok3 := dv.(map[string]any); ok3 {
-				sub := diffMaps(om, dm)
-				if len(sub) > 0 {
-					result[k] = sub
-				}
-				continue
-			}
-		}
-		if !reflect.DeepEqual(ov, dv) {
-			result[k] = ov
+// writeOverrides replaces config.yaml with data, unless the current file
+// doesn't load over defaults.
+func writeOverrides(data []byte, defaults Config) error {
+	path := filepath.Join(configDir, "config.yaml")
+	if cur, err := os.ReadFile(path); err == nil {
+		if _, problems := mergeOverrides(defaults, cur); len(problems) > 0 {
+			return fmt.Errorf("config: %w: %v", ErrInvalidOverrides, problems[0])
 		}
 	}
-	return result
+	return os.WriteFile(path, data, 0644)
 }
 
-func parseDuration(s, field string) time.Duration {
-	d, err := time.ParseDuration(s)
-	if err != nil {
-		log.Fatalf("config: invalid %s %q: %v", field, s, err)
-	}
-	return d
+func toMap(v any) map[string]any {
+	b, _ := json.Marshal(v)
+	var m map[string]any
+	_ = json.Unmarshal(b, &m)
+	return m
 }
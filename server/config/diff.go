@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change is one setting that differs between two configs.
+type Change struct {
+	Path string `json:"path"` // dotted key, e.g. "hardware.oled.driver"
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// Diff returns the settings that differ between from and to, in the order
+// they're declared. Structs are compared key by key; anything else,
+// including lists, is a single setting. Parsed (unserialized) fields are
+// ignored.
+func Diff(from, to Config) []Change {
+	var out []Change
+	diffValues(reflect.ValueOf(from), reflect.ValueOf(to), "", &out)
+	return out
+}
+
+func diffValues(a, b reflect.Value, path string, out *[]Change) {
+	if a.Kind() == reflect.Struct {
+		t := a.Type()
+		for i := range t.NumField() {
+			if name := yamlName(t.Field(i)); name != "" {
+				diffValues(a.Field(i), b.Field(i), joinPath(path, name), out)
+			}
+		}
+		return
+	}
+	// A missing list and an empty one are the same setting.
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*out = append(*out, Change{Path: path, Old: a.Interface(), New: b.Interface()})
+	}
+}
+
+// overridesYAML returns the config.yaml contents for updated: only the
+// settings that differ from defaults.
+func overridesYAML(updated, defaults Config) ([]byte, error) {
+	overrides := map[string]any{}
+	for _, c := range Diff(defaults, updated) {
+		m := overrides
+		keys := strings.Split(c.Path, ".")
+		for _, k := range keys[:len(keys)-1] {
+			sub, ok := m[k].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				m[k] = sub
+			}
+			m = sub
+		}
+		m[keys[len(keys)-1]] = c.New
+	}
+	return yaml.Marshal(overrides)
+}
+
+// Value returns the setting at the dotted path in cfg, e.g. "music.volume"
+// or "dvr.cameras.0.name".
+func Value(cfg Config, path string) (any, bool) {
+	v := reflect.ValueOf(cfg)
+	if path == "" {
+		return cfg, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := structField(v, key)
+			if !ok {
+				return nil, false
+			}
+			v = field
+		case reflect.Slice:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= v.Len() {
+				return nil, false
+			}
+			v = v.Index(i)
+		default:
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
+
+func structField(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		if yamlName(t.Field(i)) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
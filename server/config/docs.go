@@ -0,0 +1,179 @@
+// Code generated by gen_docs.go; DO NOT EDIT.
+
+package config
+
+// fieldDocs holds the doc comments of the config structs, keyed by
+// "Type" and "Type.Field".
+var fieldDocs = map[string]string{
+	"AccelPoint":                         "AccelPoint is one point of an encoder's acceleration curve.",
+	"AccelPoint.Speed":                   "detents per second",
+	"AccelPoint.Steps":                   "steps per detent at that speed",
+	"AirConConfig":                       "AirConConfig holds settings for the aircon state/command relay (hardware/aircon) -- transport is the knob's serial link (see KnobConfig); the aircon subsystem is enabled/disabled by whether the knob itself is configured, not by anything here.",
+	"AirConConfig.HistoryMinutes":        "HistoryMinutes is how many minutes of temperature data to keep in memory.",
+	"AirConConfig.SampleIntervalSecs":    "SampleIntervalSecs is how often a temperature sample is recorded. Defaults to 10.",
+	"AuthConfig":                         "AuthConfig controls login and the roles that gate the API. Roles are \"viewer\" (read-only), \"passenger\" (music only), \"pilot\" and \"admin\".",
+	"AuthConfig.AnonymousRole":           "role without a session; \"\" = none (login required)",
+	"AuthConfig.KeyFile":                 "session signing key, created if missing; relative to the config dir",
+	"AuthConfig.LocalRole":               "role for unproxied loopback requests (panel, intercom-stt /music/duck)",
+	"AuthConfig.SessionHours":            "how long a login lasts",
+	"AutoDJConfig":                       "AutoDJConfig tunes auto-DJ, which keeps the queue topped up from a seed (artist, genre, decade, smart search or playlist) once turned on.",
+	"AutoDJConfig.AvoidHours":            "don't repeat songs played this recently (unless nothing else is left)",
+	"AutoDJConfig.Batch":                 "songs appended per top-up",
+	"AutoDJConfig.MinQueue":              "top up when fewer songs than this are left",
+	"AxisConfig":                         "AxisConfig holds settings for the Axis (formerly G3X) avionics module. Currently unused by hardware/axis, which only generates mock data -- present here so the config key isn't silently swallowed once a real serial/UDP/BT feed replaces the mock.",
+	"BrightnessConfig":                   "BrightnessConfig holds settings for the ambient-light-driven brightness engine (hardware/brightness), shared by every subscriber (LCD, knob, ...).",
+	"BrightnessConfig.Delay":             "debounce/average window, e.g. \"2s\"",
+	"BrightnessConfig.MaxLux":            "lux at/above which brightness is 100%",
+	"BrightnessConfig.MinLux":            "lux at/below which brightness is 0%",
+	"BrightnessConfig.Speed":             "ramp duration when the target percentage changes, e.g. \"2s\"",
+	"CameraConfig":                       "CameraConfig holds connection parameters for a single IP camera.",
+	"CameraConfig.Audio":                 "record and stream audio (default false)",
+	"CameraConfig.Driver":                "\"rtsp\" (default/empty), \"siyi\", or \"onvif\"",
+	"CameraConfig.ONVIFURL":              "ONVIF device service URL and media profile token for driver \"onvif\" (PTZ control); filled in by the /camera/add API from discovery.",
+	"CameraConfig.Path":                  "RTSP path, e.g. \"/live/main\"; empty = \"/\"",
+	"CameraConfig.Presets":               "named gimbal positions",
+	"CameraConfig.Record":                "nil or true = record; false = skip",
+	"CameraConfig.Retention":             "nil = use dvr.retention",
+	"CameraConfig.SiyiAIHost":            "IP of AI tracking module; empty = disabled",
+	"CameraPreset":                       "CameraPreset is a named gimbal position, e.g. \"left wing\", \"gear\" or \"tail\".",
+	"CameraPreset.Pitch":                 "degrees, positive = up",
+	"CameraPreset.Yaw":                   "degrees relative to the airframe, positive = right",
+	"CameraPreset.Zoom":                  "zoom factor; 0 = leave unchanged",
+	"Config":                             "Config holds all runtime configuration.",
+	"Config.Addr":                        "HTTP listen address",
+	"Config.AppURL":                      "Parsed values — not serialized, populated by Load()",
+	"Config.PingInterval":                "websocket ping interval",
+	"Config.TailNumber":                  "aircraft identifier shown in the UI",
+	"DVRConfig":                          "DVRConfig holds settings for the DVR recording subsystem.",
+	"DVRConfig.DiskSpacePoll":            "how often to poll disk space (and apply retention), e.g. \"1m\"",
+	"DVRConfig.FFmpegLog":                "pipe ffmpeg stderr to server log",
+	"DVRConfig.MinFreeDisk":              "minimum free disk space in GiB (1024³ bytes); 0 = disabled",
+	"DVRConfig.Record":                   "enable recording on startup (default true)",
+	"DVRConfig.Retention":                "default policy; cameras may override",
+	"DVRConfig.SegmentDuration":          "seconds",
+	"DVRConfig.ThumbnailHeight":          "px height for snapshot + segment thumbnails",
+	"DuckConfig":                         "DuckConfig controls how music reacts to radio traffic reported by the intercom-stt process. Our own PTT always pauses playback.",
+	"DuckConfig.HangoverSec":             "hold the duck this long after traffic stops",
+	"DuckConfig.Level":                   "% of the normal volume while ducked",
+	"DuckConfig.Mode":                    "\"volume\" (lower to Level), \"pause\", or \"off\"",
+	"DuckConfig.RampSec":                 "volume ramp time in and out of the duck",
+	"EncoderConfig":                      "EncoderConfig tunes how one encoder's quadrature turns into steps.",
+	"EncoderConfig.Acceleration":         "steps per detent by speed, interpolated; empty = always 1",
+	"EncoderConfig.Invert":               "swap left and right",
+	"EncoderConfig.TransitionsPerDetent": "quadrature transitions between detents: 1, 2 or 4; 0 = 2",
+	"EncodersConfig":                     "EncodersConfig tunes the panel's rotary encoders.",
+	"ExpanderBits":                       "ExpanderBits maps each panel input and LED to its pin (0-15) on the I/O expander.",
+	"ExpanderBits.JoyKnobA":              "quadrature A",
+	"ExpanderBits.JoyKnobB":              "quadrature B",
+	"ExpanderBits.KnobInnerA":            "quadrature A",
+	"ExpanderBits.KnobInnerB":            "quadrature B",
+	"ExpanderBits.KnobOuterA":            "quadrature A",
+	"ExpanderBits.KnobOuterB":            "quadrature B",
+	"ExpanderConfig":                     "ExpanderConfig holds settings for the panel's I/O expander.",
+	"ExpanderConfig.Address":             "I2C address",
+	"ExpanderConfig.GPIOChip":            "gpiochip device for the interrupt pin",
+	"ExpanderConfig.InterruptPin":        "GPIO pin wired to the expander's INTA/INTB; 0 = poll instead",
+	"ExpanderConfig.Interval":            "input poll interval when interrupts aren't wired, e.g. \"2ms\"",
+	"GestureBinding":                     "GestureBinding binds one gesture to an action, on every panel page or only on the pages under Route. A binding for a Route replaces the everywhere binding of the same gesture.",
+	"GestureBinding.Action":              "what the gesture does",
+	"GestureBinding.Gesture":             "e.g. \"enter.long\", \"up.double\", \"up+left\", \"enter+inner-right\", \"outer-left\"",
+	"GestureBinding.Mode":                "LED pattern to show, \"toggle\", or \"clear\"",
+	"GestureBinding.Route":               "panel page relative to /panel/, e.g. \"music\"; \"\" = every page",
+	"GestureBinding.Target":              "key name, music command, LED channel (r/w/b/y), camera name or \"next\"/\"prev\", page path, or \"setpoint\"",
+	"GestureBinding.Value":               "music volume or skip step, setpoint step, or LED pattern rate in ms",
+	"GestureConfig":                      "GestureConfig binds panel input gestures to actions. Buttons and knobs that aren't part of a bound gesture keep sending their keyMap keys.",
+	"GestureConfig.DoublePressMs":        "ms allowed between the taps of a double press",
+	"GestureConfig.LongPressMs":          "ms hold for a long press",
+	"HardwareConfig":                     "HardwareConfig groups all the physical-hardware wiring config: bus devices, the shared reset pin, and each attached peripheral.",
+	"HardwareConfig.I2CDevice":           "I2C bus for the sensors and expander",
+	"HardwareConfig.ResetPin":            "shared hardware reset GPIO pin; 0 = disabled",
+	"HardwareConfig.SPIDevice":           "SPI bus for the OLED",
+	"KeyMapConfig":                       "KeyMapConfig maps logical key names to the JS key values used in DOM events.",
+	"KnobConfig":                         "KnobConfig holds settings for the AC control knob's serial connection.",
+	"KnobConfig.Device":                  "serial device path",
+	"KnobConfig.MaxBrightness":           "0-100, ceiling",
+	"KnobConfig.MinBrightness":           "0-100, floor",
+	"LCDConfig":                          "LCDConfig holds settings for the Pi's own built-in LCD backlight.",
+	"LCDConfig.Device":                   "sysfs backlight device path; empty = hardware/lcd's own default",
+	"LCDConfig.MaxBrightness":            "raw device units; 0 = read from sysfs max_brightness",
+	"LCDConfig.MinBrightness":            "raw device units, floor",
+	"LoadResult":                         "LoadResult holds both the effective merged config and the raw defaults.",
+	"LoadResult.Config":                  "effective merged config (defaults + overrides)",
+	"LoadResult.Defaults":                "values from config.default.yaml only",
+	"MusicConfig":                        "MusicConfig holds settings for the music player subsystem.",
+	"MusicConfig.AcoustIDKey":            "AcoustID API key (register free at acoustid.org)",
+	"MusicConfig.AcoustIDMinScore":       "minimum AcoustID match score (0.0–1.0) to accept a result",
+	"MusicConfig.AudioDevice":            "mpv --audio-device value; \"auto\" = let mpv choose",
+	"MusicConfig.CrossfadeSec":           "crossfade overlap; only used when transition is \"crossfade\"",
+	"MusicConfig.LyricsURL":              "LRCLIB-compatible lyrics API used by musicsync -lyrics; \"\" = off",
+	"MusicConfig.MaxBitrate":             "kbps; 0 = no limit",
+	"MusicConfig.PlayedRequiredPercent":  "% elapsed before a skip counts as a play",
+	"MusicConfig.ReplayGain":             "loudness normalisation: \"track\", \"album\" or \"off\"",
+	"MusicConfig.ReplayGainPreamp":       "dB added to the ReplayGain adjustment (reference is -18 LUFS)",
+	"MusicConfig.Scrobble":               "queue plays for export via /music/scrobbles",
+	"MusicConfig.TranscodeFormat":        "e.g. \"aac\", \"mp3\"",
+	"MusicConfig.Transition":             "\"gapless\" (default) or \"crossfade\"",
+	"MusicConfig.Zones":                  "independent outputs; empty = one \"main\" zone on audioDevice",
+	"NavMenuConfig":                      "NavMenuConfig holds display settings for the panel navigation menu.",
+	"NavMenuConfig.CellWidth":            "px",
+	"NavMenuConfig.HideDelay":            "ms",
+	"NavMenuConfig.LongPressMs":          "ms hold for long-press cancel",
+	"OLEDConfig":                         "OLEDConfig holds settings for the panel's OLED display.",
+	"OLEDConfig.Driver":                  "\"ssd1327\" or \"ge256x64b\"",
+	"OLEDConfig.Flip":                    "rotate the image 180°",
+	"OLEDConfig.GPIOChip":                "gpiochip device for the status and reset pins",
+	"OLEDConfig.ResetPin":                "display reset GPIO pin; 0 = none",
+	"OLEDConfig.SPISpeed":                "SPI clock, e.g. \"2.40MHz\"",
+	"OLEDConfig.StatusPin":               "data/command (SSD1327) or busy (GE256X64B) GPIO pin",
+	"PanelConfig":                        "PanelConfig holds the physical dimensions and color scheme of the OLED panel display.",
+	"PanelConfig.ActiveBackground":       "active (editing) control background",
+	"PanelConfig.ActiveBorder":           "active control border",
+	"PanelConfig.ActiveText":             "active control text",
+	"PanelConfig.ControlBackground":      "default control background",
+	"PanelConfig.ControlBorder":          "default control border",
+	"PanelConfig.ControlText":            "default control text",
+	"PanelConfig.HomeTimezone":           "IANA tz for \"Home\" clock",
+	"PanelConfig.SelectedBackground":     "focused (selected) control background",
+	"PanelConfig.SelectedBorder":         "focused control border",
+	"PanelConfig.SelectedText":           "focused control text",
+	"PanelConfig.TimeFormat":             "dayjs format string e.g. \"hh:mm:ssa\", \"HH:mm:ss\"",
+	"RetentionConfig":                    "RetentionConfig holds the DVR retention policy applied to each camera's recordings. Every rule is disabled by its zero value. Protected sessions and locked recordings are never touched.",
+	"RetentionConfig.MaxAgeDays":         "delete recordings older than this",
+	"RetentionConfig.MaxSizeGB":          "per-camera cap on total size in GiB (1024³ bytes); oldest deleted first",
+	"RetentionConfig.ShrinkBitrate":      "video kbps; 0 = constant-quality encode",
+	"RetentionConfig.ShrinkDays":         "transcode to shrinkHeight/shrinkBitrate after this many days",
+	"RetentionConfig.ShrinkHeight":       "px; 0 = keep resolution",
+	"RetentionConfig.ThumbsOnlyDays":     "delete the video but keep thumbnails after this many days",
+	"ScreenConfig":                       "ScreenConfig holds settings for rendering the panel UI to the OLED.",
+	"ScreenConfig.FPS":                   "screencast frame rate",
+	"ScreenConfig.SplashDuration":        "how long the splash image stays up",
+	"ScreenConfig.SplashImage":           "shown on the OLED at startup",
+	"SensorConfig":                       "SensorConfig holds settings for an I2C sensor.",
+	"SensorConfig.Address":               "I2C address",
+	"SensorConfig.Interval":              "sample interval, e.g. \"1s\"",
+	"StorageConfig":                      "StorageConfig holds filesystem directory paths for all subsystems.",
+	"StorageConfig.Backup":               "database backup directory; default \"backup\"",
+	"StorageConfig.DVR":                  "recordings directory; default \"recordings\"",
+	"StorageConfig.LiveATC":              "liveatc audio/transcripts root (used by the intercom-stt process)",
+	"StorageConfig.Music":                "music library root; default \"music\"",
+	"StorageConfig.Snaps":                "downloaded camera snaps/photos; default \"snaps\"",
+	"ThermalConfig":                      "ThermalConfig holds settings for the thermal camera serial interface.",
+	"ThermalConfig.Device":               "Device is the path to the serial device (e.g. \"/dev/ttyUSB0\"). If empty, the thermal camera subsystem is disabled.",
+	"ThermalConfig.FFCTempDelta":         "run a flat-field calibration when the air temperature moves this many °C; 0 = off",
+	"ThermalConfig.PollInterval":         "how often the camera's state is re-read, e.g. \"30s\"",
+	"ThermalConfig.Presets":              "named image settings",
+	"ThermalPreset":                      "ThermalPreset is a named set of thermal camera image settings, e.g. \"night\" or \"ground\".",
+	"ThermalPreset.Mirroring":            "\"none\", \"center\", \"left-right\" or \"up-down\"",
+	"ThermalPreset.Palette":              "e.g. \"white-hot\", \"iron-red-1\"",
+	"ThermalPreset.ShutterIntervalMin":   "minutes between automatic FFCs",
+	"ThermalPreset.ShutterMode":          "\"off\", \"timing\", \"temp-delta\" or \"full-auto\"",
+	"TireAddresses":                      "TireAddresses maps one or more BT addresses to a wheel position label.",
+	"UIConfig":                           "UIConfig holds the subset of config sent to the browser UI via /config.",
+	"UserConfig":                         "UserConfig is one login. Hash is a PIN or password hashed with `go run ./cmd/passwd`.",
+	"ZoneConfig":                         "ZoneConfig is one audio zone: an output with its own queue, mpv instance and volume, e.g. the pilot's intercom input and a cabin Bluetooth speaker.",
+	"ZoneConfig.AudioDevice":             "mpv --audio-device value; \"\" = music.audioDevice",
+	"ZoneConfig.ID":                      "used in the API and state keys",
+	"ZoneConfig.IgnoreDuck":              "keep playing through radio traffic and PTT",
+	"ZoneConfig.Name":                    "display name; defaults to ID",
+	"ZoneConfig.Volume":                  "initial volume; 0 = music.volume",
+}
@@ -0,0 +1,94 @@
+//go:build ignore
+
+// gen_docs extracts the doc comments of the config structs in config.go into
+// docs.go, where JSONSchema picks them up as descriptions. Run it with
+// `go generate ./config` after changing a struct comment.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config.go", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	docs := map[string]string{}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil {
+				doc = gd.Doc
+			}
+			if s := clean(doc); s != "" {
+				docs[ts.Name.Name] = s
+			}
+			for _, field := range st.Fields.List {
+				doc := field.Doc
+				if doc == nil {
+					doc = field.Comment
+				}
+				s := clean(doc)
+				if s == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					docs[ts.Name.Name+"."+name.Name] = s
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_docs.go; DO NOT EDIT.\n\npackage config\n\n")
+	buf.WriteString("// fieldDocs holds the doc comments of the config structs, keyed by\n")
+	buf.WriteString("// \"Type\" and \"Type.Field\".\n")
+	buf.WriteString("var fieldDocs = map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "\t%q: %q,\n", k, docs[k])
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("docs.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// clean joins a comment group into a single line.
+func clean(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
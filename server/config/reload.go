@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrRestartRequired is returned by an apply function for a change it can't
+// make live. The change is still saved and takes effect on the next start.
+var ErrRestartRequired = errors.New("restart required")
+
+// watchInterval is how often Watch checks config.yaml for edits on disk.
+const watchInterval = 2 * time.Second
+
+// Result reports what a config change did.
+type Result struct {
+	Applied []string `json:"applied"` // sections changed live
+	Restart []string `json:"restart"` // changed sections or keys that need a restart
+}
+
+// section is a registered apply function, type-erased by Register.
+type section struct {
+	path    string // dotted JSON path, e.g. "hardware.knob"
+	changed func(old, new *Config) bool
+	apply   func(old, new *Config) error
+}
+
+// Bus distributes config changes to the subsystems that registered for
+// them. Every change goes through the same steps: validate the whole
+// config, apply each changed section in registration order (rolling back
+// the ones already applied if one fails), save config.yaml and finally
+// swap the shared *Config. Keys no section covers are reported as needing
+// a restart.
+type Bus struct {
+	mu       sync.Mutex
+	cfg      *Config
+	defaults *Config
+	sections []section
+	written  []byte // config.yaml as last saved or loaded, so Watch ignores our own writes
+}
+
+// NewBus creates a Bus for the shared config cfg, as returned by Load.
+func NewBus(cfg, defaults *Config) *Bus {
+	b := &Bus{cfg: cfg, defaults: defaults}
+	b.written, _ = os.ReadFile(filepath.Join(configDir, "config.yaml"))
+	return b
+}
+
+// Register adds an apply function for the section at path (dotted JSON
+// keys, e.g. "brightness" or "hardware.knob"). get extracts the section's
+// value; apply is called with the old and new values whenever they differ,
+// and again with them swapped to roll back if a later section fails. Apply
+// functions should return ErrRestartRequired (after applying what they can)
+// for changes that need a restart.
+func Register[T any](b *Bus, path string, get func(*Config) T, apply func(old, new T) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sections = append(b.sections, section{
+		path: path,
+		changed: func(old, new *Config) bool {
+			return !reflect.DeepEqual(get(old), get(new))
+		},
+		apply: func(old, new *Config) error {
+			return apply(get(old), get(new))
+		},
+	})
+}
+
+// Update validates, applies and saves a new config.
+func (b *Bus) Update(updated Config) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.change(updated, true)
+}
+
+// change applies updated, saving it to config.yaml if save is set. b.mu
+// must be held.
+func (b *Bus) change(updated Config, save bool) (Result, error) {
+	if err := Validate(&updated); err != nil {
+		return Result{}, err
+	}
+	updated.AppURL = b.cfg.AppURL
+	old := *b.cfg
+
+	res := Result{Applied: []string{}, Restart: []string{}}
+	var done []section
+	for _, s := range b.sections {
+		if !s.changed(&old, &updated) {
+			continue
+		}
+		err := s.apply(&old, &updated)
+		switch {
+		case err == nil:
+			res.Applied = appendUnique(res.Applied, s.path)
+		case errors.Is(err, ErrRestartRequired):
+			res.Restart = appendUnique(res.Restart, s.path)
+		default:
+			rollback(done, &old, &updated)
+			return Result{}, fmt.Errorf("config: apply %s: %w", s.path, err)
+		}
+		done = append(done, s)
+	}
+	for _, c := range Diff(old, updated) {
+		if !b.covered(c.Path) {
+			res.Restart = appendUnique(res.Restart, c.Path)
+		}
+	}
+
+	if save {
+		data, err := overridesYAML(updated, *b.defaults)
+		if err == nil {
+			err = writeOverrides(data, *b.defaults)
+		}
+		if err != nil {
+			rollback(done, &old, &updated)
+			return Result{}, err
+		}
+		b.written = data
+	}
+	*b.cfg = updated
+	sort.Strings(res.Restart)
+	return res, nil
+}
+
+// rollback re-applies the old values of already-applied sections, newest
+// first. Failures are logged; there's nothing better to do with them.
+func rollback(done []section, old, updated *Config) {
+	for i := len(done) - 1; i >= 0; i-- {
+		if err := done[i].apply(updated, old); err != nil && !errors.Is(err, ErrRestartRequired) {
+			log.Printf("config: rollback %s: %v", done[i].path, err)
+		}
+	}
+}
+
+// covered reports whether a registered section includes the key at path.
+func (b *Bus) covered(path string) bool {
+	for _, s := range b.sections {
+		if path == s.path || strings.HasPrefix(path, s.path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads config.yaml when it's edited on disk, applying the change
+// as Update would (without re-saving it). Blocks until ctx is cancelled.
+func (b *Bus) Watch(ctx context.Context) {
+	path := filepath.Join(configDir, "config.yaml")
+	var lastMod time.Time
+	if fi, err := os.Stat(path); err == nil {
+		lastMod = fi.ModTime()
+	}
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var mod time.Time
+		if fi, err := os.Stat(path); err == nil {
+			mod = fi.ModTime()
+		}
+		if mod.Equal(lastMod) {
+			continue
+		}
+		lastMod = mod
+		b.reloadFile(path)
+	}
+}
+
+// reloadFile applies config.yaml's current contents if they differ from
+// what was last saved or loaded.
+func (b *Bus) reloadFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("config: reload:", err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bytes.Equal(data, b.written) {
+		return
+	}
+	for _, p := range CheckYAML("config.yaml", data) {
+		log.Println("config:", p)
+	}
+	updated := *b.defaults
+	if err := yaml.Unmarshal(data, &updated); err != nil {
+		log.Println("config: ignoring malformed config.yaml:", err)
+		return
+	}
+	resolveStoragePaths(&updated, storageDirAbs())
+	res, err := b.change(updated, false)
+	if err != nil {
+		log.Println("config: reload:", err)
+		return
+	}
+	b.written = data
+	log.Printf("config: reloaded config.yaml: applied %v, restart required for %v", res.Applied, res.Restart)
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useDir points the package at config dir dir for the rest of the test.
+func useDir(t *testing.T, dir string) {
+	old := configDir
+	SetDir(dir)
+	t.Cleanup(func() { configDir = old })
+}
+
+// testBus returns a Bus over a minimal valid config in a temp config dir.
+func testBus(t *testing.T) (*Bus, *Config) {
+	t.Helper()
+	useDir(t, t.TempDir())
+	var defaults Config
+	defaults.PingInterval = "5s"
+	defaults.Hardware.Expander.Interval = "10ms"
+	defaults.Hardware.AirSensor.Interval = "1s"
+	defaults.Hardware.LightSensor.Interval = "1s"
+	defaults.Hardware.Screen.SplashDuration = "1s"
+	defaults.Hardware.Screen.FPS = 30
+	defaults.Hardware.OLED.SPISpeed = "2MHz"
+	defaults.DVR.DiskSpacePoll = "1m"
+	defaults.Brightness.Delay = "2s"
+	defaults.Brightness.Speed = "2s"
+	defaults.Hardware.Thermal.PollInterval = "30s"
+	defaults.UI.Panel.Width = 256
+	defaults.UI.Panel.Height = 64
+	if err := Validate(&defaults); err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaults
+	return NewBus(&cfg, &defaults), &cfg
+}
+
+func TestBusUpdate(t *testing.T) {
+	b, cfg := testBus(t)
+	var applied []float64
+	Register(b, "brightness", func(c *Config) BrightnessConfig { return c.Brightness },
+		func(_, new BrightnessConfig) error {
+			applied = append(applied, new.MinLux)
+			return nil
+		})
+	Register(b, "dvr", func(c *Config) DVRConfig { return c.DVR },
+		func(_, _ DVRConfig) error { return ErrRestartRequired })
+
+	updated := *cfg
+	updated.Brightness.MinLux = 5
+	updated.Brightness.Speed = "500ms"
+	updated.DVR.ThumbnailHeight = 90
+	updated.Hardware.OLED.Driver = "ssd1327"
+	res, err := b.Update(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Applied, []string{"brightness"}) {
+		t.Errorf("applied = %v", res.Applied)
+	}
+	if !slices.Equal(res.Restart, []string{"dvr", "hardware.oled.driver"}) {
+		t.Errorf("restart = %v", res.Restart)
+	}
+	if !slices.Equal(applied, []float64{5}) {
+		t.Errorf("apply calls = %v", applied)
+	}
+	if cfg.Brightness.MinLux != 5 || cfg.BrightnessSpeedDur.String() != "500ms" {
+		t.Errorf("shared config not updated: %+v %v", cfg.Brightness, cfg.BrightnessSpeedDur)
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "config.yaml"))
+	if err != nil || !strings.Contains(string(data), "minLux: 5") || strings.Contains(string(data), "pingInterval") {
+		t.Errorf("config.yaml = %q, %v", data, err)
+	}
+
+	// Unchanged sections aren't applied again.
+	if _, err := b.Update(*cfg); err != nil || len(applied) != 1 {
+		t.Errorf("no-op update: %v, apply calls = %v", err, applied)
+	}
+}
+
+func TestBusRollback(t *testing.T) {
+	b, cfg := testBus(t)
+	var calls []string
+	Register(b, "brightness", func(c *Config) float64 { return c.Brightness.MinLux },
+		func(old, new float64) error {
+			if new == 7 {
+				calls = append(calls, "apply")
+			} else {
+				calls = append(calls, "rollback")
+			}
+			return nil
+		})
+	Register(b, "tailNumber", func(c *Config) string { return c.TailNumber },
+		func(_, _ string) error { return errors.New("no") })
+
+	updated := *cfg
+	updated.Brightness.MinLux = 7
+	updated.TailNumber = "N123"
+	if _, err := b.Update(updated); err == nil {
+		t.Fatal("update with a failing section succeeded")
+	}
+	if !slices.Equal(calls, []string{"apply", "rollback"}) {
+		t.Errorf("calls = %v", calls)
+	}
+	if cfg.TailNumber != "" || cfg.Brightness.MinLux != 0 {
+		t.Error("shared config changed by a failed update")
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "config.yaml")); !os.IsNotExist(err) {
+		t.Error("config.yaml written by a failed update")
+	}
+
+	updated = *cfg
+	updated.Brightness.Delay = "soon"
+	if _, err := b.Update(updated); err == nil || len(calls) != 2 {
+		t.Errorf("invalid duration: %v, calls = %v", err, calls)
+	}
+}
+
+func TestBusReloadFile(t *testing.T) {
+	b, cfg := testBus(t)
+	var tails []string
+	Register(b, "tailNumber", func(c *Config) string { return c.TailNumber },
+		func(_, new string) error {
+			tails = append(tails, new)
+			return nil
+		})
+
+	path := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(path, []byte("tailNumber: N42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b.reloadFile(path)
+	b.reloadFile(path) // unchanged contents are ignored
+	if cfg.TailNumber != "N42" || !slices.Equal(tails, []string{"N42"}) {
+		t.Errorf("tail = %q, applies = %v", cfg.TailNumber, tails)
+	}
+
+	// Our own writes aren't reloaded.
+	updated := *cfg
+	updated.TailNumber = "N7"
+	if _, err := b.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	b.reloadFile(path)
+	if !slices.Equal(tails, []string{"N42", "N7"}) {
+		t.Errorf("applies = %v", tails)
+	}
+
+	os.WriteFile(path, []byte("brightness: {delay: later}\n"), 0644)
+	b.reloadFile(path)
+	if cfg.TailNumber != "N7" {
+		t.Error("invalid config.yaml applied")
+	}
+}
@@ -0,0 +1,536 @@
+package config
+
+//go:generate go run gen_docs.go
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a JSON Schema (draft 2020-12) for the config, generated from
+// the Config struct by JSONSchema. Descriptions are the struct comments
+// (see gen_docs.go); units, ranges and enums come from rules.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Unit                 string             `json:"x-unit,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+
+	keys []string // property names in struct order
+}
+
+// rule constrains a config field beyond what its Go type says.
+type rule struct {
+	enum     []string
+	min, max *float64
+	unit     string
+	format   string // "duration" or "frequency"
+}
+
+func num(f float64) *float64 { return &f }
+
+var (
+	percent = rule{min: num(0), max: num(100), unit: "%"}
+	roles   = []string{"viewer", "passenger", "pilot", "admin"}
+)
+
+// rules holds the constraints for each field, keyed by "Type.Field".
+var rules = map[string]rule{
+	"Config.PingInterval":               {format: "duration"},
+	"ExpanderConfig.Interval":           {format: "duration"},
+	"AccelPoint.Speed":                  {min: num(0), unit: "detents/s"},
+	"AccelPoint.Steps":                  {min: num(0)},
+	"SensorConfig.Interval":             {format: "duration"},
+	"ScreenConfig.SplashDuration":       {format: "duration"},
+	"ScreenConfig.FPS":                  {min: num(1), max: num(60), unit: "fps"},
+	"DVRConfig.DiskSpacePoll":           {format: "duration"},
+	"BrightnessConfig.Delay":            {format: "duration"},
+	"BrightnessConfig.Speed":            {format: "duration"},
+	"BrightnessConfig.MinLux":           {min: num(0), unit: "lx"},
+	"BrightnessConfig.MaxLux":           {min: num(0), unit: "lx"},
+	"OLEDConfig.Driver":                 {enum: []string{"", "ssd1327", "ge256x64b"}},
+	"OLEDConfig.SPISpeed":               {format: "frequency"},
+	"KnobConfig.MinBrightness":          percent,
+	"KnobConfig.MaxBrightness":          percent,
+	"LCDConfig.MinBrightness":           {min: num(0)},
+	"LCDConfig.MaxBrightness":           {min: num(0)},
+	"CameraConfig.Driver":               {enum: []string{"", "rtsp", "siyi", "onvif"}},
+	"CameraConfig.Port":                 {min: num(0), max: num(65535)},
+	"CameraPreset.Yaw":                  {min: num(-180), max: num(180), unit: "°"},
+	"CameraPreset.Pitch":                {min: num(-90), max: num(90), unit: "°"},
+	"CameraPreset.Zoom":                 {min: num(0), unit: "×"},
+	"DVRConfig.SegmentDuration":         {min: num(0), unit: "s"},
+	"DVRConfig.ThumbnailHeight":         {min: num(0), unit: "px"},
+	"DVRConfig.MinFreeDisk":             {min: num(0), unit: "GB"},
+	"RetentionConfig.MaxAgeDays":        {min: num(0), unit: "d"},
+	"RetentionConfig.MaxSizeGB":         {min: num(0), unit: "GB"},
+	"RetentionConfig.ThumbsOnlyDays":    {min: num(0), unit: "d"},
+	"RetentionConfig.ShrinkDays":        {min: num(0), unit: "d"},
+	"RetentionConfig.ShrinkHeight":      {min: num(0), unit: "px"},
+	"RetentionConfig.ShrinkBitrate":     {min: num(0), unit: "kbps"},
+	"MusicConfig.Volume":                percent,
+	"MusicConfig.Transition":            {enum: []string{"", "gapless", "crossfade"}},
+	"MusicConfig.CrossfadeSec":          {min: num(0), unit: "s"},
+	"MusicConfig.ReplayGain":            {enum: []string{"", "track", "album", "off"}},
+	"MusicConfig.ReplayGainPreamp":      {min: num(-20), max: num(20), unit: "dB"},
+	"MusicConfig.AlbumRequiredPercent":  percent,
+	"MusicConfig.MaxBitrate":            {min: num(0), unit: "kbps"},
+	"MusicConfig.TranscodeFormat":       {enum: []string{"", "aac", "mp3", "opus", "flac"}},
+	"MusicConfig.PlayedRequiredPercent": percent,
+	"MusicConfig.AcoustIDMinScore":      {min: num(0), max: num(1)},
+	"DuckConfig.Mode":                   {enum: []string{"", "volume", "pause", "off"}},
+	"DuckConfig.Level":                  percent,
+	"DuckConfig.HangoverSec":            {min: num(0), unit: "s"},
+	"DuckConfig.RampSec":                {min: num(0), unit: "s"},
+	"AutoDJConfig.MinQueue":             {min: num(0)},
+	"AutoDJConfig.Batch":                {min: num(0)},
+	"AutoDJConfig.AvoidHours":           {min: num(0), unit: "h"},
+	"ZoneConfig.Volume":                 percent,
+	"AuthConfig.AnonymousRole":          {enum: append([]string{""}, roles...)},
+	"AuthConfig.LocalRole":              {enum: append([]string{""}, roles...)},
+	"AuthConfig.SessionHours":           {min: num(0), unit: "h"},
+	"UserConfig.Role":                   {enum: roles},
+	"NavMenuConfig.HideDelay":           {min: num(0), unit: "ms"},
+	"NavMenuConfig.CellWidth":           {min: num(0), unit: "px"},
+	"NavMenuConfig.LongPressMs":         {min: num(0), unit: "ms"},
+	"GestureConfig.LongPressMs":         {min: num(0), unit: "ms"},
+	"GestureConfig.DoublePressMs":       {min: num(0), unit: "ms"},
+	"GestureBinding.Action":             {enum: []string{"key", "music", "led", "bookmark", "camera", "navigate", "aircon"}},
+	"GestureBinding.Mode":               {enum: []string{"", "on", "off", "blink", "heartbeat", "double-blink", "sos", "fade", "toggle", "clear"}},
+	"PanelConfig.Width":                 {min: num(1), unit: "px"},
+	"PanelConfig.Height":                {min: num(1), unit: "px"},
+	"AirConConfig.HistoryMinutes":       {min: num(0), unit: "min"},
+	"AirConConfig.SampleIntervalSecs":   {min: num(0), unit: "s"},
+	"ThermalConfig.PollInterval":        {format: "duration"},
+	"ThermalConfig.FFCTempDelta":        {min: num(0), unit: "°C"},
+	"ThermalPreset.Brightness":          percent,
+	"ThermalPreset.Contrast":            percent,
+	"ThermalPreset.DetailEnhancement":   percent,
+	"ThermalPreset.StaticDenoising":     percent,
+	"ThermalPreset.DynamicDenoising":    percent,
+	"ThermalPreset.Palette":             {enum: thermalPalettes},
+	"ThermalPreset.Mirroring":           {enum: []string{"none", "center", "left-right", "up-down"}},
+	"ThermalPreset.ShutterMode":         {enum: []string{"off", "timing", "temp-delta", "full-auto"}},
+	"ThermalPreset.ShutterIntervalMin":  {unit: "min"},
+	"ExpanderBits.KnobCenter":           expanderPin,
+	"ExpanderBits.KnobInnerA":           expanderPin,
+	"ExpanderBits.KnobInnerB":           expanderPin,
+	"ExpanderBits.KnobOuterA":           expanderPin,
+	"ExpanderBits.KnobOuterB":           expanderPin,
+	"ExpanderBits.LEDR":                 expanderPin,
+	"ExpanderBits.LEDW":                 expanderPin,
+	"ExpanderBits.LEDB":                 expanderPin,
+	"ExpanderBits.LEDY":                 expanderPin,
+	"ExpanderBits.JoyCenter":            expanderPin,
+	"ExpanderBits.JoyDown":              expanderPin,
+	"ExpanderBits.JoyUp":                expanderPin,
+	"ExpanderBits.JoyRight":             expanderPin,
+	"ExpanderBits.JoyLeft":              expanderPin,
+	"ExpanderBits.JoyKnobA":             expanderPin,
+	"ExpanderBits.JoyKnobB":             expanderPin,
+}
+
+var expanderPin = rule{min: num(0), max: num(15)}
+
+// thermalPalettes are the thermalcam.Palette names.
+var thermalPalettes = []string{
+	"white-hot", "black-hot", "fusion-1", "rainbow", "fusion-2", "iron-red-1", "iron-red-2", "dark-brown",
+	"color-1", "color-2", "ice-fire", "rain", "green-hot", "red-hot", "deep-blue",
+}
+
+// formats maps a rule format to the pattern its strings must match.
+var formats = map[string]string{
+	"duration":  `^([0-9]*\.?[0-9]+(ns|us|µs|ms|s|m|h))+$`,
+	"frequency": `^[0-9]*\.?[0-9]+[kMG]?Hz$`,
+}
+
+// externalKeys are top-level keys read from the same file by other
+// processes. They're allowed but not described further.
+var externalKeys = map[string]string{
+	"tailType": "Aircraft type, substituted into intercom-stt's whisper prompt.",
+	"liveatc":  "Settings for the intercom-stt process (see liveatc/).",
+}
+
+// rootSchema is the schema without defaults, used for checks and lookups.
+var rootSchema = sync.OnceValue(func() *Schema { return JSONSchema(nil) })
+
+// JSONSchema returns the schema for Config. If defaults is non-nil its
+// values are filled in as each property's default.
+func JSONSchema(defaults *Config) *Schema {
+	s := schemaFor(reflect.TypeFor[Config]())
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.Title = "velocipi config"
+	for _, k := range slices.Sorted(maps.Keys(externalKeys)) {
+		s.Properties[k] = &Schema{Description: externalKeys[k]}
+		s.keys = append(s.keys, k)
+	}
+	if defaults != nil {
+		setDefaults(s, toMap(*defaults))
+	}
+	return s
+}
+
+func schemaFor(t reflect.Type) *Schema {
+	if t == reflect.TypeFor[StringSlice]() {
+		return &Schema{AnyOf: []*Schema{
+			{Type: "string"},
+			{Type: "array", Items: &Schema{Type: "string"}},
+		}}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		closed := false
+		s := &Schema{
+			Type:                 "object",
+			Description:          fieldDocs[t.Name()],
+			Properties:           map[string]*Schema{},
+			AdditionalProperties: &closed,
+		}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name := yamlName(f)
+			if name == "" {
+				continue
+			}
+			fs := schemaFor(f.Type)
+			key := t.Name() + "." + f.Name
+			if d := fieldDocs[key]; d != "" {
+				fs.Description = d
+			}
+			fs.apply(rules[key])
+			s.Properties[name] = fs
+			s.keys = append(s.keys, name)
+		}
+		return s
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: num(0)}
+	case reflect.Uint8:
+		return &Schema{Type: "integer", Minimum: num(0), Maximum: num(255)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	panic("config: no schema for " + t.String())
+}
+
+func (s *Schema) apply(r rule) {
+	if r.enum != nil {
+		s.Enum = r.enum
+	}
+	if r.min != nil {
+		s.Minimum = r.min
+	}
+	if r.max != nil {
+		s.Maximum = r.max
+	}
+	s.Unit = r.unit
+	if r.format != "" {
+		s.Format = r.format
+		s.Pattern = formats[r.format]
+	}
+}
+
+// setDefaults fills in Default on s and its properties from v, a config
+// (or part of one) as decoded from JSON.
+func setDefaults(s *Schema, v any) {
+	if m, ok := v.(map[string]any); ok && s.Type == "object" {
+		for k, child := range m {
+			if ps := s.Properties[k]; ps != nil {
+				setDefaults(ps, child)
+			}
+		}
+		return
+	}
+	s.Default = v
+}
+
+// Keys returns the names of an object schema's properties in the order
+// they're declared.
+func (s *Schema) Keys() []string {
+	return s.keys
+}
+
+// Lookup returns the schema for the dotted path, e.g. "music.duck.mode", or
+// nil if there's no such key. List indexes may be given or left out
+// ("dvr.cameras.0.name" and "dvr.cameras.name" are the same).
+func (s *Schema) Lookup(path string) *Schema {
+	if path == "" {
+		return s
+	}
+	for _, key := range strings.Split(path, ".") {
+		if s.Type == "array" {
+			s = s.Items
+			if _, err := strconv.Atoi(key); err == nil {
+				continue
+			}
+		}
+		if s = s.Properties[key]; s == nil {
+			return nil
+		}
+	}
+	return s
+}
+
+// LookupSchema is Lookup on the schema without defaults.
+func LookupSchema(path string) *Schema {
+	return rootSchema().Lookup(path)
+}
+
+// TypeName describes the schema's type for people, e.g. "integer (ms)" or
+// "string or list".
+func (s *Schema) TypeName() string {
+	var t string
+	switch {
+	case s.AnyOf != nil:
+		names := make([]string, len(s.AnyOf))
+		for i, a := range s.AnyOf {
+			names[i] = a.TypeName()
+		}
+		t = strings.Join(names, " or ")
+	case s.Type == "array":
+		t = "list"
+		if s.Items != nil && s.Items.Type != "object" {
+			t = "list of " + s.Items.TypeName()
+		}
+	case s.Type == "":
+		t = "any"
+	default:
+		t = s.Type
+	}
+	if s.Format != "" {
+		t += " (" + s.Format + ")"
+	} else if s.Unit != "" {
+		t += " (" + s.Unit + ")"
+	}
+	return t
+}
+
+// CheckYAML checks the structure of a config file against the schema. It
+// returns a problem for each unknown key and each value of the wrong type,
+// prefixed with name, the line and the key's path. Values are checked by
+// Validate once the file is decoded.
+func CheckYAML(name string, data []byte) []error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []error{fmt.Errorf("%s: %w", name, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	var problems []error
+	checkNode(rootSchema(), doc.Content[0], "", func(n *yaml.Node, path, msg string) {
+		problems = append(problems, fmt.Errorf("%s:%d: %s: %s", name, n.Line, path, msg))
+	})
+	return problems
+}
+
+func checkNode(s *Schema, n *yaml.Node, path string, report func(n *yaml.Node, path, msg string)) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.ShortTag() == "!!null" {
+		return
+	}
+	if s.AnyOf != nil {
+		for _, alt := range s.AnyOf {
+			ok := true
+			checkNode(alt, n, path, func(*yaml.Node, string, string) { ok = false })
+			if ok {
+				return
+			}
+		}
+		report(n, path, "expected a "+s.TypeName())
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			report(n, path, "expected a mapping")
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			p := joinPath(path, k.Value)
+			ps := s.Properties[k.Value]
+			if ps == nil {
+				msg := "unknown key"
+				if guess := closest(k.Value, s.keys); guess != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", guess)
+				}
+				report(k, p, msg)
+				continue
+			}
+			checkNode(ps, v, p, report)
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			report(n, path, "expected a list")
+			return
+		}
+		for i, item := range n.Content {
+			checkNode(s.Items, item, fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	case "string", "integer", "number", "boolean":
+		tag := n.ShortTag()
+		ok := n.Kind == yaml.ScalarNode
+		switch s.Type {
+		case "integer":
+			ok = ok && tag == "!!int"
+		case "number":
+			ok = ok && (tag == "!!int" || tag == "!!float")
+		case "boolean":
+			ok = ok && tag == "!!bool"
+		}
+		if !ok {
+			report(n, path, fmt.Sprintf("expected %s, got %s", article(s.Type), describeNode(n)))
+		}
+	}
+}
+
+func describeNode(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(n.Value)
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+// closest returns the candidate that's a likely misspelling of key, or "".
+func closest(key string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if strings.EqualFold(c, key) {
+			return c
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// checkValues checks v against the enums and ranges in s, appending a
+// problem for each violation.
+func checkValues(v reflect.Value, s *Schema, path string, problems *[]error) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			checkValues(v.Elem(), s, path, problems)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			name := yamlName(t.Field(i))
+			if ps := s.Properties[name]; name != "" && ps != nil {
+				checkValues(v.Field(i), ps, joinPath(path, name), problems)
+			}
+		}
+	case reflect.Slice:
+		if s.Items == nil {
+			return
+		}
+		for i := range v.Len() {
+			checkValues(v.Index(i), s.Items, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case reflect.String:
+		if s.Enum != nil && !slices.Contains(s.Enum, v.String()) {
+			quoted := make([]string, len(s.Enum))
+			for i, e := range s.Enum {
+				quoted[i] = strconv.Quote(e)
+			}
+			*problems = append(*problems, fmt.Errorf("%s: %q is not one of %s", path, v.String(), strings.Join(quoted, ", ")))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		var f float64
+		switch {
+		case v.CanInt():
+			f = float64(v.Int())
+		case v.CanUint():
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			*problems = append(*problems, fmt.Errorf("%s: %v is below the minimum of %v", path, f, *s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			*problems = append(*problems, fmt.Errorf("%s: %v is above the maximum of %v", path, f, *s.Maximum))
+		}
+	}
+}
+
+// yamlName returns the config key for a struct field, or "" if it isn't
+// serialized.
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
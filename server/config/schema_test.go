@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDefaultConfigMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "config.default.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range CheckYAML("config.default.yaml", data) {
+		t.Error(p)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(&cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckYAML(t *testing.T) {
+	data := []byte(`hardware:
+  oled:
+    drvier: ssd1327
+    flip: "yes"
+dvr:
+  segmentDuration: 10m
+  cameras:
+    - name: nose
+      port: x
+tires:
+  nose: abc
+  left: [a, b]
+liveatc:
+  anything: goes
+`)
+	var got []string
+	for _, p := range CheckYAML("config.yaml", data) {
+		got = append(got, p.Error())
+	}
+	want := []string{
+		`config.yaml:3: hardware.oled.drvier: unknown key (did you mean "driver"?)`,
+		`config.yaml:4: hardware.oled.flip: expected a boolean, got "yes"`,
+		`config.yaml:6: dvr.segmentDuration: expected an integer, got "10m"`,
+		`config.yaml:9: dvr.cameras[0].port: expected an integer, got "x"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateReportsPaths(t *testing.T) {
+	_, cfg := testBus(t)
+	cfg.Hardware.OLED.Driver = "sh1106"
+	cfg.Music.Duck.Level = 150
+	cfg.Brightness.Delay = "soon"
+	cfg.Hardware.Thermal.PollInterval = "0s"
+	cfg.DVR.Cameras = []CameraConfig{{Name: "nose"}, {Name: "Nose", Driver: "webcam"}}
+	cfg.UI.Gestures.Bindings = []GestureBinding{
+		{Gesture: "enter.triple", Action: "bookmark"},
+		{Gesture: "left+up", Action: "music", Target: "louder"},
+		{Gesture: "up+left", Action: "led", Target: "r"},
+		{Gesture: "up+left", Route: "music", Action: "key", Target: "enter"},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("invalid config passed validation")
+	}
+	for _, want := range []string{
+		`brightness.delay: invalid duration "soon"`,
+		`hardware.thermal.pollInterval: "0s" must be positive`,
+		`hardware.oled.driver: "sh1106" is not one of "", "ssd1327", "ge256x64b"`,
+		`music.duck.level: 150 is above the maximum of 100`,
+		`dvr.cameras[1].driver: "webcam" is not one of`,
+		`dvr.cameras[1].name: duplicate camera name "Nose"`,
+		`ui.gestures.bindings[0].gesture: unknown gesture "enter.triple"`,
+		`ui.gestures.bindings[1].target: "louder" is not one of play, pause,`,
+		`ui.gestures.bindings[2].gesture: "up+left" is already bound on this route`,
+		`ui.gestures.bindings[2].mode: required for led`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestDiffAndOverrides(t *testing.T) {
+	_, defaults := testBus(t)
+	updated := *defaults
+	updated.Music.Duck.Mode = "pause"
+	updated.Tires.Nose = StringSlice{"aa:bb"}
+	updated.DVR.Cameras = []CameraConfig{} // same as none
+
+	changes := Diff(*defaults, updated)
+	if len(changes) != 2 || changes[0].Path != "music.duck.mode" || changes[0].New != "pause" ||
+		changes[1].Path != "tires.nose" {
+		t.Fatalf("changes = %+v", changes)
+	}
+
+	data, err := overridesYAML(updated, *defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]any
+	if err := yaml.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(back); string(got) != `{"music":{"duck":{"mode":"pause"}},"tires":{"nose":["aa:bb"]}}` {
+		t.Errorf("overrides = %s", got)
+	}
+
+	if v, ok := Value(updated, "music.duck.mode"); !ok || v != "pause" {
+		t.Errorf("Value = %v, %v", v, ok)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	_, defaults := testBus(t)
+	defaults.Music.Duck.Mode = "volume"
+	s := JSONSchema(defaults)
+
+	mode := s.Lookup("music.duck.mode")
+	if mode == nil || mode.Default != "volume" || len(mode.Enum) == 0 || mode.Description == "" {
+		t.Fatalf("music.duck.mode = %+v", mode)
+	}
+	if name := s.Lookup("dvr.cameras.0.name"); name == nil || name != s.Lookup("dvr.cameras.name") {
+		t.Error("list lookups should skip the index")
+	}
+	if d := s.Lookup("brightness.delay"); d.Format != "duration" || d.TypeName() != "string (duration)" {
+		t.Errorf("brightness.delay = %+v", d)
+	}
+	if s.Lookup("music.nope") != nil {
+		t.Error("unknown key found")
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadIgnoresInvalidOverrides(t *testing.T) {
+	defaults, err := os.ReadFile(filepath.Join("..", "..", "config.default.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, overrides := range map[string]string{
+		"malformed": "music: [",
+		"invalid":   "music:\n  duck:\n    level: 150\nbrightness:\n  minLux: 3\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			useDir(t, dir)
+			if err := os.WriteFile(filepath.Join(dir, "config.default.yaml"), defaults, 0644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(path, []byte(overrides), 0644); err != nil {
+				t.Fatal(err)
+			}
+			res := Load()
+			if res.Config.Music.Duck != res.Defaults.Music.Duck || res.Config.Brightness != res.Defaults.Brightness {
+				t.Errorf("overrides applied: duck %+v, brightness %+v", res.Config.Music.Duck, res.Config.Brightness)
+			}
+
+			// Saving would replace the user's file with overrides built from
+			// the defaults, so it's refused until the file is fixed.
+			updated := *res.Config
+			updated.TailNumber = "N12345"
+			if err := SaveOverrides(updated, *res.Defaults); !errors.Is(err, ErrInvalidOverrides) {
+				t.Errorf("SaveOverrides: %v", err)
+			}
+			b := NewBus(res.Config, res.Defaults)
+			if _, err := b.Update(updated); !errors.Is(err, ErrInvalidOverrides) {
+				t.Errorf("Update: %v", err)
+			}
+			if got, _ := os.ReadFile(path); string(got) != overrides {
+				t.Errorf("config.yaml overwritten:\n%s", got)
+			}
+
+			if err := os.WriteFile(path, []byte("brightness:\n  minLux: 3\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := b.Update(updated); err != nil {
+				t.Errorf("Update after fixing config.yaml: %v", err)
+			}
+		})
+	}
+}
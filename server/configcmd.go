@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+const configUsage = `usage: velocipi [-config dir] config <command>
+
+commands:
+  check           check config.default.yaml and config.yaml against the schema
+  diff            list the settings config.yaml changes from the defaults
+  explain [path]  describe a setting, e.g. "music.duck.mode", or a section
+  schema          print the JSON Schema
+`
+
+// configCommand runs `velocipi config ...`, which inspects the config files
+// without starting the server, and returns the exit status.
+func configCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	res, problems := config.Check()
+	if res == nil {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return 1
+	}
+	cfg := config.RelativizeStorage(*res.Config)
+	defaults := config.RelativizeStorage(*res.Defaults)
+
+	switch args[0] {
+	case "check":
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			return 1
+		}
+		fmt.Println("config OK")
+	case "diff":
+		for _, c := range config.Diff(defaults, cfg) {
+			fmt.Printf("%s: %s -> %s\n", c.Path, jsonValue(c.Old), jsonValue(c.New))
+		}
+	case "explain":
+		path := ""
+		if len(args) > 1 {
+			path = args[1]
+		}
+		s := config.JSONSchema(&defaults).Lookup(path)
+		if s == nil {
+			fmt.Fprintf(os.Stderr, "no setting %q\n", path)
+			return 1
+		}
+		explain(os.Stdout, path, s, cfg)
+	case "schema":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(config.JSONSchema(&defaults))
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	return 0
+}
+
+// explain prints what the setting or section at path is, its constraints,
+// and its default and current values.
+func explain(w *os.File, path string, s *config.Schema, cfg config.Config) {
+	name := path
+	if name == "" {
+		name = "(config)"
+	}
+	fmt.Fprintf(w, "%s  %s\n", name, s.TypeName())
+	if s.Description != "" {
+		fmt.Fprintf(w, "  %s\n", s.Description)
+	}
+
+	if s.Type == "object" {
+		fmt.Fprintln(w, "\n  keys:")
+		for _, k := range s.Keys() {
+			child := s.Properties[k]
+			desc, _, _ := strings.Cut(child.Description, ". ")
+			fmt.Fprintf(w, "    %-22s %-20s %s\n", k, child.TypeName(), desc)
+		}
+		return
+	}
+
+	if s.Enum != nil {
+		quoted := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			quoted[i] = fmt.Sprintf("%q", e)
+		}
+		fmt.Fprintf(w, "  one of:  %s\n", strings.Join(quoted, ", "))
+	}
+	if s.Minimum != nil || s.Maximum != nil {
+		lo, hi := "", ""
+		if s.Minimum != nil {
+			lo = fmt.Sprint(*s.Minimum)
+		}
+		if s.Maximum != nil {
+			hi = fmt.Sprint(*s.Maximum)
+		}
+		fmt.Fprintf(w, "  range:   %s..%s\n", lo, hi)
+	}
+	if s.Default != nil {
+		fmt.Fprintf(w, "  default: %s\n", jsonValue(s.Default))
+	}
+	if v, ok := config.Value(cfg, path); ok {
+		fmt.Fprintf(w, "  current: %s\n", jsonValue(v))
+	}
+}
+
+func jsonValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -17,8 +17,23 @@ type RecordingFile struct {
 	Filename  string `json:"filename"`  // basename without extension, e.g. "2026-02-22_15-04-05_Left"
 	HasThumb  bool   `json:"hasThumb"`  // _thumb.jpg exists
 	HasFull   bool   `json:"hasFull"`   // _full.jpg exists
+	HasVideo  bool   `json:"hasVideo"`  // .mp4 exists (false once retention keeps thumbnails only)
+	Shrunk    bool   `json:"shrunk"`    // video was transcoded down by retention
+	Locked    bool   `json:"locked"`    // retention and minFreeDisk never touch this recording
+	Protected bool   `json:"protected"` // the whole session is protected from retention
+	Size      int64  `json:"size"`      // bytes on disk for the mp4 and its JPEGs
 }
 
+const (
+	// protectedMarker is the file whose presence in a session directory
+	// protects every recording in it from automatic deletion.
+	protectedMarker = ".protected"
+	// lockSuffix and shrunkSuffix name the per-recording marker files kept
+	// next to the mp4: {base}.lock and {base}.shrunk.
+	lockSuffix   = ".lock"
+	shrunkSuffix = ".shrunk"
+)
+
 // parseRecordingName parses a filename of the form
 // "{yyyy-mm-dd_hh-mm-ss}_{cam}.mp4" into its components.
 // Returns ("", "", "", false) if the name doesn't match.
@@ -39,8 +54,9 @@ func parseRecordingName(name string) (date, startTime, cam string, ok bool) {
 	return
 }
 
-// ListRecordings returns all MP4 segments found under recordingsDir,
-// sorted by session descending then start time ascending.
+// ListRecordings returns all segments found under recordingsDir, sorted by
+// session descending then start time ascending. Segments whose video has been
+// removed by retention are still listed (HasVideo false) while a thumbnail remains.
 func (m *Manager) ListRecordings() ([]RecordingFile, error) {
 	root := m.recordingsDir
 	entries, err := os.ReadDir(root)
@@ -62,26 +78,62 @@ func (m *Manager) ListRecordings() ([]RecordingFile, error) {
 		if err != nil {
 			continue
 		}
+		_, protErr := os.Stat(filepath.Join(sessionDir, protectedMarker))
+
+		// Collect each segment's base name once, from either its mp4 or its thumbnail.
+		seen := make(map[string]bool)
+		var bases []string
 		for _, f := range files {
-			if f.IsDir() || !strings.HasSuffix(f.Name(), ".mp4") {
+			if f.IsDir() {
+				continue
+			}
+			name := f.Name()
+			var base string
+			switch {
+			case strings.HasSuffix(name, ".mp4"):
+				base = strings.TrimSuffix(name, ".mp4")
+			case strings.HasSuffix(name, "_thumb.jpg"):
+				base = strings.TrimSuffix(name, "_thumb.jpg")
+			default:
 				continue
 			}
-			date, startTime, cam, ok := parseRecordingName(f.Name())
+			if !seen[base] {
+				seen[base] = true
+				bases = append(bases, base)
+			}
+		}
+
+		for _, name := range bases {
+			date, startTime, cam, ok := parseRecordingName(name + ".mp4")
 			if !ok {
 				continue
 			}
-			base := filepath.Join(sessionDir, strings.TrimSuffix(f.Name(), ".mp4"))
-			_, thumbErr := os.Stat(base + "_thumb.jpg")
-			_, fullErr := os.Stat(base + "_full.jpg")
-			out = append(out, RecordingFile{
+			base := filepath.Join(sessionDir, name)
+			rec := RecordingFile{
 				Camera:    unsanitizeName(cam),
 				Session:   session,
 				Date:      date,
 				StartTime: startTime,
-				Filename:  strings.TrimSuffix(f.Name(), ".mp4"),
-				HasThumb:  thumbErr == nil,
-				HasFull:   fullErr == nil,
-			})
+				Filename:  name,
+				Protected: protErr == nil,
+			}
+			if fi, err := os.Stat(base + ".mp4"); err == nil {
+				rec.HasVideo = true
+				rec.Size += fi.Size()
+			}
+			if fi, err := os.Stat(base + "_thumb.jpg"); err == nil {
+				rec.HasThumb = true
+				rec.Size += fi.Size()
+			}
+			if fi, err := os.Stat(base + "_full.jpg"); err == nil {
+				rec.HasFull = true
+				rec.Size += fi.Size()
+			}
+			_, lockErr := os.Stat(base + lockSuffix)
+			_, shrunkErr := os.Stat(base + shrunkSuffix)
+			rec.Locked = lockErr == nil
+			rec.Shrunk = shrunkErr == nil
+			out = append(out, rec)
 		}
 	}
 
@@ -102,7 +154,7 @@ func (m *Manager) DeleteRecording(session, filename string) error {
 	}
 	dir := filepath.Join(m.recordingsDir, session)
 	base := filepath.Join(dir, filename)
-	for _, ext := range []string{".mp4", "_thumb.jpg", "_full.jpg"} {
+	for _, ext := range []string{".mp4", "_thumb.jpg", "_full.jpg", lockSuffix, shrunkSuffix} {
 		path := base + ext
 		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("delete %s: %w", path, err)
@@ -153,6 +205,65 @@ func (m *Manager) DeleteSession(session string) error {
 	return nil
 }
 
+// SetSessionProtected adds or removes the protection marker on a session
+// directory. Retention and minFreeDisk skip every recording in a protected session.
+func (m *Manager) SetSessionProtected(session string, protected bool) error {
+	if session == "" || strings.ContainsAny(session, "/\\") {
+		return fmt.Errorf("invalid session name")
+	}
+	dir := filepath.Join(m.recordingsDir, session)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("session %s: %w", session, err)
+	}
+	return setMarker(filepath.Join(dir, protectedMarker), protected)
+}
+
+// SetRecordingLocked adds or removes the lock marker on a single recording.
+// Retention and minFreeDisk never touch a locked recording.
+func (m *Manager) SetRecordingLocked(session, filename string, locked bool) error {
+	if strings.ContainsAny(session, "/\\") || strings.ContainsAny(filename, "/\\") {
+		return fmt.Errorf("invalid session or filename")
+	}
+	base := filepath.Join(m.recordingsDir, session, filename)
+	if _, err := os.Stat(base + ".mp4"); err != nil {
+		if _, thumbErr := os.Stat(base + "_thumb.jpg"); thumbErr != nil {
+			return fmt.Errorf("recording %s/%s: %w", session, filename, err)
+		}
+	}
+	return setMarker(base+lockSuffix, locked)
+}
+
+// Bookmark locks every recording currently being written, so the moment
+// survives retention and minFreeDisk. It returns how many were locked.
+func (m *Manager) Bookmark() (int, error) {
+	m.mu.RLock()
+	var files []string
+	for mp4File, writing := range m.writing {
+		if writing {
+			files = append(files, mp4File)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, f := range files {
+		if err := setMarker(strings.TrimSuffix(f, ".mp4")+lockSuffix, true); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// setMarker creates (on) or removes (off) an empty marker file.
+func setMarker(path string, on bool) error {
+	if !on {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, nil, 0644)
+}
+
 // unsanitizeName reverses the underscore-for-space substitution done by
 // sanitizeName so that camera names are restored for display. Other
 // substitutions (/ → -, etc.) are lossy and cannot be reversed; this is
@@ -41,6 +41,9 @@ const (
 	// Slow browsers get dropped after the buffer fills rather than blocking
 	// the broadcaster.
 	subscriberBuf = 64
+	// bytesPerGB is the unit behind every "GB" here: minFreeDisk, maxSizeGB,
+	// disk space reports and log messages. It's binary (GiB), like df -h.
+	bytesPerGB = 1 << 30
 )
 
 // broadcaster fans out []byte chunks to all current subscribers.
@@ -155,6 +158,12 @@ type RecordingReadyMsg struct {
 	Filename string `json:"filename"` // base filename without extension
 }
 
+// cameraRun is one camera's running loop.
+type cameraRun struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed when runCamera returns
+}
+
 // Manager starts and supervises DVR recording for all configured cameras.
 type Manager struct {
 	mu               sync.RWMutex
@@ -167,6 +176,10 @@ type Manager struct {
 	sessions         map[string]*streamSession // clientID → per-connection state
 	state            RecordingState            // overall recording state: on, paused, off
 	lastDiskSpace    *DiskSpaceMsg             // most recent disk space reading
+	writing          map[string]bool           // mp4 paths ffmpeg is currently recording to
+	ctx              context.Context           // from Start; nil until then
+	runs             map[string]*cameraRun     // sanitized name → running camera loop
+	retentionMu      sync.Mutex                // held while ApplyRetention runs
 	onStatusChange   func(CameraStatusMsg)
 	onRecordingReady func(RecordingReadyMsg)
 	onDiskSpace      func(DiskSpaceMsg)
@@ -197,10 +210,19 @@ func New(cfg config.DVRConfig, recordingsDir string, pollDur time.Duration) *Man
 		live:          live,
 		recording:     make(map[string]bool),
 		sessions:      make(map[string]*streamSession),
+		writing:       make(map[string]bool),
+		runs:          make(map[string]*cameraRun),
 		state:         state,
 	}
 }
 
+// conf returns the current DVR settings.
+func (m *Manager) conf() config.DVRConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
 // OnStatusChange registers a callback invoked whenever a camera's recording
 // state changes. Must be called before Start.
 func (m *Manager) OnStatusChange(fn func(CameraStatusMsg)) {
@@ -255,9 +277,9 @@ func (m *Manager) LastDiskSpace() *DiskSpaceMsg {
 
 // CameraStatuses returns the current recording status of all configured cameras.
 func (m *Manager) CameraStatuses() []CameraStatusMsg {
-	msgs := make([]CameraStatusMsg, 0, len(m.cfg.Cameras))
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	msgs := make([]CameraStatusMsg, 0, len(m.cfg.Cameras))
 	for _, cam := range m.cfg.Cameras {
 		key := sanitizeName(cam.Name)
 		msgs = append(msgs, CameraStatusMsg{
@@ -321,16 +343,15 @@ func (m *Manager) pollDiskSpace() {
 	total := float64(stat.Blocks) * float64(stat.Bsize)
 	free := float64(stat.Bavail) * float64(stat.Bsize)
 	used := total - float64(stat.Bfree)*float64(stat.Bsize)
-	toGB := 1.0 / (1024 * 1024 * 1024)
 	var usedPct float64
 	if total > 0 {
 		usedPct = used / total * 100
 	}
 	msg := DiskSpaceMsg{
 		Type:    "diskSpace",
-		TotalGB: total * toGB,
-		UsedGB:  used * toGB,
-		FreeGB:  free * toGB,
+		TotalGB: total / bytesPerGB,
+		UsedGB:  used / bytesPerGB,
+		FreeGB:  free / bytesPerGB,
 		UsedPct: usedPct,
 	}
 	m.mu.Lock()
@@ -341,7 +362,8 @@ func (m *Manager) pollDiskSpace() {
 	}
 }
 
-// runDiskSpaceLoop polls disk space at the configured interval until ctx is cancelled.
+// runDiskSpaceLoop polls disk space at the configured interval until ctx is
+// cancelled, applying the retention policy (if any) on each poll.
 func (m *Manager) runDiskSpaceLoop(ctx context.Context) {
 	dur := m.pollDur
 	if dur <= 0 {
@@ -351,6 +373,15 @@ func (m *Manager) runDiskSpaceLoop(ctx context.Context) {
 	ticker := time.NewTicker(dur)
 	defer ticker.Stop()
 	for {
+		if m.retentionEnabled() {
+			// ApplyRetention skips the run if the previous one (e.g. a long
+			// shrink transcode) is still going.
+			go func() {
+				if _, err := m.ApplyRetention(ctx); err != nil && ctx.Err() == nil {
+					log.Println("dvr: retention error:", err)
+				}
+			}()
+		}
 		select {
 		case <-ctx.Done():
 			return
@@ -363,7 +394,7 @@ func (m *Manager) runDiskSpaceLoop(ctx context.Context) {
 // Start launches the background recording loop for each camera.
 // It returns immediately; all loops run until ctx is cancelled.
 func (m *Manager) Start(ctx context.Context) {
-	if len(m.cfg.Cameras) == 0 {
+	if len(m.conf().Cameras) == 0 {
 		return
 	}
 	if err := os.MkdirAll(m.recordingsDir, 0755); err != nil {
@@ -393,15 +424,121 @@ func (m *Manager) Start(ctx context.Context) {
 		log.Println("dvr: session dir:", dir)
 	}
 
-	for _, cam := range m.cfg.Cameras {
-		go m.runCamera(ctx, cam)
+	m.mu.Lock()
+	m.ctx = ctx
+	cams := m.cfg.Cameras
+	m.mu.Unlock()
+	for _, cam := range cams {
+		m.startCamera(ctx, cam)
+	}
+}
+
+// startCamera launches runCamera for cam, recording how to stop it.
+func (m *Manager) startCamera(ctx context.Context, cam config.CameraConfig) {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &cameraRun{cancel: cancel, done: make(chan struct{})}
+	m.mu.Lock()
+	m.runs[sanitizeName(cam.Name)] = run
+	m.mu.Unlock()
+	go func() {
+		defer close(run.done)
+		m.runCamera(ctx, cam)
+	}()
+}
+
+// ApplyConfig updates the settings of a running Manager. Cameras that were
+// added, removed or had their stream settings changed get their loops
+// stopped and (re)started; every other setting is read as it's used.
+// Returns config.ErrRestartRequired if Start didn't get as far as starting
+// cameras (e.g. there were none), since disk polling and the session dir
+// are only set up then.
+func (m *Manager) ApplyConfig(cfg config.DVRConfig) error {
+	m.mu.Lock()
+	old := m.cfg
+	m.cfg = cfg
+	ctx := m.ctx
+	if ctx == nil {
+		m.mu.Unlock()
+		if len(cfg.Cameras) == 0 {
+			return nil
+		}
+		return config.ErrRestartRequired
+	}
+
+	oldCams := make(map[string]config.CameraConfig, len(old.Cameras))
+	for _, c := range old.Cameras {
+		oldCams[sanitizeName(c.Name)] = c
+	}
+	newCams := make(map[string]config.CameraConfig, len(cfg.Cameras))
+	for _, c := range cfg.Cameras {
+		newCams[sanitizeName(c.Name)] = c
+	}
+	var stop []*cameraRun
+	for key, c := range oldCams {
+		n, ok := newCams[key]
+		if ok && streamSettings(n) == streamSettings(c) {
+			continue
+		}
+		if run := m.runs[key]; run != nil {
+			stop = append(stop, run)
+			delete(m.runs, key)
+		}
+		if !ok {
+			delete(m.live, key)
+		}
+	}
+	var start []config.CameraConfig
+	for key, c := range newCams {
+		if o, ok := oldCams[key]; ok && streamSettings(o) == streamSettings(c) {
+			continue
+		}
+		if m.live[key] == nil {
+			m.live[key] = &liveCamera{ts: newBroadcaster(), frame: newFrameEntry()}
+		}
+		start = append(start, c)
+	}
+	m.mu.Unlock()
+
+	for _, run := range stop {
+		run.cancel()
+		select {
+		case <-run.done:
+		case <-time.After(10 * time.Second):
+			log.Println("dvr: camera loop slow to stop; starting its replacement anyway")
+		}
+	}
+	for _, cam := range start {
+		log.Printf("dvr[%s]: starting after config change", cam.Name)
+		m.startCamera(ctx, cam)
+	}
+	return nil
+}
+
+// cameraStream holds the CameraConfig fields that affect a camera's
+// recording loop; changing any other field doesn't restart it.
+type cameraStream struct {
+	Driver, Host, Path, Username, Password string
+	Port                                   int
+	Audio, Record                          bool
+}
+
+func streamSettings(c config.CameraConfig) cameraStream {
+	return cameraStream{
+		Driver:   c.Driver,
+		Host:     c.Host,
+		Path:     c.Path,
+		Username: c.Username,
+		Password: c.Password,
+		Port:     c.Port,
+		Audio:    c.Audio,
+		Record:   shouldRecord(c),
 	}
 }
 
 // segmentDur returns the configured segment duration, falling back to 600s.
 func (m *Manager) segmentDur() int {
-	if m.cfg.SegmentDuration > 0 {
-		return m.cfg.SegmentDuration
+	if d := m.conf().SegmentDuration; d > 0 {
+		return d
 	}
 	return 600
 }
@@ -457,7 +594,9 @@ func (m *Manager) runCamera(ctx context.Context, cam config.CameraConfig) {
 		return
 	}
 
+	m.mu.RLock()
 	lc := m.live[key]
+	m.mu.RUnlock()
 
 	// openFIFO opens a named pipe for reading without blocking by using O_RDWR.
 	// On Linux a FIFO opened O_RDWR never blocks (no need for a writer to be
@@ -476,7 +615,11 @@ func (m *Manager) runCamera(ctx context.Context, cam config.CameraConfig) {
 				log.Printf("dvr[%s]: open fifo %s: %v", cam.Name, path, err)
 				return
 			}
+			// A FIFO opened O_RDWR never sees EOF, so close it to unblock
+			// fn when the camera is stopped.
+			stop := context.AfterFunc(ctx, func() { f.Close() })
 			fn(f)
+			stop()
 			f.Close()
 			if ctx.Err() != nil {
 				return
@@ -542,8 +685,8 @@ func splitJPEGs(r io.Reader, fe *frameEntry) {
 
 // thumbnailHeight returns the configured thumbnail height, falling back to 240px.
 func (m *Manager) thumbnailHeight() int {
-	if m.cfg.ThumbnailHeight > 0 {
-		return m.cfg.ThumbnailHeight
+	if h := m.conf().ThumbnailHeight; h > 0 {
+		return h
 	}
 	return 240
 }
@@ -597,7 +740,7 @@ func (m *Manager) captureSegmentThumbs(mp4File, cameraName string) {
 	}
 
 	// Enforce minimum free disk space by deleting oldest recordings.
-	if m.cfg.MinFreeDisk > 0 {
+	if m.conf().MinFreeDisk > 0 {
 		m.enforceMinFreeDisk()
 	}
 }
@@ -605,7 +748,8 @@ func (m *Manager) captureSegmentThumbs(mp4File, cameraName string) {
 // enforceMinFreeDisk deletes the oldest recordings until at least MinFreeDisk GB is free.
 // It re-polls disk space after each deletion and broadcasts updates.
 func (m *Manager) enforceMinFreeDisk() {
-	minFreeBytes := m.cfg.MinFreeDisk * 1024 * 1024 * 1024
+	minFreeDisk := m.conf().MinFreeDisk
+	minFreeBytes := minFreeDisk * bytesPerGB
 	for {
 		var stat syscall.Statfs_t
 		if err := syscall.Statfs(m.recordingsDir, &stat); err != nil {
@@ -617,18 +761,29 @@ func (m *Manager) enforceMinFreeDisk() {
 			return
 		}
 
-		// Find the oldest recording.
+		// Find the oldest recording that isn't locked, protected, or in progress.
 		recs, err := m.ListRecordings()
-		if err != nil || len(recs) == 0 {
-			log.Printf("dvr: minFreeDisk: %.1f GB free < %.1f GB required but no recordings to delete",
-				freeBytes/1e9, m.cfg.MinFreeDisk)
-			return
+		if err != nil {
+			recs = nil
 		}
 		// ListRecordings returns sessions descending, time ascending.
-		// The oldest is the last recording in the last (oldest) session, i.e. recs[len-1].
-		oldest := recs[len(recs)-1]
+		// The oldest is the last recording in the last (oldest) session, so walk
+		// backwards to the first one we're allowed to delete.
+		var oldest *RecordingFile
+		for i := len(recs) - 1; i >= 0; i-- {
+			r := recs[i]
+			if !r.Locked && !r.Protected && !m.isWriting(r.Session, r.Filename) {
+				oldest = &r
+				break
+			}
+		}
+		if oldest == nil {
+			log.Printf("dvr: minFreeDisk: %.1f GB free < %.1f GB required but no deletable recordings",
+				freeBytes/bytesPerGB, minFreeDisk)
+			return
+		}
 		log.Printf("dvr: minFreeDisk: %.1f GB free < %.1f GB required, deleting %s/%s",
-			freeBytes/1e9, m.cfg.MinFreeDisk, oldest.Session, oldest.Filename)
+			freeBytes/bytesPerGB, minFreeDisk, oldest.Session, oldest.Filename)
 		if err := m.DeleteRecording(oldest.Session, oldest.Filename); err != nil {
 			log.Printf("dvr: minFreeDisk: delete error: %v", err)
 			return
@@ -739,12 +894,22 @@ func (m *Manager) runLoop(ctx context.Context, cam config.CameraConfig, tsFIFO,
 		segCtx, cancelSeg := context.WithDeadline(ctx, boundary)
 		cmd := exec.CommandContext(segCtx, "ffmpeg", args...)
 		cmd.Stdout = nil
-		if m.cfg.FFmpegLog {
+		if m.conf().FFmpegLog {
 			cmd.Stderr = os.Stderr
 		}
 		m.setRecording(cam.Name, key, true)
+		if record {
+			m.mu.Lock()
+			m.writing[mp4File] = true
+			m.mu.Unlock()
+		}
 		runErr := cmd.Run()
 		cancelSeg()
+		if record {
+			m.mu.Lock()
+			delete(m.writing, mp4File)
+			m.mu.Unlock()
+		}
 
 		// Distinguish clean boundary rollover (deadline elapsed, parent ctx still alive)
 		// from a genuine error (camera offline, etc.).
@@ -1021,7 +1186,11 @@ func rtspURL(cam config.CameraConfig) string {
 	if port == 0 {
 		port = 554
 	}
-	return fmt.Sprintf("rtsp://%s%s:%d/", creds, cam.Host, port)
+	path := cam.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("rtsp://%s%s:%d%s", creds, cam.Host, port, path)
 }
 
 // sanitizeName makes a camera name safe to use as a directory/file component.
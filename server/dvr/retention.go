@@ -0,0 +1,326 @@
+package dvr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+// RetentionActionKind names what retention does to a single recording.
+type RetentionActionKind string
+
+const (
+	RetentionDelete     RetentionActionKind = "delete"     // remove the mp4 and its JPEGs
+	RetentionThumbsOnly RetentionActionKind = "thumbsOnly" // remove the mp4, keep the JPEGs
+	RetentionShrink     RetentionActionKind = "shrink"     // transcode the mp4 to a lower resolution/bitrate
+)
+
+// RetentionAction is one step of a retention plan.
+type RetentionAction struct {
+	Camera   string              `json:"camera"`
+	Session  string              `json:"session"`
+	Filename string              `json:"filename"`
+	Action   RetentionActionKind `json:"action"`
+	Reason   string              `json:"reason"` // which rule triggered, e.g. "maxAgeDays=30"
+	Bytes    int64               `json:"bytes"`  // bytes freed (delete/thumbsOnly) or current size (shrink)
+}
+
+// RetentionPlan is the result of evaluating the retention policy, returned by
+// the dry-run preview and applied as-is by ApplyRetention.
+type RetentionPlan struct {
+	Actions    []RetentionAction `json:"actions"`
+	FreedBytes int64             `json:"freedBytes"` // total bytes freed by delete/thumbsOnly actions
+}
+
+// retentionFor returns the policy in effect for the named camera: its own
+// retention block if set, otherwise the DVR-wide default.
+func (m *Manager) retentionFor(camera string) config.RetentionConfig {
+	cfg := m.conf()
+	for _, cam := range cfg.Cameras {
+		if cam.Name == camera || sanitizeName(cam.Name) == sanitizeName(camera) {
+			if cam.Retention != nil {
+				return *cam.Retention
+			}
+			break
+		}
+	}
+	return cfg.Retention
+}
+
+// retentionEnabled reports whether any camera has at least one retention rule.
+func (m *Manager) retentionEnabled() bool {
+	enabled := func(r config.RetentionConfig) bool {
+		return r.MaxAgeDays > 0 || r.MaxSizeGB > 0 || r.ThumbsOnlyDays > 0 || r.ShrinkDays > 0
+	}
+	cfg := m.conf()
+	if enabled(cfg.Retention) {
+		return true
+	}
+	for _, cam := range cfg.Cameras {
+		if cam.Retention != nil && enabled(*cam.Retention) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingTime parses the UTC start time encoded in a recording's filename.
+func recordingTime(rec RecordingFile) (time.Time, error) {
+	return time.Parse("2006-01-02 15-04-05", rec.Date+" "+rec.StartTime)
+}
+
+// isWriting reports whether ffmpeg is currently writing the given recording.
+func (m *Manager) isWriting(session, filename string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.writing[filepath.Join(m.recordingsDir, session, filename+".mp4")]
+}
+
+// PlanRetention evaluates the retention policy against the recordings on
+// disk without changing anything. If camera is non-empty only that camera's
+// recordings are considered.
+//
+// Rules are applied per recording in order of severity (maxAgeDays, then
+// thumbsOnlyDays, then shrinkDays); maxSizeGB then deletes the oldest
+// remaining recordings until the camera's total fits. Protected sessions,
+// locked recordings and the segment currently being written are skipped.
+func (m *Manager) PlanRetention(now time.Time, camera string) (*RetentionPlan, error) {
+	recs, err := m.ListRecordings()
+	if err != nil {
+		return nil, err
+	}
+
+	byCamera := make(map[string][]RecordingFile)
+	for _, rec := range recs {
+		if camera != "" && rec.Camera != camera && sanitizeName(rec.Camera) != sanitizeName(camera) {
+			continue
+		}
+		byCamera[rec.Camera] = append(byCamera[rec.Camera], rec)
+	}
+	cameras := make([]string, 0, len(byCamera))
+	for name := range byCamera {
+		cameras = append(cameras, name)
+	}
+	sort.Strings(cameras)
+
+	plan := &RetentionPlan{Actions: []RetentionAction{}}
+	day := 24 * time.Hour
+	for _, name := range cameras {
+		list := byCamera[name]
+		policy := m.retentionFor(name)
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Date != list[j].Date {
+				return list[i].Date < list[j].Date
+			}
+			return list[i].StartTime < list[j].StartTime
+		})
+
+		var total int64
+		var remaining []RecordingFile // candidates for the size cap, oldest first
+		for _, rec := range list {
+			t, err := recordingTime(rec)
+			if err != nil {
+				continue
+			}
+			age := now.Sub(t)
+			size, videoSize := m.recordingSizes(rec)
+			total += size
+			if rec.Protected || rec.Locked || m.isWriting(rec.Session, rec.Filename) {
+				continue
+			}
+			action := RetentionAction{Camera: rec.Camera, Session: rec.Session, Filename: rec.Filename}
+			switch {
+			case policy.MaxAgeDays > 0 && age > time.Duration(policy.MaxAgeDays)*day:
+				action.Action = RetentionDelete
+				action.Reason = "maxAgeDays=" + strconv.Itoa(policy.MaxAgeDays)
+				action.Bytes = size
+			case rec.HasVideo && policy.ThumbsOnlyDays > 0 && age > time.Duration(policy.ThumbsOnlyDays)*day:
+				action.Action = RetentionThumbsOnly
+				action.Reason = "thumbsOnlyDays=" + strconv.Itoa(policy.ThumbsOnlyDays)
+				action.Bytes = videoSize
+			case rec.HasVideo && !rec.Shrunk && policy.ShrinkDays > 0 && age > time.Duration(policy.ShrinkDays)*day:
+				action.Action = RetentionShrink
+				action.Reason = "shrinkDays=" + strconv.Itoa(policy.ShrinkDays)
+				action.Bytes = videoSize
+			}
+			switch action.Action {
+			case RetentionDelete:
+				total -= action.Bytes
+				plan.FreedBytes += action.Bytes
+				plan.Actions = append(plan.Actions, action)
+				continue
+			case RetentionThumbsOnly:
+				total -= action.Bytes
+				plan.FreedBytes += action.Bytes
+				plan.Actions = append(plan.Actions, action)
+			case RetentionShrink:
+				plan.Actions = append(plan.Actions, action)
+			}
+			remaining = append(remaining, rec)
+		}
+
+		if policy.MaxSizeGB <= 0 {
+			continue
+		}
+		limit := int64(policy.MaxSizeGB * bytesPerGB)
+		for _, rec := range remaining {
+			if total <= limit {
+				break
+			}
+			size, _ := m.recordingSizes(rec)
+			if pending := findAction(plan.Actions, rec); pending != nil {
+				// Already reduced to thumbnails (or queued for a shrink) above;
+				// upgrade to a full delete, counting only what is left to free.
+				extra := size
+				if pending.Action == RetentionThumbsOnly {
+					extra -= pending.Bytes
+				}
+				pending.Action = RetentionDelete
+				pending.Reason = fmt.Sprintf("maxSizeGB=%g", policy.MaxSizeGB)
+				pending.Bytes = size
+				total -= extra
+				plan.FreedBytes += extra
+				continue
+			}
+			plan.Actions = append(plan.Actions, RetentionAction{
+				Camera:   rec.Camera,
+				Session:  rec.Session,
+				Filename: rec.Filename,
+				Action:   RetentionDelete,
+				Reason:   fmt.Sprintf("maxSizeGB=%g", policy.MaxSizeGB),
+				Bytes:    size,
+			})
+			total -= size
+			plan.FreedBytes += size
+		}
+	}
+	return plan, nil
+}
+
+// findAction returns the planned action for rec, or nil if there is none.
+func findAction(actions []RetentionAction, rec RecordingFile) *RetentionAction {
+	for i := range actions {
+		if actions[i].Session == rec.Session && actions[i].Filename == rec.Filename {
+			return &actions[i]
+		}
+	}
+	return nil
+}
+
+// recordingSizes returns the total on-disk size of a recording and the size
+// of its mp4 alone.
+func (m *Manager) recordingSizes(rec RecordingFile) (total, video int64) {
+	if rec.HasVideo {
+		if fi, err := os.Stat(filepath.Join(m.recordingsDir, rec.Session, rec.Filename+".mp4")); err == nil {
+			video = fi.Size()
+		}
+	}
+	return rec.Size, video
+}
+
+// ApplyRetention evaluates the retention policy and carries out the plan.
+// Only one run happens at a time; a call made while another is in progress
+// returns immediately with a nil plan. Shrink transcodes run sequentially and
+// can take a while, so callers on a hot path should run this in a goroutine.
+func (m *Manager) ApplyRetention(ctx context.Context) (*RetentionPlan, error) {
+	return m.applyRetention(ctx, time.Now().UTC())
+}
+
+func (m *Manager) applyRetention(ctx context.Context, now time.Time) (*RetentionPlan, error) {
+	if !m.retentionMu.TryLock() {
+		return nil, nil
+	}
+	defer m.retentionMu.Unlock()
+
+	plan, err := m.PlanRetention(now, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range plan.Actions {
+		if ctx.Err() != nil {
+			return plan, ctx.Err()
+		}
+		// Re-check the markers: a user may have locked or protected the
+		// recording while earlier actions (e.g. a long transcode) were running.
+		if m.retentionBlocked(a.Session, a.Filename) {
+			continue
+		}
+		var err error
+		switch a.Action {
+		case RetentionDelete:
+			log.Printf("dvr: retention (%s): deleting %s/%s", a.Reason, a.Session, a.Filename)
+			err = m.DeleteRecording(a.Session, a.Filename)
+		case RetentionThumbsOnly:
+			log.Printf("dvr: retention (%s): dropping video for %s/%s", a.Reason, a.Session, a.Filename)
+			path := filepath.Join(m.recordingsDir, a.Session, a.Filename+".mp4")
+			if err = os.Remove(path); os.IsNotExist(err) {
+				err = nil
+			}
+		case RetentionShrink:
+			log.Printf("dvr: retention (%s): shrinking %s/%s", a.Reason, a.Session, a.Filename)
+			err = m.shrinkRecording(ctx, a.Session, a.Filename, m.retentionFor(a.Camera))
+		}
+		if err != nil {
+			log.Printf("dvr: retention: %s %s/%s: %v", a.Action, a.Session, a.Filename, err)
+		}
+	}
+	if plan.FreedBytes > 0 {
+		m.pollDiskSpace()
+	}
+	return plan, nil
+}
+
+// retentionBlocked reports whether a recording is currently locked, in a
+// protected session, or being written.
+func (m *Manager) retentionBlocked(session, filename string) bool {
+	dir := filepath.Join(m.recordingsDir, session)
+	if _, err := os.Stat(filepath.Join(dir, protectedMarker)); err == nil {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, filename+lockSuffix)); err == nil {
+		return true
+	}
+	return m.isWriting(session, filename)
+}
+
+// shrinkRecording transcodes a recording's mp4 in place to the policy's
+// height and bitrate, then drops a marker so it isn't shrunk again.
+func (m *Manager) shrinkRecording(ctx context.Context, session, filename string, policy config.RetentionConfig) error {
+	base := filepath.Join(m.recordingsDir, session, filename)
+	src := base + ".mp4"
+	tmp := base + ".shrinking" // not *.mp4, so ListRecordings ignores it
+
+	args := []string{"-i", src}
+	if policy.ShrinkHeight > 0 {
+		args = append(args, "-vf", "scale=-2:'min("+strconv.Itoa(policy.ShrinkHeight)+",ih)'")
+	}
+	args = append(args, "-c:v", "libx264", "-preset", "veryfast")
+	if policy.ShrinkBitrate > 0 {
+		args = append(args, "-b:v", strconv.Itoa(policy.ShrinkBitrate)+"k")
+	} else {
+		args = append(args, "-crf", "28")
+	}
+	args = append(args, "-c:a", "copy", "-f", "mp4", "-movflags", "+faststart", "-y", tmp)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if m.conf().FFmpegLog {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	if err := os.Rename(tmp, src); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return setMarker(base+shrunkSuffix, true)
+}
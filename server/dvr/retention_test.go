@@ -0,0 +1,253 @@
+package dvr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+var retentionNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// sizeGB is n bytes in GB. Keep n a sum of few powers of two so that the
+// conversion back to bytes is exact.
+func sizeGB(n int) float64 { return float64(n) / bytesPerGB }
+
+// testRec is a recording to lay out on disk: 400 bytes of video unless
+// thumbsOnly, and 100 bytes of JPEGs.
+type testRec struct {
+	label      string
+	days       int
+	session    string // default "s"
+	thumbsOnly bool
+	locked     bool
+	shrunk     bool
+	protected  bool // marks the whole session
+	writing    bool
+}
+
+// layout writes recs for camera "Left" under a temp dir and returns a
+// manager over it, with the filename of each label.
+func layout(t *testing.T, policy config.RetentionConfig, recs []testRec) (*Manager, map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	m := New(config.DVRConfig{Retention: policy}, root, time.Minute)
+	names := make(map[string]string)
+	for i, r := range recs {
+		session := r.session
+		if session == "" {
+			session = "s"
+		}
+		dir := filepath.Join(root, session)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// Later entries of the same age start a minute later.
+		start := retentionNow.Add(-time.Duration(r.days)*24*time.Hour - time.Hour + time.Duration(i)*time.Minute)
+		name := start.Format("2006-01-02_15-04-05") + "_Left"
+		names[name] = r.label
+		base := filepath.Join(dir, name)
+
+		write := func(path string, n int) {
+			if err := os.WriteFile(path, make([]byte, n), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !r.thumbsOnly {
+			write(base+".mp4", 400)
+		}
+		write(base+"_thumb.jpg", 50)
+		write(base+"_full.jpg", 50)
+		if r.locked {
+			write(base+lockSuffix, 0)
+		}
+		if r.shrunk {
+			write(base+shrunkSuffix, 0)
+		}
+		if r.protected {
+			write(filepath.Join(dir, protectedMarker), 0)
+		}
+		if r.writing {
+			m.writing[base+".mp4"] = true
+		}
+	}
+	return m, names
+}
+
+func TestPlanRetention(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		policy config.RetentionConfig
+		recs   []testRec
+		want   []string // "action label rule", in plan order
+		freed  int64
+	}{
+		{
+			name:   "age rules by severity",
+			policy: config.RetentionConfig{MaxAgeDays: 30, ThumbsOnlyDays: 14, ShrinkDays: 7},
+			recs: []testRec{
+				{label: "d40", days: 40},
+				{label: "d20", days: 20},
+				{label: "d10", days: 10},
+				{label: "d2", days: 2},
+			},
+			want:  []string{"delete d40 maxAgeDays", "thumbsOnly d20 thumbsOnlyDays", "shrink d10 shrinkDays"},
+			freed: 500 + 400,
+		},
+		{
+			name:   "nothing twice",
+			policy: config.RetentionConfig{ThumbsOnlyDays: 14, ShrinkDays: 7},
+			recs: []testRec{
+				{label: "thumbs", days: 20, thumbsOnly: true},
+				{label: "shrunk", days: 10, shrunk: true},
+			},
+		},
+		{
+			name:   "skips locked, protected and in-progress",
+			policy: config.RetentionConfig{MaxAgeDays: 30},
+			recs: []testRec{
+				{label: "locked", days: 40, locked: true},
+				{label: "protected", days: 40, session: "p", protected: true},
+				{label: "writing", days: 40, writing: true},
+				{label: "plain", days: 40},
+			},
+			want:  []string{"delete plain maxAgeDays"},
+			freed: 500,
+		},
+		{
+			name:   "size cap deletes oldest first",
+			policy: config.RetentionConfig{MaxSizeGB: sizeGB(1024)},
+			recs: []testRec{
+				{label: "d1", days: 1},
+				{label: "d4", days: 4},
+				{label: "d2", days: 2},
+				{label: "d3", days: 3},
+			},
+			want:  []string{"delete d4 maxSizeGB", "delete d3 maxSizeGB"},
+			freed: 1000,
+		},
+		{
+			name:   "size cap counts what age rules freed",
+			policy: config.RetentionConfig{MaxAgeDays: 30, MaxSizeGB: sizeGB(1024)},
+			recs: []testRec{
+				{label: "d40", days: 40},
+				{label: "d3", days: 3},
+				{label: "d2", days: 2},
+				{label: "d1", days: 1},
+			},
+			want:  []string{"delete d40 maxAgeDays", "delete d3 maxSizeGB"},
+			freed: 1000,
+		},
+		{
+			name:   "thumbnails alone fit the cap",
+			policy: config.RetentionConfig{ThumbsOnlyDays: 14, MaxSizeGB: sizeGB(1024)},
+			recs: []testRec{
+				{label: "d20", days: 20},
+				{label: "d19", days: 19},
+				{label: "d2", days: 2},
+			},
+			want:  []string{"thumbsOnly d20 thumbsOnlyDays", "thumbsOnly d19 thumbsOnlyDays"},
+			freed: 800,
+		},
+		{
+			name:   "size cap upgrades downgrades to deletes",
+			policy: config.RetentionConfig{ThumbsOnlyDays: 14, ShrinkDays: 7, MaxSizeGB: sizeGB(512)},
+			recs: []testRec{
+				{label: "d20", days: 20},
+				{label: "d10", days: 10},
+				{label: "d2", days: 2},
+			},
+			// 1500 bytes, 1100 after the thumbnails-only downgrade; upgrading
+			// d20 frees its last 100 and d10 its 500.
+			want:  []string{"delete d20 maxSizeGB", "delete d10 maxSizeGB"},
+			freed: 1000,
+		},
+		{
+			name:   "size cap counts but keeps locked recordings",
+			policy: config.RetentionConfig{MaxSizeGB: sizeGB(512)},
+			recs: []testRec{
+				{label: "locked", days: 3, locked: true},
+				{label: "d2", days: 2},
+				{label: "d1", days: 1},
+			},
+			want:  []string{"delete d2 maxSizeGB", "delete d1 maxSizeGB"},
+			freed: 1000,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m, names := layout(t, c.policy, c.recs)
+			plan, err := m.PlanRetention(retentionNow, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range plan.Actions {
+				rule, _, _ := strings.Cut(a.Reason, "=")
+				got = append(got, string(a.Action)+" "+names[a.Filename]+" "+rule)
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("actions:\n got %q\nwant %q", got, c.want)
+			}
+			if plan.FreedBytes != c.freed {
+				t.Errorf("freed %d bytes, want %d", plan.FreedBytes, c.freed)
+			}
+		})
+	}
+}
+
+func TestRetentionCameraPolicy(t *testing.T) {
+	m, _ := layout(t, config.RetentionConfig{MaxAgeDays: 30}, []testRec{{label: "d40", days: 40}})
+	m.cfg.Cameras = []config.CameraConfig{{Name: "Left", Retention: &config.RetentionConfig{MaxAgeDays: 60}}}
+	plan, err := m.PlanRetention(retentionNow, "Left")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Errorf("camera's own policy ignored: %+v", plan.Actions)
+	}
+	if plan, _ = m.PlanRetention(retentionNow, "Right"); len(plan.Actions) != 0 {
+		t.Errorf("other camera's recordings planned: %+v", plan.Actions)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	m, names := layout(t, config.RetentionConfig{MaxAgeDays: 30, ThumbsOnlyDays: 14}, []testRec{
+		{label: "d40", days: 40},
+		{label: "locked", days: 40, locked: true},
+		{label: "d20", days: 20},
+		{label: "d2", days: 2},
+	})
+	if _, err := m.applyRetention(context.Background(), retentionNow); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := m.ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rec := range recs {
+		state := "video"
+		if !rec.HasVideo {
+			state = "thumbs"
+		}
+		got = append(got, names[rec.Filename]+" "+state)
+	}
+	if want := []string{"locked video", "d20 thumbs", "d2 video"}; !slices.Equal(got, want) {
+		t.Errorf("after retention:\n got %q\nwant %q", got, want)
+	}
+
+	// A second run has nothing left to do.
+	plan, err := m.applyRetention(context.Background(), retentionNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Errorf("second run: %+v", plan.Actions)
+	}
+}
@@ -0,0 +1,163 @@
+// Package encoder decodes the quadrature signals of the panel's rotary
+// encoders into steps, with detent alignment, glitch rejection and
+// speed-based acceleration.
+package encoder
+
+import (
+	"math"
+	"time"
+)
+
+// Rest is the quadrature state the panel's encoders sit in at a detent:
+// both contacts closed, which reads as 1s through the expander's inverted
+// inputs.
+const Rest = 0b11
+
+// quadTable maps (prev<<2)|cur to a step direction for all 16 possible
+// 2-bit quadrature transitions (bit 0 = A, bit 1 = B). Valid single steps
+// are ±1; no change and 2-step jumps are 0.
+//
+// Right: 11→10→00→01→11. Left: 11→01→00→10→11.
+var quadTable = [16]int{
+	//        cur: 00  01  10  11
+	/* prev 00 */ 0, +1, -1, 0,
+	/* prev 01 */ -1, 0, 0, +1,
+	/* prev 10 */ +1, 0, 0, -1,
+	/* prev 11 */ 0, -1, +1, 0,
+}
+
+// Point is one point of an acceleration curve.
+type Point struct {
+	Speed float64 // detents per second
+	Steps float64 // steps per detent at that speed
+}
+
+// Config tunes a Decoder. The zero value is 2 transitions per detent
+// with no acceleration.
+type Config struct {
+	// TransitionsPerDetent is how many quadrature transitions the encoder
+	// makes between detents: 1, 2 or 4.
+	TransitionsPerDetent int
+	// Invert swaps left and right.
+	Invert bool
+	// Accel maps turning speed to steps per detent, interpolating between
+	// points (in increasing Speed order) and clamping at both ends. Empty
+	// means 1 step per detent at any speed.
+	Accel []Point
+}
+
+// Decoder turns successive quadrature samples into signed steps. It isn't
+// safe for concurrent use.
+type Decoder struct {
+	Config Config
+
+	// Glitches counts the transitions rejected because both signals
+	// changed at once, meaning samples were missed.
+	Glitches int
+
+	prev  uint8
+	acc   int       // transitions since the last detent, signed
+	last  time.Time // when the last detent was counted
+	dir   int       // direction of the last detent
+	carry float64   // fractional steps owed from acceleration
+}
+
+// New returns a decoder whose encoder is currently in state sample.
+func New(cfg Config, sample uint8) *Decoder {
+	return &Decoder{Config: cfg, prev: sample & 0b11}
+}
+
+// Update takes the next 2-bit sample (bit 0 = A, bit 1 = B) and returns
+// the steps it completes: 0 in between detents, otherwise ±1 scaled by
+// the acceleration curve. Positive is right.
+func (d *Decoder) Update(sample uint8, now time.Time) int {
+	cur := sample & 0b11
+	prev := d.prev
+	if cur == prev {
+		return 0
+	}
+	d.prev = cur
+
+	step := quadTable[prev<<2|cur]
+	if step == 0 {
+		// A 2-step jump has no direction; start the detent over.
+		d.Glitches++
+		d.acc = 0
+		return 0
+	}
+	d.acc += step
+
+	per := d.perDetent()
+	if d.acc > -per && d.acc < per || !d.atDetent(cur) {
+		return 0
+	}
+	dir := 1
+	if d.acc < 0 {
+		dir = -1
+	}
+	d.acc = 0
+	n := d.accelerate(dir, now)
+	if d.Config.Invert {
+		n = -n
+	}
+	return n
+}
+
+func (d *Decoder) perDetent() int {
+	switch d.Config.TransitionsPerDetent {
+	case 1, 4:
+		return d.Config.TransitionsPerDetent
+	}
+	return 2
+}
+
+// atDetent reports whether the encoder can rest in state s: every state
+// with 1 transition per detent, 00 and 11 with 2, and only Rest with 4.
+// Counting a detent only there keeps bounce on one contact from
+// completing it early.
+func (d *Decoder) atDetent(s uint8) bool {
+	switch d.perDetent() {
+	case 1:
+		return true
+	case 2:
+		return s == 0b00 || s == 0b11
+	}
+	return s == Rest
+}
+
+// accelerate returns the steps for a detent in direction dir, from the
+// time since the previous detent in the same direction.
+func (d *Decoder) accelerate(dir int, now time.Time) int {
+	speed := 0.0
+	if dir == d.dir && !d.last.IsZero() {
+		if dt := now.Sub(d.last).Seconds(); dt > 0 {
+			speed = 1 / dt
+		}
+	} else {
+		d.carry = 0
+	}
+	d.dir = dir
+	d.last = now
+
+	total := d.carry + stepsAt(d.Config.Accel, speed)
+	n := math.Floor(total)
+	d.carry = total - n
+	return dir * int(n)
+}
+
+// stepsAt interpolates the curve at speed.
+func stepsAt(curve []Point, speed float64) float64 {
+	if len(curve) == 0 {
+		return 1
+	}
+	if speed <= curve[0].Speed {
+		return curve[0].Steps
+	}
+	for i := 1; i < len(curve); i++ {
+		a, b := curve[i-1], curve[i]
+		if speed <= b.Speed {
+			return a.Steps + (b.Steps-a.Steps)*(speed-a.Speed)/(b.Speed-a.Speed)
+		}
+	}
+	return curve[len(curve)-1].Steps
+}
@@ -0,0 +1,122 @@
+package encoder
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spin feeds a decoder samples written as "BA" pairs, e.g. "11 10 00",
+// every interval, and returns the non-zero results.
+func spin(d *Decoder, t0 time.Time, interval time.Duration, samples string) []int {
+	var out []int
+	for i, s := range strings.Fields(samples) {
+		v, err := strconv.ParseUint(s, 2, 8)
+		if err != nil {
+			panic(err)
+		}
+		if n := d.Update(uint8(v), t0.Add(time.Duration(i)*interval)); n != 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+const (
+	right = "10 00 01 11" // one full cycle from rest
+	left  = "01 00 10 11"
+)
+
+func TestDetents(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	for _, c := range []struct {
+		per         int
+		right, left []int
+	}{
+		{1, []int{1, 1, 1, 1}, []int{-1, -1, -1, -1}},
+		{2, []int{1, 1}, []int{-1, -1}},
+		{4, []int{1}, []int{-1}},
+		{0, []int{1, 1}, []int{-1, -1}}, // the default is 2
+	} {
+		d := New(Config{TransitionsPerDetent: c.per}, Rest)
+		if got := spin(d, t0, time.Second, right); !slices.Equal(got, c.right) {
+			t.Errorf("%d per detent, right: %v, want %v", c.per, got, c.right)
+		}
+		if got := spin(d, t0, time.Second, left); !slices.Equal(got, c.left) {
+			t.Errorf("%d per detent, left: %v, want %v", c.per, got, c.left)
+		}
+	}
+}
+
+func TestInvert(t *testing.T) {
+	d := New(Config{TransitionsPerDetent: 4, Invert: true}, Rest)
+	if got := spin(d, time.Unix(0, 0), time.Second, right+" "+left); !slices.Equal(got, []int{-1, 1}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestBounceAndGlitches(t *testing.T) {
+	t0 := time.Unix(0, 0)
+
+	// Contact A chattering at the start of a detent cancels itself out.
+	d := New(Config{TransitionsPerDetent: 4}, Rest)
+	if got := spin(d, t0, time.Second, "10 11 10 11 10 00 01 11"); !slices.Equal(got, []int{1}) {
+		t.Errorf("bounce: %v", got)
+	}
+
+	// Turning back before reaching the next detent counts nothing.
+	if got := spin(d, t0, time.Second, "10 00 10 11"); got != nil {
+		t.Errorf("half turn and back: %v", got)
+	}
+
+	// A jump across two states has no direction: it's dropped along with
+	// the partial detent, and the next full one counts.
+	if got := spin(d, t0, time.Second, "10 01 11 "+right); !slices.Equal(got, []int{1}) {
+		t.Errorf("glitch: %v", got)
+	}
+	if d.Glitches != 1 {
+		t.Errorf("Glitches = %d", d.Glitches)
+	}
+
+	// With 2 transitions per detent, 00 and 11 are both detents; a glitch
+	// between them loses the partial detent.
+	d = New(Config{TransitionsPerDetent: 2}, Rest)
+	if got := spin(d, t0, time.Second, "10 01 11 10 00"); !slices.Equal(got, []int{1}) {
+		t.Errorf("2 per detent glitch: %v", got)
+	}
+}
+
+func TestAcceleration(t *testing.T) {
+	curve := []Point{{Speed: 5, Steps: 1}, {Speed: 25, Steps: 5}}
+	t0 := time.Unix(0, 0)
+	detents := func(n int) string { return strings.TrimSpace(strings.Repeat(right+" ", n)) }
+
+	// 4 samples per detent, so a sample every 25ms is 10 detents/s.
+	d := New(Config{TransitionsPerDetent: 4, Accel: curve}, Rest)
+	if got := spin(d, t0, 25*time.Millisecond, detents(3)); !slices.Equal(got, []int{1, 2, 2}) {
+		t.Errorf("10/s: %v", got)
+	}
+
+	// 50 detents/s is past the end of the curve.
+	d = New(Config{TransitionsPerDetent: 4, Accel: curve}, Rest)
+	if got := spin(d, t0, 5*time.Millisecond, detents(3)); !slices.Equal(got, []int{1, 5, 5}) {
+		t.Errorf("50/s: %v", got)
+	}
+
+	// Slow turns and reversals get single steps.
+	d = New(Config{TransitionsPerDetent: 4, Accel: curve}, Rest)
+	if got := spin(d, t0, 250*time.Millisecond, detents(2)); !slices.Equal(got, []int{1, 1}) {
+		t.Errorf("1/s: %v", got)
+	}
+	if got := spin(d, t0.Add(2*time.Second), 5*time.Millisecond, left+" "+left); !slices.Equal(got, []int{-1, -5}) {
+		t.Errorf("reversal: %v", got)
+	}
+
+	// Fractional steps carry over to the next detent.
+	d = New(Config{TransitionsPerDetent: 4, Accel: []Point{{0, 1}, {10, 1.5}}}, Rest)
+	if got := spin(d, t0, 25*time.Millisecond, detents(5)); !slices.Equal(got, []int{1, 1, 2, 1, 2}) {
+		t.Errorf("1.5 steps per detent: %v", got)
+	}
+}
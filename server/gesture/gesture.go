@@ -0,0 +1,262 @@
+// Package gesture recognizes long presses, double presses, chords and
+// press-and-turn on the panel's buttons and knobs. Only gestures that are
+// bound to an action are recognized; input that isn't part of one passes
+// through unchanged as key events.
+//
+// Gesture names:
+//
+//	enter, up, ...         a short press (tap) of a button
+//	enter.long             a button held for the long-press time
+//	enter.double           two taps within the double-press time
+//	up+left, enter+down    buttons pressed together (a chord)
+//	enter+inner-right      a knob step while a button is held
+//	inner-right, joy-left  a knob step
+package gesture
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Buttons are the panel's momentary inputs, in the order chord names list
+// them.
+var Buttons = []string{"enter", "up", "down", "left", "right"}
+
+// Turns are single detent steps of the panel's knobs.
+var Turns = []string{"inner-left", "inner-right", "outer-left", "outer-right", "joy-left", "joy-right"}
+
+// Normalize checks a gesture name and returns it in canonical form, with
+// chord buttons in Buttons order.
+func Normalize(name string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(name), "+")
+	if len(parts) == 1 {
+		base, suffix, _ := strings.Cut(parts[0], ".")
+		switch {
+		case slices.Contains(Turns, parts[0]):
+			return parts[0], nil
+		case !slices.Contains(Buttons, base):
+			return "", fmt.Errorf("unknown gesture %q", name)
+		case suffix == "" || suffix == "long" || suffix == "double":
+			return parts[0], nil
+		default:
+			return "", fmt.Errorf("unknown gesture %q: want .long or .double", name)
+		}
+	}
+
+	var buttons []string
+	turn := ""
+	for i, p := range parts {
+		switch {
+		case slices.Contains(Turns, p) && i == len(parts)-1:
+			turn = p
+		case !slices.Contains(Buttons, p):
+			return "", fmt.Errorf("unknown gesture %q: %q isn't a button", name, p)
+		case slices.Contains(buttons, p):
+			return "", fmt.Errorf("unknown gesture %q: %s is repeated", name, p)
+		default:
+			buttons = append(buttons, p)
+		}
+	}
+	if turn != "" && len(buttons) != 1 {
+		return "", fmt.Errorf("unknown gesture %q: hold one button while turning", name)
+	}
+	slices.SortFunc(buttons, func(a, b string) int {
+		return slices.Index(Buttons, a) - slices.Index(Buttons, b)
+	})
+	if turn != "" {
+		buttons = append(buttons, turn)
+	}
+	return strings.Join(buttons, "+"), nil
+}
+
+// Kind is what an Event reports.
+type Kind int
+
+const (
+	KeyDown  Kind = iota // a button went down
+	KeyUp                // a button went up
+	KeyPress             // a knob stepped
+	Gesture              // a bound gesture was recognized
+)
+
+// Event is a key event to pass on, or a recognized gesture. Name is the
+// logical key or the canonical gesture name.
+type Event struct {
+	Kind Kind
+	Name string
+}
+
+func (e Event) String() string {
+	return [...]string{"keydown", "keyup", "keypress", "gesture"}[e.Kind] + " " + e.Name
+}
+
+// press is a button being held.
+type press struct {
+	at       time.Time
+	deferred bool // part of a bound gesture, so its key events wait for release
+	consumed bool // a gesture used it, so releasing it does nothing
+}
+
+// Recognizer turns button and knob input into events. It isn't safe for
+// concurrent use; the input loop owns it and calls Tick when Next says.
+type Recognizer struct {
+	LongPress   time.Duration
+	DoublePress time.Duration
+
+	bound    map[string]bool
+	involved map[string]bool // buttons that are part of a bound gesture
+	held     map[string]*press
+	pending  map[string]time.Time // released taps waiting for a second press
+}
+
+// New returns a recognizer with no gestures bound, which passes every
+// input through.
+func New(longPress, doublePress time.Duration) *Recognizer {
+	return &Recognizer{
+		LongPress:   longPress,
+		DoublePress: doublePress,
+		held:        make(map[string]*press),
+		pending:     make(map[string]time.Time),
+	}
+}
+
+// SetBound sets the gestures to recognize. Names must be canonical (see
+// Normalize). Buttons already down keep the behavior they had when
+// pressed.
+func (r *Recognizer) SetBound(names []string) {
+	r.bound = make(map[string]bool, len(names))
+	r.involved = make(map[string]bool)
+	for _, name := range names {
+		r.bound[name] = true
+		for _, p := range strings.Split(name, "+") {
+			base, _, _ := strings.Cut(p, ".")
+			if slices.Contains(Buttons, base) {
+				r.involved[base] = true
+			}
+		}
+	}
+}
+
+// Press reports that button went down.
+func (r *Recognizer) Press(button string, now time.Time) []Event {
+	if !r.involved[button] {
+		r.held[button] = &press{at: now}
+		return []Event{{KeyDown, button}}
+	}
+
+	p := &press{at: now, deferred: true}
+	r.held[button] = p
+	if t, ok := r.pending[button]; ok && now.Sub(t) <= r.DoublePress {
+		delete(r.pending, button)
+		p.consumed = true
+		return append(r.flush(), Event{Gesture, button + ".double"})
+	}
+	out := r.flush()
+
+	var chord []string
+	for _, b := range Buttons {
+		if h := r.held[b]; h != nil && h.deferred && !h.consumed {
+			chord = append(chord, b)
+		}
+	}
+	if name := strings.Join(chord, "+"); len(chord) > 1 && r.bound[name] {
+		for _, b := range chord {
+			r.held[b].consumed = true
+		}
+		out = append(out, Event{Gesture, name})
+	}
+	return out
+}
+
+// Release reports that button went up.
+func (r *Recognizer) Release(button string, now time.Time) []Event {
+	p := r.held[button]
+	if p == nil {
+		return nil
+	}
+	delete(r.held, button)
+	switch {
+	case !p.deferred:
+		return []Event{{KeyUp, button}}
+	case p.consumed:
+		return nil
+	case r.bound[button+".double"]:
+		r.pending[button] = now
+		return nil
+	}
+	return r.tap(button)
+}
+
+// Turn reports a knob step, one of Turns.
+func (r *Recognizer) Turn(turn string, now time.Time) []Event {
+	out := r.flush()
+	for _, b := range Buttons {
+		if p := r.held[b]; p != nil && p.deferred && r.bound[b+"+"+turn] {
+			p.consumed = true
+			return append(out, Event{Gesture, b + "+" + turn})
+		}
+	}
+	if r.bound[turn] {
+		return append(out, Event{Gesture, turn})
+	}
+	return append(out, Event{KeyPress, turn})
+}
+
+// Tick fires the long presses and ends the double-press waits that are due.
+func (r *Recognizer) Tick(now time.Time) []Event {
+	var out []Event
+	for _, b := range Buttons {
+		if p := r.held[b]; p != nil && p.deferred && !p.consumed &&
+			r.bound[b+".long"] && now.Sub(p.at) >= r.LongPress {
+			p.consumed = true
+			out = append(out, Event{Gesture, b + ".long"})
+		}
+		if t, ok := r.pending[b]; ok && now.Sub(t) >= r.DoublePress {
+			delete(r.pending, b)
+			out = append(out, r.tap(b)...)
+		}
+	}
+	return out
+}
+
+// Next returns when Tick next has something to do, if anything.
+func (r *Recognizer) Next() (time.Time, bool) {
+	var next time.Time
+	earlier := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	for b, p := range r.held {
+		if p.deferred && !p.consumed && r.bound[b+".long"] {
+			earlier(p.at.Add(r.LongPress))
+		}
+	}
+	for _, t := range r.pending {
+		earlier(t.Add(r.DoublePress))
+	}
+	return next, !next.IsZero()
+}
+
+// flush ends every double-press wait early because other input came in.
+func (r *Recognizer) flush() []Event {
+	var out []Event
+	for _, b := range Buttons {
+		if _, ok := r.pending[b]; ok {
+			delete(r.pending, b)
+			out = append(out, r.tap(b)...)
+		}
+	}
+	return out
+}
+
+// tap is a short press of a deferred button: its bound gesture, or the
+// key events it held back.
+func (r *Recognizer) tap(button string) []Event {
+	if r.bound[button] {
+		return []Event{{Gesture, button}}
+	}
+	return []Event{{KeyDown, button}, {KeyUp, button}}
+}
@@ -0,0 +1,185 @@
+package gesture
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"enter", "enter"},
+		{"enter.long", "enter.long"},
+		{"up.double", "up.double"},
+		{"left+up", "up+left"},
+		{"right+enter+down", "enter+down+right"},
+		{"enter+inner-right", "enter+inner-right"},
+		{"joy-left", "joy-left"},
+		{"enter.triple", ""},
+		{"knob", ""},
+		{"up+up", ""},
+		{"inner-left+enter", ""},
+		{"up+left+outer-right", ""},
+	} {
+		got, err := Normalize(c.in)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("Normalize(%q) = %q, want error", c.in, got)
+			}
+		} else if got != c.want || err != nil {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
+
+// script drives a recognizer with input at millisecond offsets and
+// collects the events as strings.
+type script struct {
+	r   *Recognizer
+	t0  time.Time
+	out []string
+}
+
+func newScript(bound ...string) *script {
+	r := New(500*time.Millisecond, 300*time.Millisecond)
+	r.SetBound(bound)
+	return &script{r: r, t0: time.Unix(0, 0)}
+}
+
+func (s *script) at(ms int) time.Time { return s.t0.Add(time.Duration(ms) * time.Millisecond) }
+
+func (s *script) add(events []Event) {
+	for _, e := range events {
+		s.out = append(s.out, e.String())
+	}
+}
+
+func (s *script) press(ms int, b string)   { s.tick(ms); s.add(s.r.Press(b, s.at(ms))) }
+func (s *script) release(ms int, b string) { s.tick(ms); s.add(s.r.Release(b, s.at(ms))) }
+func (s *script) turn(ms int, k string)    { s.tick(ms); s.add(s.r.Turn(k, s.at(ms))) }
+
+// tick runs Tick whenever Next asks for it, up to ms, as the input loop's
+// timer would.
+func (s *script) tick(ms int) {
+	for {
+		next, ok := s.r.Next()
+		if !ok || next.After(s.at(ms)) {
+			return
+		}
+		s.add(s.r.Tick(next))
+	}
+}
+
+func (s *script) check(t *testing.T, want ...string) {
+	t.Helper()
+	if !slices.Equal(s.out, want) {
+		t.Errorf("events:\n got %q\nwant %q", s.out, want)
+	}
+	s.out = nil
+}
+
+func TestUnboundPassesThrough(t *testing.T) {
+	s := newScript()
+	s.press(0, "enter")
+	s.turn(100, "inner-right")
+	s.release(2000, "enter")
+	s.check(t, "keydown enter", "keypress inner-right", "keyup enter")
+}
+
+func TestLongPress(t *testing.T) {
+	s := newScript("enter.long")
+
+	// A short press is delayed to the release, then passed through.
+	s.press(0, "enter")
+	s.release(200, "enter")
+	s.check(t, "keydown enter", "keyup enter")
+
+	s.press(1000, "enter")
+	s.tick(1499)
+	s.check(t)
+	s.tick(1500)
+	s.check(t, "gesture enter.long")
+	s.release(3000, "enter")
+	s.check(t)
+
+	// Other buttons are untouched.
+	s.press(4000, "up")
+	s.check(t, "keydown up")
+}
+
+func TestDoublePress(t *testing.T) {
+	s := newScript("up.double")
+
+	s.press(0, "up")
+	s.release(100, "up")
+	s.press(250, "up")
+	s.release(300, "up")
+	s.check(t, "gesture up.double")
+
+	// A single tap waits out the double-press time.
+	s.press(1000, "up")
+	s.release(1100, "up")
+	s.tick(1399)
+	s.check(t)
+	s.tick(1400)
+	s.check(t, "keydown up", "keyup up")
+
+	// Other input ends the wait early, keeping the order.
+	s.press(2000, "up")
+	s.release(2100, "up")
+	s.turn(2150, "outer-left")
+	s.check(t, "keydown up", "keyup up", "keypress outer-left")
+}
+
+func TestChord(t *testing.T) {
+	s := newScript("up+left", "up.long")
+
+	// The joystick presses both directions in the same change.
+	s.press(0, "up")
+	s.press(0, "left")
+	s.release(100, "up")
+	s.release(100, "left")
+	s.check(t, "gesture up+left")
+
+	// Either alone is a plain tap.
+	s.press(1000, "left")
+	s.release(1100, "left")
+	s.check(t, "keydown left", "keyup left")
+
+	// A chord consumes its buttons, so no long press follows.
+	s.press(2000, "left")
+	s.press(2000, "up")
+	s.release(3000, "up")
+	s.release(3000, "left")
+	s.check(t, "gesture up+left")
+}
+
+func TestPressAndTurn(t *testing.T) {
+	s := newScript("enter+inner-right", "outer-left")
+
+	s.press(0, "enter")
+	s.turn(100, "inner-right")
+	s.turn(150, "inner-right")
+	s.turn(200, "inner-left")
+	s.release(300, "enter")
+	s.check(t, "gesture enter+inner-right", "gesture enter+inner-right", "keypress inner-left")
+
+	// Without turning, enter is an ordinary press.
+	s.press(1000, "enter")
+	s.release(1100, "enter")
+	s.turn(1200, "inner-right")
+	s.turn(1300, "outer-left")
+	s.check(t, "keydown enter", "keyup enter", "keypress inner-right", "gesture outer-left")
+}
+
+func TestRebindWhileHeld(t *testing.T) {
+	s := newScript("enter.long")
+	s.press(0, "enter")
+	s.r.SetBound(nil)
+	s.release(100, "enter")
+	s.press(200, "enter")
+	s.check(t, "keydown enter", "keyup enter", "keydown enter")
+	if _, ok := s.r.Next(); ok {
+		t.Error("timer still wanted after unbinding")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/gesture"
+	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/music"
+)
+
+// gestureBindings returns the gesture bindings in effect on the panel's
+// current page, keyed by canonical gesture name. A binding for the
+// longest matching route wins.
+func (h *Hub) gestureBindings() map[string]config.GestureBinding {
+	h.mu.RLock()
+	page := h.panelRoute
+	h.mu.RUnlock()
+
+	out := make(map[string]config.GestureBinding)
+	depth := make(map[string]int)
+	for _, b := range h.cfg.UI.Gestures.Bindings {
+		name, err := gesture.Normalize(b.Gesture)
+		if err != nil {
+			continue
+		}
+		route := strings.Trim(b.Route, "/")
+		if route != "" && page != route && !strings.HasPrefix(page, route+"/") {
+			continue
+		}
+		if d, ok := depth[name]; ok && d > len(route) {
+			continue
+		}
+		out[name] = b
+		depth[name] = len(route)
+	}
+	return out
+}
+
+// syncGestures brings r up to date with the config and the panel's page,
+// and returns the bindings it recognizes.
+func (h *Hub) syncGestures(r *gesture.Recognizer) map[string]config.GestureBinding {
+	gc := h.cfg.UI.Gestures
+	r.LongPress = time.Duration(gc.LongPressMs) * time.Millisecond
+	r.DoublePress = time.Duration(gc.DoublePressMs) * time.Millisecond
+	bindings := h.gestureBindings()
+	r.SetBound(slices.Collect(maps.Keys(bindings)))
+	return bindings
+}
+
+// emitInput dispatches recognizer events: keys to the browser, gestures to
+// their bound actions.
+func (h *Hub) emitInput(events []gesture.Event, bindings map[string]config.GestureBinding) {
+	for _, ev := range events {
+		switch ev.Kind {
+		case gesture.KeyDown:
+			h.dispatchLogical(input.KeyDown, ev.Name)
+		case gesture.KeyUp:
+			h.dispatchLogical(input.KeyUp, ev.Name)
+		case gesture.KeyPress:
+			h.sendLogical(ev.Name)
+		case gesture.Gesture:
+			h.runGesture(bindings[ev.Name])
+		}
+	}
+}
+
+// runGesture performs a bound gesture's action. Actions that can block
+// run in the background so they don't hold up the input loop.
+func (h *Hub) runGesture(b config.GestureBinding) {
+	switch b.Action {
+	case "key":
+		h.sendLogical(b.Target)
+	case "music":
+		h.musicGesture(b.Target, b.Value)
+	case "led":
+		go h.handleLEDMsg(b.Target, b.Mode, int(b.Value), 0)
+	case "bookmark":
+		go h.bookmark()
+	case "camera":
+		h.switchCamera(b.Target)
+	case "navigate":
+		go h.navigate(b.Target)
+	case "aircon":
+		go h.adjustAirCon(b.Target, b.Value)
+	}
+}
+
+// musicGesture sends a player command to the default zone. playPause and
+// volume (a relative step) are gesture shorthands.
+func (h *Hub) musicGesture(command string, value float64) {
+	h.mu.RLock()
+	mp := h.musicPlayer
+	h.mu.RUnlock()
+	if mp == nil {
+		return
+	}
+	msg := music.ControlMsg{Action: command, Value: value}
+	switch command {
+	case "playPause":
+		msg.Action = "play"
+		if states := mp.StateMsgs(); len(states) > 0 && states[0].Status == "playing" {
+			msg.Action = "pause"
+		}
+	case "volume":
+		msg.Action = "adjustVolume"
+	}
+	mp.Control(msg)
+}
+
+// bookmark locks the recordings being written right now.
+func (h *Hub) bookmark() {
+	if h.dvrManager == nil {
+		log.Println("gesture: bookmark: DVR not running")
+		return
+	}
+	n, err := h.dvrManager.Bookmark()
+	if err != nil {
+		log.Println("gesture: bookmark error:", err)
+		return
+	}
+	log.Printf("gesture: bookmarked %d recordings", n)
+}
+
+// switchCamera shows the named camera on the panel, or steps through the
+// configured cameras for "next" and "prev".
+func (h *Hub) switchCamera(target string) {
+	name := target
+	if target == "next" || target == "prev" {
+		cams := h.cfg.DVR.Cameras
+		if len(cams) == 0 {
+			return
+		}
+		h.mu.RLock()
+		cur := h.localCamera
+		h.mu.RUnlock()
+		i := slices.IndexFunc(cams, func(c config.CameraConfig) bool { return c.Name == cur })
+		step := 1
+		if target == "prev" {
+			step = -1
+			i = max(i, 0)
+		}
+		name = cams[(i+step+len(cams))%len(cams)].Name
+	}
+	h.setLocalCamera(name)
+}
+
+// adjustAirCon steps an aircon setting; only the setpoint has one.
+func (h *Hub) adjustAirCon(field string, step float64) {
+	ac := hardware.AirCon()
+	if ac == nil || field != "setpoint" {
+		return
+	}
+	if err := ac.SetSetpoint(ac.GetState().Setpoint + step); err != nil {
+		log.Println("gesture: setpoint error:", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/gorilla/websocket"
+	"github.com/vincent99/velocipi/server/auth"
 	"github.com/vincent99/velocipi/server/music"
 )
 
@@ -31,10 +32,9 @@ func spaHandler(dir string) http.Handler {
 	})
 }
 
-// isAdmin reports whether r carries a valid admin cookie.
+// isAdmin reports whether r was made by a logged-in admin.
 func isAdmin(r *http.Request) bool {
-	c, err := r.Cookie("admin")
-	return err == nil && c.Value == "true"
+	return auth.RoleFrom(r) == auth.RoleAdmin
 }
 
 // corsMiddleware adds CORS headers to all responses.
@@ -52,13 +52,18 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func wsHandler(w http.ResponseWriter, r *http.Request) {
+	role := auth.RoleFrom(r)
+	if role == auth.RoleNone {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("websocket upgrade error:", err)
 		return
 	}
 
-	c := &client{conn: conn, send: make(chan []byte, 32)}
+	c := &client{conn: conn, send: make(chan []byte, 32), role: role}
 	hub.register(c)
 	log.Println("websocket client connected:", r.RemoteAddr)
 	go hub.sendReading(c)
@@ -70,6 +75,8 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	go hub.sendMusicState(c)
 	go hub.sendMusicQueue(c)
 	go hub.sendAirConState(c)
+	go hub.sendThermalState(c)
+	go hub.sendCameraCapabilities(c)
 
 	// Write pump: drains c.send and writes to the WebSocket connection.
 	go func() {
@@ -95,6 +102,10 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		if err := json.Unmarshal(data, &msg); err != nil {
 			continue
 		}
+		if !c.role.CanSend(msg.Type) {
+			log.Printf("websocket: %s (%s) may not send %q", r.RemoteAddr, c.role, msg.Type)
+			continue
+		}
 		switch msg.Type {
 		case "reload":
 			go hub.reload()
@@ -106,7 +117,7 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		case "led":
 			var lm inboundLEDMsg
 			if err := json.Unmarshal(data, &lm); err == nil {
-				go hub.handleLEDMsg(lm.Channel, lm.State, lm.Rate)
+				go hub.handleLEDMsg(lm.Channel, lm.State, lm.Rate, lm.TTL)
 			}
 		case "navigate":
 			var nm inboundNavigateMsg
@@ -118,6 +129,13 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 			if err := json.Unmarshal(data, &pm); err == nil {
 				go hub.setLocalCamera(pm.Camera)
 			}
+		case "cameraControl":
+			var cm inboundCameraControlMsg
+			if err := json.Unmarshal(data, &cm); err == nil {
+				// Not in a goroutine: a joystick release (move 0,0) must
+				// not overtake the move it stops.
+				hub.handleCameraControl(cm)
+			}
 		case "musicControl":
 			hub.mu.RLock()
 			mp := hub.musicPlayer
@@ -125,7 +143,7 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 			if mp != nil {
 				var mc inboundMusicControlMsg
 				if err := json.Unmarshal(data, &mc); err == nil {
-					go mp.Control(music.ControlMsg{Action: mc.Action, Value: mc.Value, Str: mc.Str})
+					go mp.Control(music.ControlMsg{Action: mc.Action, Value: mc.Value, Str: mc.Str, Zone: mc.Zone})
 				}
 			}
 		}
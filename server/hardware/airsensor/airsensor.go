@@ -49,7 +49,7 @@ type Calibration struct {
 }
 
 type AirSensor struct {
-	iface             *i2c.I2C
+	iface             i2c.Bus
 	config            *Config
 	calibration       *Calibration
 	tFine             int32
@@ -133,20 +133,27 @@ func NewAirSensorWithOptions(opt *Config) (*AirSensor, error) {
 	}
 
 	iface, err := i2c.New(opt.Device, address)
+	if err != nil {
+		return newAirSensor(iface, opt), err
+	}
+	return NewAirSensorWithBus(iface, opt)
+}
+
+// NewAirSensorWithBus initializes a sensor on an already-open bus; opt's
+// Address and Device are ignored.
+func NewAirSensorWithBus(bus i2c.Bus, opt *Config) (*AirSensor, error) {
+	v := newAirSensor(bus, opt)
+	return v, v.Init()
+}
 
-	v := &AirSensor{
-		iface:             iface,
+func newAirSensor(bus i2c.Bus, opt *Config) *AirSensor {
+	return &AirSensor{
+		iface:             bus,
 		config:            opt,
 		calibration:       &Calibration{},
 		referencePressure: 101325.0,
 		tFine:             0,
 	}
-
-	if err != nil {
-		return v, err
-	}
-
-	return v, v.Init()
 }
 
 func (v *AirSensor) Init() error {
@@ -0,0 +1,63 @@
+//go:build linux
+
+package airsensor
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/vincent99/velocipi/server/hardware/i2c/i2ctest"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Mode:               NORMAL,
+		Standby:            SB_1,
+		Filter:             FILTER_2,
+		TempOversample:     OS_16,
+		PressureOversample: OS_16,
+		HumidityOversample: OS_16,
+	}
+}
+
+func TestRead(t *testing.T) {
+	chip := i2ctest.NewBME280()
+	s, err := NewAirSensorWithBus(i2ctest.NewFake(chip), testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chip.Register(CONFIG_MEAS_RES); got != 0b101_101_11 {
+		t.Errorf("ctrl_meas = %08b", got)
+	}
+
+	for _, c := range []struct{ tempC, pressurePa, humidity float64 }{
+		{20, 101325, 50},
+		{-12.5, 84300, 18},
+		{38.2, 99000, 83},
+	} {
+		chip.Set(c.tempC, c.pressurePa, c.humidity)
+		r, err := s.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		near := func(name string, got, want, tol float64) {
+			if math.Abs(got-want) > tol {
+				t.Errorf("%+v: %s = %v, want %v", c, name, got, want)
+			}
+		}
+		near("TempC", float64(r.TempC), c.tempC, 0.02)
+		near("TempF", float64(r.TempF), c.tempC*9/5+32, 0.04)
+		near("PressureInches", float64(r.PressureInches), c.pressurePa/3386.39, 0.01)
+		near("Humidity", float64(r.Humidity), c.humidity, 0.1)
+	}
+}
+
+func TestMissingSensor(t *testing.T) {
+	bus := i2ctest.NewFake(i2ctest.NewBME280())
+	bus.Fail(errors.New("remote I/O error"))
+	if _, err := NewAirSensorWithBus(bus, testConfig()); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("err = %v", err)
+	}
+}
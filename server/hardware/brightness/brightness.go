@@ -67,6 +67,7 @@ type Brightness struct {
 	maxLux float64
 
 	mu        sync.Mutex
+	reconfig  chan struct{} // signals Run that speed changed
 	listeners []Handler
 	samples   []sample
 	haveGood  bool
@@ -77,29 +78,58 @@ type Brightness struct {
 // New never fails -- there's no configuration or missing hardware that
 // should prevent this package from running (see the package doc).
 func New(cfg Config) *Brightness {
-	maxLux := cfg.MaxLux
-	if maxLux == 0 {
-		maxLux = 100
-	}
-	delay := cfg.Delay
-	if delay <= 0 {
-		delay = 2 * time.Second
-	}
-	speed := cfg.Speed
-	if speed <= 0 {
-		speed = 2 * time.Second
-	}
-	return &Brightness{
-		sensor: cfg.Sensor,
-		delay:  delay,
-		speed:  speed,
-		minLux: cfg.MinLux,
-		maxLux: maxLux,
+	b := &Brightness{
+		sensor:   cfg.Sensor,
+		reconfig: make(chan struct{}, 1),
 		// Starting point before the first real sample (or forever, with no
 		// working sensor) is full brightness -- never start dark.
 		target:  100,
 		current: 100,
 	}
+	b.setConfig(cfg)
+	return b
+}
+
+// Reconfigure applies new Delay/Speed/MinLux/MaxLux settings to a running
+// engine (cfg.Sensor is ignored; the sensor can't be swapped live). The new
+// lux range takes effect with the next sample, the new speed with the next
+// ramp step.
+func (b *Brightness) Reconfigure(cfg Config) {
+	b.mu.Lock()
+	b.setConfig(cfg)
+	b.mu.Unlock()
+	select {
+	case b.reconfig <- struct{}{}:
+	default:
+	}
+}
+
+// setConfig applies cfg's settings and defaults. Caller holds b.mu (or
+// owns b exclusively).
+func (b *Brightness) setConfig(cfg Config) {
+	b.maxLux = cfg.MaxLux
+	if b.maxLux == 0 {
+		b.maxLux = 100
+	}
+	b.minLux = cfg.MinLux
+	b.delay = cfg.Delay
+	if b.delay <= 0 {
+		b.delay = 2 * time.Second
+	}
+	b.speed = cfg.Speed
+	if b.speed <= 0 {
+		b.speed = 2 * time.Second
+	}
+}
+
+// rampInterval is the time between ramp steps.
+func (b *Brightness) rampInterval() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d := b.speed / rampSteps; d > 0 {
+		return d
+	}
+	return 50 * time.Millisecond
 }
 
 // Subscribe registers fn to be called with every new current percentage --
@@ -131,11 +161,7 @@ func (b *Brightness) Run(ctx context.Context) {
 	sampleTicker := time.NewTicker(sampleInterval)
 	defer sampleTicker.Stop()
 
-	rampInterval := b.speed / rampSteps
-	if rampInterval <= 0 {
-		rampInterval = 50 * time.Millisecond
-	}
-	rampTicker := time.NewTicker(rampInterval)
+	rampTicker := time.NewTicker(b.rampInterval())
 	defer rampTicker.Stop()
 
 	var rampStep float64
@@ -145,6 +171,9 @@ func (b *Brightness) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 
+		case <-b.reconfig:
+			rampTicker.Reset(b.rampInterval())
+
 		case <-sampleTicker.C:
 			b.sample()
 			newTarget := b.recomputeTarget()
@@ -190,10 +219,10 @@ func (b *Brightness) sample() {
 		return
 	}
 
-	pct := luxToPct(lux, b.minLux, b.maxLux)
 	now := time.Now()
 
 	b.mu.Lock()
+	pct := luxToPct(lux, b.minLux, b.maxLux)
 	b.haveGood = true
 	b.samples = append(b.samples, sample{at: now, pct: pct})
 	cutoff := now.Add(-b.delay)
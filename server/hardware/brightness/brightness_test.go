@@ -0,0 +1,70 @@
+//go:build linux
+
+package brightness
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/i2c/i2ctest"
+	"github.com/vincent99/velocipi/server/hardware/lightsensor"
+)
+
+func TestTargetFollowsSensor(t *testing.T) {
+	chip := i2ctest.NewVEML6030()
+	bus := i2ctest.NewFake(chip)
+	sensor, err := lightsensor.NewLightSensorWithBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lux := func() float64 {
+		v, err := sensor.GetAmbientLux()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	chip.SetLux(1)
+	low := lux()
+	chip.SetLux(3)
+	high := lux()
+
+	b := New(Config{Sensor: sensor, Delay: time.Hour, MinLux: low, MaxLux: high})
+	if got := b.recomputeTarget(); got != 100 {
+		t.Errorf("target before any samples = %v, want 100", got)
+	}
+
+	chip.SetLux(1)
+	b.sample()
+	if got := b.recomputeTarget(); got != 0 {
+		t.Errorf("target at MinLux = %v, want 0", got)
+	}
+
+	// The target is the average over the Delay window, so one bright
+	// sample only moves it halfway.
+	chip.SetLux(3)
+	b.sample()
+	if got := b.recomputeTarget(); got != 50 {
+		t.Errorf("target after a bright sample = %v, want 50", got)
+	}
+
+	// Read errors are skipped rather than treated as darkness.
+	bus.Fail(errors.New("remote I/O error"))
+	b.sample()
+	if got := b.recomputeTarget(); got != 50 {
+		t.Errorf("target after a failed read = %v, want 50", got)
+	}
+}
+
+func TestNoSensorHoldsFull(t *testing.T) {
+	b := New(Config{})
+	b.sample()
+	if got := b.recomputeTarget(); got != 100 {
+		t.Errorf("target = %v, want 100", got)
+	}
+	if got := luxToPct(50, 0, 100); got != 50 {
+		t.Errorf("luxToPct(50, 0, 100) = %v", got)
+	}
+}
@@ -3,6 +3,8 @@
 package expander
 
 import (
+	"log"
+	"sync"
 	"time"
 
 	"github.com/vincent99/velocipi/server/config"
@@ -18,18 +20,41 @@ const (
 	INTERRUPT_ENABLE  = 0x04
 	INTERRUPT_MODE    = 0x08
 	INTERRUPT_COMPARE = 0x06
+	IO_CONF           = 0x0A
 	INTERRUPT         = 0x0E
 	INTERRUPT_VALUE   = 0x10
 	INPUT_VALUE       = 0x12
 	OUTPUT_VALUE      = 0x14
+
+	IO_CONF_MIRROR     = 0x40 // INTA and INTB both report either port
+	IO_CONF_OPEN_DRAIN = 0x04 // INT pins are open-drain, active low
+)
+
+// IRQ is the host GPIO line wired to the expander's INT output.
+type IRQ interface {
+	// Edges receives when the line goes active.
+	Edges() <-chan struct{}
+	// Active reports whether the line is active now.
+	Active() bool
+	Close() error
+}
+
+// checkInterval is how often the interrupt handler looks for changes it
+// wasn't told about, and checkMisses how many in a row mean the INT line
+// isn't wired.
+var (
+	checkInterval = 100 * time.Millisecond
+	checkMisses   = 3
 )
 
 type Expander struct {
-	iface    *i2c.I2C
+	iface    i2c.Bus
 	interval time.Duration
 	previous uint16
 	updates  chan Change
 	stop     chan struct{}
+
+	writeMu sync.Mutex // serializes read-modify-write of the outputs
 }
 
 type Change struct {
@@ -49,16 +74,57 @@ func New() (*Expander, error) {
 		return nil, err
 	}
 
+	return NewWithBus(iface, cfg.ExpanderIntervalDur), nil
+}
+
+// NewWithBus returns an expander on an already-open bus that polls its
+// inputs every interval once Init is called.
+func NewWithBus(bus i2c.Bus, interval time.Duration) *Expander {
 	return &Expander{
-		iface:    iface,
-		interval: cfg.ExpanderIntervalDur,
+		iface:    bus,
+		interval: interval,
 		updates:  make(chan Change, 16),
 		stop:     make(chan struct{}),
-	}, nil
+	}
 }
 
-// Init configures the expander. inputs is a bitmask where 1 = input pin, 0 = output pin.
+// Init configures the expander and polls its inputs. inputs is a bitmask where 1 = input pin, 0 = output pin.
 func (e *Expander) Init(inputs uint16) error {
+	if err := e.setup(inputs); err != nil {
+		return err
+	}
+	go e.poll()
+	return nil
+}
+
+// InitInterrupt configures the expander like Init, but reads the inputs
+// only when irq reports a change. If changes turn up without interrupts,
+// the line isn't wired and the expander falls back to polling. irq is
+// closed along with the expander, or on error.
+func (e *Expander) InitInterrupt(inputs uint16, irq IRQ) error {
+	err := e.setup(inputs)
+	if err == nil {
+		err = e.iface.WriteRegisterU8(IO_CONF, IO_CONF_MIRROR|IO_CONF_OPEN_DRAIN)
+	}
+	if err == nil {
+		// Interrupt on any change from the previous value.
+		err = e.SetInterrupts(inputs, 0, 0)
+	}
+	if err == nil {
+		// Clear anything that changed while configuring.
+		_, err = e.iface.ReadRegister(INTERRUPT, 6)
+	}
+	if err != nil {
+		irq.Close()
+		return err
+	}
+	go e.watch(irq)
+	return nil
+}
+
+// setup sets pin directions, polarity and pull-ups, and reads the initial
+// input state.
+func (e *Expander) setup(inputs uint16) error {
 	// log.Printf("expander: init  inputs(dir)=%016b  outputs=%016b", inputs, ^inputs)
 
 	if err := e.SetDirection(inputs); err != nil {
@@ -79,8 +145,6 @@ func (e *Expander) Init(inputs uint16) error {
 	}
 	// log.Printf("expander: initial input state=%016b", val)
 	e.previous = val
-
-	go e.poll()
 	return nil
 }
 
@@ -89,7 +153,7 @@ func (e *Expander) Updates() <-chan Change {
 	return e.updates
 }
 
-// Close stops the polling goroutine.
+// Close stops the polling or interrupt goroutine.
 func (e *Expander) Close() {
 	close(e.stop)
 }
@@ -108,22 +172,80 @@ func (e *Expander) poll() {
 			if err != nil {
 				continue
 			}
+			e.update(value)
+		}
+	}
+}
+
+// watch services the expander's interrupts until Close. Edges can be
+// missed, so it also checks every checkInterval; if those checks keep
+// finding interrupts that never reached irq, it switches to polling.
+func (e *Expander) watch(irq IRQ) {
+	defer irq.Close()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
 
-			previous := e.previous
-			if value == previous {
+	misses := 0
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-irq.Edges():
+			misses = 0
+			// Another input can change between the read and the
+			// interrupt clearing, leaving the line active without a
+			// new edge.
+			for e.service() && irq.Active() {
+			}
+		case <-ticker.C:
+			if !e.service() {
+				misses = 0
 				continue
 			}
-			e.previous = value
-			// log.Printf("expander: input change  prev=%016b  now=%016b  diff=%016b", previous, value, previous^value)
-
-			select {
-			case e.updates <- Change{Value: value, Previous: previous}:
-			default:
+			if misses++; misses >= checkMisses {
+				log.Println("expander: no interrupts from the INT line, polling instead")
+				go e.poll()
+				return
 			}
 		}
 	}
 }
 
+// service reads the interrupt flags, the inputs captured when the
+// interrupt fired and the current inputs in one transfer, which also
+// clears the interrupt. It reports whether an interrupt was pending.
+func (e *Expander) service() bool {
+	buf, err := e.iface.ReadRegister(INTERRUPT, 6)
+	if err != nil {
+		return false
+	}
+	flags := uint16(buf[0]) | uint16(buf[1])<<8
+	captured := uint16(buf[2]) | uint16(buf[3])<<8
+	value := uint16(buf[4]) | uint16(buf[5])<<8
+	if flags != 0 {
+		// The captured state keeps the intermediate step of a fast
+		// encoder transition that has moved on by the time it's read.
+		e.update(captured)
+	}
+	e.update(value)
+	return flags != 0
+}
+
+// update sends a Change if value differs from the last input state.
+func (e *Expander) update(value uint16) {
+	previous := e.previous
+	if value == previous {
+		return
+	}
+	e.previous = value
+	// log.Printf("expander: input change  prev=%016b  now=%016b  diff=%016b", previous, value, previous^value)
+
+	select {
+	case e.updates <- Change{Value: value, Previous: previous}:
+	default:
+	}
+}
+
 // --- Configuration ---
 
 func (e *Expander) SetDirection(pins uint16) error {
@@ -182,10 +304,14 @@ func (e *Expander) Read() (uint16, error) {
 }
 
 // Write sets output pins. If mask is 0xFFFF all pins are written directly.
-// Otherwise only the masked bits are changed.
+// Otherwise only the masked bits are changed, starting from the output
+// latches so that reading doesn't clear a pending input interrupt. Writes
+// from several goroutines don't undo each other's bits.
 func (e *Expander) Write(value, mask uint16) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
 	if mask != 0xFFFF {
-		cur, err := e.Read()
+		cur, err := e.iface.ReadRegisterU16LE(OUTPUT_VALUE)
 		if err != nil {
 			return err
 		}
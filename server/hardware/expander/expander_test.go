@@ -0,0 +1,161 @@
+package expander
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/i2c/i2ctest"
+)
+
+func newTestExpander(t *testing.T, inputs uint16) (*Expander, *i2ctest.MCP23017) {
+	t.Helper()
+	chip := i2ctest.NewMCP23017()
+	e := NewWithBus(i2ctest.NewFake(chip), time.Millisecond)
+	if err := e.Init(inputs); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e, chip
+}
+
+func nextChange(t *testing.T, e *Expander) Change {
+	t.Helper()
+	select {
+	case ch := <-e.Updates():
+		return ch
+	case <-time.After(time.Second):
+		t.Fatal("no change from the expander")
+		return Change{}
+	}
+}
+
+func TestInit(t *testing.T) {
+	_, chip := newTestExpander(t, 0xFE1F)
+	for _, r := range []struct {
+		name string
+		reg  byte
+		want uint16
+	}{
+		{"IODIR", DIRECTION_CONF, 0xFE1F},
+		{"IPOL", POLARITY_CONF, 0xFFFF},
+		{"GPPU", PULL_UP_CONF, 0xFE1F},
+	} {
+		if got := chip.Register(r.reg); got != r.want {
+			t.Errorf("%s = %016b, want %016b", r.name, got, r.want)
+		}
+	}
+}
+
+func TestInputChanges(t *testing.T) {
+	e, chip := newTestExpander(t, 0xFFFF)
+
+	// Inputs are active-low with inverted polarity: pulling a pin low
+	// reads as 1.
+	chip.SetPin(4, false)
+	if ch := nextChange(t, e); ch.Value != 1<<4 || ch.Previous != 0 {
+		t.Errorf("press: %+v", ch)
+	}
+	chip.SetPin(4, true)
+	if ch := nextChange(t, e); ch.Value != 0 || ch.Previous != 1<<4 {
+		t.Errorf("release: %+v", ch)
+	}
+}
+
+func TestWriteMasked(t *testing.T) {
+	e, chip := newTestExpander(t, 0xFE1F) // bits 5-8 are outputs
+
+	if err := e.Write(1<<5|1<<7, 0xFFFF); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Write(1<<6, 1<<6|1<<7); err != nil {
+		t.Fatal(err)
+	}
+	if got := chip.Outputs(); got != 1<<5|1<<6 {
+		t.Errorf("outputs = %016b", got)
+	}
+}
+
+// testIRQ is an INT line driven by the simulated chip, or left unwired.
+type testIRQ struct {
+	chip  *i2ctest.MCP23017
+	wired bool
+	edges chan struct{}
+}
+
+func newTestIRQ(chip *i2ctest.MCP23017, wired bool) *testIRQ {
+	irq := &testIRQ{chip: chip, wired: wired, edges: make(chan struct{}, 1)}
+	if wired {
+		chip.OnInterrupt(func() {
+			select {
+			case irq.edges <- struct{}{}:
+			default:
+			}
+		})
+	}
+	return irq
+}
+
+func (i *testIRQ) Edges() <-chan struct{} { return i.edges }
+func (i *testIRQ) Active() bool           { return i.wired && i.chip.Interrupt() }
+func (i *testIRQ) Close() error           { return nil }
+
+func newInterruptExpander(t *testing.T, wired bool) (*Expander, *i2ctest.MCP23017, *i2ctest.Fake) {
+	t.Helper()
+	chip := i2ctest.NewMCP23017()
+	bus := i2ctest.NewFake(chip)
+	e := NewWithBus(bus, time.Millisecond)
+	if err := e.InitInterrupt(0xFFFF, newTestIRQ(chip, wired)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e, chip, bus
+}
+
+func TestInterruptChanges(t *testing.T) {
+	e, chip, bus := newInterruptExpander(t, true)
+	if got := chip.Register(INTERRUPT_ENABLE); got != 0xFFFF {
+		t.Errorf("GPINTEN = %016b", got)
+	}
+	if got := chip.Register(IO_CONF) & 0xFF; got != IO_CONF_MIRROR|IO_CONF_OPEN_DRAIN {
+		t.Errorf("IOCON = %08b", got)
+	}
+
+	// Nothing is read while the inputs are idle.
+	bus.ResetOps()
+	time.Sleep(20 * time.Millisecond)
+	if ops := bus.Ops(); len(ops) != 0 {
+		t.Errorf("idle bus traffic: %v", ops)
+	}
+
+	chip.SetPin(4, false)
+	if ch := nextChange(t, e); ch.Value != 1<<4 || ch.Previous != 0 {
+		t.Errorf("press: %+v", ch)
+	}
+	chip.SetPin(4, true)
+	if ch := nextChange(t, e); ch.Value != 0 || ch.Previous != 1<<4 {
+		t.Errorf("release: %+v", ch)
+	}
+	if chip.Interrupt() {
+		t.Error("interrupt left pending")
+	}
+}
+
+func TestInterruptNotWired(t *testing.T) {
+	saved := checkInterval
+	t.Cleanup(func() { checkInterval = saved })
+	checkInterval = time.Millisecond
+	e, chip, _ := newInterruptExpander(t, false)
+
+	// Changes are still found by the periodic check, and after a few of
+	// those the expander polls instead.
+	for i := range 2 * checkMisses {
+		chip.SetPin(4, i%2 == 1)
+		want := uint16(1 << 4)
+		if i%2 == 1 {
+			want = 0
+		}
+		if ch := nextChange(t, e); ch.Value != want {
+			t.Errorf("change %d: %+v", i, ch)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package expander
+
+import "github.com/warthog618/go-gpiocdev"
+
+// gpioIRQ is an INT line on a Linux GPIO chip.
+type gpioIRQ struct {
+	line  *gpiocdev.Line
+	edges chan struct{}
+}
+
+// OpenIRQ requests pin on the named gpiochip (default "gpiochip0") as the
+// expander's open-drain, active-low INT line.
+func OpenIRQ(chip string, pin int) (IRQ, error) {
+	if chip == "" {
+		chip = "gpiochip0"
+	}
+	irq := &gpioIRQ{edges: make(chan struct{}, 1)}
+	line, err := gpiocdev.RequestLine(chip, pin,
+		gpiocdev.AsInput,
+		gpiocdev.WithPullUp,
+		gpiocdev.WithFallingEdge,
+		gpiocdev.WithEventHandler(func(gpiocdev.LineEvent) {
+			select {
+			case irq.edges <- struct{}{}:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	irq.line = line
+	return irq, nil
+}
+
+func (g *gpioIRQ) Edges() <-chan struct{} { return g.edges }
+
+func (g *gpioIRQ) Active() bool {
+	v, err := g.line.Value()
+	return err == nil && v == 0
+}
+
+func (g *gpioIRQ) Close() error { return g.line.Close() }
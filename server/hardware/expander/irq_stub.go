@@ -0,0 +1,10 @@
+//go:build !linux
+
+package expander
+
+import "errors"
+
+// OpenIRQ is unavailable without Linux GPIO; the expander polls instead.
+func OpenIRQ(chip string, pin int) (IRQ, error) {
+	return nil, errors.New("expander: interrupts need Linux GPIO")
+}
@@ -1,7 +1,6 @@
 package hardware
 
 import (
-	"encoding/json"
 	"log"
 	"sync"
 	"time"
@@ -55,6 +54,9 @@ var (
 	thermalOnce sync.Once
 	thermalUnit *thermalcam.ThermalCam
 
+	thermalCtlOnce sync.Once
+	thermalCtl     *thermalcam.Controller
+
 	lcdOnce sync.Once
 	lcdUnit *lcd.LCD
 
@@ -172,7 +174,18 @@ func Expander() *expander.Expander {
 		outputs := uint16((1 << cfg.Hardware.Expander.Bits.LEDR) | (1 << cfg.Hardware.Expander.Bits.LEDW) | (1 << cfg.Hardware.Expander.Bits.LEDB) | (1 << cfg.Hardware.Expander.Bits.LEDY))
 		inputs := uint16(0xFFFF) &^ outputs
 
-		if err := e.Init(inputs); err != nil {
+		var irq expander.IRQ
+		if pin := cfg.Hardware.Expander.InterruptPin; pin != 0 {
+			if irq, err = expander.OpenIRQ(cfg.Hardware.Expander.GPIOChip, pin); err != nil {
+				log.Println("hardware: expander interrupt unavailable, polling instead:", err)
+			}
+		}
+		if irq != nil {
+			err = e.InitInterrupt(inputs, irq)
+		} else {
+			err = e.Init(inputs)
+		}
+		if err != nil {
 			log.Println("hardware: expander init error:", err)
 			return
 		}
@@ -202,20 +215,21 @@ func ThermalCam() *thermalcam.ThermalCam {
 			return
 		}
 		thermalUnit = c
-		go func() {
-			state, errs := c.ReadState(30 * time.Second)
-			for _, err := range errs {
-				log.Println("thermalcam:", err)
-			}
-			if state != nil {
-				b, _ := json.Marshal(state)
-				log.Printf("thermalcam: %s", b)
-			}
-		}()
 	})
 	return thermalUnit
 }
 
+// Thermal returns the singleton controller that serializes access to the
+// thermal camera, or nil if it's not configured.
+func Thermal() *thermalcam.Controller {
+	thermalCtlOnce.Do(func() {
+		if c := ThermalCam(); c != nil {
+			thermalCtl = thermalcam.NewController(c)
+		}
+	})
+	return thermalCtl
+}
+
 func LEDRed() *led.Controller {
 	ledRedOnce.Do(func() {
 		ledRedUnit = led.New(config.Load().Config.Hardware.Expander.Bits.LEDR)
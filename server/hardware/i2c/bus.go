@@ -0,0 +1,15 @@
+package i2c
+
+// Bus is the register-level access the device drivers use. *I2C implements
+// it over /dev/i2c-*; i2ctest.Fake implements it over simulated devices.
+type Bus interface {
+	WriteBytes(buf []byte) (int, error)
+	ReadRegister(reg byte, n int) ([]byte, error)
+	ReadRegisterU8(reg byte) (byte, error)
+	ReadRegisterU16LE(reg byte) (uint16, error)
+	WriteRegisterU8(reg byte, value byte) error
+	WriteRegisterU16LE(reg byte, value uint16) error
+	Close() error
+}
+
+var _ Bus = (*I2C)(nil)
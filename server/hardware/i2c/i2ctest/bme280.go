@@ -0,0 +1,148 @@
+package i2ctest
+
+import (
+	"math"
+	"sync"
+)
+
+// bme280Cal is a BME280's factory calibration, in register order.
+type bme280Cal struct {
+	T1                             uint16
+	T2, T3                         int16
+	P1                             uint16
+	P2, P3, P4, P5, P6, P7, P8, P9 int16
+	H1, H3                         uint8
+	H2, H4, H5                     int16
+	H6                             int8
+}
+
+// defaultBME280Cal are the example trimming values from Bosch's datasheets.
+var defaultBME280Cal = bme280Cal{
+	T1: 27504, T2: 26435, T3: -1000,
+	P1: 36477, P2: -10685, P3: 3024, P4: 2855, P5: 140, P6: -7, P7: 15500, P8: -14600, P9: 6000,
+	H1: 75, H2: 362, H3: 0, H4: 313, H5: 50, H6: 30,
+}
+
+// BME280 models the air sensor: chip ID, calibration registers and the
+// data registers, which hold whatever raw readings correspond to the
+// conditions given to Set.
+type BME280 struct {
+	mu   sync.Mutex
+	regs Registers
+	cal  bme280Cal
+}
+
+// NewBME280 returns a sensor reading 20°C, 1013.25 hPa and 50% humidity.
+func NewBME280() *BME280 {
+	b := &BME280{cal: defaultBME280Cal}
+	b.regs[0xD0] = 0x60 // chip ID
+	c := b.cal
+	le := func(reg byte, v uint16) { b.regs[reg], b.regs[reg+1] = byte(v), byte(v>>8) }
+	for i, v := range []uint16{c.T1, uint16(c.T2), uint16(c.T3), c.P1, uint16(c.P2), uint16(c.P3),
+		uint16(c.P4), uint16(c.P5), uint16(c.P6), uint16(c.P7), uint16(c.P8), uint16(c.P9)} {
+		le(0x88+byte(2*i), v)
+	}
+	b.regs[0xA1] = c.H1
+	le(0xE1, uint16(c.H2))
+	b.regs[0xE3] = c.H3
+	b.regs[0xE4] = byte(c.H4 >> 4)
+	b.regs[0xE5] = byte(c.H4&0x0F) | byte(c.H5&0x0F)<<4
+	b.regs[0xE6] = byte(c.H5 >> 4)
+	b.regs[0xE7] = byte(c.H6)
+	b.Set(20, 101325, 50)
+	return b
+}
+
+func (b *BME280) ReadRegister(reg byte, buf []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.regs.ReadRegister(reg, buf)
+}
+
+func (b *BME280) WriteRegister(reg byte, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.regs.WriteRegister(reg, data)
+}
+
+// Register returns the value of a register, e.g. 0xF4 (ctrl_meas).
+func (b *BME280) Register(reg byte) byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.regs[reg]
+}
+
+// Set changes the conditions the sensor reports, in °C, Pa and %RH. The
+// raw readings are found by searching the datasheet's floating-point
+// compensation formulas, so they're independent of the driver's
+// fixed-point ones.
+func (b *BME280) Set(tempC, pressurePa, humidity float64) {
+	rawT := search(1<<20, func(adc int) float64 { return b.temp(adc) / 5120 }, tempC)
+	tFine := b.temp(rawT)
+	// Pressure falls as the raw reading rises.
+	rawP := search(1<<20, func(adc int) float64 { return -b.pressure(adc, tFine) }, -pressurePa)
+	rawH := search(1<<16, func(adc int) float64 { return b.humidity(adc, tFine) }, humidity)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	copy(b.regs[0xF7:], []byte{
+		byte(rawP >> 12), byte(rawP >> 4), byte(rawP << 4),
+		byte(rawT >> 12), byte(rawT >> 4), byte(rawT << 4),
+		byte(rawH >> 8), byte(rawH),
+	})
+}
+
+// search returns the input in [0, n) for which the increasing function f
+// comes closest to want.
+func search(n int, f func(int) float64, want float64) int {
+	lo, hi := 0, n-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if f(mid) < want {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo > 0 && math.Abs(f(lo-1)-want) < math.Abs(f(lo)-want) {
+		return lo - 1
+	}
+	return lo
+}
+
+// temp returns t_fine for a raw temperature reading.
+func (b *BME280) temp(adc int) float64 {
+	c := b.cal
+	v1 := (float64(adc)/16384 - float64(c.T1)/1024) * float64(c.T2)
+	v2 := float64(adc)/131072 - float64(c.T1)/8192
+	return v1 + v2*v2*float64(c.T3)
+}
+
+// pressure returns Pa for a raw pressure reading.
+func (b *BME280) pressure(adc int, tFine float64) float64 {
+	c := b.cal
+	v1 := tFine/2 - 64000
+	v2 := v1 * v1 * float64(c.P6) / 32768
+	v2 += v1 * float64(c.P5) * 2
+	v2 = v2/4 + float64(c.P4)*65536
+	v1 = (float64(c.P3)*v1*v1/524288 + float64(c.P2)*v1) / 524288
+	v1 = (1 + v1/32768) * float64(c.P1)
+	if v1 == 0 {
+		return 0
+	}
+	p := 1048576 - float64(adc)
+	p = (p - v2/4096) * 6250 / v1
+	v1 = float64(c.P9) * p * p / 2147483648
+	v2 = p * float64(c.P8) / 32768
+	return p + (v1+v2+float64(c.P7))/16
+}
+
+// humidity returns %RH for a raw humidity reading.
+func (b *BME280) humidity(adc int, tFine float64) float64 {
+	c := b.cal
+	h := tFine - 76800
+	h = (float64(adc) - (float64(c.H4)*64 + float64(c.H5)/16384*h)) *
+		(float64(c.H2) / 65536 * (1 + float64(c.H6)/67108864*h*(1+float64(c.H3)/67108864*h)))
+	h *= 1 - float64(c.H1)*h/524288
+	return h
+}
@@ -0,0 +1,157 @@
+package i2ctest
+
+import "sync"
+
+// MCP23017 register pairs (IOCON.BANK = 0): register 2n is port A, 2n+1
+// port B, so 16-bit little-endian transfers cover both ports.
+const (
+	mcpIODIR   = 0x00
+	mcpIPOL    = 0x02
+	mcpGPINTEN = 0x04
+	mcpDEFVAL  = 0x06
+	mcpINTCON  = 0x08
+	mcpIOCON   = 0x0A
+	mcpGPPU    = 0x0C
+	mcpINTF    = 0x0E
+	mcpINTCAP  = 0x10
+	mcpGPIO    = 0x12
+	mcpOLAT    = 0x14
+	mcpRegs    = 0x16
+)
+
+// MCP23017 models the panel's 16-bit GPIO expander: pin direction, input
+// polarity, pull-ups, output latches and interrupt-on-change. Tests drive
+// the input pins with SetPins and read the outputs with Outputs.
+type MCP23017 struct {
+	mu   sync.Mutex
+	regs [mcpRegs / 2]uint16
+	pins uint16 // electrical level on each input pin, 1 = high
+	intr []func()
+}
+
+// NewMCP23017 returns an expander in its power-on state: every pin an
+// input, and every input pin high.
+func NewMCP23017() *MCP23017 {
+	m := &MCP23017{pins: 0xFFFF}
+	m.regs[mcpIODIR/2] = 0xFFFF
+	return m
+}
+
+func (m *MCP23017) ReadRegister(reg byte, buf []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range buf {
+		r := (reg + byte(i)) % mcpRegs
+		buf[i] = byte(m.read(r&^1) >> (8 * (r & 1)))
+		// Reading GPIO or INTCAP clears that port's interrupt.
+		if r&^1 == mcpGPIO || r&^1 == mcpINTCAP {
+			m.regs[mcpINTF/2] &^= 0xFF << (8 * (r & 1))
+		}
+	}
+}
+
+func (m *MCP23017) WriteRegister(reg byte, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, b := range data {
+		r := (reg + byte(i)) % mcpRegs
+		pair := r &^ 1
+		switch pair {
+		case mcpINTF, mcpINTCAP:
+			continue // read-only
+		case mcpGPIO:
+			pair = mcpOLAT
+		}
+		shift := 8 * (r & 1)
+		v := &m.regs[pair/2]
+		*v = *v&^(0xFF<<shift) | uint16(b)<<shift
+	}
+}
+
+// read returns the 16-bit value of a register pair; m.mu must be held.
+func (m *MCP23017) read(pair byte) uint16 {
+	if pair == mcpGPIO {
+		return m.gpio()
+	}
+	return m.regs[pair/2]
+}
+
+// gpio is what the GPIO register reads: inputs (after IPOL) and the output
+// latches; m.mu must be held.
+func (m *MCP23017) gpio() uint16 {
+	iodir := m.regs[mcpIODIR/2]
+	in := (m.pins ^ m.regs[mcpIPOL/2]) & iodir
+	return in | m.regs[mcpOLAT/2]&^iodir
+}
+
+// SetPins sets the electrical level of the input pins (1 = high), raising
+// an interrupt for the enabled pins that change.
+func (m *MCP23017) SetPins(levels uint16) {
+	m.mu.Lock()
+	prev := m.pins
+	m.pins = levels
+
+	enabled := m.regs[mcpGPINTEN/2] & m.regs[mcpIODIR/2]
+	intcon := m.regs[mcpINTCON/2]
+	fired := enabled & (^intcon&(prev^levels) | intcon&(levels^m.regs[mcpDEFVAL/2]))
+	var notify []func()
+	if fired != 0 {
+		if m.regs[mcpINTF/2] == 0 {
+			m.regs[mcpINTCAP/2] = m.gpio()
+			notify = m.intr
+		}
+		m.regs[mcpINTF/2] |= fired
+	}
+	m.mu.Unlock()
+	for _, fn := range notify {
+		fn()
+	}
+}
+
+// SetPin sets the level of a single input pin.
+func (m *MCP23017) SetPin(n uint, high bool) {
+	m.mu.Lock()
+	levels := m.pins &^ (1 << n)
+	if high {
+		levels |= 1 << n
+	}
+	m.mu.Unlock()
+	m.SetPins(levels)
+}
+
+// Pins returns the electrical level of the input pins.
+func (m *MCP23017) Pins() uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pins
+}
+
+// Outputs returns the output latches of the pins configured as outputs.
+func (m *MCP23017) Outputs() uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.regs[mcpOLAT/2] &^ m.regs[mcpIODIR/2]
+}
+
+// Register returns the 16-bit value of a register pair, e.g. 0x00 for
+// IODIR, without the side effects of a bus read.
+func (m *MCP23017) Register(pair byte) uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.read(pair &^ 1)
+}
+
+// Interrupt reports whether the INT line is active: an enabled pin has
+// changed since the interrupt was last cleared.
+func (m *MCP23017) Interrupt() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.regs[mcpINTF/2] != 0
+}
+
+// OnInterrupt registers fn to be called when the INT line goes active.
+func (m *MCP23017) OnInterrupt(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.intr = append(m.intr, fn)
+}
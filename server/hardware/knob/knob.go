@@ -67,7 +67,9 @@ type Config struct {
 }
 
 type Knob struct {
-	f             *os.File
+	f *os.File
+
+	rangeMu       sync.Mutex
 	minBrightness int
 	maxBrightness int
 
@@ -92,17 +94,12 @@ func New(cfg Config) (*Knob, error) {
 	if err != nil {
 		return nil, fmt.Errorf("knob: %w", err)
 	}
-	maxBrightness := cfg.MaxBrightness
-	if maxBrightness <= 0 {
-		maxBrightness = 100
-	}
 	k := &Knob{
-		f:             f,
-		minBrightness: cfg.MinBrightness,
-		maxBrightness: maxBrightness,
-		pending:       make(map[int]chan inMsg),
-		linkUp:        true, // optimistic until pingLoop says otherwise -- see maxMissedPongs
+		f:       f,
+		pending: make(map[int]chan inMsg),
+		linkUp:  true, // optimistic until pingLoop says otherwise -- see maxMissedPongs
 	}
+	k.SetRange(cfg.MinBrightness, cfg.MaxBrightness)
 	go k.readLoop()
 	go k.pingLoop()
 	return k, nil
@@ -113,6 +110,18 @@ func (k *Knob) Close() error {
 	return k.f.Close()
 }
 
+// SetRange changes the min/maxBrightness floor and ceiling (see Config).
+// It takes effect with the next SetBrightness.
+func (k *Knob) SetRange(minBrightness, maxBrightness int) {
+	if maxBrightness <= 0 {
+		maxBrightness = 100
+	}
+	k.rangeMu.Lock()
+	k.minBrightness = minBrightness
+	k.maxBrightness = maxBrightness
+	k.rangeMu.Unlock()
+}
+
 // SetBrightness scales the given 0-100 percentage onto this knob's own
 // configured min/maxBrightness range and sends it as a setBrightness command.
 func (k *Knob) SetBrightness(pct float64) error {
@@ -121,7 +130,9 @@ func (k *Knob) SetBrightness(pct float64) error {
 	} else if pct > 100 {
 		pct = 100
 	}
+	k.rangeMu.Lock()
 	scaled := float64(k.minBrightness) + float64(k.maxBrightness-k.minBrightness)*pct/100.0
+	k.rangeMu.Unlock()
 	resp, err := k.send("setBrightness", map[string]any{"val": scaled})
 	if err != nil {
 		return err
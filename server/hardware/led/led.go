@@ -1,21 +1,58 @@
+// Package led drives the panel LEDs with named patterns. Each LED keeps a
+// stack of requests from different owners; the highest-priority one shows,
+// and requests can expire on their own.
 package led
 
 import (
+	"sync"
 	"time"
+)
 
-	"github.com/vincent99/velocipi/server/hardware/expander"
+// Priorities for requests. Higher wins; equal priorities go to the most
+// recent request.
+const (
+	PriorityStatus = 0  // background indications, such as startup
+	PriorityUser   = 10 // set by hand from the UI or a gesture
+	PriorityAlert  = 20 // something needs attention
 )
 
+// Writer sets masked output bits; *expander.Expander is one.
+type Writer interface {
+	Write(value, mask uint16) error
+}
+
+// Request asks for a pattern on an LED.
+type Request struct {
+	Owner    string        // a new request from the same owner replaces its last
+	Priority int           // see PriorityStatus and friends
+	Pattern  string        // one of Patterns
+	Rate     time.Duration // the pattern's unit; 0 is its default
+	TTL      time.Duration // how long until the request lapses; 0 is never
+}
+
 // State holds the current LED state.
 type State struct {
-	Mode string        // "off", "on", or "blink"
-	Rate time.Duration // only meaningful when Mode == "blink"
+	Mode  string        // the pattern showing; "off" when nothing is requested
+	Rate  time.Duration // the pattern's unit, 0 for "on" and "off"
+	Owner string        // whose request is showing; "" when none is
+}
+
+type entry struct {
+	req     Request
+	pattern Pattern
+	expires time.Time // zero if it doesn't
 }
 
 // Controller manages a single LED wired to one bit of an expander.
 type Controller struct {
+	mu       sync.Mutex
 	mask     uint16
-	blinkCh  chan struct{}
+	w        Writer
+	stack    []*entry // in request order
+	active   *entry
+	stop     chan struct{} // stops the running pattern
+	done     chan struct{} // closed once it has stopped
+	expiry   *time.Timer
 	state    State
 	onChange func(State)
 }
@@ -31,66 +68,190 @@ func New(bit uint) *Controller {
 // OnChange registers a callback that is called whenever the LED state changes.
 // Only one callback is supported; calling again replaces the previous one.
 func (l *Controller) OnChange(fn func(State)) {
+	l.mu.Lock()
 	l.onChange = fn
+	l.mu.Unlock()
 }
 
 // CurrentState returns the current LED state.
 func (l *Controller) CurrentState() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.state
 }
 
-func (l *Controller) stopBlink() {
-	if l.blinkCh != nil {
-		close(l.blinkCh)
-		l.blinkCh = nil
+// Request adds r to the stack, replacing any earlier request from the same
+// owner, and shows whichever request now wins.
+func (l *Controller) Request(w Writer, r Request) error {
+	p, err := Named(r.Pattern, r.Rate)
+	if err != nil {
+		return err
+	}
+	if r.Rate <= 0 {
+		r.Rate = defaultRates[r.Pattern]
+	}
+	e := &entry{req: r, pattern: p}
+	if r.TTL > 0 {
+		e.expires = time.Now().Add(r.TTL)
 	}
+
+	l.mu.Lock()
+	l.w = w
+	l.remove(r.Owner)
+	l.stack = append(l.stack, e)
+	l.update()
+	return nil
+}
+
+// Release withdraws owner's request, if it has one.
+func (l *Controller) Release(w Writer, owner string) {
+	l.mu.Lock()
+	l.w = w
+	l.remove(owner)
+	l.update()
+}
+
+// Reset withdraws every request and turns the LED off.
+func (l *Controller) Reset(w Writer) {
+	l.mu.Lock()
+	l.w = w
+	l.stack = nil
+	l.update()
 }
 
-func (l *Controller) notify() {
-	if l.onChange != nil {
-		l.onChange(l.state)
+func (l *Controller) remove(owner string) {
+	for i, e := range l.stack {
+		if e.req.Owner == owner {
+			l.stack = append(l.stack[:i:i], l.stack[i+1:]...)
+			return
+		}
 	}
 }
 
-// On turns the LED on and stops any active blink.
-func (l *Controller) On(e *expander.Expander) {
-	l.stopBlink()
-	_ = e.Write(l.mask, l.mask)
-	l.state = State{Mode: "on"}
-	l.notify()
+// update drops expired requests and shows the winner if it changed. It's
+// called with l.mu held and releases it, notifying after.
+func (l *Controller) update() {
+	now := time.Now()
+	var top *entry
+	var next time.Time
+	live := l.stack[:0]
+	for _, e := range l.stack {
+		if !e.expires.IsZero() {
+			if !now.Before(e.expires) {
+				continue
+			}
+			if next.IsZero() || e.expires.Before(next) {
+				next = e.expires
+			}
+		}
+		live = append(live, e)
+		if top == nil || e.req.Priority >= top.req.Priority {
+			top = e
+		}
+	}
+	clear(l.stack[len(live):])
+	l.stack = live
+
+	if l.expiry != nil {
+		l.expiry.Stop()
+		l.expiry = nil
+	}
+	if !next.IsZero() {
+		l.expiry = time.AfterFunc(next.Sub(now), func() {
+			l.mu.Lock()
+			l.update()
+		})
+	}
+
+	var fn func(State)
+	if top != l.active {
+		l.active = top
+		l.show(top)
+		fn = l.onChange
+	}
+	state := l.state
+	l.mu.Unlock()
+	if fn != nil {
+		fn(state)
+	}
 }
 
-// Off turns the LED off and stops any active blink.
-func (l *Controller) Off(e *expander.Expander) {
-	l.stopBlink()
-	_ = e.Write(0, l.mask)
-	l.state = State{Mode: "off"}
-	l.notify()
+// show stops the running pattern and starts e's, or turns the LED off if e
+// is nil.
+func (l *Controller) show(e *entry) {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+		l.stop, l.done = nil, nil
+	}
+	if e == nil {
+		l.state = State{Mode: "off"}
+		if l.w != nil {
+			_ = l.w.Write(0, l.mask)
+		}
+		return
+	}
+	l.state = State{Mode: e.req.Pattern, Rate: e.req.Rate, Owner: e.req.Owner}
+	if l.w == nil {
+		return
+	}
+	if len(e.pattern) == 1 && (e.pattern[0].Level <= 0 || e.pattern[0].Level >= 1) {
+		_ = l.w.Write(level(e.pattern[0].Level >= 1, l.mask), l.mask)
+		return
+	}
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go run(l.w, l.mask, e.pattern, l.stop, l.done)
 }
 
-// Blink toggles the LED at the given rate, stopping any previous blink.
-func (l *Controller) Blink(e *expander.Expander, rate time.Duration) {
-	l.stopBlink()
-	stop := make(chan struct{})
-	l.blinkCh = stop
-	l.state = State{Mode: "blink", Rate: rate}
-	l.notify()
-	go func() {
-		on := true
-		ticker := time.NewTicker(rate)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-stop:
-				return
-			case <-ticker.C:
-				val := uint16(0)
-				if on {
-					val = l.mask
+func level(on bool, mask uint16) uint16 {
+	if on {
+		return mask
+	}
+	return 0
+}
+
+// run plays p on the masked bit until stop is closed.
+func run(w Writer, mask uint16, p Pattern, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+	wait := func(d time.Duration) bool {
+		t.Reset(d)
+		select {
+		case <-stop:
+			return false
+		case <-t.C:
+			return true
+		}
+	}
+	lit, written := false, false
+	set := func(on bool) {
+		if !written || on != lit {
+			_ = w.Write(level(on, mask), mask)
+			lit, written = on, true
+		}
+	}
+
+	for {
+		for _, s := range p {
+			if s.Level <= 0 || s.Level >= 1 {
+				set(s.Level >= 1)
+				if !wait(s.Dur) {
+					return
+				}
+				continue
+			}
+			on := time.Duration(s.Level * float64(pwmPeriod))
+			for end := time.Now().Add(s.Dur); time.Now().Before(end); {
+				set(true)
+				if !wait(on) {
+					return
+				}
+				set(false)
+				if !wait(pwmPeriod - on) {
+					return
 				}
-				_ = e.Write(val, l.mask)
-				on = !on
 			}
 		}
-	}()
+	}
 }
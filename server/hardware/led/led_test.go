@@ -0,0 +1,164 @@
+package led
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// pin records the writes to one LED.
+type pin struct {
+	mu     sync.Mutex
+	value  uint16
+	writes int
+}
+
+func (p *pin) Write(value, mask uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.value = p.value&^mask | value&mask
+	p.writes++
+	return nil
+}
+
+func (p *pin) lit() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.value != 0
+}
+
+func check(t *testing.T, l *Controller, mode, owner string) {
+	t.Helper()
+	if s := l.CurrentState(); s.Mode != mode || s.Owner != owner {
+		t.Errorf("state = %s by %q, want %s by %q", s.Mode, s.Owner, mode, owner)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	p := &pin{}
+	l := New(3)
+	defer l.Reset(p)
+
+	_ = l.Request(p, Request{Owner: "startup", Pattern: "on"})
+	check(t, l, "on", "startup")
+	if p.value != 1<<3 {
+		t.Errorf("value = %016b, want bit 3", p.value)
+	}
+
+	// A higher priority hides it; a lower one waits underneath.
+	_ = l.Request(p, Request{Owner: "alert", Priority: PriorityAlert, Pattern: "sos"})
+	_ = l.Request(p, Request{Owner: "user", Priority: PriorityUser, Pattern: "off"})
+	check(t, l, "sos", "alert")
+	l.Release(p, "alert")
+	check(t, l, "off", "user")
+	if p.lit() {
+		t.Error("LED lit under an off request")
+	}
+
+	// A new request from an owner replaces its old one, and equal
+	// priorities go to the latest.
+	_ = l.Request(p, Request{Owner: "other", Priority: PriorityUser, Pattern: "on"})
+	check(t, l, "on", "other")
+	_ = l.Request(p, Request{Owner: "user", Priority: PriorityUser, Pattern: "blink"})
+	check(t, l, "blink", "user")
+	if s := l.CurrentState(); s.Rate != 500*time.Millisecond {
+		t.Errorf("rate = %v, want the default", s.Rate)
+	}
+	l.Release(p, "user")
+	l.Release(p, "other")
+	check(t, l, "on", "startup")
+
+	l.Reset(p)
+	check(t, l, "off", "")
+	if p.lit() {
+		t.Error("LED lit after Reset")
+	}
+
+	if err := l.Request(p, Request{Owner: "x", Pattern: "strobe"}); err == nil {
+		t.Error("unknown pattern accepted")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	p := &pin{}
+	l := New(0)
+	defer l.Reset(p)
+	changes := make(chan State, 4)
+	l.OnChange(func(s State) { changes <- s })
+
+	_ = l.Request(p, Request{Owner: "status", Pattern: "on"})
+	_ = l.Request(p, Request{Owner: "flash", Priority: PriorityAlert, Pattern: "off", TTL: 30 * time.Millisecond})
+	<-changes
+	<-changes
+	check(t, l, "off", "flash")
+
+	select {
+	case s := <-changes:
+		if s.Owner != "status" {
+			t.Errorf("after expiry, owner = %q", s.Owner)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request didn't expire")
+	}
+	if !p.lit() {
+		t.Error("LED not back on after expiry")
+	}
+}
+
+func TestFade(t *testing.T) {
+	p := &pin{}
+	l := New(0)
+	_ = l.Request(p, Request{Owner: "x", Pattern: "fade", Rate: 400 * time.Millisecond})
+	time.Sleep(150 * time.Millisecond)
+	l.Reset(p)
+
+	// Partial brightness toggles the pin every PWM half cycle.
+	p.mu.Lock()
+	n := p.writes
+	p.mu.Unlock()
+	if n < 10 {
+		t.Errorf("%d writes, want software PWM", n)
+	}
+	if p.lit() {
+		t.Error("LED lit after Reset")
+	}
+}
+
+func TestNamed(t *testing.T) {
+	p, err := Named("sos", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var on, total time.Duration
+	flashes := 0
+	for _, s := range p {
+		if s.Level > 0 {
+			flashes++
+			on += s.Dur
+		}
+		total += s.Dur
+	}
+	// Three dots, three dashes and three dots, each gap a dot long except
+	// the letter and word gaps.
+	if flashes != 9 || on != 1500*time.Millisecond || total != 3400*time.Millisecond {
+		t.Errorf("sos: %d flashes, %v on, %v total", flashes, on, total)
+	}
+
+	p, _ = Named("fade", 0)
+	total = 0
+	for _, s := range p {
+		if s.Level < 0 || s.Level > 1 {
+			t.Errorf("fade level %v", s.Level)
+		}
+		total += s.Dur
+	}
+	if total != 2*time.Second || p[len(p)-1].Level != 0 {
+		t.Errorf("fade: %v total, ends at %v", total, p[len(p)-1].Level)
+	}
+
+	for _, name := range Patterns {
+		if _, err := Named(name, 0); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package led
+
+import (
+	"fmt"
+	"time"
+)
+
+// Patterns are the names Request accepts.
+var Patterns = []string{"off", "on", "blink", "heartbeat", "double-blink", "sos", "fade"}
+
+// Step is one stretch of a pattern at a fixed brightness from 0 to 1.
+// Brightness between 0 and 1 is made with software PWM.
+type Step struct {
+	Level float64
+	Dur   time.Duration
+}
+
+// Pattern is a sequence of steps that repeats until replaced. A pattern
+// with a single step holds it.
+type Pattern []Step
+
+// pwmPeriod is the software PWM cycle for partial brightness. The
+// expander has no LED driver, so each cycle is two I2C writes.
+const pwmPeriod = 10 * time.Millisecond
+
+// fadeLevels is how many brightness steps fade ramps through each way.
+const fadeLevels = 16
+
+// defaultRates is each timed pattern's unit when a request leaves Rate 0:
+// blink's half period, heartbeat's and double-blink's flash, SOS's dot and
+// fade's full cycle.
+var defaultRates = map[string]time.Duration{
+	"blink":        500 * time.Millisecond,
+	"heartbeat":    100 * time.Millisecond,
+	"double-blink": 150 * time.Millisecond,
+	"sos":          150 * time.Millisecond,
+	"fade":         2 * time.Second,
+}
+
+// Named returns the pattern called name at rate, or at its default rate
+// if rate is 0.
+func Named(name string, rate time.Duration) (Pattern, error) {
+	if rate <= 0 {
+		rate = defaultRates[name]
+	}
+	u := func(n int) time.Duration { return time.Duration(n) * rate }
+	switch name {
+	case "off":
+		return Pattern{{0, 0}}, nil
+	case "on":
+		return Pattern{{1, 0}}, nil
+	case "blink":
+		return Pattern{{1, rate}, {0, rate}}, nil
+	case "heartbeat":
+		// A strong beat and a weaker one, then a rest.
+		return Pattern{{1, u(1)}, {0, u(1)}, {0.3, u(1)}, {0, u(7)}}, nil
+	case "double-blink":
+		return Pattern{{1, u(1)}, {0, u(1)}, {1, u(1)}, {0, u(5)}}, nil
+	case "sos":
+		var p Pattern
+		for i, n := range []int{1, 3, 1} {
+			for range 3 {
+				p = append(p, Step{1, u(n)}, Step{0, u(1)})
+			}
+			if i < 2 {
+				p[len(p)-1].Dur = u(3) // gap between letters
+			}
+		}
+		p[len(p)-1].Dur = u(7) // gap between words
+		return p, nil
+	case "fade":
+		step := rate / (2 * fadeLevels)
+		var p Pattern
+		for i := range fadeLevels {
+			p = append(p, Step{float64(i+1) / fadeLevels, step})
+		}
+		for i := range fadeLevels {
+			p = append(p, Step{float64(fadeLevels-1-i) / fadeLevels, step})
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("led: unknown pattern %q", name)
+}
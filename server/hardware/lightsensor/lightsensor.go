@@ -67,7 +67,7 @@ var FIFTY_HIT = [4]float64{0.0576, 0.1152, 0.4608, 0.9216}
 var TWENTY_FIVE_IT = [4]float64{0.1152, 0.2304, 0.9216, 1.8432}
 
 type LightSensor struct {
-	iface *i2c.I2C
+	iface i2c.Bus
 }
 
 type Config struct {
@@ -90,15 +90,15 @@ func NewLightSensorWithOptions(opt *Config) (*LightSensor, error) {
 	}
 
 	iface, err := i2c.New(opt.Device, address)
-
-	v := &LightSensor{
-		iface,
-	}
-
 	if err != nil {
-		return v, err
+		return &LightSensor{iface}, err
 	}
+	return NewLightSensorWithBus(iface)
+}
 
+// NewLightSensorWithBus initializes a sensor on an already-open bus.
+func NewLightSensorWithBus(bus i2c.Bus) (*LightSensor, error) {
+	v := &LightSensor{bus}
 	return v, v.Init()
 }
 
@@ -0,0 +1,53 @@
+//go:build linux
+
+package lightsensor
+
+import (
+	"testing"
+
+	"github.com/vincent99/velocipi/server/hardware/i2c/i2ctest"
+)
+
+func TestInit(t *testing.T) {
+	chip := i2ctest.NewVEML6030()
+	s, err := NewLightSensorWithBus(i2ctest.NewFake(chip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Gain 2, 800 ms, persistence 8, interrupt off, powered on.
+	if got := chip.Register(SETTING_REG); got != 0x08F0 {
+		t.Errorf("ALS_CONF = %#04x", got)
+	}
+	if gain, _ := s.GetGain(); gain != 4 {
+		t.Errorf("gain = %d", gain)
+	}
+	if it, _ := s.GetIntegrationTime(); it != 800 {
+		t.Errorf("integration time = %d", it)
+	}
+}
+
+func TestLuxTracksSensor(t *testing.T) {
+	chip := i2ctest.NewVEML6030()
+	s, err := NewLightSensorWithBus(i2ctest.NewFake(chip))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chip.SetLux(5)
+	dim, err := s.GetAmbientLux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chip.SetLux(20)
+	bright, _ := s.GetAmbientLux()
+	if dim <= 0 || bright < 3.9*dim || bright > 4.1*dim {
+		t.Errorf("5 lux -> %v, 20 lux -> %v; want 4x", dim, bright)
+	}
+
+	if err := s.SetPower(false); err != nil {
+		t.Fatal(err)
+	}
+	if lux, _ := s.GetAmbientLux(); lux != 0 {
+		t.Errorf("lux while shut down = %v", lux)
+	}
+}
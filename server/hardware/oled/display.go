@@ -13,6 +13,8 @@ type Display interface {
 	// SetBrightness sets display brightness. Range and semantics are
 	// driver-specific (see each driver's SetBrightness doc).
 	SetBrightness(b byte)
+	// SetFlip turns 180° rotation on or off from the next Blit.
+	SetFlip(flip bool)
 	// Width returns the display width in pixels.
 	Width() int
 	// Height returns the display height in pixels.
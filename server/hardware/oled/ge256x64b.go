@@ -25,6 +25,7 @@ package oled
 
 import (
 	"image"
+	"sync/atomic"
 	"time"
 
 	"github.com/warthog618/go-gpiocdev"
@@ -43,6 +44,7 @@ type Noritake struct {
 	sbusyLine *gpiocdev.Line
 	rstLine   *gpiocdev.Line
 	frameBuf  []byte // column-major, 8 pixels per byte
+	flip      atomic.Bool
 }
 
 // NewGE256X64B opens the SPI bus and GPIO lines, resets the display, and
@@ -103,6 +105,7 @@ func NewGE256X64B(cfg Config, width, height int) (*Noritake, error) {
 		rstLine:   rstLine,
 		frameBuf:  make([]byte, width*(height/8)),
 	}
+	n.flip.Store(cfg.Flip)
 
 	if err := n.Init(); err != nil {
 		n.Close()
@@ -150,6 +153,9 @@ func (n *Noritake) SetBrightness(b byte) {
 	n.write([]byte{0x1f, 0x58, level})
 }
 
+// SetFlip turns 180° rotation on or off from the next Blit.
+func (n *Noritake) SetFlip(flip bool) { n.flip.Store(flip) }
+
 // Reset pulses /RESET low for 5 ms then waits for the display to boot.
 // The pin toggle is skipped if no ResetPin was configured.
 func (n *Noritake) Reset() error {
@@ -174,6 +180,7 @@ func (n *Noritake) Blit(img image.Image) {
 	bounds := img.Bounds()
 	bands := n.height / 8
 	buf := n.frameBuf
+	flip := n.flip.Load()
 
 	// Clear the frame buffer.
 	for i := range buf {
@@ -186,7 +193,7 @@ func (n *Noritake) Blit(img image.Image) {
 		for x := bounds.Min.X; x < bounds.Max.X && x < n.width; x++ {
 			if lumaOver128(img.At(x, y)) {
 				col := x
-				if n.cfg.Flip {
+				if flip {
 					col = n.width - 1 - x
 					band = bands - 1 - (y / 8)
 					bit = uint(y % 8) // reversed vertical
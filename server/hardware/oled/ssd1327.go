@@ -15,6 +15,7 @@ import (
 	"image/color"
 	"image/draw"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/warthog618/go-gpiocdev"
@@ -74,6 +75,7 @@ type SSD1327 struct {
 	rstLine  *gpiocdev.Line
 	frameBuf []byte
 	frameNum int64
+	flip     atomic.Bool
 }
 
 // NewSSD1327 opens the SPI bus and GPIO lines, then initialises the display.
@@ -131,6 +133,7 @@ func NewSSD1327(cfg Config, width, height int) (*SSD1327, error) {
 		rstLine:  rstLine,
 		frameBuf: make([]byte, (width/2)*height),
 	}
+	o.flip.Store(cfg.Flip)
 
 	if err := o.Init(); err != nil {
 		o.Close()
@@ -205,6 +208,9 @@ func (o *SSD1327) SetBrightness(b byte) {
 	o.writeCmd(setContrastCurrent, b)
 }
 
+// SetFlip turns 180° rotation on or off from the next Blit.
+func (o *SSD1327) SetFlip(flip bool) { o.flip.Store(flip) }
+
 // Reset pulses the reset pin low for 200 ms then releases it (skipped if no
 // ResetPin was configured), then clears the frame buffer.
 func (o *SSD1327) Reset() error {
@@ -235,9 +241,10 @@ func (o *SSD1327) Blit(img image.Image) {
 	bounds := img.Bounds()
 	buf := o.frameBuf
 
+	flip := o.flip.Load()
 	framePtr := 0
 	inc := 1
-	if o.cfg.Flip {
+	if flip {
 		framePtr = len(buf) - 1
 		inc = -1
 	}
@@ -246,7 +253,7 @@ func (o *SSD1327) Blit(img image.Image) {
 		for x := bounds.Min.X; x < bounds.Max.X; x += 2 {
 			hi := toGray(img.At(x, y))
 			lo := toGray(img.At(x+1, y))
-			if o.cfg.Flip {
+			if flip {
 				buf[framePtr] = hi | (lo << 4)
 			} else {
 				buf[framePtr] = lo | (hi << 4)
@@ -21,6 +21,7 @@ type SSD1327 struct{ width, height int }
 
 func (o *SSD1327) Blit(_ image.Image)   {}
 func (o *SSD1327) SetBrightness(_ byte) {}
+func (o *SSD1327) SetFlip(_ bool)       {}
 func (o *SSD1327) Width() int           { return o.width }
 func (o *SSD1327) Height() int          { return o.height }
 func (o *SSD1327) Close()               {}
@@ -30,6 +31,7 @@ type Noritake struct{ width, height int }
 
 func (n *Noritake) Blit(_ image.Image)   {}
 func (n *Noritake) SetBrightness(_ byte) {}
+func (n *Noritake) SetFlip(_ bool)       {}
 func (n *Noritake) Width() int           { return n.width }
 func (n *Noritake) Height() int          { return n.height }
 func (n *Noritake) Close()               {}
@@ -0,0 +1,139 @@
+package onvif
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// wsDiscoveryAddr is the WS-Discovery multicast group and port.
+const wsDiscoveryAddr = "239.255.255.250:3702"
+
+// Device is a camera that answered a WS-Discovery probe.
+type Device struct {
+	Endpoint string   `json:"endpoint"` // EndpointReference address, usually urn:uuid:...
+	XAddrs   []string `json:"xaddrs"`   // device service URLs
+	Name     string   `json:"name"`     // from the onvif://www.onvif.org/name/... scope
+	Hardware string   `json:"hardware"` // from the onvif://www.onvif.org/hardware/... scope
+	Host     string   `json:"host"`     // host of the first XAddr
+}
+
+// Discover multicasts a WS-Discovery probe for NetworkVideoTransmitter devices
+// and collects the replies until timeout (or ctx) expires. Devices are
+// de-duplicated by endpoint and returned sorted by host.
+func Discover(ctx context.Context, timeout time.Duration) ([]Device, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("onvif: discover: %w", err)
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", wsDiscoveryAddr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP([]byte(probeMessage(newUUID())), dst); err != nil {
+		return nil, fmt.Errorf("onvif: discover: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	found := make(map[string]Device)
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // deadline reached
+		}
+		for _, d := range parseProbeMatches(buf[:n]) {
+			found[d.Endpoint] = d
+		}
+	}
+
+	out := make([]Device, 0, len(found))
+	for _, d := range found {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out, nil
+}
+
+// probeMessage builds the WS-Discovery Probe for ONVIF video transmitters.
+func probeMessage(messageID string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+		` xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery"` +
+		` xmlns:dn="http://www.onvif.org/ver10/network/wsdl">` +
+		`<e:Header><w:MessageID>uuid:` + messageID + `</w:MessageID>` +
+		`<w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>` +
+		`<w:Action e:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</w:Action>` +
+		`</e:Header><e:Body><d:Probe><d:Types>dn:NetworkVideoTransmitter</d:Types></d:Probe></e:Body></e:Envelope>`
+}
+
+// parseProbeMatches decodes a ProbeMatches reply. Malformed replies yield nil.
+func parseProbeMatches(data []byte) []Device {
+	var env struct {
+		Matches []struct {
+			Endpoint string `xml:"EndpointReference>Address"`
+			Scopes   string `xml:"Scopes"`
+			XAddrs   string `xml:"XAddrs"`
+		} `xml:"Body>ProbeMatches>ProbeMatch"`
+	}
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil
+	}
+	var out []Device
+	for _, m := range env.Matches {
+		d := Device{
+			Endpoint: strings.TrimSpace(m.Endpoint),
+			XAddrs:   strings.Fields(m.XAddrs),
+		}
+		for _, scope := range strings.Fields(m.Scopes) {
+			if v, ok := strings.CutPrefix(scope, "onvif://www.onvif.org/name/"); ok {
+				d.Name = unescapeScope(v)
+			} else if v, ok := strings.CutPrefix(scope, "onvif://www.onvif.org/hardware/"); ok {
+				d.Hardware = unescapeScope(v)
+			}
+		}
+		if len(d.XAddrs) > 0 {
+			if u, err := url.Parse(d.XAddrs[0]); err == nil {
+				d.Host = u.Hostname()
+			}
+		}
+		if d.Endpoint == "" {
+			d.Endpoint = strings.Join(d.XAddrs, " ")
+		}
+		if d.Endpoint != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// unescapeScope decodes the percent-escapes cameras use in scope values.
+func unescapeScope(s string) string {
+	if v, err := url.PathUnescape(s); err == nil {
+		return v
+	}
+	return s
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID string.
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,310 @@
+// Package onvif implements the small subset of ONVIF needed to use generic
+// IP cameras: WS-Discovery on the LAN, media profiles and RTSP stream URIs,
+// and PTZ movement. Requests are SOAP 1.2 over HTTP, authenticated with a
+// WS-Security UsernameToken digest when credentials are configured.
+package onvif
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nsEnvelope = "http://www.w3.org/2003/05/soap-envelope"
+	nsDevice   = "http://www.onvif.org/ver10/device/wsdl"
+	nsMedia    = "http://www.onvif.org/ver10/media/wsdl"
+	nsPTZ      = "http://www.onvif.org/ver20/ptz/wsdl"
+	nsSchema   = "http://www.onvif.org/ver10/schema"
+	nsSecurity = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	nsUtility  = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+
+	passwordDigestType = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+)
+
+// Profile is one media profile advertised by a camera.
+type Profile struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"` // e.g. "H264"
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	PTZ      bool   `json:"ptz"` // profile has a PTZ configuration
+}
+
+// Client talks to a single ONVIF device through its device service URL
+// (the XAddr from discovery, e.g. http://192.168.1.20/onvif/device_service).
+type Client struct {
+	xaddr    string
+	username string
+	password string
+	http     *http.Client
+
+	mu       sync.Mutex
+	mediaURL string // resolved lazily via GetCapabilities
+	ptzURL   string
+}
+
+// New creates a Client for the device service at xaddr. username may be empty
+// for cameras that allow anonymous access.
+func New(xaddr, username, password string) *Client {
+	return &Client{
+		xaddr:    xaddr,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// XAddr returns the device service URL.
+func (c *Client) XAddr() string {
+	return c.xaddr
+}
+
+// soapFault is the SOAP 1.2 fault body.
+type soapFault struct {
+	Code   string `xml:"Code>Value"`
+	Reason string `xml:"Reason>Text"`
+}
+
+// call POSTs a SOAP request whose body is the already-serialized payload and
+// decodes the first element of the response body into resp (if non-nil).
+func (c *Client) call(ctx context.Context, url, payload string, resp any) error {
+	var env bytes.Buffer
+	env.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	env.WriteString(`<s:Envelope xmlns:s="` + nsEnvelope + `" xmlns:tds="` + nsDevice +
+		`" xmlns:trt="` + nsMedia + `" xmlns:tptz="` + nsPTZ + `" xmlns:tt="` + nsSchema + `">`)
+	if c.username != "" {
+		env.WriteString("<s:Header>")
+		env.WriteString(c.securityHeader(time.Now().UTC()))
+		env.WriteString("</s:Header>")
+	}
+	env.WriteString("<s:Body>")
+	env.WriteString(payload)
+	env.WriteString("</s:Body></s:Envelope>")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &env)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var out struct {
+		Body struct {
+			Fault *soapFault `xml:"Fault"`
+			Inner []byte     `xml:",innerxml"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("onvif: %s: bad response (HTTP %d): %w", url, res.StatusCode, err)
+	}
+	if f := out.Body.Fault; f != nil {
+		return fmt.Errorf("onvif: fault %s: %s", strings.TrimSpace(f.Code), strings.TrimSpace(f.Reason))
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("onvif: %s: HTTP %d", url, res.StatusCode)
+	}
+	if resp == nil {
+		return nil
+	}
+	return xml.Unmarshal(out.Body.Inner, resp)
+}
+
+// securityHeader builds a WS-Security UsernameToken with a password digest:
+// base64(sha1(nonce + created + password)).
+func (c *Client) securityHeader(now time.Time) string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	created := now.Format("2006-01-02T15:04:05.000Z")
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(c.password))
+	digest := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	var b strings.Builder
+	b.WriteString(`<wsse:Security s:mustUnderstand="1" xmlns:wsse="` + nsSecurity + `" xmlns:wsu="` + nsUtility + `">`)
+	b.WriteString("<wsse:UsernameToken><wsse:Username>")
+	xml.EscapeText(&b, []byte(c.username))
+	b.WriteString(`</wsse:Username><wsse:Password Type="` + passwordDigestType + `">` + digest + "</wsse:Password>")
+	b.WriteString("<wsse:Nonce>" + base64.StdEncoding.EncodeToString(nonce) + "</wsse:Nonce>")
+	b.WriteString("<wsu:Created>" + created + "</wsu:Created>")
+	b.WriteString("</wsse:UsernameToken></wsse:Security>")
+	return b.String()
+}
+
+// services resolves the media and PTZ service URLs via GetCapabilities,
+// falling back to the device service URL (which many cameras accept for
+// every service) when a capability isn't advertised.
+func (c *Client) services(ctx context.Context) (media, ptz string, err error) {
+	c.mu.Lock()
+	media, ptz = c.mediaURL, c.ptzURL
+	c.mu.Unlock()
+	if media != "" {
+		return media, ptz, nil
+	}
+
+	var resp struct {
+		Media string `xml:"Capabilities>Media>XAddr"`
+		PTZ   string `xml:"Capabilities>PTZ>XAddr"`
+	}
+	payload := "<tds:GetCapabilities><tds:Category>All</tds:Category></tds:GetCapabilities>"
+	if err := c.call(ctx, c.xaddr, payload, &resp); err != nil {
+		return "", "", err
+	}
+	media = strings.TrimSpace(resp.Media)
+	ptz = strings.TrimSpace(resp.PTZ)
+	if media == "" {
+		media = c.xaddr
+	}
+	c.mu.Lock()
+	c.mediaURL, c.ptzURL = media, ptz
+	c.mu.Unlock()
+	return media, ptz, nil
+}
+
+// Profiles returns the camera's media profiles.
+func (c *Client) Profiles(ctx context.Context) ([]Profile, error) {
+	media, _, err := c.services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Profiles []struct {
+			Token   string `xml:"token,attr"`
+			Name    string `xml:"Name"`
+			Encoder *struct {
+				Encoding string `xml:"Encoding"`
+				Width    int    `xml:"Resolution>Width"`
+				Height   int    `xml:"Resolution>Height"`
+			} `xml:"VideoEncoderConfiguration"`
+			PTZ *struct{} `xml:"PTZConfiguration"`
+		} `xml:"Profiles"`
+	}
+	if err := c.call(ctx, media, "<trt:GetProfiles/>", &resp); err != nil {
+		return nil, err
+	}
+	out := make([]Profile, 0, len(resp.Profiles))
+	for _, p := range resp.Profiles {
+		prof := Profile{Token: p.Token, Name: p.Name, PTZ: p.PTZ != nil}
+		if p.Encoder != nil {
+			prof.Encoding = p.Encoder.Encoding
+			prof.Width = p.Encoder.Width
+			prof.Height = p.Encoder.Height
+		}
+		out = append(out, prof)
+	}
+	return out, nil
+}
+
+// StreamURI returns the RTSP URI for the given profile.
+func (c *Client) StreamURI(ctx context.Context, profile string) (string, error) {
+	media, _, err := c.services(ctx)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		URI string `xml:"MediaUri>Uri"`
+	}
+	payload := "<trt:GetStreamUri><trt:StreamSetup><tt:Stream>RTP-Unicast</tt:Stream>" +
+		"<tt:Transport><tt:Protocol>RTSP</tt:Protocol></tt:Transport></trt:StreamSetup>" +
+		"<trt:ProfileToken>" + escape(profile) + "</trt:ProfileToken></trt:GetStreamUri>"
+	if err := c.call(ctx, media, payload, &resp); err != nil {
+		return "", err
+	}
+	uri := strings.TrimSpace(resp.URI)
+	if uri == "" {
+		return "", fmt.Errorf("onvif: no stream URI for profile %q", profile)
+	}
+	return uri, nil
+}
+
+// ptzService returns the PTZ service URL, or an error if the device has none.
+func (c *Client) ptzService(ctx context.Context) (string, error) {
+	_, ptz, err := c.services(ctx)
+	if err != nil {
+		return "", err
+	}
+	if ptz == "" {
+		return "", fmt.Errorf("onvif: %s has no PTZ service", c.xaddr)
+	}
+	return ptz, nil
+}
+
+// ContinuousMove starts moving at the given normalized velocities (-1..+1;
+// positive pan = right, positive tilt = up, positive zoom = in). The camera
+// keeps moving until Stop or another move command.
+func (c *Client) ContinuousMove(ctx context.Context, profile string, pan, tilt, zoom float64) error {
+	url, err := c.ptzService(ctx)
+	if err != nil {
+		return err
+	}
+	payload := "<tptz:ContinuousMove><tptz:ProfileToken>" + escape(profile) + "</tptz:ProfileToken>" +
+		"<tptz:Velocity>" + vector(pan, tilt, zoom) + "</tptz:Velocity></tptz:ContinuousMove>"
+	return c.call(ctx, url, payload, nil)
+}
+
+// AbsoluteMove moves to a position in the camera's normalized generic space
+// (pan/tilt -1..+1, zoom 0..1).
+func (c *Client) AbsoluteMove(ctx context.Context, profile string, pan, tilt, zoom float64) error {
+	url, err := c.ptzService(ctx)
+	if err != nil {
+		return err
+	}
+	payload := "<tptz:AbsoluteMove><tptz:ProfileToken>" + escape(profile) + "</tptz:ProfileToken>" +
+		"<tptz:Position>" + vector(pan, tilt, zoom) + "</tptz:Position></tptz:AbsoluteMove>"
+	return c.call(ctx, url, payload, nil)
+}
+
+// Stop halts any pan/tilt and zoom movement.
+func (c *Client) Stop(ctx context.Context, profile string) error {
+	url, err := c.ptzService(ctx)
+	if err != nil {
+		return err
+	}
+	payload := "<tptz:Stop><tptz:ProfileToken>" + escape(profile) + "</tptz:ProfileToken>" +
+		"<tptz:PanTilt>true</tptz:PanTilt><tptz:Zoom>true</tptz:Zoom></tptz:Stop>"
+	return c.call(ctx, url, payload, nil)
+}
+
+// GotoHome moves to the camera's home position.
+func (c *Client) GotoHome(ctx context.Context, profile string) error {
+	url, err := c.ptzService(ctx)
+	if err != nil {
+		return err
+	}
+	payload := "<tptz:GotoHomePosition><tptz:ProfileToken>" + escape(profile) + "</tptz:ProfileToken></tptz:GotoHomePosition>"
+	return c.call(ctx, url, payload, nil)
+}
+
+// vector serializes a PTZ pan/tilt + zoom vector.
+func vector(pan, tilt, zoom float64) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	return `<tt:PanTilt x="` + f(pan) + `" y="` + f(tilt) + `"/><tt:Zoom x="` + f(zoom) + `"/>`
+}
+
+// escape XML-escapes a text value.
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
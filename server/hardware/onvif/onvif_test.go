@@ -0,0 +1,197 @@
+package onvif
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// standIn is a minimal ONVIF device: it answers the SOAP operations the
+// client uses, checks the WS-Security digest, and records PTZ calls.
+type standIn struct {
+	t        *testing.T
+	srv      *httptest.Server
+	password string
+
+	mu    sync.Mutex
+	calls []string // local names of the operations received
+	body  string   // last request body
+}
+
+func newStandIn(t *testing.T, password string) *standIn {
+	s := &standIn{t: t, password: password}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *standIn) handle(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	var env struct {
+		Header struct {
+			Username string `xml:"Security>UsernameToken>Username"`
+			Password string `xml:"Security>UsernameToken>Password"`
+			Nonce    string `xml:"Security>UsernameToken>Nonce"`
+			Created  string `xml:"Security>UsernameToken>Created"`
+		} `xml:"Header"`
+		Body struct {
+			Op struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(data, &env); err != nil {
+		s.t.Errorf("stand-in: bad request: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/soap+xml")
+
+	nonce, _ := base64.StdEncoding.DecodeString(env.Header.Nonce)
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(env.Header.Created))
+	h.Write([]byte(s.password))
+	if env.Header.Username != "admin" || env.Header.Password != base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, envelope(`<s:Fault><s:Code><s:Value>s:Sender</s:Value></s:Code>`+
+			`<s:Reason><s:Text>Sender not authorized</s:Text></s:Reason></s:Fault>`))
+		return
+	}
+
+	op := env.Body.Op.XMLName.Local
+	s.mu.Lock()
+	s.calls = append(s.calls, op)
+	s.body = string(data)
+	s.mu.Unlock()
+
+	switch op {
+	case "GetCapabilities":
+		io.WriteString(w, envelope(`<tds:GetCapabilitiesResponse><tds:Capabilities>`+
+			`<tt:Media><tt:XAddr>`+s.srv.URL+`/onvif/media</tt:XAddr></tt:Media>`+
+			`<tt:PTZ><tt:XAddr>`+s.srv.URL+`/onvif/ptz</tt:XAddr></tt:PTZ>`+
+			`</tds:Capabilities></tds:GetCapabilitiesResponse>`))
+	case "GetProfiles":
+		io.WriteString(w, envelope(`<trt:GetProfilesResponse>`+
+			`<trt:Profiles token="main"><tt:Name>MainStream</tt:Name><tt:VideoEncoderConfiguration>`+
+			`<tt:Encoding>H264</tt:Encoding><tt:Resolution><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:Resolution>`+
+			`</tt:VideoEncoderConfiguration><tt:PTZConfiguration token="ptz0"/></trt:Profiles>`+
+			`<trt:Profiles token="sub"><tt:Name>SubStream</tt:Name></trt:Profiles>`+
+			`</trt:GetProfilesResponse>`))
+	case "GetStreamUri":
+		io.WriteString(w, envelope(`<trt:GetStreamUriResponse><trt:MediaUri>`+
+			`<tt:Uri>rtsp://192.168.1.20:8554/live/main</tt:Uri></trt:MediaUri></trt:GetStreamUriResponse>`))
+	case "ContinuousMove", "Stop", "GotoHomePosition", "AbsoluteMove":
+		io.WriteString(w, envelope(`<tptz:`+op+`Response/>`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, envelope(`<s:Fault><s:Code><s:Value>s:Receiver</s:Value></s:Code>`+
+			`<s:Reason><s:Text>unsupported `+op+`</s:Text></s:Reason></s:Fault>`))
+	}
+}
+
+func envelope(body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><s:Envelope xmlns:s="` + nsEnvelope +
+		`" xmlns:tds="` + nsDevice + `" xmlns:trt="` + nsMedia + `" xmlns:tptz="` + nsPTZ +
+		`" xmlns:tt="` + nsSchema + `"><s:Body>` + body + `</s:Body></s:Envelope>`
+}
+
+func TestProfilesAndStreamURI(t *testing.T) {
+	s := newStandIn(t, "secret")
+	c := New(s.srv.URL+"/onvif/device_service", "admin", "secret")
+
+	profiles, err := c.Profiles(context.Background())
+	if err != nil {
+		t.Fatalf("Profiles: %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(profiles))
+	}
+	main := profiles[0]
+	if main.Token != "main" || main.Encoding != "H264" || main.Width != 1920 || main.Height != 1080 || !main.PTZ {
+		t.Errorf("unexpected main profile: %+v", main)
+	}
+	if profiles[1].PTZ {
+		t.Errorf("sub profile should not report PTZ")
+	}
+
+	uri, err := c.StreamURI(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("StreamURI: %v", err)
+	}
+	if uri != "rtsp://192.168.1.20:8554/live/main" {
+		t.Errorf("unexpected stream URI %q", uri)
+	}
+}
+
+func TestPTZCommands(t *testing.T) {
+	s := newStandIn(t, "secret")
+	c := New(s.srv.URL+"/onvif/device_service", "admin", "secret")
+	ctx := context.Background()
+
+	if err := c.ContinuousMove(ctx, "main", 0.5, -0.25, 0); err != nil {
+		t.Fatalf("ContinuousMove: %v", err)
+	}
+	s.mu.Lock()
+	body := s.body
+	s.mu.Unlock()
+	if !strings.Contains(body, `x="0.500" y="-0.250"`) || !strings.Contains(body, "<tptz:ProfileToken>main</tptz:ProfileToken>") {
+		t.Errorf("ContinuousMove body missing velocity/profile: %s", body)
+	}
+	if err := c.Stop(ctx, "main"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := c.GotoHome(ctx, "main"); err != nil {
+		t.Fatalf("GotoHome: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{"GetCapabilities", "ContinuousMove", "Stop", "GotoHomePosition"}
+	if strings.Join(s.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v (capabilities resolved once)", s.calls, want)
+	}
+}
+
+func TestBadCredentialsReturnFault(t *testing.T) {
+	s := newStandIn(t, "secret")
+	c := New(s.srv.URL+"/onvif/device_service", "admin", "wrong")
+	_, err := c.Profiles(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Fatalf("expected authorization fault, got %v", err)
+	}
+}
+
+func TestParseProbeMatches(t *testing.T) {
+	reply := `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope"
+  xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing"
+  xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<SOAP-ENV:Body><d:ProbeMatches><d:ProbeMatch>
+  <wsa:EndpointReference><wsa:Address>urn:uuid:1234</wsa:Address></wsa:EndpointReference>
+  <d:Types>dn:NetworkVideoTransmitter</d:Types>
+  <d:Scopes>onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/name/Wing%20Cam onvif://www.onvif.org/hardware/IPC-123</d:Scopes>
+  <d:XAddrs>http://192.168.1.20/onvif/device_service http://[fe80::1]/onvif/device_service</d:XAddrs>
+</d:ProbeMatch></d:ProbeMatches></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+	devs := parseProbeMatches([]byte(reply))
+	if len(devs) != 1 {
+		t.Fatalf("expected 1 device, got %d", len(devs))
+	}
+	d := devs[0]
+	if d.Endpoint != "urn:uuid:1234" || d.Name != "Wing Cam" || d.Hardware != "IPC-123" || d.Host != "192.168.1.20" {
+		t.Errorf("unexpected device: %+v", d)
+	}
+	if len(d.XAddrs) != 2 {
+		t.Errorf("expected 2 xaddrs, got %v", d.XAddrs)
+	}
+	if parseProbeMatches([]byte("not xml")) != nil {
+		t.Errorf("malformed reply should yield nil")
+	}
+}
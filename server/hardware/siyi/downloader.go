@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,7 +29,8 @@ type Downloader struct {
 	client  *http.Client
 }
 
-// NewDownloader creates a Downloader that saves files to destDir.
+// NewDownloader creates a Downloader that saves files to destDir. host may
+// carry a port; without one the camera's DownloadPort is used.
 func NewDownloader(host, destDir string) *Downloader {
 	return &Downloader{
 		host:    host,
@@ -65,7 +69,7 @@ func (d *Downloader) ListVideos(ctx context.Context) ([]MediaFile, error) {
 }
 
 func (d *Downloader) listMedia(ctx context.Context, mediaType int) ([]MediaFile, error) {
-	base := fmt.Sprintf("http://%s:%d", d.host, DownloadPort)
+	base := "http://" + d.addr()
 
 	// Step 1: get directories.
 	dirURL := fmt.Sprintf("%s/cgi-bin/media.cgi/api/v1/getdirectories?media_type=%d", base, mediaType)
@@ -117,31 +121,113 @@ func (d *Downloader) listMedia(ctx context.Context, mediaType int) ([]MediaFile,
 }
 
 // Download downloads a single MediaFile to destDir and returns the local path.
+// Bytes land in {name}.part first; if a previous attempt left one behind the
+// transfer resumes from its end with a Range request (falling back to a full
+// download if the camera ignores it). On completion the file is renamed into
+// place and its mtime set from the camera's Last-Modified header, if any.
+// A resumed response that doesn't start where the .part ends is refused and
+// the .part dropped, so the next attempt starts over.
 func (d *Downloader) Download(ctx context.Context, f MediaFile) (string, error) {
 	if err := os.MkdirAll(d.destDir, 0o755); err != nil {
 		return "", err
 	}
+	dest := filepath.Join(d.destDir, filepath.Base(f.Name))
+	part := dest + ".part"
+
+	var offset int64
+	if fi, err := os.Stat(part); err == nil {
+		offset = fi.Size()
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
 	if err != nil {
 		return "", err
 	}
-	resp, err := d.client.Do(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	// Large videos can take far longer than the listing timeout; rely on ctx.
+	client := *d.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	dest := filepath.Join(d.destDir, f.Name)
-	out, err := os.Create(dest)
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+			os.Remove(part)
+			return "", fmt.Errorf("siyi downloader: %s: resumed at %q, want byte %d", f.Name, resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The .part is already complete, unless the camera says otherwise.
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && size >= 0 && size != offset {
+			os.Remove(part)
+			return "", fmt.Errorf("siyi downloader: %s: %d bytes on hand but the file is %d", f.Name, offset, size)
+		}
+		return dest, d.finish(part, dest, resp.Header.Get("Last-Modified"))
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+	default:
+		return "", fmt.Errorf("siyi downloader: %s: HTTP %d", f.Name, resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(part, flags, 0o644)
 	if err != nil {
 		return "", err
 	}
-	defer out.Close()
-
 	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
 		return "", err
 	}
-	return dest, nil
+	return dest, d.finish(part, dest, resp.Header.Get("Last-Modified"))
+}
+
+// parseContentRange parses "bytes start-end/size" or "bytes */size". start
+// is -1 for the second form and size -1 when it's "*".
+func parseContentRange(h string) (start, size int64, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, total, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	start, size = -1, -1
+	var err error
+	if total != "*" {
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng != "*" {
+		first, _, found := strings.Cut(rng, "-")
+		if !found {
+			return 0, 0, false
+		}
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
+
+// finish renames a completed .part into place and applies lastModified.
+func (d *Downloader) finish(part, dest, lastModified string) error {
+	if err := os.Rename(part, dest); err != nil {
+		return err
+	}
+	if t, err := http.ParseTime(lastModified); err == nil {
+		_ = os.Chtimes(dest, t, t)
+	}
+	return nil
 }
 
 // fixHost replaces the host portion of a URL with d.host to handle cases where
@@ -151,6 +237,14 @@ func (d *Downloader) fixHost(rawURL string) string {
 	if err != nil {
 		return rawURL
 	}
-	u.Host = fmt.Sprintf("%s:%d", d.host, DownloadPort)
+	u.Host = d.addr()
 	return u.String()
 }
+
+// addr returns the camera's media server address.
+func (d *Downloader) addr() string {
+	if _, _, err := net.SplitHostPort(d.host); err == nil {
+		return d.host
+	}
+	return net.JoinHostPort(d.host, strconv.Itoa(DownloadPort))
+}
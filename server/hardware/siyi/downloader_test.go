@@ -0,0 +1,134 @@
+package siyi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const clip = "0123456789abcdef"
+
+var clipTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestDownloadResume(t *testing.T) {
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "clip.mp4", clipTime, bytes.NewReader([]byte(clip)))
+	}
+	for _, c := range []struct {
+		name    string
+		part    string // left by an earlier attempt; "" for none
+		handler http.HandlerFunc
+		want    string // the finished file; "" for an error
+	}{
+		{name: "fresh", handler: serve, want: clip},
+		{name: "206 appends", part: clip[:6], handler: serve, want: clip},
+		{
+			name: "200 truncates",
+			part: "stale bytes from an older file",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(clip)) // ignores Range
+			},
+			want: clip,
+		},
+		{name: "416 means complete", part: clip, handler: serve, want: clip},
+		{
+			name: "206 at the wrong offset",
+			part: clip[:6],
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 0-15/16")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(clip))
+			},
+		},
+		{
+			name: "416 with a different size",
+			part: clip + "extra",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes */16")
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(c.handler)
+			defer srv.Close()
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "clip.mp4")
+			if c.part != "" {
+				if err := os.WriteFile(dest+".part", []byte(c.part), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := NewDownloader(srv.Listener.Addr().String(), dir)
+			path, err := d.Download(t.Context(), MediaFile{Name: "clip.mp4", URL: srv.URL + "/clip.mp4"})
+			if c.want == "" {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				// The bad .part is dropped so the next attempt starts over.
+				if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+					t.Errorf(".part kept: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != dest || string(got) != c.want {
+				t.Errorf("%s = %q, want %s = %q", path, got, dest, c.want)
+			}
+			if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+				t.Errorf(".part left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadLastModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "clip.mp4", clipTime, bytes.NewReader([]byte(clip)))
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(srv.Listener.Addr().String(), t.TempDir())
+	path, err := d.Download(t.Context(), MediaFile{Name: "clip.mp4", URL: srv.URL + "/clip.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(clipTime) {
+		t.Errorf("mtime %v, want %v", fi.ModTime(), clipTime)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, c := range []struct {
+		in          string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 6-15/16", 6, 16, true},
+		{"bytes 6-15/*", 6, -1, true},
+		{"bytes */16", -1, 16, true},
+		{"bytes 6/16", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	} {
+		start, size, ok := parseContentRange(c.in)
+		if start != c.start || size != c.size || ok != c.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v; want %d, %d, %v", c.in, start, size, ok, c.start, c.size, c.ok)
+		}
+	}
+}
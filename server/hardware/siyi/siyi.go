@@ -34,6 +34,7 @@ type Manager struct {
 	conn       *net.UDPConn
 	seq        uint16
 	attitude   GimbalAttitude
+	attitudeAt time.Time // when attitude was last received; zero if never
 	onAttitude func(name string, att GimbalAttitude)
 	bootMs     atomic.Uint32
 }
@@ -116,6 +117,7 @@ func (m *Manager) recvLoop(ctx context.Context, conn *net.UDPConn) {
 			if err == nil {
 				m.mu.Lock()
 				m.attitude = att
+				m.attitudeAt = time.Now()
 				cb := m.onAttitude
 				name := m.cfg.Name
 				m.mu.Unlock()
@@ -148,6 +150,11 @@ func (m *Manager) send(cmdID byte, data []byte) error {
 	return m.sendRaw(conn, cmdID, data)
 }
 
+// Name returns the configured camera name.
+func (m *Manager) Name() string {
+	return m.cfg.Name
+}
+
 // Host returns the IP/hostname of this gimbal.
 func (m *Manager) Host() string {
 	return m.cfg.Host
@@ -160,6 +167,14 @@ func (m *Manager) Attitude() GimbalAttitude {
 	return m.attitude
 }
 
+// LastAttitude is like Attitude but also reports whether an attitude has
+// been received within the last few seconds, i.e. the gimbal is responding.
+func (m *Manager) LastAttitude() (GimbalAttitude, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attitude, !m.attitudeAt.IsZero() && time.Since(m.attitudeAt) < 3*time.Second
+}
+
 // GimbalRotate sends a gimbal rotation command. yaw and pitch are rates in
 // the range -100..+100 (positive = right/up).
 func (m *Manager) GimbalRotate(yaw, pitch int8) error {
@@ -0,0 +1,163 @@
+package thermalcam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Capture files record a serial session as text, one line per transfer:
+//
+//	# comment
+//	> F0 05 36 78 02 00 64 14 FF
+//	< F0 05 36 78 02 03 01 B4 FF
+//
+// ">" lines are bytes sent to the camera, one per Write; "<" lines are the
+// bytes received after it. A request with no "<" line got no reply.
+
+// Recorder passes traffic through to a connection and writes it to a
+// capture file.
+type Recorder struct {
+	rw io.ReadWriter
+	w  io.Writer
+
+	mu sync.Mutex
+	rx []byte // received since the last Write
+}
+
+// NewRecorder records the traffic on rw to w.
+func NewRecorder(rw io.ReadWriter, w io.Writer) *Recorder {
+	return &Recorder{rw: rw, w: w}
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush()
+	fmt.Fprintf(r.w, "> % X\n", p)
+	return r.rw.Write(p)
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	r.mu.Lock()
+	r.rx = append(r.rx, p[:n]...)
+	r.mu.Unlock()
+	return n, err
+}
+
+// Close writes any pending received bytes and closes the connection if it
+// can be closed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	r.flush()
+	r.mu.Unlock()
+	if c, ok := r.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// flush writes the bytes received since the last Write; r.mu must be held.
+func (r *Recorder) flush() {
+	if len(r.rx) > 0 {
+		fmt.Fprintf(r.w, "< % X\n", r.rx)
+		r.rx = r.rx[:0]
+	}
+}
+
+// transfer is one request and the bytes received after it.
+type transfer struct {
+	line int
+	tx   []byte
+	rx   []byte
+}
+
+// Replay plays back a capture file: each Write must match the next
+// recorded request, and Reads return the bytes recorded after it. Reads
+// with nothing left to return report a timeout (0 bytes).
+type Replay struct {
+	mu        sync.Mutex
+	transfers []transfer
+	next      int
+	pending   []byte
+	err       error
+}
+
+// ReadCapture parses a capture file.
+func ReadCapture(r io.Reader) (*Replay, error) {
+	rp := &Replay{}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		dir, hexBytes := text[0], strings.ReplaceAll(text[1:], " ", "")
+		data, err := hex.DecodeString(hexBytes)
+		if err != nil {
+			return nil, fmt.Errorf("thermalcam: capture line %d: %w", line, err)
+		}
+		switch {
+		case dir == '>':
+			rp.transfers = append(rp.transfers, transfer{line: line, tx: data})
+		case dir == '<' && len(rp.transfers) > 0:
+			t := &rp.transfers[len(rp.transfers)-1]
+			t.rx = append(t.rx, data...)
+		default:
+			return nil, fmt.Errorf("thermalcam: capture line %d: expected \"> \" or \"< \"", line)
+		}
+	}
+	return rp, sc.Err()
+}
+
+func (rp *Replay) Write(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.next >= len(rp.transfers) {
+		rp.fail(fmt.Errorf("thermalcam: replay: sent % X after the end of the capture", p))
+		return 0, rp.err
+	}
+	t := rp.transfers[rp.next]
+	if !bytes.Equal(p, t.tx) {
+		rp.fail(fmt.Errorf("thermalcam: replay: sent % X, capture line %d has % X", p, t.line, t.tx))
+		return 0, rp.err
+	}
+	rp.next++
+	rp.pending = append(rp.pending[:0], t.rx...)
+	return len(p), nil
+}
+
+func (rp *Replay) Read(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	n := copy(p, rp.pending)
+	rp.pending = rp.pending[n:]
+	return n, nil
+}
+
+// fail records the first mismatch; rp.mu must be held.
+func (rp *Replay) fail(err error) {
+	if rp.err == nil {
+		rp.err = err
+	}
+}
+
+// Err returns the first mismatch between the requests sent and the
+// capture, or an error if requests in the capture were never sent.
+func (rp *Replay) Err() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.err != nil {
+		return rp.err
+	}
+	if left := len(rp.transfers) - rp.next; left > 0 {
+		return fmt.Errorf("thermalcam: replay: %d requests from capture line %d were never sent",
+			left, rp.transfers[rp.next].line)
+	}
+	return nil
+}
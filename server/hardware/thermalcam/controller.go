@@ -0,0 +1,132 @@
+package thermalcam
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// startupTimeout is how long Run waits for the camera to finish
+	// initializing before its first state read.
+	startupTimeout = 30 * time.Second
+	// pollTimeout bounds the ready check on later state reads.
+	pollTimeout = 5 * time.Second
+)
+
+// Controller serializes access to a ThermalCam -- one command or state
+// read at a time, so a multi-packet operation is never interleaved with
+// another -- and keeps the last State read from it, refreshed by Run.
+type Controller struct {
+	cam *ThermalCam
+
+	opMu sync.Mutex // held for the whole of a command or ReadState
+
+	mu       sync.RWMutex
+	state    *State
+	lastErr  string
+	onChange []func(State)
+
+	refresh chan struct{}
+}
+
+// NewController wraps cam. Start Run to populate State.
+func NewController(cam *ThermalCam) *Controller {
+	return &Controller{cam: cam, refresh: make(chan struct{}, 1)}
+}
+
+// Do runs fn with exclusive use of the camera, then has Run re-read the
+// state so the change is reported.
+func (c *Controller) Do(fn func(t *ThermalCam) error) error {
+	c.opMu.Lock()
+	err := fn(c.cam)
+	c.opMu.Unlock()
+	c.Refresh()
+	return err
+}
+
+// Refresh asks Run to re-read the state now.
+func (c *Controller) Refresh() {
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// State returns the last state read, and false if there hasn't been one.
+func (c *Controller) State() (State, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.state == nil {
+		return State{}, false
+	}
+	return *c.state, true
+}
+
+// OnChange registers fn to be called from Run whenever the state changes.
+func (c *Controller) OnChange(fn func(State)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// Run waits for the camera to become ready, then re-reads its state every
+// interval and after each command until ctx is cancelled.
+func (c *Controller) Run(ctx context.Context, interval time.Duration) {
+	c.poll(startupTimeout)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.refresh:
+		}
+		c.poll(pollTimeout)
+	}
+}
+
+func (c *Controller) poll(timeout time.Duration) {
+	c.opMu.Lock()
+	s, errs := c.cam.ReadState(timeout)
+	c.opMu.Unlock()
+
+	// Log errors once, not on every poll while the camera is unplugged.
+	var msg string
+	if len(errs) > 0 {
+		msg = errs[0].Error()
+	}
+	c.mu.Lock()
+	logErrs := msg != c.lastErr
+	c.lastErr = msg
+	c.mu.Unlock()
+	if logErrs {
+		for _, err := range errs {
+			log.Println("thermalcam:", err)
+		}
+	}
+	if s == nil {
+		return
+	}
+
+	c.mu.Lock()
+	first := c.state == nil
+	changed := first || *c.state != *s
+	c.state = s
+	fns := c.onChange
+	c.mu.Unlock()
+
+	if first {
+		b, _ := json.Marshal(s)
+		log.Printf("thermalcam: %s", b)
+	}
+	if changed {
+		for _, fn := range fns {
+			fn(*s)
+		}
+	}
+}
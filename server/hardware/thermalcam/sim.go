@@ -0,0 +1,269 @@
+package thermalcam
+
+import (
+	"bytes"
+	"maps"
+	"sync"
+	"time"
+)
+
+// reg identifies a register or command by class and subclass address.
+type reg struct{ class, subclass byte }
+
+// simSettings are the registers a Sim lets you read and write, with their
+// factory defaults.
+var simSettings = map[reg][]byte{
+	{0x7C, 0x04}: {byte(ShutterFullAuto)},
+	{0x7C, 0x05}: {0x00, 0x05}, // minutes
+	{0x78, 0x02}: {50},         // brightness
+	{0x78, 0x03}: {50},         // contrast
+	{0x78, 0x10}: {50},         // detail enhancement
+	{0x78, 0x15}: {50},         // static denoising
+	{0x78, 0x16}: {50},         // dynamic denoising
+	{0x78, 0x20}: {byte(PaletteWhiteHot)},
+	{0x70, 0x11}: {byte(MirrorNone)},
+}
+
+// simInfo are the read-only information registers.
+var simInfo = map[reg][]byte{
+	{0x74, 0x02}: []byte("HM-TM5X-XRG"),
+	{0x74, 0x03}: {1, 4, 2},          // FPGA version
+	{0x74, 0x04}: u32Bytes(20230612), // FPGA compile date
+	{0x74, 0x05}: {2, 1, 7},          // software version
+	{0x74, 0x06}: u32Bytes(20231103), // software compile date
+	{0x74, 0x0B}: u32Bytes(20240115), // calibration date
+	{0x74, 0x0C}: u32Bytes(3),        // ISP parameter version
+}
+
+// simCommands are the write-only commands.
+var simCommands = map[reg]string{
+	{0x74, 0x10}: "save",
+	{0x74, 0x0F}: "factoryReset",
+	{0x7C, 0x02}: "ffc",
+	{0x7C, 0x03}: "backgroundCorrection",
+	{0x7C, 0x0C}: "vignettingCorrection",
+	{0x78, 0x1A}: "cursor",
+}
+
+var readyReg = reg{0x7C, 0x14}
+
+func u32Bytes(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// Packet is one well-formed request received by a Sim.
+type Packet struct {
+	Class    byte
+	Subclass byte
+	Flag     byte
+	Data     []byte
+}
+
+// Sim is an in-process thermal camera for tests and for running without the
+// hardware. It implements the module's packet protocol over an
+// io.ReadWriter: use it with NewConn.
+//
+// Writes to the setting registers are stored and read back; commands are
+// acknowledged and recorded; information registers return fixed values;
+// anything else gets the "command not recognised" reply. Until ReadyAfter
+// ready queries have been answered "not ready", the sim answers nothing
+// else, like a module that is still booting. Requests with a bad checksum,
+// device address or END byte are dropped, so the reader times out.
+type Sim struct {
+	ReadyAfter int           // ready queries to answer "not ready" before becoming ready
+	Timeout    time.Duration // how long Read waits when no reply is pending, like the port's VTIME
+
+	mu       sync.Mutex
+	in       []byte // unparsed request bytes
+	out      bytes.Buffer
+	settings map[reg][]byte
+	saved    map[reg][]byte // settings restored by Restart
+	faults   map[reg]byte   // register → reply flag; 0 means no reply
+	readyQs  int
+	received []Packet
+	bad      int
+}
+
+// NewSim returns a ready sim with factory settings.
+func NewSim() *Sim {
+	return &Sim{
+		Timeout:  10 * time.Millisecond,
+		settings: maps.Clone(simSettings),
+		saved:    maps.Clone(simSettings),
+		faults:   make(map[reg]byte),
+	}
+}
+
+// Write accepts request bytes, in any chunking, and queues the replies.
+func (s *Sim) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.in = append(s.in, p...)
+	for s.parse() {
+	}
+	return len(p), nil
+}
+
+// Read returns queued reply bytes. If there are none it waits Timeout and
+// returns 0 bytes, which ThermalCam treats as a timeout.
+func (s *Sim) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.out.Len() > 0 {
+		defer s.mu.Unlock()
+		return s.out.Read(p)
+	}
+	s.mu.Unlock()
+	time.Sleep(s.Timeout)
+	return 0, nil
+}
+
+// parse handles the first complete request in s.in, reporting whether
+// there may be another.
+func (s *Sim) parse() bool {
+	start := bytes.IndexByte(s.in, beginByte)
+	if start < 0 {
+		s.in = s.in[:0]
+		return false
+	}
+	s.in = s.in[start:]
+	if len(s.in) < 2 {
+		return false
+	}
+	size := int(s.in[1])
+	if len(s.in) < size+4 {
+		return false
+	}
+	body := s.in[2 : 2+size+2]
+	if size < 4 || body[0] != devAddr || body[size+1] != endByte ||
+		body[size] != checksum(body[1], body[2], body[3], body[4:size]) {
+		// Resync from the next BEGIN byte.
+		s.bad++
+		s.in = s.in[1:]
+		return true
+	}
+	pkt := Packet{Class: body[1], Subclass: body[2], Flag: body[3], Data: bytes.Clone(body[4:size])}
+	s.in = s.in[size+4:]
+	s.received = append(s.received, pkt)
+	s.handle(pkt)
+	return true
+}
+
+func (s *Sim) handle(pkt Packet) {
+	r := reg{pkt.Class, pkt.Subclass}
+	if r == readyReg && pkt.Flag == flagWrite {
+		if s.ready() {
+			s.reply(r, 0x01)
+		} else {
+			s.readyQs++
+			s.reply(r, 0x00)
+		}
+		return
+	}
+	if !s.ready() {
+		return
+	}
+	if flag, ok := s.faults[r]; ok {
+		if flag != 0 {
+			s.out.Write(buildPacket(r.class, r.subclass, flag, []byte{0x00}))
+		}
+		return
+	}
+
+	switch pkt.Flag {
+	case flagRead:
+		if v, ok := s.settings[r]; ok {
+			s.reply(r, v...)
+		} else if v, ok := simInfo[r]; ok {
+			s.reply(r, v...)
+		} else {
+			s.reply(r, 0x00)
+		}
+	case flagWrite:
+		if v, ok := s.settings[r]; ok && len(pkt.Data) == len(v) {
+			s.settings[r] = pkt.Data
+			s.reply(r, 0x01)
+			return
+		}
+		switch simCommands[r] {
+		case "":
+			s.reply(r, 0x00)
+			return
+		case "save":
+			s.saved = maps.Clone(s.settings)
+		case "factoryReset":
+			s.settings = maps.Clone(simSettings)
+			s.saved = maps.Clone(simSettings)
+		}
+		s.reply(r, 0x01)
+	}
+}
+
+// ready reports whether the sim has finished "booting"; s.mu must be held.
+func (s *Sim) ready() bool {
+	return s.readyQs >= s.ReadyAfter
+}
+
+func (s *Sim) reply(r reg, data ...byte) {
+	s.out.Write(buildPacket(r.class, r.subclass, flagOK, data))
+}
+
+// Get returns the current value of a setting register.
+func (s *Sim) Get(class, subclass byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return bytes.Clone(s.settings[reg{class, subclass}])
+}
+
+// Set changes a setting register, as if from the camera's own OSD.
+func (s *Sim) Set(class, subclass byte, data ...byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[reg{class, subclass}] = bytes.Clone(data)
+}
+
+// Fault makes every request to the register get a reply with the given
+// flag, or no reply at all if flag is 0, until ClearFaults.
+func (s *Sim) Fault(class, subclass, flag byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[reg{class, subclass}] = flag
+}
+
+// ClearFaults removes all faults.
+func (s *Sim) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.faults)
+}
+
+// Inject queues raw bytes, e.g. line noise, ahead of the next reply.
+func (s *Sim) Inject(b ...byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(b)
+}
+
+// Restart simulates a power cycle: unsaved settings are lost and the sim
+// answers ReadyAfter ready queries with "not ready" again.
+func (s *Sim) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = maps.Clone(s.saved)
+	s.readyQs = 0
+	s.in = s.in[:0]
+	s.out.Reset()
+}
+
+// Received returns the well-formed requests received so far.
+func (s *Sim) Received() []Packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Packet(nil), s.received...)
+}
+
+// BadPackets returns how many malformed requests were dropped.
+func (s *Sim) BadPackets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bad
+}
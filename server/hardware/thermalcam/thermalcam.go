@@ -11,8 +11,8 @@ package thermalcam
 
 import (
 	"fmt"
+	"io"
 	"log"
-	"os"
 	"sync"
 	"time"
 
@@ -28,10 +28,14 @@ const (
 	flagOK    = 0x03
 )
 
+// readyPollInterval is how often ReadState re-checks Ready while the camera
+// is initializing.
+var readyPollInterval = 2 * time.Second
+
 // ThermalCam communicates with a HM-TM5X-XRG/C thermal camera over UART.
 type ThermalCam struct {
 	mu      sync.Mutex
-	f       *os.File
+	rw      io.ReadWriter
 	Verbose bool // log every sent/received byte when true
 }
 
@@ -46,12 +50,22 @@ func NewBaud(device string, baud int) (*ThermalCam, error) {
 	if err != nil {
 		return nil, fmt.Errorf("thermalcam: %w", err)
 	}
-	return &ThermalCam{f: f}, nil
+	return &ThermalCam{rw: f}, nil
+}
+
+// NewConn talks to a camera over rw instead of a serial device, e.g. a Sim,
+// a Replay, or a serial port wrapped in a Recorder. A read that returns no
+// bytes and no error is treated as a timeout, like the serial port's VTIME.
+func NewConn(rw io.ReadWriter) *ThermalCam {
+	return &ThermalCam{rw: rw}
 }
 
-// Close releases the serial port.
+// Close releases the serial port, if the connection can be closed.
 func (t *ThermalCam) Close() error {
-	return t.f.Close()
+	if c, ok := t.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // --- packet helpers ---
@@ -90,7 +104,7 @@ func (t *ThermalCam) RawExchange(pkt []byte, maxBytes int, dur time.Duration) ([
 	if t.Verbose {
 		log.Printf("thermalcam tx: %X", pkt)
 	}
-	if _, err := t.f.Write(pkt); err != nil {
+	if _, err := t.rw.Write(pkt); err != nil {
 		return nil, err
 	}
 
@@ -112,7 +126,7 @@ func (t *ThermalCam) RawExchange(pkt []byte, maxBytes int, dur time.Duration) ([
 // readByte reads one byte, returning an error on timeout (0-byte read with VTIME).
 func (t *ThermalCam) readByte() (byte, error) {
 	var b [1]byte
-	n, err := t.f.Read(b[:])
+	n, err := t.rw.Read(b[:])
 	if err != nil {
 		if t.Verbose {
 			log.Printf("thermalcam rx: read error: %v", err)
@@ -199,7 +213,7 @@ func (t *ThermalCam) write(classAddr, subclassAddr byte, data []byte) error {
 	if t.Verbose {
 		log.Printf("thermalcam tx: %X", pkt)
 	}
-	if _, err := t.f.Write(pkt); err != nil {
+	if _, err := t.rw.Write(pkt); err != nil {
 		return err
 	}
 	_, _, flag, resp, err := t.recv()
@@ -224,7 +238,7 @@ func (t *ThermalCam) read(classAddr, subclassAddr byte) ([]byte, error) {
 	if t.Verbose {
 		log.Printf("thermalcam tx: %X", pkt)
 	}
-	if _, err := t.f.Write(pkt); err != nil {
+	if _, err := t.rw.Write(pkt); err != nil {
 		return nil, err
 	}
 	_, _, flag, data, err := t.recv()
@@ -332,7 +346,7 @@ func (t *ThermalCam) Ready() (bool, error) {
 	if t.Verbose {
 		log.Printf("thermalcam tx: %X", pkt)
 	}
-	if _, err := t.f.Write(pkt); err != nil {
+	if _, err := t.rw.Write(pkt); err != nil {
 		return false, err
 	}
 	_, _, flag, data, err := t.recv()
@@ -582,6 +596,42 @@ func (t *ThermalCam) RemoveDefectivePixel() error {
 	return t.write(0x78, 0x1A, []byte{0x0E})
 }
 
+// --- Settings ---
+
+// ApplySettings writes every setting in s. It stops at the first error; the
+// settings before it have been applied. Call SaveSettings to keep them
+// across power cycles.
+func (t *ThermalCam) ApplySettings(s Settings) error {
+	palette, ok := ParsePalette(s.Palette)
+	if !ok {
+		return fmt.Errorf("thermalcam: unknown palette %q", s.Palette)
+	}
+	mirror, ok := ParseMirrorMode(s.Mirroring)
+	if !ok {
+		return fmt.Errorf("thermalcam: unknown mirroring %q", s.Mirroring)
+	}
+	shutter, ok := ParseShutterMode(s.ShutterMode)
+	if !ok {
+		return fmt.Errorf("thermalcam: unknown shutter mode %q", s.ShutterMode)
+	}
+	for _, step := range []func() error{
+		func() error { return t.SetBrightness(s.Brightness) },
+		func() error { return t.SetContrast(s.Contrast) },
+		func() error { return t.SetDetailEnhancement(s.DetailEnhancement) },
+		func() error { return t.SetStaticDenoising(s.StaticDenoising) },
+		func() error { return t.SetDynamicDenoising(s.DynamicDenoising) },
+		func() error { return t.SetPalette(palette) },
+		func() error { return t.SetMirroring(mirror) },
+		func() error { return t.SetShutterMode(shutter) },
+		func() error { return t.SetShutterInterval(s.ShutterIntervalMin) },
+	} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // --- State snapshot ---
 
 // ReadState polls until the camera is ready (up to timeout), then reads all
@@ -601,7 +651,7 @@ func (t *ThermalCam) ReadState(timeout time.Duration) (*State, []error) {
 		} else if time.Now().After(deadline) {
 			return nil, []error{fmt.Errorf("thermalcam: timed out waiting for ready")}
 		}
-		time.Sleep(2 * time.Second)
+		time.Sleep(readyPollInterval)
 	}
 
 	s := &State{}
@@ -83,6 +83,26 @@ func (p Palette) String() string {
 	}
 }
 
+// ParseShutterMode returns the ShutterMode whose String is name.
+func ParseShutterMode(name string) (ShutterMode, bool) {
+	for m := ShutterOff; m <= ShutterFullAuto; m++ {
+		if m.String() == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// ParsePalette returns the Palette whose String is name.
+func ParsePalette(name string) (Palette, bool) {
+	for p := PaletteWhiteHot; p <= PaletteDeepBlue; p++ {
+		if p.String() == name {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
 // MirrorMode controls image mirroring.
 type MirrorMode byte
 
@@ -108,6 +128,16 @@ func (m MirrorMode) String() string {
 	}
 }
 
+// ParseMirrorMode returns the MirrorMode whose String is name.
+func ParseMirrorMode(name string) (MirrorMode, bool) {
+	for m := MirrorNone; m <= MirrorUpDown; m++ {
+		if m.String() == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
 // CursorDir is used for defective-pixel correction cursor movement.
 type CursorDir byte
 
@@ -138,3 +168,33 @@ type State struct {
 	Palette             string `json:"palette"`
 	Mirroring           string `json:"mirroring"`
 }
+
+// Settings returns the adjustable part of the state, as applied by
+// ApplySettings.
+func (s *State) Settings() Settings {
+	return Settings{
+		Brightness:         s.Brightness,
+		Contrast:           s.Contrast,
+		DetailEnhancement:  s.DetailEnhancement,
+		StaticDenoising:    s.StaticDenoising,
+		DynamicDenoising:   s.DynamicDenoising,
+		Palette:            s.Palette,
+		Mirroring:          s.Mirroring,
+		ShutterMode:        s.ShutterMode,
+		ShutterIntervalMin: s.ShutterIntervalMin,
+	}
+}
+
+// Settings holds the image and shutter settings that can be written to the
+// camera, e.g. from a named preset.
+type Settings struct {
+	Brightness         uint8  `json:"brightness"`
+	Contrast           uint8  `json:"contrast"`
+	DetailEnhancement  uint8  `json:"detailEnhancement"`
+	StaticDenoising    uint8  `json:"staticDenoising"`
+	DynamicDenoising   uint8  `json:"dynamicDenoising"`
+	Palette            string `json:"palette"`
+	Mirroring          string `json:"mirroring"`
+	ShutterMode        string `json:"shutterMode"`
+	ShutterIntervalMin uint16 `json:"shutterIntervalMin"`
+}
@@ -5,22 +5,28 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/vincent99/velocipi/server/auth"
+	"github.com/vincent99/velocipi/server/camera"
 	"github.com/vincent99/velocipi/server/config"
 	"github.com/vincent99/velocipi/server/dvr"
 	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/hardware/airsensor"
 	"github.com/vincent99/velocipi/server/hardware/axis"
 	"github.com/vincent99/velocipi/server/hardware/led"
 	"github.com/vincent99/velocipi/server/hardware/oled"
 	"github.com/vincent99/velocipi/server/hardware/siyi"
 	"github.com/vincent99/velocipi/server/music"
+	"github.com/vincent99/velocipi/server/snaps"
 )
 
 type client struct {
 	conn *websocket.Conn
 	send chan []byte
+	role auth.Role // what inbound messages the client may send
 }
 
 type Hub struct {
@@ -35,6 +41,13 @@ type Hub struct {
 	musicPlayer   music.PlayerController   // nil if music subsystem is disabled
 	siyiManagers  map[string]*siyi.Manager // camera name → Siyi manager (nil if no Siyi cameras)
 
+	cameraControllers map[string]camera.Controller // camera name → PTZ controller (siyi and onvif drivers)
+	cameraLookAt      map[string]camera.GeoTarget  // camera name → ground point being tracked
+	snapTrack         *snaps.Track                 // aircraft track for tagging synced media
+
+	lastAirReading atomic.Pointer[airsensor.Reading] // latest air sensor reading, for auto-FFC
+	panelRoute     string                            // page the panel browser shows, relative to the app URL
+
 	lastFrameMu sync.RWMutex
 	lastFrame   []byte // most recent decoded PNG from the screencast
 }
@@ -218,7 +231,7 @@ func (h *Hub) BroadcastAll(msg any) {
 	h.broadcastAll(msg)
 }
 
-// sendMusicState sends the current player state to a single newly-connected client.
+// sendMusicState sends every zone's player state to a single newly-connected client.
 func (h *Hub) sendMusicState(c *client) {
 	h.mu.RLock()
 	p := h.musicPlayer
@@ -226,17 +239,19 @@ func (h *Hub) sendMusicState(c *client) {
 	if p == nil {
 		return
 	}
-	data, err := json.Marshal(p.StateMsg())
-	if err != nil {
-		return
-	}
-	select {
-	case c.send <- data:
-	default:
+	for _, msg := range p.StateMsgs() {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+		}
 	}
 }
 
-// sendMusicQueue sends the full queue snapshot to a single newly-connected client.
+// sendMusicQueue sends every zone's queue snapshot to a single newly-connected client.
 func (h *Hub) sendMusicQueue(c *client) {
 	h.mu.RLock()
 	p := h.musicPlayer
@@ -244,13 +259,15 @@ func (h *Hub) sendMusicQueue(c *client) {
 	if p == nil {
 		return
 	}
-	data, err := json.Marshal(p.QueueMsg())
-	if err != nil {
-		return
-	}
-	select {
-	case c.send <- data:
-	default:
+	for _, msg := range p.QueueMsgs() {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+		}
 	}
 }
 
@@ -281,7 +298,8 @@ func (h *Hub) sendAxisState(c *client) {
 
 // runAxisLoop runs the Axis mock avionics loop. It broadcasts state changes
 // as axisState WS messages and feeds attitude/GPS to any Siyi gimbal
-// managers at 10 Hz (attitude) and 1 Hz (GPS).
+// managers at 10 Hz (attitude) and 1 Hz (GPS). Cameras in look-at mode are
+// re-aimed at the attitude rate.
 func (h *Hub) runAxisLoop(ctx context.Context) {
 	a := hardware.Axis()
 	a.OnChange(func(s axis.State) {
@@ -305,6 +323,7 @@ func (h *Hub) runAxisLoop(ctx context.Context) {
 			return
 		case <-attTicker.C:
 			s := a.State()
+			h.updateLookAt(s)
 			h.mu.RLock()
 			mgrs := h.siyiManagers
 			h.mu.RUnlock()
@@ -315,7 +334,11 @@ func (h *Hub) runAxisLoop(ctx context.Context) {
 			s := a.State()
 			h.mu.RLock()
 			mgrs := h.siyiManagers
+			track := h.snapTrack
 			h.mu.RUnlock()
+			if track != nil {
+				track.Record(s, time.Now())
+			}
 			for _, mgr := range mgrs {
 				go func(m *siyi.Manager) { _ = m.SendGPS(s) }(mgr)
 			}
@@ -349,8 +372,8 @@ func (h *Hub) runBrightnessLoop(ctx context.Context) {
 		})
 		// One-time clock sync on startup -- send() blocks for a response
 		// (or requestTimeout), so this runs in its own goroutine rather
-		// than delaying b.Run(ctx) below, same reasoning as hardware.go's
-		// ThermalCam() backgrounding its own startup ReadState() call.
+		// than delaying b.Run(ctx) below, same reasoning as the thermal
+		// camera's startup ReadState() running in its own loop.
 		go func() {
 			if err := k.SetClock(time.Now()); err != nil {
 				log.Println("knob: setClock error:", err)
@@ -361,8 +384,11 @@ func (h *Hub) runBrightnessLoop(ctx context.Context) {
 	b.Run(ctx)
 }
 
-// handleLEDMsg controls the expander LED from a websocket message.
-func (h *Hub) handleLEDMsg(channel string, state string, rateMs int) {
+// handleLEDMsg controls the expander LED from a websocket message or a
+// gesture. Its requests belong to the "user" owner at user priority, so
+// alerts still show over them; "clear" withdraws them. "toggle" turning the
+// LED off withdraws the user's request when it's the one showing.
+func (h *Hub) handleLEDMsg(channel string, state string, rateMs, ttlMs int) {
 	e := hardware.Expander()
 	if e == nil {
 		log.Println("led: expander not available")
@@ -383,14 +409,32 @@ func (h *Hub) handleLEDMsg(channel string, state string, rateMs int) {
 		return
 	}
 	switch state {
-	case "on":
-		l.On(e)
-	case "off":
-		l.Off(e)
-	case "blink":
-		if rateMs <= 0 {
-			rateMs = 500
+	case "clear":
+		l.Release(e, "user")
+		return
+	case "toggle":
+		cur := l.CurrentState()
+		switch {
+		case cur.Mode == "off":
+			state = "on"
+		case cur.Owner == "user":
+			// Withdraw our own request rather than stacking an "off"
+			// on it, so toggling doesn't leave a request that keeps
+			// hiding lower-priority ones.
+			l.Release(e, "user")
+			return
+		default:
+			state = "off"
 		}
-		l.Blink(e, time.Duration(rateMs)*time.Millisecond)
+	}
+	err := l.Request(e, led.Request{
+		Owner:    "user",
+		Priority: led.PriorityUser,
+		Pattern:  state,
+		Rate:     time.Duration(rateMs) * time.Millisecond,
+		TTL:      time.Duration(ttlMs) * time.Millisecond,
+	})
+	if err != nil {
+		log.Println(err)
 	}
 }
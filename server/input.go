@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/chromedp/cdproto/input"
 	"github.com/chromedp/chromedp"
 	"github.com/chromedp/chromedp/kb"
 	cfg "github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/encoder"
+	"github.com/vincent99/velocipi/server/gesture"
 	"github.com/vincent99/velocipi/server/hardware"
 	"github.com/vincent99/velocipi/server/hardware/expander"
 )
@@ -136,54 +139,45 @@ func (h *Hub) sendKeyEvent(jsKey string) {
 	}
 }
 
-// quadTable maps (prev<<2)|cur to a step direction for all 16 possible
-// 2-bit quadrature transitions. Valid single steps are ±1; invalid
-// transitions (same state or 2-step jumps) are 0.
-//
-// Observed sequence for one right detent: 11→10→00→01→11 (+4 steps)
-// Observed sequence for one left detent:  11→01→00→10→11 (-4 steps)
-var quadTable = [16]int{
-	//        cur: 00  01  10  11
-	/* prev 00 */ 0, +1, -1, 0,
-	/* prev 01 */ -1, 0, 0, +1,
-	/* prev 10 */ +1, 0, 0, -1,
-	/* prev 11 */ 0, -1, +1, 0,
+// inputState is the panel input decoding state owned by runInputLoop.
+type inputState struct {
+	inner, outer, joyKnob *encoder.Decoder
+	gestures              *gesture.Recognizer
 }
 
-// knobState accumulates quadrature steps and emits once per detent (2 steps).
-type knobState struct {
-	prev        uint8
-	accumulated int
+func newInputState() *inputState {
+	return &inputState{
+		inner:    encoder.New(encoder.Config{}, encoder.Rest),
+		outer:    encoder.New(encoder.Config{}, encoder.Rest),
+		joyKnob:  encoder.New(encoder.Config{}, encoder.Rest),
+		gestures: gesture.New(0, 0),
+	}
 }
 
-// update takes a new 2-bit quadrature sample and returns -1, 0, or +1 when
-// a full detent (2 steps) is reached.
-func (k *knobState) update(cur uint8) int {
-	k.accumulated += quadTable[(k.prev<<2)|cur]
-	k.prev = cur
-	if k.accumulated >= 2 {
-		k.accumulated = 0
-		return +1
-	}
-	if k.accumulated <= -2 {
-		k.accumulated = 0
-		return -1
+// encoderConfig converts an encoder's config for the decoder.
+func encoderConfig(c cfg.EncoderConfig) encoder.Config {
+	accel := make([]encoder.Point, len(c.Acceleration))
+	for i, p := range c.Acceleration {
+		accel[i] = encoder.Point{Speed: p.Speed, Steps: p.Steps}
 	}
-	return 0
+	return encoder.Config{TransitionsPerDetent: c.TransitionsPerDetent, Invert: c.Invert, Accel: accel}
 }
 
-func (h *Hub) handleChange(ch expander.Change, config *cfg.Config, inner, outer, joyKnob *knobState) {
+func (h *Hub) handleChange(ch expander.Change, in *inputState) {
 	v := ch.Value
 	p := ch.Previous
+	now := time.Now()
+	bindings := h.syncGestures(in.gestures)
+	var events []gesture.Event
 
 	bit := func(val uint16, n uint) bool { return val>>n&1 == 1 }
 	pressed := func(n uint) bool { return !bit(p, n) && bit(v, n) }
 	released := func(n uint) bool { return bit(p, n) && !bit(v, n) }
 
 	// Joystick directions: center bit drives press/release.
-	// keydown fires when center is pressed, for each direction bit currently held.
-	// keyup fires when center is released, for each direction bit that was held.
-	bits := config.Hardware.Expander.Bits
+	// A direction goes down when center is pressed, for each direction bit currently held,
+	// and up when center is released, for each direction bit that was held.
+	bits := h.cfg.Hardware.Expander.Bits
 	dirs := []struct {
 		bit     uint
 		logical string
@@ -196,58 +190,67 @@ func (h *Hub) handleChange(ch expander.Change, config *cfg.Config, inner, outer,
 	if pressed(bits.JoyCenter) {
 		for _, d := range dirs {
 			if bit(v, d.bit) {
-				h.dispatchLogical(input.KeyDown, d.logical)
+				events = append(events, in.gestures.Press(d.logical, now)...)
 			}
 		}
 	}
 	if released(bits.JoyCenter) {
 		for _, d := range dirs {
 			if bit(p, d.bit) {
-				h.dispatchLogical(input.KeyUp, d.logical)
+				events = append(events, in.gestures.Release(d.logical, now)...)
 			}
 		}
 	}
 
-	// Knob center: keydown on press, keyup on release.
+	// Knob center: down on press, up on release.
 	if pressed(bits.KnobCenter) {
-		h.dispatchLogical(input.KeyDown, "enter")
+		events = append(events, in.gestures.Press("enter", now)...)
 	}
 	if released(bits.KnobCenter) {
-		h.dispatchLogical(input.KeyUp, "enter")
+		events = append(events, in.gestures.Release("enter", now)...)
 	}
 
 	// quadSample builds a 2-bit value from two arbitrary bit positions:
-	// bit 0 = A, bit 1 = B — matching the ordering the quadTable expects.
+	// bit 0 = A, bit 1 = B — matching the ordering the decoder expects.
 	quadSample := func(val uint16, bitA, bitB uint) uint8 {
 		a := uint8((val >> bitA) & 1)
 		b := uint8((val >> bitB) & 1)
 		return (b << 1) | a
 	}
 
-	// Rotary encoders: update returns -1 (left), 0 (none), or 1 (right).
-	if d := outer.update(quadSample(v, bits.KnobOuterA, bits.KnobOuterB)); d == -1 {
-		h.sendLogical("outer-left")
-	} else if d == 1 {
-		h.sendLogical("outer-right")
+	// Rotary encoders: Update returns the steps completed, negative for
+	// left, scaled up when the knob spins fast.
+	encoders := h.cfg.Hardware.Expander.Encoders
+	knobs := []struct {
+		dec  *encoder.Decoder
+		conf cfg.EncoderConfig
+		a, b uint
+		name string
+	}{
+		{in.outer, encoders.Outer, bits.KnobOuterA, bits.KnobOuterB, "outer"},
+		{in.inner, encoders.Inner, bits.KnobInnerA, bits.KnobInnerB, "inner"},
+		{in.joyKnob, encoders.Joy, bits.JoyKnobA, bits.JoyKnobB, "joy"},
 	}
-
-	if d := inner.update(quadSample(v, bits.KnobInnerA, bits.KnobInnerB)); d == -1 {
-		h.sendLogical("inner-left")
-	} else if d == 1 {
-		h.sendLogical("inner-right")
+	for _, k := range knobs {
+		k.dec.Config = encoderConfig(k.conf)
+		n := k.dec.Update(quadSample(v, k.a, k.b), now)
+		turn := k.name + "-right"
+		if n < 0 {
+			n, turn = -n, k.name+"-left"
+		}
+		for range n {
+			events = append(events, in.gestures.Turn(turn, now)...)
+		}
 	}
 
-	if d := joyKnob.update(quadSample(v, bits.JoyKnobA, bits.JoyKnobB)); d == -1 {
-		h.sendLogical("joy-left")
-	} else if d == 1 {
-		h.sendLogical("joy-right")
-	}
+	h.emitInput(events, bindings)
 }
 
 // runInputLoop reads changes from the expander and fires chromedp keyboard events.
 //
 // Held inputs (joystick directions, knobCenter): keydown on press, keyup on release.
-// Rotary encoders (outer, inner, joyKnob): single KeyEvent per detected step.
+// Rotary encoders (outer, inner, joyKnob): a KeyEvent per step, several per detent when spun fast.
+// Inputs that are part of a bound gesture (ui.gestures) run its action instead.
 func (h *Hub) runInputLoop(ctx context.Context) {
 	e := hardware.Expander()
 	if e == nil {
@@ -255,12 +258,11 @@ func (h *Hub) runInputLoop(ctx context.Context) {
 		return
 	}
 
-	config := h.cfg
-
-	inner := &knobState{prev: 0b11}
-	outer := &knobState{prev: 0b11}
-	joyKnob := &knobState{prev: 0b11}
+	in := newInputState()
 
+	// The gesture timer fires long presses and ends double-press waits.
+	timer := time.NewTimer(0)
+	<-timer.C
 	for {
 		select {
 		case <-ctx.Done():
@@ -269,7 +271,13 @@ func (h *Hub) runInputLoop(ctx context.Context) {
 			if !ok {
 				return
 			}
-			h.handleChange(ch, config, inner, outer, joyKnob)
+			h.handleChange(ch, in)
+		case now := <-timer.C:
+			bindings := h.syncGestures(in.gestures)
+			h.emitInput(in.gestures.Tick(now), bindings)
+		}
+		if next, ok := in.gestures.Next(); ok {
+			timer.Reset(time.Until(next))
 		}
 	}
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/hardware/expander"
+	"github.com/vincent99/velocipi/server/hardware/i2c/i2ctest"
+)
+
+// inputRig is the panel input path from expander pins to key echoes, with a
+// simulated MCP23017 in place of the hardware.
+type inputRig struct {
+	t      *testing.T
+	hub    *Hub
+	client *client
+	chip   *i2ctest.MCP23017
+	exp    *expander.Expander
+	cfg    *config.Config
+	in     *inputState
+}
+
+func newInputRig(t *testing.T) *inputRig {
+	cfg := &config.Config{}
+	cfg.Hardware.Expander.Bits = config.ExpanderBits{
+		KnobInnerA: 0, KnobOuterA: 1, KnobInnerB: 2, KnobOuterB: 3, KnobCenter: 4,
+		LEDR: 5, LEDW: 6, LEDB: 7, LEDY: 8,
+		JoyCenter: 9, JoyUp: 10, JoyLeft: 11, JoyDown: 12, JoyRight: 13, JoyKnobA: 14, JoyKnobB: 15,
+	}
+	cfg.UI.KeyMap = config.KeyMapConfig{
+		Up: "ArrowUp", Down: "ArrowDown", Left: "ArrowLeft", Right: "ArrowRight", Enter: "Enter",
+		JoyLeft: "[", JoyRight: "]", InnerLeft: "a", InnerRight: "d", OuterLeft: "q", OuterRight: "e",
+	}
+	cfg.UI.Gestures = config.GestureConfig{LongPressMs: 600, DoublePressMs: 300}
+
+	c := &client{send: make(chan []byte, 64)}
+	h := &Hub{cfg: cfg, clients: map[*client]struct{}{c: {}}}
+
+	// The encoders rest with both contacts closed (pins low).
+	chip := i2ctest.NewMCP23017()
+	b := cfg.Hardware.Expander.Bits
+	chip.SetPins(0xFFFF &^ (1<<b.KnobInnerA | 1<<b.KnobInnerB | 1<<b.KnobOuterA | 1<<b.KnobOuterB | 1<<b.JoyKnobA | 1<<b.JoyKnobB))
+	e := expander.NewWithBus(i2ctest.NewFake(chip), time.Millisecond)
+	if err := e.Init(0xFE1F); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+
+	return &inputRig{
+		t: t, hub: h, client: c, chip: chip, exp: e, cfg: cfg, in: newInputState(),
+	}
+}
+
+// press pulls pins low (active; the expander inverts them) and release
+// lets them float high. Each feeds the resulting change through
+// handleChange.
+func (r *inputRig) press(pins ...uint) {
+	r.t.Helper()
+	levels := r.chip.Pins()
+	for _, n := range pins {
+		levels &^= 1 << n
+	}
+	r.apply(levels)
+}
+
+func (r *inputRig) release(pins ...uint) {
+	r.t.Helper()
+	levels := r.chip.Pins()
+	for _, n := range pins {
+		levels |= 1 << n
+	}
+	r.apply(levels)
+}
+
+func (r *inputRig) apply(levels uint16) {
+	r.t.Helper()
+	r.chip.SetPins(levels)
+	select {
+	case ch := <-r.exp.Updates():
+		r.hub.handleChange(ch, r.in)
+	case <-time.After(time.Second):
+		r.t.Fatal("no change from the expander")
+	}
+}
+
+// echoes returns the key echoes broadcast since the last call, as
+// "eventType key".
+func (r *inputRig) echoes() []string {
+	var out []string
+	for {
+		select {
+		case data := <-r.client.send:
+			var msg KeyEchoMsg
+			if err := json.Unmarshal(data, &msg); err != nil {
+				r.t.Fatal(err)
+			}
+			out = append(out, msg.EventType+" "+msg.Key)
+		default:
+			return out
+		}
+	}
+}
+
+func TestKnobDecoding(t *testing.T) {
+	r := newInputRig(t)
+	bits := r.cfg.Hardware.Expander.Bits
+
+	// One right detent on the inner knob, as (B, A) samples:
+	// 11 -> 10 -> 00 -> 01 -> 11.
+	a, b := bits.KnobInnerA, bits.KnobInnerB
+	r.release(a)
+	r.release(b)
+	r.press(a)
+	r.press(b)
+	if got, want := r.echoes(), []string{"keydown inner-right", "keydown inner-right"}; !slices.Equal(got, want) {
+		t.Errorf("inner right detent: %v, want %v", got, want)
+	}
+
+	// One left detent on the outer knob: 11 -> 01 -> 00 -> 10 -> 11.
+	a, b = bits.KnobOuterA, bits.KnobOuterB
+	r.release(b)
+	r.release(a)
+	r.press(b)
+	r.press(a)
+	if got, want := r.echoes(), []string{"keydown outer-left", "keydown outer-left"}; !slices.Equal(got, want) {
+		t.Errorf("outer left detent: %v, want %v", got, want)
+	}
+}
+
+func TestKnobConfig(t *testing.T) {
+	r := newInputRig(t)
+	bits := r.cfg.Hardware.Expander.Bits
+	r.cfg.Hardware.Expander.Encoders.Inner = config.EncoderConfig{TransitionsPerDetent: 4, Invert: true}
+
+	// A full right cycle is one detent, reported as left.
+	a, b := bits.KnobInnerA, bits.KnobInnerB
+	r.release(a)
+	r.release(b)
+	r.press(a)
+	r.press(b)
+	if got, want := r.echoes(), []string{"keydown inner-left"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJoystickAndEnter(t *testing.T) {
+	r := newInputRig(t)
+	bits := r.cfg.Hardware.Expander.Bits
+
+	r.press(bits.KnobCenter)
+	r.release(bits.KnobCenter)
+	r.press(bits.JoyUp)
+	r.press(bits.JoyCenter)
+	r.release(bits.JoyCenter)
+	r.release(bits.JoyUp)
+	want := []string{"keydown enter", "keyup enter", "keydown up", "keyup up"}
+	if got := r.echoes(); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGestureBindings(t *testing.T) {
+	r := newInputRig(t)
+	bits := r.cfg.Hardware.Expander.Bits
+	r.cfg.UI.Gestures.Bindings = []config.GestureBinding{
+		{Gesture: "enter+inner-right", Action: "key", Target: "outer-right"},
+		{Gesture: "left+up", Action: "key", Target: "joy-left"},
+		{Gesture: "inner-right", Route: "music", Action: "key", Target: "joy-right"},
+	}
+
+	// Turning the inner knob with the center held.
+	a, b := bits.KnobInnerA, bits.KnobInnerB
+	r.press(bits.KnobCenter)
+	r.release(a)
+	r.release(b)
+	r.release(bits.KnobCenter)
+	if got, want := r.echoes(), []string{"keydown outer-right"}; !slices.Equal(got, want) {
+		t.Errorf("press and turn: %v, want %v", got, want)
+	}
+
+	// A joystick diagonal is a chord; a single direction isn't.
+	r.press(bits.JoyUp, bits.JoyLeft)
+	r.press(bits.JoyCenter)
+	r.release(bits.JoyCenter)
+	r.release(bits.JoyUp)
+	r.press(bits.JoyCenter)
+	r.release(bits.JoyCenter)
+	want := []string{"keydown joy-left", "keydown left", "keyup left"}
+	if got := r.echoes(); !slices.Equal(got, want) {
+		t.Errorf("chord: %v, want %v", got, want)
+	}
+	r.release(bits.JoyLeft)
+
+	// The music page rebinds the plain turn.
+	r.hub.panelRoute = "music/songs"
+	r.press(a)
+	r.press(b)
+	r.hub.panelRoute = "aircon"
+	r.release(a)
+	r.release(b)
+	want = []string{"keydown joy-right", "keydown inner-right"}
+	if got := r.echoes(); !slices.Equal(got, want) {
+		t.Errorf("route binding: %v, want %v", got, want)
+	}
+}
@@ -17,19 +17,26 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/vincent99/velocipi/server/auth"
+	"github.com/vincent99/velocipi/server/camera"
 	"github.com/vincent99/velocipi/server/config"
 	"github.com/vincent99/velocipi/server/dvr"
 	"github.com/vincent99/velocipi/server/hardware"
 	"github.com/vincent99/velocipi/server/hardware/blescan"
+	"github.com/vincent99/velocipi/server/hardware/led"
 	"github.com/vincent99/velocipi/server/hardware/oled"
 	"github.com/vincent99/velocipi/server/hardware/siyi"
 	"github.com/vincent99/velocipi/server/music"
+	"github.com/vincent99/velocipi/server/snaps"
 )
 
 func main() {
 	configDir := flag.String("config", ".", "directory containing config.default.yaml / config.yaml")
 	flag.Parse()
 	config.SetDir(*configDir)
+	if flag.Arg(0) == "config" {
+		os.Exit(configCommand(flag.Args()[1:]))
+	}
 
 	result := config.Load()
 	cfg := result.Config
@@ -40,12 +47,12 @@ func main() {
 	hardware.Reset(200 * time.Millisecond)
 
 	// Start LED blinking immediately as a startup indicator.
-	// It will be turned off once the first frame blits to the OLED.
+	// It will be released once the first frame blits to the OLED.
 	if e := hardware.Expander(); e != nil {
-		hardware.LEDRed().Blink(e, 250*time.Millisecond)
-		hardware.LEDWhite().On(e)
-		hardware.LEDBlue().On(e)
-		hardware.LEDYellow().On(e)
+		_ = hardware.LEDRed().Request(e, led.Request{Owner: "startup", Pattern: "blink", Rate: 250 * time.Millisecond})
+		for _, l := range []*led.Controller{hardware.LEDWhite(), hardware.LEDBlue(), hardware.LEDYellow()} {
+			_ = l.Request(e, led.Request{Owner: "startup", Pattern: "on"})
+		}
 	}
 
 	// Initialise the OLED display. Non-fatal if the hardware isn't present.
@@ -77,10 +84,25 @@ func main() {
 	// browserCtx is set after the browser starts up below.
 	hub = newHub(nil, cfg, display)
 
+	authn, err := auth.New(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Config changes (POST /config, edits to config.yaml) go through the
+	// bus, which applies them to each subsystem registered below.
+	bus := config.NewBus(cfg, defaults)
+
 	// Start HTTP server first so the browser can reach /app when it navigates.
 	mux := http.NewServeMux()
+	authn.RegisterRoutes(mux)
 	mux.HandleFunc("/ws", wsHandler)
 	mux.HandleFunc("/screen", screenHandler)
+	mux.HandleFunc("/config/schema", func(w http.ResponseWriter, r *http.Request) {
+		relDefaults := config.RelativizeStorage(*defaults)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(config.JSONSchema(&relDefaults))
+	})
 	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -89,6 +111,11 @@ func main() {
 				err  error
 			)
 			if r.URL.Query().Get("full") == "true" {
+				// The full config includes user password hashes.
+				if !isAdmin(r) {
+					http.Error(w, "forbidden", http.StatusForbidden)
+					return
+				}
 				// Present storage paths relative to the config dir (they are
 				// absolute internally); POST re-resolves them via ResolveStorage.
 				relCfg := config.RelativizeStorage(*cfg)
@@ -116,14 +143,15 @@ func main() {
 				return
 			}
 			// The UI sees storage relative to the config dir; resolve back to
-			// absolute so internal use and the SaveOverrides diff stay consistent.
+			// absolute so internal use and the saved overrides stay consistent.
 			config.ResolveStorage(&updated)
-			if err := config.SaveOverrides(updated, *defaults); err != nil {
-				http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+			res, err := bus.Update(updated)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			*cfg = updated
-			w.WriteHeader(http.StatusNoContent)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(res)
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -131,9 +159,10 @@ func main() {
 	// /cameras — list configured cameras sorted by sort then alphabetically.
 	mux.HandleFunc("/cameras", func(w http.ResponseWriter, r *http.Request) {
 		type cameraInfo struct {
-			Name   string `json:"name"`
-			Driver string `json:"driver"`
-			Audio  bool   `json:"audio"`
+			Name         string               `json:"name"`
+			Driver       string               `json:"driver"`
+			Audio        bool                 `json:"audio"`
+			Capabilities *camera.Capabilities `json:"capabilities,omitempty"` // nil if not controllable
 		}
 		cams := make([]config.CameraConfig, len(cfg.DVR.Cameras))
 		copy(cams, cfg.DVR.Cameras)
@@ -157,7 +186,12 @@ func main() {
 			if driver == "" {
 				driver = "rtsp"
 			}
-			infos = append(infos, cameraInfo{Name: c.Name, Driver: driver, Audio: c.Audio})
+			info := cameraInfo{Name: c.Name, Driver: driver, Audio: c.Audio}
+			if ctl := hub.lookupCameraController(c.Name); ctl != nil {
+				caps := ctl.Capabilities()
+				info.Capabilities = &caps
+			}
+			infos = append(infos, info)
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(infos)
@@ -197,6 +231,34 @@ func main() {
 		}
 	})
 
+	// /dvr/retention — GET previews the retention plan (dry run, optionally
+	// ?camera=<name>); POST applies it now in the background (admin only).
+	mux.HandleFunc("/dvr/retention", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			plan, err := dvrManager.PlanRetention(time.Now().UTC(), r.URL.Query().Get("camera"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(plan)
+		case http.MethodPost:
+			if !isAdmin(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			go func() {
+				if _, err := dvrManager.ApplyRetention(ctx); err != nil {
+					log.Println("dvr: retention error:", err)
+				}
+			}()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	// /mpegts/{camera} — on-demand MPEG-TS stream piped directly from ffmpeg.
 	// The browser plays this with mpegts.js via MSE. The stream runs until the
 	// client disconnects or the server shuts down.
@@ -262,16 +324,9 @@ func main() {
 		}
 	})
 
-	// /admin — sets or clears the admin cookie then redirects to /remote/home.
-	// /admin       → sets admin=true cookie (1 year)
-	// /admin?off   → clears cookie
+	// /admin — old bookmark for the admin cookie; admins now log in.
 	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
-		if _, off := r.URL.Query()["off"]; off {
-			http.SetCookie(w, &http.Cookie{Name: "admin", Value: "", MaxAge: -1, Path: "/"})
-		} else {
-			http.SetCookie(w, &http.Cookie{Name: "admin", Value: "true", MaxAge: 86400 * 365, Path: "/"})
-		}
-		http.Redirect(w, r, "/remote/home", http.StatusFound)
+		http.Redirect(w, r, "/remote/login", http.StatusFound)
 	})
 
 	// /recordings — list, serve, and delete archived MP4 segments.
@@ -307,6 +362,62 @@ func main() {
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// /recordings/protect/{session} — PUT {"protected": bool} protects a whole
+	// session from retention and minFreeDisk (admin only).
+	mux.HandleFunc("/recordings/protect/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Protected bool `json:"protected"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		session := r.URL.Path[len("/recordings/protect/"):]
+		if err := dvrManager.SetSessionProtected(session, body.Protected); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// /recordings/lock/{session}/{filename} — PUT {"locked": bool} locks a single
+	// recording against retention and minFreeDisk (admin only).
+	mux.HandleFunc("/recordings/lock/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Locked bool `json:"locked"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := strings.SplitN(r.URL.Path[len("/recordings/lock/"):], "/", 2)
+		if len(parts) != 2 {
+			http.Error(w, "expected /recordings/lock/{session}/{filename}", http.StatusBadRequest)
+			return
+		}
+		if err := dvrManager.SetRecordingLocked(parts[0], parts[1], body.Locked); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
 	// /recordings/hour/{session}/{hour} — DELETE removes all recordings in a given hour.
 	mux.HandleFunc("/recordings/hour/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
@@ -360,7 +471,16 @@ func main() {
 		}
 	})
 
-	// /siyi/{camera}/{action} — Siyi gimbal control routes.
+	// Snaps library: media synced from siyi SD cards, tagged with the
+	// aircraft position from the track log. Cameras are added below once
+	// their managers exist.
+	snapsLib := snaps.NewLibrary(cfg.Storage.Snaps, cfg.DVR.ThumbnailHeight)
+	snapsSyncer := snaps.NewSyncer(snapsLib, func(st snaps.SyncStatus) {
+		hub.broadcastAll(SnapsSyncMsg{Type: "snapsSync", SyncStatus: st})
+	})
+
+	// /siyi/{camera}/{action} — Siyi SD card access (files, download).
+	// Gimbal control lives on the driver-neutral /camera/ routes.
 	// Camera name is URL-decoded from the path segment.
 	lookupSiyi := func(name string) *siyi.Manager {
 		hub.mu.RLock()
@@ -389,45 +509,8 @@ func main() {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		var body map[string]any
-		_ = json.NewDecoder(r.Body).Decode(&body)
 
-		var err error
 		switch action {
-		case "gimbal":
-			yaw := int8(jsonInt(body, "yaw"))
-			pitch := int8(jsonInt(body, "pitch"))
-			err = mgr.GimbalRotate(yaw, pitch)
-		case "zoom":
-			if v, ok := body["absolute"]; ok {
-				err = mgr.AbsoluteZoom(float32(toFloat(v)))
-			} else {
-				err = mgr.ZoomRate(int8(jsonInt(body, "direction")))
-			}
-		case "photo":
-			err = mgr.TakePhoto()
-		case "video":
-			err = mgr.ToggleVideo()
-		case "center":
-			err = mgr.Center()
-		case "focus":
-			if jsonStr(body, "mode") == "auto" {
-				err = mgr.AutoFocus()
-			} else {
-				err = mgr.ManualFocus(int8(jsonInt(body, "direction")))
-			}
-		case "mode":
-			switch jsonStr(body, "mode") {
-			case "lock":
-				err = mgr.SetMode(siyi.ModeLock)
-			case "follow":
-				err = mgr.SetMode(siyi.ModeFollow)
-			case "fpv":
-				err = mgr.SetMode(siyi.ModeFPV)
-			default:
-				http.Error(w, "unknown mode", http.StatusBadRequest)
-				return
-			}
 		case "files":
 			// GET override for file listing (POST not ideal but kept consistent)
 			dl := siyi.NewDownloader(mgr.Host(), cfg.Storage.Snaps)
@@ -439,7 +522,6 @@ func main() {
 			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]any{"photos": photos, "videos": videos})
-			return
 		case "download":
 			fileURL := r.URL.Query().Get("url")
 			fileName := r.URL.Query().Get("name")
@@ -447,30 +529,28 @@ func main() {
 				http.Error(w, "url and name params required", http.StatusBadRequest)
 				return
 			}
-			dl := siyi.NewDownloader(mgr.Host(), cfg.Storage.Snaps)
-			dest, dlErr := dl.Download(r.Context(), siyi.MediaFile{Name: fileName, URL: fileURL})
+			item, dlErr := snapsSyncer.Fetch(r.Context(), cameraName, siyi.MediaFile{Name: fileName, URL: fileURL})
 			if dlErr != nil {
 				http.Error(w, dlErr.Error(), http.StatusInternalServerError)
 				return
 			}
+			// "path" is the local file, as before the snaps library; the
+			// file now lands in {snaps}/{camera}.
+			path, _ := snapsLib.Resolve(item.Path)
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]string{"path": dest})
-			return
+			json.NewEncoder(w).Encode(map[string]any{"path": path, "item": item})
 		default:
 			http.NotFound(w, r)
-			return
 		}
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
 	})
 
 	registerAirConRoutes(mux)
+	registerCameraRoutes(mux, cfg, bus)
+	registerThermalRoutes(mux, cfg, bus)
+	registerSnapsRoutes(ctx, mux, snapsLib, snapsSyncer)
 
 	mux.Handle("/", spaHandler("ui/dist"))
-	handler := corsMiddleware(mux)
+	handler := corsMiddleware(authn.Middleware(mux))
 
 	addr := cfg.Addr
 	ln, err := net.Listen("tcp", addr)
@@ -493,6 +573,7 @@ func main() {
 		hub.mu.Lock()
 		hub.browserCtx = browserCtx
 		hub.mu.Unlock()
+		hub.trackPanelRoute(browserCtx)
 
 		// Navigate to the app now that the HTTP server is listening.
 		if err := navigateTo(browserCtx, cfg.AppURL); err != nil {
@@ -511,6 +592,7 @@ func main() {
 	go hub.runScreencastLoop(ctx)
 	go hub.runAxisLoop(ctx)
 	go hub.runAirConLoop(ctx)
+	go hub.runThermalLoop(ctx)
 	go hub.runBrightnessLoop(ctx)
 
 	// Start Siyi managers for cameras with driver: "siyi".
@@ -520,11 +602,7 @@ func main() {
 			continue
 		}
 		mgr := siyi.New(cam, func(name string, att siyi.GimbalAttitude) {
-			hub.broadcastAll(SiyiAttitudeMsg{
-				Type: "siyiAttitude", Camera: name,
-				Yaw: att.Yaw, Pitch: att.Pitch, Roll: att.Roll,
-				YawRate: att.YawRate, PitchRate: att.PitchRate, RollRate: att.RollRate,
-			})
+			hub.broadcastAll(CameraAttitudeMsg{Type: "cameraAttitude", Camera: name, Attitude: camera.SiyiAttitude(att)})
 		})
 		siyiManagers[cam.Name] = mgr
 		go mgr.Start(ctx)
@@ -536,10 +614,31 @@ func main() {
 			log.Printf("siyi: started AI tracker for camera %q at %s", cam.Name, cam.SiyiAIHost)
 		}
 	}
+	// PTZ controllers for the driver-neutral /camera/ routes.
+	cameraControllers := make(map[string]camera.Controller)
+	for name, mgr := range siyiManagers {
+		cameraControllers[name] = camera.NewSiyi(mgr)
+	}
+	for _, cam := range cfg.DVR.Cameras {
+		if cam.Driver == "onvif" && cam.ONVIFURL != "" {
+			cameraControllers[cam.Name] = camera.NewONVIF(cam)
+		}
+	}
 	hub.mu.Lock()
 	hub.siyiManagers = siyiManagers
+	hub.cameraControllers = cameraControllers
+	hub.snapTrack = snapsLib.Track()
 	hub.mu.Unlock()
 
+	// Sync siyi SD cards into the snaps library after every landing.
+	for name, mgr := range siyiManagers {
+		snapsSyncer.AddCamera(name, mgr.Host())
+	}
+	snapsLib.Track().OnLanding(func(flight string) {
+		log.Printf("snaps: flight %s landed, syncing camera media", flight)
+		snapsSyncer.SyncAll(ctx)
+	})
+
 	// Connect DVR manager to hub for camera status broadcasts.
 	hub.mu.Lock()
 	hub.dvrManager = dvrManager
@@ -566,15 +665,20 @@ func main() {
 	dvrManager.Start(ctx)
 
 	// Initialize music subsystem (requires mpv in PATH; disabled gracefully otherwise).
+	var zones *music.Zones
 	musicDB, musicEnabled := music.InitDB(cfg.Music, "schemas", cfg.Storage.Backup)
 	if musicEnabled {
 		defer musicDB.Close()
-		player := music.NewPlayer(musicDB, cfg.Music, hub)
-		hub.SetMusicPlayer(player)
-		go player.Run(ctx)
-		music.RegisterRoutes(mux, musicDB, player, *cfg, isAdmin)
+		zones = music.NewZones(musicDB, cfg.Music, hub)
+		zones.SetFlight(snapsLib.Track().Flight)
+		hub.SetMusicPlayer(zones)
+		go zones.Run(ctx)
+		music.RegisterRoutes(mux, musicDB, zones, *cfg, isAdmin)
 	}
 
+	registerConfigSections(bus, hub, authn, dvrManager, zones, display)
+	go bus.Watch(ctx)
+
 	// Initialize localCamera to the first camera (same sort as /cameras handler).
 	if len(cfg.DVR.Cameras) > 0 {
 		sorted := make([]config.CameraConfig, len(cfg.DVR.Cameras))
@@ -612,41 +716,11 @@ func main() {
 
 	// Turn off LED and clear OLED on exit.
 	if e := hardware.Expander(); e != nil {
-		hardware.LEDRed().Off(e)
-		hardware.LEDWhite().Off(e)
-		hardware.LEDBlue().Off(e)
-		hardware.LEDYellow().Off(e)
+		for _, l := range []*led.Controller{hardware.LEDRed(), hardware.LEDWhite(), hardware.LEDBlue(), hardware.LEDYellow()} {
+			l.Reset(e)
+		}
 	}
 	if display != nil {
 		display.Close()
 	}
 }
-
-// jsonInt extracts an integer value from a decoded JSON body map.
-func jsonInt(m map[string]any, key string) int {
-	if v, ok := m[key]; ok {
-		return int(toFloat(v))
-	}
-	return 0
-}
-
-// jsonStr extracts a string value from a decoded JSON body map.
-func jsonStr(m map[string]any, key string) string {
-	if v, ok := m[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
-		}
-	}
-	return ""
-}
-
-// toFloat converts a JSON-decoded number (float64) or string to float64.
-func toFloat(v any) float64 {
-	switch n := v.(type) {
-	case float64:
-		return n
-	case int:
-		return float64(n)
-	}
-	return 0
-}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"github.com/vincent99/velocipi/server/camera"
 	"github.com/vincent99/velocipi/server/hardware"
 	"github.com/vincent99/velocipi/server/hardware/aircon"
 	"github.com/vincent99/velocipi/server/hardware/airsensor"
 	"github.com/vincent99/velocipi/server/hardware/led"
+	"github.com/vincent99/velocipi/server/hardware/thermalcam"
 	"github.com/vincent99/velocipi/server/hardware/tpms"
+	"github.com/vincent99/velocipi/server/snaps"
 )
 
 // Outbound message types. Each has a fixed Type field so the JSON consumer
@@ -33,8 +36,9 @@ type TpmsMsg struct {
 
 // LEDChannel carries the state of one LED channel.
 type LEDChannel struct {
-	Mode string `json:"mode"`           // "off", "on", "blink"
-	Rate int    `json:"rate,omitempty"` // blink rate in ms, only when mode == "blink"
+	Mode  string `json:"mode"`            // the pattern showing: "off", "on", "blink", "heartbeat", "double-blink", "sos" or "fade"
+	Rate  int    `json:"rate,omitempty"`  // the pattern's unit in ms, e.g. the blink half period; omitted for "on" and "off"
+	Owner string `json:"owner,omitempty"` // who requested it, e.g. "startup" or "user"; omitted when nothing is requested
 }
 
 // LEDStateMsg carries the state of all four LED channels.
@@ -77,15 +81,24 @@ type AxisStateMsg struct {
 	OATCelsius float64 `json:"oatCelsius"`
 }
 
-type SiyiAttitudeMsg struct {
-	Type      string  `json:"type"`   // always "siyiAttitude"
-	Camera    string  `json:"camera"` // camera name
-	Yaw       float32 `json:"yaw"`
-	Pitch     float32 `json:"pitch"`
-	Roll      float32 `json:"roll"`
-	YawRate   float32 `json:"yawRate"`
-	PitchRate float32 `json:"pitchRate"`
-	RollRate  float32 `json:"rollRate"`
+// CameraAttitudeMsg broadcasts a gimbal attitude update for one camera.
+type CameraAttitudeMsg struct {
+	Type   string `json:"type"`   // always "cameraAttitude"
+	Camera string `json:"camera"` // camera name
+	camera.Attitude
+}
+
+// CameraCapabilitiesMsg tells a newly-connected client which controls each
+// controllable camera supports.
+type CameraCapabilitiesMsg struct {
+	Type    string                         `json:"type"` // always "cameraCapabilities"
+	Cameras map[string]camera.Capabilities `json:"cameras"`
+}
+
+// SnapsSyncMsg broadcasts a camera's SD card sync progress.
+type SnapsSyncMsg struct {
+	Type string `json:"type"` // always "snapsSync"
+	snaps.SyncStatus
 }
 
 // AirConStateMsg broadcasts the current aircon state to all WS clients.
@@ -94,6 +107,13 @@ type AirConStateMsg struct {
 	State aircon.State `json:"state"`
 }
 
+// ThermalStateMsg broadcasts the thermal camera's settings and versions to
+// all WS clients whenever they change.
+type ThermalStateMsg struct {
+	Type  string           `json:"type"` // always "thermalState"
+	State thermalcam.State `json:"state"`
+}
+
 // AirConHistoryMsg sends the temperature history to a newly-connected client.
 type AirConHistoryMsg struct {
 	Type    string              `json:"type"` // always "airConHistory"
@@ -106,6 +126,12 @@ type AirConSampleMsg struct {
 	Sample aircon.TempSample `json:"sample"`
 }
 
+// ConfigChangedMsg tells browsers the UI config changed, so they re-fetch
+// /config.
+type ConfigChangedMsg struct {
+	Type string `json:"type"` // always "configChanged"
+}
+
 // Inbound message types from websocket clients.
 
 type inboundMsg struct {
@@ -113,15 +139,23 @@ type inboundMsg struct {
 }
 
 type inboundMusicControlMsg struct {
-	Action string  `json:"action"`          // play|pause|stop|next|prev|seek|skipForward|skipBack|setVolume|setShuffle|setRepeat
+	Action string  `json:"action"`          // play|pause|stop|next|prev|seek|skipForward|skipBack|setVolume|setShuffle|setRepeat|setAutoDJ|setAutoDJSeed
 	Value  float64 `json:"value,omitempty"` // seek: absolute sec; skipForward/skipBack: delta sec; setVolume: 0-100
-	Str    string  `json:"str,omitempty"`   // setRepeat: "off"|"song"|"queue"
+	Str    string  `json:"str,omitempty"`   // setRepeat: "off"|"song"|"queue"; setAutoDJ: "true"|"false"; setAutoDJSeed: {"kind","value"} JSON
+	Zone   string  `json:"zone,omitempty"`  // audio zone ID; "" = the default zone
 }
 
 type inboundSetLocalCameraMsg struct {
 	Camera string `json:"camera"`
 }
 
+// inboundCameraControlMsg is a camera.Command addressed to a named camera,
+// the websocket equivalent of POST /camera/{name}/{action}.
+type inboundCameraControlMsg struct {
+	Camera string `json:"camera"`
+	camera.Command
+}
+
 type inboundKeyMsg struct {
 	EventType string `json:"eventType"` // "keydown", "keyup", or "keypress"
 	Key       string `json:"key"`
@@ -129,8 +163,9 @@ type inboundKeyMsg struct {
 
 type inboundLEDMsg struct {
 	Channel string `json:"channel"`        // "r", "w", "b", "y"
-	State   string `json:"state"`          // "off", "on", "blink"
-	Rate    int    `json:"rate,omitempty"` // blink rate in ms, default 500
+	State   string `json:"state"`          // a pattern name (see led.Patterns), "toggle", or "clear" to withdraw
+	Rate    int    `json:"rate,omitempty"` // the pattern's unit in ms; 0 is its default
+	TTL     int    `json:"ttl,omitempty"`  // ms until the request lapses; 0 is never
 }
 
 type inboundNavigateMsg struct {
@@ -141,11 +176,7 @@ type inboundNavigateMsg struct {
 func currentLEDStateMsg() LEDStateMsg {
 	ch := func(l *led.Controller) LEDChannel {
 		s := l.CurrentState()
-		c := LEDChannel{Mode: s.Mode}
-		if s.Mode == "blink" {
-			c.Rate = int(s.Rate.Milliseconds())
-		}
-		return c
+		return LEDChannel{Mode: s.Mode, Rate: int(s.Rate.Milliseconds()), Owner: s.Owner}
 	}
 	return LEDStateMsg{
 		Type: "ledState",
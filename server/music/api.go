@@ -99,7 +99,7 @@ type SongsResponse struct {
 
 type musicAPI struct {
 	db        *DB
-	player    *Player
+	zones     *Zones
 	cfg       config.Config
 	musicDir  string
 	backupDir string
@@ -107,8 +107,8 @@ type musicAPI struct {
 }
 
 // RegisterRoutes registers all /music/* HTTP handlers on mux.
-func RegisterRoutes(mux *http.ServeMux, db *DB, player *Player, cfg config.Config, isAdmin func(*http.Request) bool) {
-	a := &musicAPI{db: db, player: player, cfg: cfg, musicDir: cfg.Storage.Music, backupDir: cfg.Storage.Backup, isAdmin: isAdmin}
+func RegisterRoutes(mux *http.ServeMux, db *DB, zones *Zones, cfg config.Config, isAdmin func(*http.Request) bool) {
+	a := &musicAPI{db: db, zones: zones, cfg: cfg, musicDir: cfg.Storage.Music, backupDir: cfg.Storage.Backup, isAdmin: isAdmin}
 
 	mux.HandleFunc("/music/songs", a.handleSongs)
 	mux.HandleFunc("/music/albums", a.handleAlbums)
@@ -116,6 +116,7 @@ func RegisterRoutes(mux *http.ServeMux, db *DB, player *Player, cfg config.Confi
 	mux.HandleFunc("/music/genres", a.handleGenres)
 	mux.HandleFunc("/music/decades", a.handleDecades)
 	mux.HandleFunc("/music/cover/", a.handleCover)
+	mux.HandleFunc("/music/zones", a.handleZones) // GET /music/zones — configured audio zones
 	mux.HandleFunc("/music/state", a.handleState)
 	mux.HandleFunc("/music/queue", a.handleQueue)
 	mux.HandleFunc("/music/queue/enqueue", a.handleEnqueue)
@@ -124,9 +125,19 @@ func RegisterRoutes(mux *http.ServeMux, db *DB, player *Player, cfg config.Confi
 	mux.HandleFunc("/music/queue/remove", a.handleQueueRemove)
 	mux.HandleFunc("/music/queue/move", a.handleQueueMove)
 	mux.HandleFunc("/music/control", a.handleControl)
+	mux.HandleFunc("/music/duck", a.handleDuck) // POST /music/duck — radio traffic signal from intercom-stt
 	mux.HandleFunc("/music/sync", a.handleSync)
+	mux.HandleFunc("/music/history", a.handleHistory)                     // GET /music/history — recorded plays, newest first
+	mux.HandleFunc("/music/stats/top", a.handleStatsTop)                  // GET /music/stats/top?by=artist|album
+	mux.HandleFunc("/music/stats/never-played", a.handleStatsNeverPlayed) // GET /music/stats/never-played
+	mux.HandleFunc("/music/stats/skipped", a.handleStatsSkipped)          // GET /music/stats/skipped?days=30
+	mux.HandleFunc("/music/scrobbles", a.handleScrobbles)                 // GET /music/scrobbles?format=listenbrainz|lastfm — offline scrobble export
+	mux.HandleFunc("/music/scrobbles/clear", a.handleScrobblesClear)      // POST /music/scrobbles/clear — drop submitted scrobbles (admin)
 	mux.HandleFunc("/music/playlists", a.handlePlaylists)
-	mux.HandleFunc("/music/playlists/", a.handlePlaylist) // PUT/DELETE /music/playlists/{id} and GET /music/playlists/{id}/songs
+	mux.HandleFunc("/music/playlists/", a.handlePlaylist)              // PUT/DELETE /music/playlists/{id}, GET /music/playlists/{id}/songs and /export
+	mux.HandleFunc("/music/playlists/import", a.handlePlaylistImport)  // POST /music/playlists/import?name=&filename= — M3U/PLS/XSPF body
+	mux.HandleFunc("/music/duplicates", a.handleDuplicates)            // GET /music/duplicates — likely duplicate recordings with a recommended keeper
+	mux.HandleFunc("/music/duplicates/merge", a.handleDuplicatesMerge) // POST /music/duplicates/merge — fold duplicates into the keeper (admin)
 	mux.HandleFunc("/music/smartsearches", a.handleSmartSearches)
 	mux.HandleFunc("/music/smartsearches/", a.handleSmartSearch) // GET /music/smartsearches/{id}/songs, DELETE /music/smartsearches/{id}
 	mux.HandleFunc("/music/songs/", a.handleSongByIDOrAction)    // /music/songs/{id}, /music/songs/{id}/mark, /music/songs/{id}/delete
@@ -141,19 +152,31 @@ func jsonOK(w http.ResponseWriter, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// queryError writes a smart search query error as 400 JSON {error, pos}.
+func queryError(w http.ResponseWriter, err error) {
+	qe, ok := err.(*QueryError)
+	if !ok {
+		qe = &QueryError{Msg: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(qe)
+}
+
 // scanSong scans one song row (with genre as JSON text) into a Song struct.
-func scanSong(rows *sql.Rows) (*Song, error) {
+// Any leading columns selected before the song's are scanned into extra.
+func scanSong(rows *sql.Rows, extra ...any) (*Song, error) {
 	var s Song
 	var genreJSON string
 	var deleted sql.NullString
 	var coverID sql.NullInt64
-	err := rows.Scan(
+	err := rows.Scan(append(extra,
 		&s.ID, &s.Path, &s.Hash, &coverID,
 		&s.Added, &s.Updated, &deleted, &s.Marked, &s.Favorite,
 		&s.Artist, &s.Album, &s.ArtistSort, &s.AlbumSort,
 		&s.Title, &s.DiscNumber, &s.TrackNumber, &s.TrackTotal,
 		&genreJSON, &s.Length, &s.Year, &s.Plays, &s.Format, &s.Bitrate,
-	)
+	)...)
 	if err != nil {
 		return nil, err
 	}
@@ -400,12 +423,34 @@ func (a *musicAPI) handleCover(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// zonePlayer returns the player for the request's ?zone= (the default zone
+// if absent), writing a 404 and returning nil if there's no such zone.
+func (a *musicAPI) zonePlayer(w http.ResponseWriter, r *http.Request) *Player {
+	p := a.zones.Zone(r.URL.Query().Get("zone"))
+	if p == nil {
+		http.Error(w, "unknown zone", http.StatusNotFound)
+	}
+	return p
+}
+
+func (a *musicAPI) handleZones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, a.zones.Configs())
+}
+
 func (a *musicAPI) handleState(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	jsonOK(w, a.player.StateMsg())
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
+	jsonOK(w, p.StateMsg())
 }
 
 // QueueEntry is the API shape for one queue row (song + position metadata).
@@ -422,9 +467,17 @@ type QueueResponse struct {
 }
 
 func (a *musicAPI) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
-		qs := a.player.QueueSnapshot()
+		qs := p.QueueSnapshot()
 		resp := QueueResponse{
 			CurrentIndex: qs.CurrentIndex,
 			Entries:      make([]QueueEntryResponse, 0, len(qs.Entries)),
@@ -455,10 +508,8 @@ func (a *musicAPI) handleQueue(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		ids, _ := json.Marshal(body.SongIDs)
-		a.player.Control(ControlMsg{Action: "replace", Str: string(ids)})
+		p.Control(ControlMsg{Action: "replace", Str: string(ids)})
 		w.WriteHeader(http.StatusNoContent)
-	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -467,6 +518,10 @@ func (a *musicAPI) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	var body struct {
 		SongIDs []int64 `json:"songIds"`
 	}
@@ -475,7 +530,7 @@ func (a *musicAPI) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ids, _ := json.Marshal(body.SongIDs)
-	a.player.Control(ControlMsg{Action: "enqueue", Str: string(ids)})
+	p.Control(ControlMsg{Action: "enqueue", Str: string(ids)})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -484,6 +539,10 @@ func (a *musicAPI) handleAppend(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	var body struct {
 		SongIDs []int64 `json:"songIds"`
 	}
@@ -492,7 +551,7 @@ func (a *musicAPI) handleAppend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ids, _ := json.Marshal(body.SongIDs)
-	a.player.Control(ControlMsg{Action: "append", Str: string(ids)})
+	p.Control(ControlMsg{Action: "append", Str: string(ids)})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -501,6 +560,10 @@ func (a *musicAPI) handleInsertAt(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	var body struct {
 		SongIDs []int64 `json:"songIds"`
 		Index   int     `json:"index"`
@@ -513,7 +576,7 @@ func (a *musicAPI) handleInsertAt(w http.ResponseWriter, r *http.Request) {
 		IDs   []int64 `json:"ids"`
 		Index int     `json:"index"`
 	}{body.SongIDs, body.Index})
-	a.player.Control(ControlMsg{Action: "insertAt", Str: string(payload)})
+	p.Control(ControlMsg{Action: "insertAt", Str: string(payload)})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -522,6 +585,10 @@ func (a *musicAPI) handleQueueRemove(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	var body struct {
 		Index int `json:"index"`
 	}
@@ -529,10 +596,11 @@ func (a *musicAPI) handleQueueRemove(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	a.player.queue.RemoveAt(body.Index)
-	a.player.saveState()
-	a.player.broadcast()
-	a.player.broadcastQueue()
+	p.queue.RemoveAt(body.Index)
+	p.Control(ControlMsg{Action: "_queueChanged"})
+	p.saveState()
+	p.broadcast()
+	p.broadcastQueue()
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -541,6 +609,10 @@ func (a *musicAPI) handleQueueMove(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	p := a.zonePlayer(w, r)
+	if p == nil {
+		return
+	}
 	var body struct {
 		From int `json:"from"`
 		To   int `json:"to"`
@@ -549,13 +621,14 @@ func (a *musicAPI) handleQueueMove(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if !a.player.queue.MoveAt(body.From, body.To) {
+	if !p.queue.MoveAt(body.From, body.To) {
 		http.Error(w, "index out of range", http.StatusBadRequest)
 		return
 	}
-	a.player.saveState()
-	a.player.broadcast()
-	a.player.broadcastQueue()
+	p.Control(ControlMsg{Action: "_queueChanged"})
+	p.saveState()
+	p.broadcast()
+	p.broadcastQueue()
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -569,7 +642,39 @@ func (a *musicAPI) handleControl(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	a.player.Control(msg)
+	if msg.Zone == "" {
+		msg.Zone = r.URL.Query().Get("zone")
+	}
+	p := a.zones.Zone(msg.Zone)
+	if p == nil {
+		http.Error(w, "unknown zone", http.StatusNotFound)
+		return
+	}
+	p.Control(msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDuck accepts {"source":"atc"|"ptt","active":bool} from the
+// intercom-stt process. Active signals are refreshed every few seconds while
+// they last; see duckLease.
+func (a *musicAPI) handleDuck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Source string `json:"source"`
+		Active bool   `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Source != DuckATC && body.Source != DuckPTT {
+		http.Error(w, "unknown source", http.StatusBadRequest)
+		return
+	}
+	a.zones.Duck(body.Source, body.Active)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -636,31 +741,9 @@ func (a *musicAPI) handleSongByIDOrAction(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// /music/songs/{id}/lyrics — return parsed LRC lines (empty array if no file)
+	// /music/songs/{id}/lyrics — GET lyrics (.lrc file, embedded or fetched), PUT to fix the offset or text
 	if len(parts) == 2 && parts[1] == "lyrics" {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		var songPath string
-		err := a.db.db.QueryRow(`SELECT path FROM song WHERE id=? AND deleted IS NULL`, id).Scan(&songPath)
-		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
-			return
-		}
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		lrcData, err := os.ReadFile(lrcPath(songPath))
-		var llines []LyricLine
-		if err == nil {
-			llines = parseLRC(lrcData)
-		}
-		if llines == nil {
-			llines = []LyricLine{}
-		}
-		jsonOK(w, map[string][]LyricLine{"lines": llines})
+		a.handleSongLyrics(w, r, id)
 		return
 	}
 
@@ -800,6 +883,12 @@ func (a *musicAPI) handlePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// GET /music/playlists/{id}/export?format=m3u8|xspf — download as a playlist file.
+	if len(parts) == 2 && parts[1] == "export" {
+		a.handlePlaylistExport(w, r, id)
+		return
+	}
+
 	// GET /music/playlists/{id}/songs — fetch full song objects in playlist order.
 	if len(parts) == 2 && parts[1] == "songs" {
 		if r.Method != http.MethodGet {
@@ -925,8 +1014,8 @@ func (a *musicAPI) handleSmartSearches(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "name and query are required", http.StatusBadRequest)
 			return
 		}
-		if strings.Contains(body.Query, ";") {
-			http.Error(w, "query may not contain semicolons", http.StatusBadRequest)
+		if _, _, err := CompileQuery(body.Query); err != nil {
+			queryError(w, err)
 			return
 		}
 		res, err := a.db.db.Exec(`INSERT INTO smartsearch (name, query) VALUES (?, ?)`, body.Name, body.Query)
@@ -943,7 +1032,7 @@ func (a *musicAPI) handleSmartSearches(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleSmartSearch handles requests to /music/smartsearches/{id}[/songs].
-// GET /music/smartsearches/{id}/songs — execute the stored WHERE clause.
+// GET /music/smartsearches/{id}/songs — run the stored smart search query.
 // DELETE /music/smartsearches/{id}   — delete the smart search.
 func (a *musicAPI) handleSmartSearch(w http.ResponseWriter, r *http.Request) {
 	rest := strings.TrimPrefix(r.URL.Path, "/music/smartsearches/")
@@ -984,15 +1073,13 @@ func (a *musicAPI) handleSmartSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sanity check: reject queries containing a semicolon.
-	if strings.Contains(query, ";") {
-		http.Error(w, "query may not contain semicolons", http.StatusBadRequest)
+	where, args, err := CompileQuery(query)
+	if err != nil {
+		queryError(w, err)
 		return
 	}
-
-	// query is a WHERE clause fragment, e.g. "length > 300"
-	fullQuery := "SELECT id,path,hash,coverId,added,updated,deleted,marked,favorite,artist,album,artistSort,albumSort,title,discNumber,trackNumber,trackTotal,genre,length,year,plays,format,bitrate FROM song WHERE deleted IS NULL AND (" + query + ")"
-	rows, err := a.db.db.Query(fullQuery)
+	fullQuery := "SELECT id,path,hash,coverId,added,updated,deleted,marked,favorite,artist,album,artistSort,albumSort,title,discNumber,trackNumber,trackTotal,genre,length,year,plays,format,bitrate FROM song WHERE deleted IS NULL AND (" + where + ")"
+	rows, err := a.db.db.Query(fullQuery, args...)
 	if err != nil {
 		http.Error(w, "query error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,234 @@
+package music
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Auto-DJ seed kinds (AutoDJSeed.Kind).
+const (
+	SeedLibrary  = "library"  // any song
+	SeedArtist   = "artist"   // Value: artist name
+	SeedGenre    = "genre"    // Value: genre
+	SeedDecade   = "decade"   // Value: first year, e.g. "1980"
+	SeedSmart    = "smart"    // Value: smartsearch ID
+	SeedPlaylist = "playlist" // Value: playlist ID
+)
+
+// Pick weights: favorites and marked songs come up more often.
+const (
+	weightFavorite = 3
+	weightMarked   = 2
+)
+
+// autoDJRetry is how long auto-DJ waits before looking again after finding
+// nothing to add, unless the queue or seed changes first. A library sync
+// can add candidates without touching either.
+const autoDJRetry = time.Minute
+
+// autoDJKey identifies what a top-up saw: the seed and the queue's length
+// and position, which between them decide the candidates.
+type autoDJKey struct {
+	seed    AutoDJSeed
+	entries int
+	index   int
+}
+
+// AutoDJSeed is what auto-DJ picks songs from.
+type AutoDJSeed struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+// autoDJCandidate is a song auto-DJ may pick.
+type autoDJCandidate struct {
+	id       int64
+	favorite bool
+	marked   bool
+	recent   bool // played within AvoidHours
+}
+
+// weight is the relative chance of c being picked.
+func (c autoDJCandidate) weight() int {
+	w := 1
+	if c.favorite {
+		w += weightFavorite
+	}
+	if c.marked {
+		w += weightMarked
+	}
+	return w
+}
+
+// pickWeighted chooses up to n distinct candidates, weighted by favorite and
+// marked. Songs played recently are only picked once the others run out.
+func pickWeighted(cands []autoDJCandidate, n int, rnd *rand.Rand) []int64 {
+	var fresh, recent []autoDJCandidate
+	for _, c := range cands {
+		if c.recent {
+			recent = append(recent, c)
+		} else {
+			fresh = append(fresh, c)
+		}
+	}
+	var out []int64
+	for _, pool := range [][]autoDJCandidate{fresh, recent} {
+		total := 0
+		for _, c := range pool {
+			total += c.weight()
+		}
+		for len(out) < n && len(pool) > 0 {
+			r := rnd.Intn(total)
+			i := 0
+			for ; r >= pool[i].weight(); i++ {
+				r -= pool[i].weight()
+			}
+			out = append(out, pool[i].id)
+			total -= pool[i].weight()
+			pool[i] = pool[len(pool)-1]
+			pool = pool[:len(pool)-1]
+		}
+	}
+	return out
+}
+
+// seedCondition returns the song condition selecting seed's songs.
+func (d *DB) seedCondition(seed AutoDJSeed) (string, []any, error) {
+	switch seed.Kind {
+	case "", SeedLibrary:
+		return "1", nil, nil
+	case SeedArtist:
+		return "artist = ? COLLATE NOCASE", []any{seed.Value}, nil
+	case SeedGenre:
+		return "EXISTS (SELECT 1 FROM json_each(song.genre) WHERE value = ? COLLATE NOCASE)", []any{seed.Value}, nil
+	case SeedDecade:
+		y, err := strconv.Atoi(seed.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("decade %q is not a year", seed.Value)
+		}
+		y -= y % 10
+		return "year >= ? AND year < ?", []any{y, y + 10}, nil
+	case SeedSmart:
+		var q string
+		if err := d.db.QueryRow(`SELECT query FROM smartsearch WHERE id=?`, seed.Value).Scan(&q); err != nil {
+			return "", nil, fmt.Errorf("smart search %s: %w", seed.Value, err)
+		}
+		return CompileQuery(q)
+	case SeedPlaylist:
+		return "id IN (SELECT value FROM playlist, json_each(playlist.items) WHERE playlist.id = ?)", []any{seed.Value}, nil
+	}
+	return "", nil, fmt.Errorf("unknown auto-DJ seed %q", seed.Kind)
+}
+
+// autoDJCandidates returns the seed's songs that aren't in exclude, marking
+// those played since avoidSince.
+func (d *DB) autoDJCandidates(seed AutoDJSeed, exclude map[int64]bool, avoidSince time.Time) ([]autoDJCandidate, error) {
+	cond, args, err := d.seedCondition(seed)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(
+		`SELECT id, favorite, marked,
+		        EXISTS (SELECT 1 FROM play_event WHERE play_event.songId = song.id AND play_event.started >= ?)
+		   FROM song WHERE deleted IS NULL AND (`+cond+`)`,
+		append([]any{avoidSince.UTC().Format(sqlTime)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []autoDJCandidate
+	for rows.Next() {
+		var c autoDJCandidate
+		if err := rows.Scan(&c.id, &c.favorite, &c.marked, &c.recent); err != nil {
+			return nil, err
+		}
+		if !exclude[c.id] {
+			out = append(out, c)
+		}
+	}
+	return out, rows.Err()
+}
+
+// setAutoDJ turns auto-DJ on or off. Run goroutine only.
+func (p *Player) setAutoDJ(on bool) {
+	p.mu.Lock()
+	p.autoDJ = on
+	p.mu.Unlock()
+	p.db.SetState(p.stateKey("autoDJ"), on)
+	if p.fillAutoDJ() {
+		p.preloadNext()
+	}
+	p.broadcast()
+}
+
+// setAutoDJSeed changes what auto-DJ picks from. Run goroutine only.
+func (p *Player) setAutoDJSeed(raw string) {
+	var seed AutoDJSeed
+	if err := json.Unmarshal([]byte(raw), &seed); err != nil {
+		log.Println("music: auto-DJ seed:", err)
+		return
+	}
+	if _, _, err := p.db.seedCondition(seed); err != nil {
+		log.Println("music: auto-DJ seed:", err)
+		return
+	}
+	p.mu.Lock()
+	p.autoDJSeed = seed
+	p.mu.Unlock()
+	p.db.SetState(p.stateKey("autoDJSeed"), seed)
+	p.broadcast()
+}
+
+// fillAutoDJ appends songs when auto-DJ is on and fewer than MinQueue are
+// left after the current one, reporting whether it did. Repeat modes never
+// run out, so they're left alone. After a scan finds nothing it backs off
+// (see autoDJRetry), since Run calls it every second. It doesn't preload:
+// callers that aren't about to advance the queue follow up with preloadNext.
+// Run goroutine only.
+func (p *Player) fillAutoDJ() bool {
+	p.mu.Lock()
+	on, seed := p.autoDJ, p.autoDJSeed
+	p.mu.Unlock()
+	cfg := p.cfg.AutoDJ
+	if !on || p.queue.Repeat() != RepeatOff {
+		return false
+	}
+	left := p.queue.Remaining()
+	if left >= max(cfg.MinQueue, 1) {
+		return false
+	}
+
+	qs := p.queue.State()
+	key := autoDJKey{seed: seed, entries: len(qs.Entries), index: qs.CurrentIndex}
+	if !p.djMissAt.IsZero() && key == p.djMiss && time.Since(p.djMissAt) < autoDJRetry {
+		return false // nothing has changed since the last empty scan
+	}
+	exclude := make(map[int64]bool, len(qs.Entries))
+	for _, e := range qs.Entries[min(max(qs.CurrentIndex, 0), len(qs.Entries)):] {
+		exclude[e.SongID] = true
+	}
+	avoid := time.Now().Add(-time.Duration(cfg.AvoidHours * float64(time.Hour)))
+	cands, err := p.db.autoDJCandidates(seed, exclude, avoid)
+	if err != nil {
+		log.Println("music: auto-DJ:", err)
+		return false
+	}
+	if p.rnd == nil {
+		p.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	ids := pickWeighted(cands, max(cfg.Batch, cfg.MinQueue-left, 1), p.rnd)
+	if len(ids) == 0 {
+		p.djMiss, p.djMissAt = key, time.Now()
+		return false
+	}
+	p.djMissAt = time.Time{}
+	p.queue.Append(ids)
+	p.saveState()
+	p.broadcast()
+	p.broadcastQueue()
+	return true
+}
@@ -0,0 +1,90 @@
+package music
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestPickWeighted(t *testing.T) {
+	cands := []autoDJCandidate{
+		{id: 1},
+		{id: 2, favorite: true},
+		{id: 3, marked: true},
+		{id: 4, recent: true},
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	// Recently played songs only come after everything else.
+	got := pickWeighted(cands, 4, rnd)
+	if len(got) != 4 || got[3] != 4 {
+		t.Fatalf("pickWeighted = %v, want 4 distinct with 4 last", got)
+	}
+	sorted := slices.Clone(got)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, []int64{1, 2, 3, 4}) {
+		t.Fatalf("pickWeighted = %v, want each song once", got)
+	}
+	if got := pickWeighted(cands, 10, rnd); len(got) != 4 {
+		t.Errorf("asking for more than available: got %d songs, want 4", len(got))
+	}
+	if got := pickWeighted(nil, 3, rnd); len(got) != 0 {
+		t.Errorf("no candidates: got %v", got)
+	}
+
+	// Favorites are picked first more often than plain songs.
+	first := map[int64]int{}
+	for range 2000 {
+		first[pickWeighted(cands[:2], 1, rnd)[0]]++
+	}
+	if first[2] < 3*first[1] {
+		t.Errorf("favorite picked first %d times vs %d for plain, want ~4x", first[2], first[1])
+	}
+}
+
+func TestFillAutoDJBacksOff(t *testing.T) {
+	d := openMemDB(t)
+	addSong := func(id int64) {
+		t.Helper()
+		_, err := d.db.Exec(`INSERT INTO song(id, path, hash, artist, title) VALUES(?, ?, ?, 'A', 'T')`,
+			id, fmt.Sprint(id, ".mp3"), fmt.Sprint(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	addSong(1)
+	p := &Player{db: d, queue: NewQueue(), autoDJ: true, autoDJSeed: AutoDJSeed{Kind: SeedArtist, Value: "A"}}
+	p.cfg.AutoDJ.MinQueue, p.cfg.AutoDJ.Batch = 2, 1
+	p.queue.Replace([]int64{1})
+
+	// The seed's only song is already queued.
+	if p.fillAutoDJ() {
+		t.Fatal("topped up with nothing to add")
+	}
+	// A new song doesn't come up until the retry interval passes...
+	addSong(2)
+	if p.fillAutoDJ() {
+		t.Error("rescanned with nothing changed")
+	}
+	p.djMissAt = p.djMissAt.Add(-autoDJRetry)
+	if !p.fillAutoDJ() {
+		t.Fatal("no top-up after the retry interval")
+	}
+	if qs := p.queue.State(); len(qs.Entries) != 2 || qs.Entries[1].SongID != 2 {
+		t.Fatalf("queue %+v", qs.Entries)
+	}
+
+	// ...or the queue changes.
+	if p.fillAutoDJ() {
+		t.Fatal("topped up with nothing to add")
+	}
+	addSong(3)
+	if p.fillAutoDJ() {
+		t.Error("rescanned with nothing changed")
+	}
+	p.queue.RemoveAt(1)
+	if !p.fillAutoDJ() {
+		t.Error("no top-up after the queue changed")
+	}
+}
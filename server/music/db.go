@@ -204,9 +204,57 @@ func OpenAndMigrate(schemasDir, backupDir string) (*DB, error) {
 		d.Close()
 		return nil, fmt.Errorf("migration error: %w", err)
 	}
+	if err := d.migrateSmartSearches(); err != nil {
+		log.Println("music:", err)
+	}
 	return d, nil
 }
 
+// migrateSmartSearches converts smart searches saved as raw SQL WHERE
+// fragments to the query language. It runs once (tracked by the
+// smartSearchSyntax state key); rows that can't be converted are logged and
+// left as-is, so they fail to compile instead of running as SQL.
+func (d *DB) migrateSmartSearches() error {
+	var version int
+	if err := d.GetState("smartSearchSyntax", &version); err != nil || version >= 1 {
+		return err
+	}
+	rows, err := d.db.Query(`SELECT id, name, query FROM smartsearch`)
+	if err != nil {
+		return fmt.Errorf("smart search migration: %w", err)
+	}
+	type row struct {
+		id          int64
+		name, query string
+	}
+	var all []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.name, &r.query); err != nil {
+			rows.Close()
+			return fmt.Errorf("smart search migration: %w", err)
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+
+	for _, r := range all {
+		q, ok := legacyToQuery(r.query)
+		if ok && q == r.query {
+			continue
+		}
+		if !ok {
+			log.Printf("music: smart search %q (%s) could not be converted; edit or delete it", r.name, r.query)
+			continue
+		}
+		if _, err := d.db.Exec(`UPDATE smartsearch SET query=? WHERE id=?`, q, r.id); err != nil {
+			return fmt.Errorf("smart search migration: %w", err)
+		}
+		log.Printf("music: smart search %q: %s → %s", r.name, r.query, q)
+	}
+	return d.SetState("smartSearchSyntax", 1)
+}
+
 // InitDB opens the music.sqlite database, runs migrations, and checks the
 // minimum required version. Returns (nil, false) if the music subsystem should
 // be disabled due to a migration error or version mismatch.
@@ -0,0 +1,108 @@
+package music
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Duck sources reported by the intercom-stt process.
+const (
+	DuckATC = "atc" // radio/intercom speech in progress
+	DuckPTT = "ptt" // our own push-to-talk keyed
+)
+
+// duckLease bounds how long an "active" signal holds without a refresh
+// (intercom-stt re-sends every few seconds), so a crashed sender can't leave
+// the music ducked forever.
+const duckLease = 15 * time.Second
+
+// Duck reports that a source started (active) or stopped making noise the
+// pilot needs to hear. Safe to call from any goroutine.
+func (p *Player) Duck(source string, active bool) {
+	v := 0.0
+	if active {
+		v = 1
+	}
+	p.Control(ControlMsg{Action: "_duck", Str: source, Value: v})
+}
+
+// setDuck records a duck request. An active source holds for duckLease; a
+// released one for the configured hangover. Run goroutine only.
+func (p *Player) setDuck(source string, active bool, now time.Time) {
+	if p.duckUntil == nil {
+		p.duckUntil = make(map[string]time.Time)
+	}
+	if active {
+		p.duckUntil[source] = now.Add(duckLease)
+	} else {
+		p.duckUntil[source] = now.Add(time.Duration(p.cfg.Duck.HangoverSec * float64(time.Second)))
+	}
+	p.applyDuck(now)
+}
+
+// applyDuck moves the volume one ramp step toward the current duck target
+// and pauses or resumes mpv as needed. Called on every poll tick. Run
+// goroutine only.
+func (p *Player) applyDuck(now time.Time) {
+	ducking := func(source string) bool { return now.Before(p.duckUntil[source]) }
+	atc := ducking(DuckATC) && p.cfg.Duck.Mode != "off"
+	wantPause := ducking(DuckPTT) || (atc && p.cfg.Duck.Mode == "pause")
+	target := 1.0
+	if atc && p.cfg.Duck.Mode == "volume" {
+		target = float64(p.cfg.Duck.Level) / 100
+	}
+
+	if p.duckGain != target {
+		step := 1.0
+		if p.cfg.Duck.RampSec > 0 {
+			step = mpvPollInterval.Seconds() / p.cfg.Duck.RampSec
+		}
+		if math.Abs(target-p.duckGain) <= step {
+			p.duckGain = target
+		} else if target < p.duckGain {
+			p.duckGain -= step
+		} else {
+			p.duckGain += step
+		}
+		if p.fade == nil && p.loaded {
+			p.deck().command("set_property", "volume", p.volume())
+		}
+	}
+
+	if wantPause != p.duckPaused {
+		p.duckPaused = wantPause
+		p.mu.Lock()
+		playing := p.status == StatusPlaying
+		p.mu.Unlock()
+		if playing && p.loaded {
+			if wantPause {
+				p.finishFade()
+			}
+			if err := p.deck().command("set_property", "pause", wantPause); err != nil {
+				log.Println("music: mpv duck pause:", err)
+			}
+		}
+	}
+
+	state := ""
+	switch {
+	case wantPause:
+		state = "paused"
+	case target < 1:
+		state = "volume"
+	}
+	p.mu.Lock()
+	changed := p.ducked != state
+	p.ducked = state
+	p.mu.Unlock()
+	if changed {
+		p.broadcast()
+	}
+}
+
+// volume is the mpv volume for the active deck: the configured volume
+// scaled by the current duck gain.
+func (p *Player) volume() float64 {
+	return float64(p.cfg.Volume) * p.duckGain
+}
@@ -0,0 +1,528 @@
+package music
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Duplicate detection thresholds.
+const (
+	// dupTagLengthSec is how far apart two songs with the same normalized
+	// artist and title may be in length and still be the same recording.
+	dupTagLengthSec = 3
+	// dupFingerprintLengthSec bounds the length difference of songs whose
+	// fingerprints are compared at all.
+	dupFingerprintLengthSec = 5
+	// dupFingerprintSimilarity is the fraction of matching fingerprint bits
+	// above which two songs are the same recording. Unrelated audio scores
+	// around 0.5.
+	dupFingerprintSimilarity = 0.75
+	// fingerprintMaxOffset is how many fingerprint frames (~0.124s each) one
+	// fingerprint may be shifted against the other, for leading silence.
+	fingerprintMaxOffset = 24
+	// fingerprintMinOverlap is the fewest overlapping frames a comparison
+	// needs to count.
+	fingerprintMinOverlap = 64
+	// fingerprintCompareFrames caps the frames compared per offset.
+	fingerprintCompareFrames = 480
+)
+
+// Reasons two songs were grouped (DuplicateGroup.Reasons).
+const (
+	DupReasonMBID        = "mbid"
+	DupReasonTags        = "tags"
+	DupReasonFingerprint = "fingerprint"
+)
+
+// DuplicateGroup is a set of songs that appear to be the same recording.
+type DuplicateGroup struct {
+	KeeperID int64    `json:"keeperId"` // recommended song to keep
+	Reasons  []string `json:"reasons"`
+	Songs    []Song   `json:"songs"`
+}
+
+// runFpcalcRaw invokes fpcalc -raw and returns the uncompressed fingerprint.
+func runFpcalcRaw(ctx context.Context, path string) ([]uint32, error) {
+	out, err := exec.CommandContext(ctx, "fpcalc", "-raw", "-json", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc exec: %w", err)
+	}
+	var result struct {
+		Fingerprint []uint32 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("fpcalc parse: %w", err)
+	}
+	return result.Fingerprint, nil
+}
+
+// encodeFingerprint packs a raw fingerprint for the song.fingerprint column.
+func encodeFingerprint(fp []uint32) []byte {
+	b := make([]byte, 4*len(fp))
+	for i, v := range fp {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// decodeFingerprint unpacks a song.fingerprint column.
+func decodeFingerprint(b []byte) []uint32 {
+	fp := make([]uint32, len(b)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return fp
+}
+
+// fingerprintSimilarity compares two raw Chromaprint fingerprints, returning
+// the fraction of matching bits at the best alignment (0.5 is unrelated, 1
+// is identical), or 0 if they don't overlap enough to tell.
+func fingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for off := -fingerprintMaxOffset; off <= fingerprintMaxOffset; off++ {
+		ai, bi := max(off, 0), max(-off, 0)
+		n := min(len(a)-ai, len(b)-bi, fingerprintCompareFrames)
+		if n < fingerprintMinOverlap {
+			continue
+		}
+		diff := 0
+		for i := 0; i < n; i++ {
+			diff += bits.OnesCount32(a[ai+i] ^ b[bi+i])
+		}
+		best = max(best, 1-float64(diff)/float64(32*n))
+	}
+	return best
+}
+
+// FingerprintSongs computes raw fingerprints for songs that don't have one
+// yet (all songs with force). Used by musicsync --fingerprint to backfill
+// existing libraries; sync fingerprints new and changed files itself.
+func (s *Syncer) FingerprintSongs(ctx context.Context, force bool) error {
+	query := `SELECT id FROM song WHERE deleted IS NULL`
+	if !force {
+		query += ` AND fingerprint IS NULL`
+	}
+	rows, err := s.db.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("fingerprint: query songs: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("fingerprint: scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	return s.fingerprintSongs(ctx, ids)
+}
+
+// fingerprintSongs runs fpcalc over the given songs and stores the results.
+func (s *Syncer) fingerprintSongs(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("fpcalc"); err != nil {
+		log.Println("music sync: fpcalc not found — skipping fingerprinting")
+		return nil
+	}
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var path string
+		if err := s.db.db.QueryRow(`SELECT path FROM song WHERE id=?`, id).Scan(&path); err != nil {
+			continue
+		}
+		fp, err := runFpcalcRaw(ctx, path)
+		if err != nil {
+			log.Printf("music sync: [%d/%d] fingerprint %s: %v", i+1, len(ids), s.relPath(path), err)
+			continue
+		}
+		if _, err := s.db.db.Exec(`UPDATE song SET fingerprint=? WHERE id=?`, encodeFingerprint(fp), id); err != nil {
+			return fmt.Errorf("fingerprint: update song %d: %w", id, err)
+		}
+	}
+	log.Printf("music sync: fingerprinted %d songs", len(ids))
+	return nil
+}
+
+// dupCandidate is the per-song data duplicate detection works from.
+type dupCandidate struct {
+	song        *Song
+	mbid        string
+	fingerprint []uint32
+	hasCover    bool
+}
+
+// metadataScore counts the descriptive fields a song has filled in.
+func (c *dupCandidate) metadataScore() int {
+	n := 0
+	for _, ok := range []bool{
+		c.song.Artist != "", c.song.Album != "", c.song.Title != "",
+		c.song.Year != 0, c.song.TrackNumber != 0, len(c.song.Genre) > 0,
+		c.mbid != "", c.hasCover,
+	} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// betterKeeper reports whether a should be kept over b: highest bitrate,
+// then most metadata, then most played, then oldest.
+func betterKeeper(a, b *dupCandidate) bool {
+	if a.song.Bitrate != b.song.Bitrate {
+		return a.song.Bitrate > b.song.Bitrate
+	}
+	if sa, sb := a.metadataScore(), b.metadataScore(); sa != sb {
+		return sa > sb
+	}
+	if a.song.Plays != b.song.Plays {
+		return a.song.Plays > b.song.Plays
+	}
+	return a.song.ID < b.song.ID
+}
+
+// unionFind groups candidate indexes, remembering why each group formed.
+type unionFind struct {
+	parent  []int
+	reasons map[int]map[string]bool // root → reasons
+}
+
+func newUnionFind(n int) *unionFind {
+	u := &unionFind{parent: make([]int, n), reasons: map[int]map[string]bool{}}
+	for i := range u.parent {
+		u.parent[i] = i
+	}
+	return u
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+func (u *unionFind) union(a, b int, reason string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u.parent[rb] = ra
+		if u.reasons[ra] == nil {
+			u.reasons[ra] = map[string]bool{}
+		}
+		for r := range u.reasons[rb] {
+			u.reasons[ra][r] = true
+		}
+		delete(u.reasons, rb)
+	}
+	if u.reasons[ra] == nil {
+		u.reasons[ra] = map[string]bool{}
+	}
+	u.reasons[ra][reason] = true
+}
+
+// findDuplicates groups candidates that share an MBID, have the same
+// normalized artist and title at nearly the same length, or have matching
+// fingerprints. Groups come back largest first, each with its recommended
+// keeper first.
+func findDuplicates(cands []*dupCandidate) []DuplicateGroup {
+	u := newUnionFind(len(cands))
+
+	byMBID := map[string]int{}
+	byTags := map[string][]int{}
+	for i, c := range cands {
+		if c.mbid != "" {
+			if j, ok := byMBID[c.mbid]; ok {
+				u.union(j, i, DupReasonMBID)
+			} else {
+				byMBID[c.mbid] = i
+			}
+		}
+		artist, title := normalizeMatch(c.song.Artist), normalizeMatch(c.song.Title)
+		if artist != "" && title != "" {
+			key := artist + "\x00" + title
+			for _, j := range byTags[key] {
+				if math.Abs(cands[j].song.Length-c.song.Length) <= dupTagLengthSec {
+					u.union(j, i, DupReasonTags)
+				}
+			}
+			byTags[key] = append(byTags[key], i)
+		}
+	}
+
+	// Fingerprints: only compare songs of similar length, walking a window
+	// over the songs sorted by length.
+	var fps []int
+	for i, c := range cands {
+		if len(c.fingerprint) > 0 {
+			fps = append(fps, i)
+		}
+	}
+	sort.Slice(fps, func(a, b int) bool { return cands[fps[a]].song.Length < cands[fps[b]].song.Length })
+	for x, i := range fps {
+		for _, j := range fps[x+1:] {
+			if cands[j].song.Length-cands[i].song.Length > dupFingerprintLengthSec {
+				break
+			}
+			if u.find(i) == u.find(j) {
+				continue
+			}
+			if fingerprintSimilarity(cands[i].fingerprint, cands[j].fingerprint) >= dupFingerprintSimilarity {
+				u.union(i, j, DupReasonFingerprint)
+			}
+		}
+	}
+
+	members := map[int][]*dupCandidate{}
+	for i, c := range cands {
+		root := u.find(i)
+		members[root] = append(members[root], c)
+	}
+	groups := []DuplicateGroup{}
+	for root, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(a, b int) bool { return betterKeeper(group[a], group[b]) })
+		g := DuplicateGroup{KeeperID: group[0].song.ID, Reasons: []string{}}
+		for _, r := range []string{DupReasonMBID, DupReasonTags, DupReasonFingerprint} {
+			if u.reasons[root][r] {
+				g.Reasons = append(g.Reasons, r)
+			}
+		}
+		for _, c := range group {
+			g.Songs = append(g.Songs, *c.song)
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(a, b int) bool {
+		if len(groups[a].Songs) != len(groups[b].Songs) {
+			return len(groups[a].Songs) > len(groups[b].Songs)
+		}
+		sa, sb := groups[a].Songs[0], groups[b].Songs[0]
+		if sa.ArtistSort != sb.ArtistSort {
+			return sa.ArtistSort < sb.ArtistSort
+		}
+		return sa.Title < sb.Title
+	})
+	return groups
+}
+
+// duplicateCandidates loads every non-deleted song for duplicate detection.
+func (d *DB) duplicateCandidates() ([]*dupCandidate, error) {
+	rows, err := d.db.Query(`SELECT song.mbid, song.fingerprint, ` + songColumns + ` FROM song WHERE song.deleted IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*dupCandidate
+	for rows.Next() {
+		c := &dupCandidate{}
+		var fp []byte
+		s, err := scanSong(rows, &c.mbid, &fp)
+		if err != nil {
+			return nil, err
+		}
+		c.song = s
+		c.hasCover = s.CoverID != nil
+		if len(fp) > 0 {
+			c.fingerprint = decodeFingerprint(fp)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// MergeDuplicates folds songs into keeper: their plays and play history,
+// favorite and marked flags, and playlist entries move to keeper, then they
+// are soft-deleted. Their files are moved under dupDir (keeping the path
+// relative to musicDir) so the next sync doesn't add them back.
+func (d *DB) MergeDuplicates(keeper int64, songs []int64, musicDir, dupDir string) error {
+	var others []int64
+	for _, id := range songs {
+		if id != keeper {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	othersJSON, _ := json.Marshal(others)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM song WHERE deleted IS NULL AND (id=? OR id IN (SELECT value FROM json_each(?)))`,
+		keeper, string(othersJSON)).Scan(&n); err != nil {
+		return err
+	}
+	if n != len(others)+1 {
+		return fmt.Errorf("merge: unknown or deleted song in %d, %v", keeper, others)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE song SET
+			plays = plays + (SELECT COALESCE(SUM(plays), 0) FROM song WHERE id IN (SELECT value FROM json_each(?1))),
+			favorite = MAX(favorite, (SELECT COALESCE(MAX(favorite), 0) FROM song WHERE id IN (SELECT value FROM json_each(?1)))),
+			marked = MAX(marked, (SELECT COALESCE(MAX(marked), 0) FROM song WHERE id IN (SELECT value FROM json_each(?1))))
+		WHERE id = ?2`, string(othersJSON), keeper); err != nil {
+		return fmt.Errorf("merge: keeper: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE play_event SET songId=? WHERE songId IN (SELECT value FROM json_each(?))`, keeper, string(othersJSON)); err != nil {
+		return fmt.Errorf("merge: history: %w", err)
+	}
+
+	// Playlists: point entries at the keeper, dropping them where the
+	// keeper is already in the playlist.
+	replace := map[int64]bool{}
+	for _, id := range others {
+		replace[id] = true
+	}
+	type playlistItems struct {
+		id    int64
+		items []int64
+	}
+	var changed []playlistItems
+	rows, err := tx.Query(`SELECT id, items FROM playlist`)
+	if err != nil {
+		return fmt.Errorf("merge: playlists: %w", err)
+	}
+	for rows.Next() {
+		var pl playlistItems
+		var itemsJSON string
+		if err := rows.Scan(&pl.id, &itemsJSON); err != nil {
+			rows.Close()
+			return fmt.Errorf("merge: playlists: %w", err)
+		}
+		var items []int64
+		json.Unmarshal([]byte(itemsJSON), &items)
+		hasKeeper, hit := false, false
+		for _, id := range items {
+			hasKeeper = hasKeeper || id == keeper
+			hit = hit || replace[id]
+		}
+		if !hit {
+			continue
+		}
+		pl.items = []int64{}
+		for _, id := range items {
+			if replace[id] {
+				if hasKeeper {
+					continue
+				}
+				id, hasKeeper = keeper, true
+			}
+			pl.items = append(pl.items, id)
+		}
+		changed = append(changed, pl)
+	}
+	rows.Close()
+	for _, pl := range changed {
+		itemsJSON, _ := json.Marshal(pl.items)
+		if _, err := tx.Exec(`UPDATE playlist SET items=? WHERE id=?`, string(itemsJSON), pl.id); err != nil {
+			return fmt.Errorf("merge: playlist %d: %w", pl.id, err)
+		}
+	}
+
+	// Soft-delete and move the files aside.
+	var paths []string
+	rows, err = tx.Query(`SELECT path FROM song WHERE id IN (SELECT value FROM json_each(?))`, string(othersJSON))
+	if err != nil {
+		return fmt.Errorf("merge: %w", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	rows.Close()
+	if _, err := tx.Exec(`UPDATE song SET deleted=CURRENT_TIMESTAMP WHERE id IN (SELECT value FROM json_each(?))`, string(othersJSON)); err != nil {
+		return fmt.Errorf("merge: delete: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		rel, err := filepath.Rel(musicDir, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(p)
+		}
+		dst := filepath.Join(dupDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+			err = os.Rename(p, dst)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("music: merge: move %s aside: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// handleDuplicates handles GET /music/duplicates — groups of songs that look
+// like the same recording, each with a recommended keeper.
+func (a *musicAPI) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cands, err := a.db.duplicateCandidates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, findDuplicates(cands))
+}
+
+// handleDuplicatesMerge handles POST /music/duplicates/merge
+// {"keeperId": 1, "songIds": [1, 2, 3]} (admin).
+func (a *musicAPI) handleDuplicatesMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.isAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var body struct {
+		KeeperID int64   `json:"keeperId"`
+		SongIDs  []int64 `json:"songIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.KeeperID == 0 {
+		http.Error(w, "keeperId is required", http.StatusBadRequest)
+		return
+	}
+	dupDir := filepath.Join(a.backupDir, "duplicates")
+	if err := a.db.MergeDuplicates(body.KeeperID, body.SongIDs, a.musicDir, dupDir); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
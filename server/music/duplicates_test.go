@@ -0,0 +1,91 @@
+package music
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFingerprintSimilarity(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a := make([]uint32, 600)
+	for i := range a {
+		a[i] = rnd.Uint32()
+	}
+	// b is a with 10 frames of leading silence and a few flipped bits, as a
+	// different rip of the same recording would be.
+	b := append(make([]uint32, 10), a...)
+	for i := 10; i < len(b); i += 3 {
+		b[i] ^= 1 << (i % 32)
+	}
+	unrelated := make([]uint32, 600)
+	for i := range unrelated {
+		unrelated[i] = rnd.Uint32()
+	}
+
+	if s := fingerprintSimilarity(a, a); s != 1 {
+		t.Errorf("identical: %v, want 1", s)
+	}
+	if s := fingerprintSimilarity(a, b); s < dupFingerprintSimilarity {
+		t.Errorf("shifted copy: %v, want >= %v", s, dupFingerprintSimilarity)
+	}
+	if s := fingerprintSimilarity(b, a); s < dupFingerprintSimilarity {
+		t.Errorf("shifted copy (reversed): %v, want >= %v", s, dupFingerprintSimilarity)
+	}
+	if s := fingerprintSimilarity(a, unrelated); s >= dupFingerprintSimilarity {
+		t.Errorf("unrelated: %v, want < %v", s, dupFingerprintSimilarity)
+	}
+	if s := fingerprintSimilarity(a, a[:10]); s != 0 {
+		t.Errorf("too short: %v, want 0", s)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	fp := make([]uint32, 300)
+	for i := range fp {
+		fp[i] = uint32(i) * 2654435761
+	}
+	song := func(id int64, artist, title string, length float64, bitrate int) *Song {
+		return &Song{ID: id, Artist: artist, Title: title, Length: length, Bitrate: bitrate}
+	}
+	cands := []*dupCandidate{
+		{song: song(1, "The Beatles", "Let It Be", 243, 192)},
+		{song: song(2, "Beatles", "Let It Be (Remastered)", 244, 320)},
+		{song: song(3, "Aretha Franklin", "Let It Be", 211, 320)},
+		{song: song(4, "Queen", "Track 1", 354, 128), fingerprint: fp},
+		{song: song(5, "", "bohemian", 356, 1000), fingerprint: fp},
+		{song: song(6, "X", "One", 100, 128), mbid: "abc"},
+		{song: song(7, "Y", "Two", 300, 128), mbid: "abc"},
+		{song: song(8, "The Beatles", "Let It Be", 400, 128)}, // live version
+	}
+	groups := findDuplicates(cands)
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups, want 3: %+v", len(groups), groups)
+	}
+	want := map[int64]struct {
+		ids    []int64
+		reason string
+	}{
+		2: {[]int64{2, 1}, DupReasonTags},
+		5: {[]int64{5, 4}, DupReasonFingerprint},
+		6: {[]int64{6, 7}, DupReasonMBID},
+	}
+	for _, g := range groups {
+		w, ok := want[g.KeeperID]
+		if !ok {
+			t.Errorf("unexpected group with keeper %d: %+v", g.KeeperID, g)
+			continue
+		}
+		if len(g.Songs) != len(w.ids) {
+			t.Errorf("keeper %d: %d songs, want %d", g.KeeperID, len(g.Songs), len(w.ids))
+			continue
+		}
+		for i, s := range g.Songs {
+			if s.ID != w.ids[i] {
+				t.Errorf("keeper %d: song %d = %d, want %d", g.KeeperID, i, s.ID, w.ids[i])
+			}
+		}
+		if len(g.Reasons) != 1 || g.Reasons[0] != w.reason {
+			t.Errorf("keeper %d: reasons %v, want [%s]", g.KeeperID, g.Reasons, w.reason)
+		}
+	}
+}
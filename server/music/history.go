@@ -0,0 +1,476 @@
+package music
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlTime is the layout of DATETIME columns (UTC, as CURRENT_TIMESTAMP).
+const sqlTime = "2006-01-02 15:04:05"
+
+// Scrobble rules (the same for Last.fm and ListenBrainz): the track must be
+// longer than 30s and played for half its length or 4 minutes.
+const (
+	scrobbleMinLength = 30
+	scrobbleMinPlayed = 4 * 60
+)
+
+// playEvent is the play in progress on the active deck. Run goroutine only.
+type playEvent struct {
+	songID  int64
+	started time.Time
+	flight  string
+}
+
+// SetFlight sets the function that reports the current flight ID ("" on the
+// ground), recorded with each play. Call before Run.
+func (p *Player) SetFlight(fn func() string) {
+	p.flight = fn
+}
+
+// beginPlay starts recording a play of songID, ending any previous one as
+// interrupted.
+func (p *Player) beginPlay(songID int64) {
+	p.endPlay(false)
+	ev := &playEvent{songID: songID, started: time.Now()}
+	if p.flight != nil {
+		ev.flight = p.flight()
+	}
+	p.play = ev
+}
+
+// endPlay writes the play in progress to play_event. completed is true when
+// the song reached its end (or crossfaded out); otherwise the play counts as
+// skipped if it stopped before PlayedRequiredPercent.
+func (p *Player) endPlay(completed bool) {
+	ev := p.play
+	p.play = nil
+	if ev == nil {
+		return
+	}
+	p.mu.Lock()
+	elapsed := p.elapsedSec
+	length := p.currentLength
+	p.mu.Unlock()
+	if completed && length > 0 {
+		elapsed = length
+	}
+	percent := 0.0
+	if length > 0 {
+		percent = min(100, elapsed/length*100)
+	}
+	skipped := !completed && percent < float64(p.playedRequiredPercent())
+	scrobble := p.cfg.Scrobble && length > scrobbleMinLength &&
+		(percent >= 50 || elapsed >= scrobbleMinPlayed)
+
+	_, err := p.db.db.Exec(
+		`INSERT INTO play_event (songId, started, ended, playedSec, percent, skipped, flight, session, scrobble) VALUES (?,?,?,?,?,?,?,?,?)`,
+		ev.songID, ev.started.UTC().Format(sqlTime), time.Now().UTC().Format(sqlTime),
+		elapsed, percent, skipped, ev.flight, p.session, scrobble,
+	)
+	if err != nil {
+		log.Printf("music: record play of song %d: %v", ev.songID, err)
+	}
+}
+
+// playedRequiredPercent is the share of a song that must play for it to
+// count as played rather than skipped.
+func (p *Player) playedRequiredPercent() int {
+	if p.cfg.PlayedRequiredPercent <= 0 {
+		return 50
+	}
+	return p.cfg.PlayedRequiredPercent
+}
+
+// parseSQLTime parses a DATETIME column scanned into a string. The driver
+// returns DATETIME columns as time.Time, which database/sql formats as
+// RFC 3339 when scanning into a string.
+func parseSQLTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse(sqlTime, s)
+	return t
+}
+
+// PlayEvent is the API representation of a play_event row.
+type PlayEvent struct {
+	ID        int64   `json:"id"`
+	SongID    int64   `json:"songId"`
+	Started   string  `json:"started"`
+	Ended     string  `json:"ended"`
+	PlayedSec float64 `json:"playedSec"`
+	Percent   float64 `json:"percent"`
+	Skipped   bool    `json:"skipped"`
+	Flight    string  `json:"flight"`
+	Session   string  `json:"session"`
+	Song      *Song   `json:"song,omitempty"`
+}
+
+// TopEntry is one row of a top artists/albums report.
+type TopEntry struct {
+	Artist    string  `json:"artist"`
+	Album     string  `json:"album,omitempty"`
+	Plays     int     `json:"plays"`
+	Skips     int     `json:"skips"`
+	PlayedSec float64 `json:"playedSec"`
+}
+
+// SkippedSong is a song with its recent skip count.
+type SkippedSong struct {
+	Song        Song   `json:"song"`
+	Skips       int    `json:"skips"`
+	LastSkipped string `json:"lastSkipped"`
+}
+
+// songColumns is the column list scanSong expects, qualified for joins.
+const songColumns = `song.id,song.path,song.hash,song.coverId,song.added,song.updated,song.deleted,song.marked,song.favorite,song.artist,song.album,song.artistSort,song.albumSort,song.title,song.discNumber,song.trackNumber,song.trackTotal,song.genre,song.length,song.year,song.plays,song.format,song.bitrate`
+
+// timeRange parses ?from= and ?to= (dates or RFC 3339 times) into play_event
+// conditions.
+func timeRange(r *http.Request) (conds []string, args []any, err error) {
+	for _, p := range []struct{ key, cond string }{
+		{"from", "play_event.started >= ?"},
+		{"to", "play_event.started < ?"},
+	} {
+		v := r.URL.Query().Get(p.key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+				return nil, nil, fmt.Errorf("%s: want a date (2006-01-02) or RFC 3339 time", p.key)
+			}
+			if p.key == "to" {
+				t = t.AddDate(0, 0, 1) // inclusive end date
+			}
+		}
+		conds = append(conds, p.cond)
+		args = append(args, t.UTC().Format(sqlTime))
+	}
+	return conds, args, nil
+}
+
+// queryLimit parses ?limit=, defaulting to def and capped at 1000.
+func queryLimit(r *http.Request, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return min(n, 1000)
+}
+
+// handleHistory handles GET /music/history?from=&to=&flight=&limit= — recent
+// plays, newest first.
+func (a *musicAPI) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	conds, args, err := timeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f := r.URL.Query().Get("flight"); f != "" {
+		conds = append(conds, "play_event.flight = ?")
+		args = append(args, f)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, queryLimit(r, 100))
+
+	rows, err := a.db.db.Query(
+		`SELECT play_event.id, play_event.songId, play_event.started, play_event.ended, play_event.playedSec,
+		        play_event.percent, play_event.skipped, play_event.flight, play_event.session, `+songColumns+`
+		   FROM play_event JOIN song ON song.id = play_event.songId `+where+`
+		  ORDER BY play_event.started DESC LIMIT ?`, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	result := []PlayEvent{}
+	for rows.Next() {
+		var ev PlayEvent
+		s, err := scanSong(rows, &ev.ID, &ev.SongID, &ev.Started, &ev.Ended, &ev.PlayedSec,
+			&ev.Percent, &ev.Skipped, &ev.Flight, &ev.Session)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ev.Song = s
+		result = append(result, ev)
+	}
+	jsonOK(w, result)
+}
+
+// handleStatsTop handles GET /music/stats/top?by=artist|album&from=&to=&limit=.
+// Skipped plays are counted separately and don't add to plays.
+func (a *musicAPI) handleStatsTop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	group := "song.artist"
+	album := "''"
+	switch r.URL.Query().Get("by") {
+	case "", "artist":
+	case "album":
+		group = "song.artist, song.album"
+		album = "song.album"
+	default:
+		http.Error(w, "by must be artist or album", http.StatusBadRequest)
+		return
+	}
+	conds, args, err := timeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, queryLimit(r, 25))
+
+	rows, err := a.db.db.Query(
+		`SELECT song.artist, `+album+`, SUM(play_event.skipped = 0), SUM(play_event.skipped), SUM(play_event.playedSec)
+		   FROM play_event JOIN song ON song.id = play_event.songId `+where+`
+		  GROUP BY `+group+`
+		 HAVING SUM(play_event.skipped = 0) > 0
+		  ORDER BY 3 DESC, 5 DESC LIMIT ?`, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	result := []TopEntry{}
+	for rows.Next() {
+		var e TopEntry
+		if err := rows.Scan(&e.Artist, &e.Album, &e.Plays, &e.Skips, &e.PlayedSec); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = append(result, e)
+	}
+	jsonOK(w, result)
+}
+
+// handleStatsNeverPlayed handles GET /music/stats/never-played?limit= —
+// songs with no recorded play (and a zero play counter), oldest first.
+func (a *musicAPI) handleStatsNeverPlayed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	const cond = `song.deleted IS NULL AND song.plays = 0 AND NOT EXISTS (SELECT 1 FROM play_event WHERE play_event.songId = song.id AND play_event.skipped = 0)`
+	var total int
+	if err := a.db.db.QueryRow(`SELECT COUNT(*) FROM song WHERE ` + cond).Scan(&total); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rows, err := a.db.db.Query(`SELECT `+songColumns+` FROM song WHERE `+cond+` ORDER BY song.added, song.artistSort, song.albumSort, song.discNumber, song.trackNumber LIMIT ?`, queryLimit(r, 200))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	songs := []Song{}
+	for rows.Next() {
+		s, err := scanSong(rows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		songs = append(songs, *s)
+	}
+	jsonOK(w, SongsResponse{Songs: songs, Total: total})
+}
+
+// handleStatsSkipped handles GET /music/stats/skipped?days=30&limit= —
+// songs skipped in the last days, most skipped first.
+func (a *musicAPI) handleStatsSkipped(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days).Format(sqlTime)
+	rows, err := a.db.db.Query(
+		`SELECT COUNT(*), MAX(play_event.started), `+songColumns+`
+		   FROM play_event JOIN song ON song.id = play_event.songId
+		  WHERE play_event.skipped = 1 AND play_event.started >= ? AND song.deleted IS NULL
+		  GROUP BY song.id
+		  ORDER BY 1 DESC, 2 DESC LIMIT ?`, since, queryLimit(r, 50))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	result := []SkippedSong{}
+	for rows.Next() {
+		var e SkippedSong
+		s, err := scanSong(rows, &e.Skips, &e.LastSkipped)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.Song = *s
+		// MAX() loses the column type, so normalise to match other times.
+		e.LastSkipped = parseSQLTime(e.LastSkipped).Format(time.RFC3339)
+		result = append(result, e)
+	}
+	jsonOK(w, result)
+}
+
+// scrobble is one pending scrobble.
+type scrobble struct {
+	id      int64
+	started time.Time
+	artist  string
+	title   string
+	album   string
+	track   int
+	length  float64
+	mbid    string
+}
+
+// pendingScrobbles returns queued scrobbles, oldest first.
+func (d *DB) pendingScrobbles() ([]scrobble, error) {
+	rows, err := d.db.Query(
+		`SELECT play_event.id, play_event.started, song.artist, song.title, song.album, song.trackNumber, song.length, song.mbid
+		   FROM play_event JOIN song ON song.id = play_event.songId
+		  WHERE play_event.scrobble = 1
+		  ORDER BY play_event.started`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []scrobble
+	for rows.Next() {
+		var s scrobble
+		var started string
+		if err := rows.Scan(&s.id, &started, &s.artist, &s.title, &s.album, &s.track, &s.length, &s.mbid); err != nil {
+			return nil, err
+		}
+		s.started = parseSQLTime(started)
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// handleScrobbles handles the offline scrobble queue:
+//
+//	GET  /music/scrobbles?format=listenbrainz|lastfm — export pending scrobbles
+//	POST /music/scrobbles/clear {"throughId": N}     — drop exported scrobbles (admin)
+//
+// The ListenBrainz export is a submit-listens "import" payload; the Last.fm
+// export is a list of track.scrobble parameter sets. Each export carries the
+// highest play_event ID so the caller can clear exactly what it submitted.
+func (a *musicAPI) handleScrobbles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pending, err := a.db.pendingScrobbles()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var through int64
+	for _, s := range pending {
+		through = max(through, s.id)
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "listenbrainz":
+		type trackMetadata struct {
+			ArtistName     string         `json:"artist_name"`
+			TrackName      string         `json:"track_name"`
+			ReleaseName    string         `json:"release_name,omitempty"`
+			AdditionalInfo map[string]any `json:"additional_info"`
+		}
+		type listen struct {
+			ListenedAt    int64         `json:"listened_at"`
+			TrackMetadata trackMetadata `json:"track_metadata"`
+		}
+		listens := make([]listen, 0, len(pending))
+		for _, s := range pending {
+			info := map[string]any{
+				"duration_ms":       int64(s.length * 1000),
+				"media_player":      "velocipi",
+				"submission_client": "velocipi",
+			}
+			if s.track > 0 {
+				info["tracknumber"] = s.track
+			}
+			if s.mbid != "" {
+				info["recording_mbid"] = s.mbid
+			}
+			listens = append(listens, listen{
+				ListenedAt:    s.started.Unix(),
+				TrackMetadata: trackMetadata{ArtistName: s.artist, TrackName: s.title, ReleaseName: s.album, AdditionalInfo: info},
+			})
+		}
+		jsonOK(w, map[string]any{"listen_type": "import", "payload": listens, "throughId": through})
+
+	case "lastfm":
+		type lastfmScrobble struct {
+			Artist      string `json:"artist"`
+			Track       string `json:"track"`
+			Album       string `json:"album,omitempty"`
+			Timestamp   int64  `json:"timestamp"`
+			Duration    int    `json:"duration,omitempty"`
+			TrackNumber int    `json:"trackNumber,omitempty"`
+			MBID        string `json:"mbid,omitempty"`
+		}
+		out := make([]lastfmScrobble, 0, len(pending))
+		for _, s := range pending {
+			out = append(out, lastfmScrobble{
+				Artist: s.artist, Track: s.title, Album: s.album, Timestamp: s.started.Unix(),
+				Duration: int(s.length), TrackNumber: s.track, MBID: s.mbid,
+			})
+		}
+		jsonOK(w, map[string]any{"scrobbles": out, "throughId": through})
+
+	default:
+		http.Error(w, "format must be listenbrainz or lastfm", http.StatusBadRequest)
+	}
+}
+
+// handleScrobblesClear handles POST /music/scrobbles/clear — marks every
+// queued scrobble up to throughId as submitted.
+func (a *musicAPI) handleScrobblesClear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.isAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var body struct {
+		ThroughID int64 `json:"throughId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ThroughID <= 0 {
+		http.Error(w, "throughId is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := a.db.db.Exec(`UPDATE play_event SET scrobble = 0 WHERE scrobble = 1 AND id <= ?`, body.ThroughID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,295 @@
+package music
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openMemDB returns an in-memory database with every migration applied.
+// The name is shared between connections so the pool sees one database.
+func openMemDB(t *testing.T) *DB {
+	t.Helper()
+	d, err := Open("file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := d.Migrate("../../schemas", t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEndPlay(t *testing.T) {
+	d := openMemDB(t)
+	if _, err := d.db.Exec(`INSERT INTO song(id, path, hash, artist, title) VALUES(1, 'a.mp3', 'a', 'A', 'T')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name              string
+		length, elapsed   float64
+		completed         bool
+		required          int
+		noScrobble        bool
+		percent           float64
+		skipped, scrobble bool
+	}{
+		{name: "completed early in the song", length: 200, elapsed: 10, completed: true, percent: 100, scrobble: true},
+		{name: "stopped short of the default", length: 200, elapsed: 80, percent: 40, skipped: true},
+		{name: "stopped past the default", length: 200, elapsed: 120, percent: 60, scrobble: true},
+		{name: "lower required percent", length: 200, elapsed: 80, required: 30, percent: 40},
+		{name: "long song scrobbles after 4 minutes", length: 1000, elapsed: 250, percent: 25, skipped: true, scrobble: true},
+		{name: "too short to scrobble", length: 25, elapsed: 25, completed: true, percent: 100},
+		{name: "scrobbling off", length: 200, elapsed: 200, completed: true, noScrobble: true, percent: 100},
+		{name: "unknown length", elapsed: 30, percent: 0, skipped: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d.db.Exec(`DELETE FROM play_event`)
+			p := &Player{db: d, cfg: MusicConfig{PlayedRequiredPercent: c.required, Scrobble: !c.noScrobble}, session: "run"}
+			p.flight = func() string { return "F1" }
+			p.beginPlay(1)
+			p.elapsedSec, p.currentLength = c.elapsed, c.length
+			p.endPlay(c.completed)
+
+			var percent float64
+			var skipped, scrobble bool
+			var flight, session string
+			err := d.db.QueryRow(`SELECT percent, skipped, scrobble, flight, session FROM play_event`).
+				Scan(&percent, &skipped, &scrobble, &flight, &session)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if percent != c.percent || skipped != c.skipped || scrobble != c.scrobble {
+				t.Errorf("percent %v skipped %v scrobble %v, want %v %v %v",
+					percent, skipped, scrobble, c.percent, c.skipped, c.scrobble)
+			}
+			if flight != "F1" || session != "run" {
+				t.Errorf("flight %q session %q", flight, session)
+			}
+		})
+	}
+
+	// Ending with nothing playing records nothing.
+	d.db.Exec(`DELETE FROM play_event`)
+	p := &Player{db: d}
+	p.endPlay(true)
+	var n int
+	d.db.QueryRow(`SELECT COUNT(*) FROM play_event`).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d plays recorded with nothing playing", n)
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	day := func(s string, days int) string {
+		d, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return d.AddDate(0, 0, days).UTC().Format(sqlTime)
+	}
+	for _, c := range []struct {
+		query string
+		conds []string
+		args  []string
+		bad   bool
+	}{
+		{query: ""},
+		{
+			query: "from=2026-03-01&to=2026-03-02",
+			conds: []string{"play_event.started >= ?", "play_event.started < ?"},
+			args:  []string{day("2026-03-01", 0), day("2026-03-02", 1)}, // to is inclusive
+		},
+		{
+			query: "to=2026-03-02T10:00:00%2B02:00",
+			conds: []string{"play_event.started < ?"},
+			args:  []string{"2026-03-02 08:00:00"},
+		},
+		{query: "from=yesterday", bad: true},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/music/history?"+c.query, nil)
+		conds, args, err := timeRange(r)
+		if c.bad {
+			if err == nil {
+				t.Errorf("%q: no error", c.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+			continue
+		}
+		var got []string
+		for _, a := range args {
+			got = append(got, a.(string))
+		}
+		if strings.Join(conds, ";") != strings.Join(c.conds, ";") || strings.Join(got, ";") != strings.Join(c.args, ";") {
+			t.Errorf("%q: %q %q, want %q %q", c.query, conds, got, c.conds, c.args)
+		}
+	}
+}
+
+// historyAPI returns an API over a database of three songs and their
+// plays, relative to now.
+func historyAPI(t *testing.T) *musicAPI {
+	t.Helper()
+	d := openMemDB(t)
+	exec := func(q string, args ...any) {
+		t.Helper()
+		if _, err := d.db.Exec(q, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(`INSERT INTO song(id, path, hash, artist, album, title, trackNumber, length, mbid) VALUES
+		(1, '1.mp3', '1', 'Alpha', 'First', 'One', 1, 200, 'mbid-1'),
+		(2, '2.mp3', '2', 'Alpha', 'Second', 'Two', 0, 180, ''),
+		(3, '3.mp3', '3', 'Beta', 'Third', 'Three', 3, 240, '')`)
+
+	now := time.Now().UTC()
+	ago := func(days int) string { return now.AddDate(0, 0, -days).Format(sqlTime) }
+	for _, p := range []struct {
+		song              int
+		days              int
+		played            float64
+		skipped, scrobble bool
+	}{
+		{1, 1, 200, false, true},
+		{1, 2, 200, false, true},
+		{2, 3, 180, false, false},
+		{2, 4, 10, true, false},
+		{3, 5, 240, false, false},
+		{3, 6, 20, true, false},
+		{3, 7, 30, true, false},
+		{3, 60, 5, true, false}, // outside the skipped window
+	} {
+		exec(`INSERT INTO play_event(songId, started, ended, playedSec, skipped, scrobble) VALUES(?,?,?,?,?,?)`,
+			p.song, ago(p.days), ago(p.days), p.played, p.skipped, p.scrobble)
+	}
+	return &musicAPI{db: d, isAdmin: func(*http.Request) bool { return true }}
+}
+
+// get calls handler and decodes its JSON response into out, returning the
+// status code.
+func get(t *testing.T, handler http.HandlerFunc, url string, out any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code == http.StatusOK && out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+	}
+	return w.Code
+}
+
+func TestStatsTop(t *testing.T) {
+	a := historyAPI(t)
+
+	var top []TopEntry
+	get(t, a.handleStatsTop, "/music/stats/top", &top)
+	want := []TopEntry{
+		{Artist: "Alpha", Plays: 3, Skips: 1, PlayedSec: 590},
+		{Artist: "Beta", Plays: 1, Skips: 3, PlayedSec: 295},
+	}
+	if len(top) != len(want) || top[0] != want[0] || top[1] != want[1] {
+		t.Errorf("by artist: %+v, want %+v", top, want)
+	}
+
+	get(t, a.handleStatsTop, "/music/stats/top?by=album&limit=1", &top)
+	if len(top) != 1 || top[0] != (TopEntry{Artist: "Alpha", Album: "First", Plays: 2, PlayedSec: 400}) {
+		t.Errorf("by album: %+v", top)
+	}
+
+	// Only the last two days: two plays of song 1.
+	from := time.Now().UTC().Add(-50 * time.Hour).Format(time.RFC3339)
+	get(t, a.handleStatsTop, "/music/stats/top?from="+from, &top)
+	if len(top) != 1 || top[0].Artist != "Alpha" || top[0].Plays != 2 {
+		t.Errorf("from %s: %+v", from, top)
+	}
+
+	if code := get(t, a.handleStatsTop, "/music/stats/top?by=genre", nil); code != http.StatusBadRequest {
+		t.Errorf("by=genre: status %d", code)
+	}
+}
+
+func TestStatsSkipped(t *testing.T) {
+	a := historyAPI(t)
+
+	var skipped []SkippedSong
+	get(t, a.handleStatsSkipped, "/music/stats/skipped", &skipped)
+	if len(skipped) != 2 || skipped[0].Song.ID != 3 || skipped[0].Skips != 2 || skipped[1].Song.ID != 2 || skipped[1].Skips != 1 {
+		t.Fatalf("skipped: %+v", skipped)
+	}
+	if _, err := time.Parse(time.RFC3339, skipped[0].LastSkipped); err != nil {
+		t.Errorf("lastSkipped %q: %v", skipped[0].LastSkipped, err)
+	}
+
+	get(t, a.handleStatsSkipped, "/music/stats/skipped?days=90", &skipped)
+	if len(skipped) != 2 || skipped[0].Skips != 3 {
+		t.Errorf("90 days: %+v", skipped)
+	}
+}
+
+func TestScrobbleExport(t *testing.T) {
+	a := historyAPI(t)
+
+	var lb struct {
+		ListenType string `json:"listen_type"`
+		Payload    []struct {
+			ListenedAt    int64 `json:"listened_at"`
+			TrackMetadata struct {
+				ArtistName     string         `json:"artist_name"`
+				TrackName      string         `json:"track_name"`
+				ReleaseName    string         `json:"release_name"`
+				AdditionalInfo map[string]any `json:"additional_info"`
+			} `json:"track_metadata"`
+		} `json:"payload"`
+		ThroughID int64 `json:"throughId"`
+	}
+	get(t, a.handleScrobbles, "/music/scrobbles", &lb)
+	// Song 1's plays went in newest first, so the oldest has the higher ID.
+	if lb.ListenType != "import" || len(lb.Payload) != 2 || lb.ThroughID != 2 {
+		t.Fatalf("listenbrainz: %+v", lb)
+	}
+	// Oldest first.
+	first := lb.Payload[0]
+	if first.ListenedAt >= lb.Payload[1].ListenedAt {
+		t.Errorf("listens out of order: %d, %d", first.ListenedAt, lb.Payload[1].ListenedAt)
+	}
+	md := first.TrackMetadata
+	if md.ArtistName != "Alpha" || md.TrackName != "One" || md.ReleaseName != "First" ||
+		md.AdditionalInfo["duration_ms"] != 200000.0 || md.AdditionalInfo["tracknumber"] != 1.0 ||
+		md.AdditionalInfo["recording_mbid"] != "mbid-1" {
+		t.Errorf("listenbrainz metadata: %+v", md)
+	}
+
+	var lfm struct {
+		Scrobbles []map[string]any `json:"scrobbles"`
+		ThroughID int64            `json:"throughId"`
+	}
+	get(t, a.handleScrobbles, "/music/scrobbles?format=lastfm", &lfm)
+	if len(lfm.Scrobbles) != 2 || lfm.ThroughID != 2 {
+		t.Fatalf("lastfm: %+v", lfm)
+	}
+	s := lfm.Scrobbles[0]
+	if s["artist"] != "Alpha" || s["track"] != "One" || s["duration"] != 200.0 ||
+		s["trackNumber"] != 1.0 || s["mbid"] != "mbid-1" || s["timestamp"] != float64(first.ListenedAt) {
+		t.Errorf("lastfm scrobble: %v", s)
+	}
+
+	if code := get(t, a.handleScrobbles, "/music/scrobbles?format=rss", nil); code != http.StatusBadRequest {
+		t.Errorf("format=rss: status %d", code)
+	}
+
+	// Clearing through the exported ID empties the queue.
+	w := httptest.NewRecorder()
+	a.handleScrobblesClear(w, httptest.NewRequest(http.MethodPost, "/music/scrobbles/clear", strings.NewReader(`{"throughId":2}`)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("clear: status %d", w.Code)
+	}
+	if pending, err := a.db.pendingScrobbles(); err != nil || len(pending) != 0 {
+		t.Errorf("after clear: %v, %v", pending, err)
+	}
+}
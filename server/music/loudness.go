@@ -0,0 +1,263 @@
+package music
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReplayGain modes (MusicConfig.ReplayGain).
+const (
+	ReplayGainTrack = "track"
+	ReplayGainAlbum = "album"
+	ReplayGainOff   = "off"
+)
+
+// replayGainReference is the ReplayGain 2.0 target loudness in LUFS.
+const replayGainReference = -18.0
+
+// measureLoudness runs ffmpeg's ebur128 filter over path and returns the
+// integrated loudness (LUFS) and true peak (dBTP).
+func measureLoudness(ctx context.Context, path string) (lufs, peakDB float64, err error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-nostats", "-hide_banner", "-nostdin",
+		"-i", path,
+		"-map", "0:a:0",
+		"-filter:a", "ebur128=peak=true",
+		"-f", "null", "-",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("ffmpeg ebur128: %w", err)
+	}
+	lufs, peakDB, ok := parseEBUR128(string(out))
+	if !ok {
+		return 0, 0, fmt.Errorf("ffmpeg ebur128: no summary in output")
+	}
+	return lufs, peakDB, nil
+}
+
+// parseEBUR128 extracts the integrated loudness and true peak from the
+// summary ffmpeg's ebur128 filter prints when it finishes:
+//
+//	Integrated loudness:
+//	  I:         -14.2 LUFS
+//	  ...
+//	True peak:
+//	  Peak:        0.3 dBFS
+func parseEBUR128(out string) (lufs, peakDB float64, ok bool) {
+	i := strings.LastIndex(out, "Summary:")
+	if i < 0 {
+		return 0, 0, false
+	}
+	var haveI, havePeak bool
+	section := ""
+	sc := bufio.NewScanner(strings.NewReader(out[i:]))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasSuffix(line, ":") {
+			section = line
+			continue
+		}
+		key, rest, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			// "-inf" for digital silence.
+			v = math.Inf(-1)
+		}
+		switch {
+		case section == "Integrated loudness:" && key == "I":
+			lufs, haveI = v, true
+		case section == "True peak:" && key == "Peak":
+			peakDB, havePeak = v, true
+		}
+	}
+	return lufs, peakDB, haveI && havePeak
+}
+
+// albumLoudness combines track loudness values into an album loudness,
+// weighting each track's power by its length.
+func albumLoudness(lufs, lengths []float64) float64 {
+	var power, total float64
+	for i, l := range lufs {
+		if math.IsInf(l, -1) {
+			continue
+		}
+		power += lengths[i] * math.Pow(10, l/10)
+		total += lengths[i]
+	}
+	if total == 0 {
+		return math.Inf(-1)
+	}
+	return 10 * math.Log10(power/total)
+}
+
+// playbackGain returns the gain in dB to apply to a song in the given mode.
+// Album mode falls back to the track gain for songs without an album gain.
+// The gain is reduced if it would push the peak above full scale.
+func playbackGain(mode string, preamp float64, trackGain, trackPeak, albumGain, albumPeak sql.NullFloat64) float64 {
+	gain, peak := trackGain, trackPeak
+	switch mode {
+	case ReplayGainAlbum:
+		if albumGain.Valid {
+			gain, peak = albumGain, albumPeak
+		}
+	case ReplayGainTrack:
+	default:
+		return 0
+	}
+	if !gain.Valid {
+		return 0
+	}
+	g := gain.Float64 + preamp
+	if peak.Valid && peak.Float64 > 0 {
+		g = min(g, -20*math.Log10(peak.Float64))
+	}
+	return g
+}
+
+// MeasureLoudness measures the EBU R128 loudness of songs that haven't been
+// measured yet (all songs with force) and updates their track and album
+// gains. Used by sync for new and changed files, and by musicsync
+// --loudness to backfill existing libraries.
+func (s *Syncer) MeasureLoudness(ctx context.Context, force bool) error {
+	query := `SELECT id FROM song WHERE deleted IS NULL`
+	if !force {
+		query += ` AND loudness IS NULL`
+	}
+	rows, err := s.db.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("loudness: query songs: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("loudness: scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	return s.measureSongs(ctx, ids)
+}
+
+// measureSongs measures the given songs, then recomputes album gains for
+// every album they belong to.
+func (s *Syncer) measureSongs(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		log.Println("music sync: ffmpeg not found — skipping loudness analysis")
+		return nil
+	}
+	albums := map[string]bool{}
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var path, album string
+		if err := s.db.db.QueryRow(`SELECT path, album FROM song WHERE id=?`, id).Scan(&path, &album); err != nil {
+			continue
+		}
+		lufs, peakDB, err := measureLoudness(ctx, path)
+		if err != nil {
+			log.Printf("music sync: [%d/%d] loudness %s: %v", i+1, len(ids), s.relPath(path), err)
+			continue
+		}
+		gain, peak := sql.NullFloat64{}, math.Pow(10, peakDB/20)
+		if !math.IsInf(lufs, -1) {
+			gain = sql.NullFloat64{Float64: replayGainReference - lufs, Valid: true}
+		} else {
+			lufs = -70 // silence; the ebur128 absolute gate
+		}
+		if _, err := s.db.db.Exec(`UPDATE song SET loudness=?, trackGain=?, trackPeak=? WHERE id=?`, lufs, gain, peak, id); err != nil {
+			return fmt.Errorf("loudness: update song %d: %w", id, err)
+		}
+		log.Printf("music sync: [%d/%d] loudness %s: %.1f LUFS, peak %.1f dBTP", i+1, len(ids), s.relPath(path), lufs, peakDB)
+		if album != "" {
+			albums[album] = true
+		}
+	}
+	for album := range albums {
+		if err := s.updateAlbumGain(album); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateAlbumGain recomputes the album gain and peak for the songs tagged
+// with album. Songs are grouped by directory as well, so same-named albums
+// by different artists stay separate. A group with any unmeasured song gets
+// no album gain (playback falls back to track gain).
+func (s *Syncer) updateAlbumGain(album string) error {
+	rows, err := s.db.db.Query(`SELECT id, path, length, loudness, trackPeak FROM song WHERE album=? AND deleted IS NULL`, album)
+	if err != nil {
+		return fmt.Errorf("loudness: album %q: %w", album, err)
+	}
+	type track struct {
+		id       int64
+		length   float64
+		loudness sql.NullFloat64
+		peak     sql.NullFloat64
+	}
+	groups := map[string][]track{}
+	for rows.Next() {
+		var t track
+		var path string
+		if err := rows.Scan(&t.id, &path, &t.length, &t.loudness, &t.peak); err != nil {
+			rows.Close()
+			return fmt.Errorf("loudness: album %q: %w", album, err)
+		}
+		dir := filepath.Dir(path)
+		groups[dir] = append(groups[dir], t)
+	}
+	rows.Close()
+
+	for _, tracks := range groups {
+		var gain, peak sql.NullFloat64
+		lufs := make([]float64, 0, len(tracks))
+		lengths := make([]float64, 0, len(tracks))
+		complete := true
+		for _, t := range tracks {
+			if !t.loudness.Valid {
+				complete = false
+				break
+			}
+			lufs = append(lufs, t.loudness.Float64)
+			lengths = append(lengths, max(t.length, 1))
+			peak.Float64 = max(peak.Float64, t.peak.Float64)
+		}
+		if complete {
+			if l := albumLoudness(lufs, lengths); !math.IsInf(l, -1) {
+				gain = sql.NullFloat64{Float64: replayGainReference - l, Valid: true}
+				peak.Valid = true
+			}
+		}
+		if !gain.Valid {
+			peak = sql.NullFloat64{}
+		}
+		for _, t := range tracks {
+			if _, err := s.db.db.Exec(`UPDATE song SET albumGain=?, albumPeak=? WHERE id=?`, gain, peak, t.id); err != nil {
+				return fmt.Errorf("loudness: album %q: %w", album, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package music
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+)
+
+const ebur128Output = `[Parsed_ebur128_0 @ 0x5581] t: 3.0  TARGET:-23 LUFS    M: -14.1 S:-120.7     I: -14.1 LUFS       LRA:   0.0 LU  FTPK:  0.1 dBFS  TPK:  0.1 dBFS
+[Parsed_ebur128_0 @ 0x5581] Summary:
+
+  Integrated loudness:
+    I:         -11.6 LUFS
+    Threshold: -21.7 LUFS
+
+  Loudness range:
+    LRA:         5.2 LU
+    Threshold: -31.8 LUFS
+    LRA low:   -15.3 LUFS
+    LRA high:  -10.1 LUFS
+
+  True peak:
+    Peak:        0.4 dBFS
+`
+
+func TestParseEBUR128(t *testing.T) {
+	lufs, peak, ok := parseEBUR128(ebur128Output)
+	if !ok || lufs != -11.6 || peak != 0.4 {
+		t.Errorf("parseEBUR128 = %v, %v, %v; want -11.6, 0.4, true", lufs, peak, ok)
+	}
+	if _, _, ok := parseEBUR128("no summary here"); ok {
+		t.Error("parseEBUR128 without summary: ok = true")
+	}
+	silent := "Summary:\n  Integrated loudness:\n    I:         -inf LUFS\n  True peak:\n    Peak:       -inf dBFS\n"
+	if lufs, _, ok := parseEBUR128(silent); !ok || !math.IsInf(lufs, -1) {
+		t.Errorf("silence: lufs = %v, ok = %v; want -Inf, true", lufs, ok)
+	}
+}
+
+func TestAlbumLoudness(t *testing.T) {
+	if got := albumLoudness([]float64{-10, -10}, []float64{100, 300}); math.Abs(got+10) > 1e-9 {
+		t.Errorf("equal tracks: %v, want -10", got)
+	}
+	// A quiet short track barely moves a loud long one.
+	if got := albumLoudness([]float64{-8, -20}, []float64{300, 30}); got < -8.5 || got > -8 {
+		t.Errorf("loud+quiet: %v, want just under -8", got)
+	}
+}
+
+func TestPlaybackGain(t *testing.T) {
+	v := func(f float64) sql.NullFloat64 { return sql.NullFloat64{Float64: f, Valid: true} }
+	none := sql.NullFloat64{}
+	cases := []struct {
+		name           string
+		mode           string
+		preamp         float64
+		tg, tp, ag, ap sql.NullFloat64
+		want           float64
+	}{
+		{"track", ReplayGainTrack, 0, v(-6), v(0.5), v(-4), v(0.6), -6},
+		{"album", ReplayGainAlbum, 0, v(-6), v(0.5), v(-4), v(0.6), -4},
+		{"album falls back to track", ReplayGainAlbum, 0, v(-6), v(0.5), none, none, -6},
+		{"off", ReplayGainOff, 0, v(-6), v(0.5), v(-4), v(0.6), 0},
+		{"unmeasured", ReplayGainTrack, 3, none, none, none, none, 0},
+		{"preamp", ReplayGainTrack, 2, v(-6), v(0.5), none, none, -4},
+		{"peak limited", ReplayGainTrack, 0, v(8), v(0.5), none, none, -20 * math.Log10(0.5)},
+	}
+	for _, c := range cases {
+		if got := playbackGain(c.mode, c.preamp, c.tg, c.tp, c.ag, c.ap); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: gain = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
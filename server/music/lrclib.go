@@ -0,0 +1,204 @@
+package music
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lrclibMaxLengthDiff is how far (seconds) a search result's duration may be
+// from the song's and still be used.
+const lrclibMaxLengthDiff = 5
+
+// LyricsLookup fetches lyrics from an LRCLIB-compatible API
+// (music.lyricsURL) for songs that have none, caching the results (including
+// misses) in the lyrics table.
+type LyricsLookup struct {
+	db      *DB
+	baseURL string
+	client  *http.Client
+	opts    LookupOptions
+}
+
+// NewLyricsLookup creates a LyricsLookup. With opts.Force, songs whose
+// previous lookup found nothing (or whose fetched lyrics may have improved)
+// are looked up again.
+func NewLyricsLookup(db *DB, cfg MusicConfig, opts LookupOptions) *LyricsLookup {
+	return &LyricsLookup{
+		db:      db,
+		baseURL: strings.TrimRight(cfg.LyricsURL, "/"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+		opts:    opts,
+	}
+}
+
+// lrclibTrack is a track record from the LRCLIB API.
+type lrclibTrack struct {
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+// Run looks up lyrics for every song without any. Songs with a companion
+// .lrc file or no artist/title are skipped.
+func (l *LyricsLookup) Run(ctx context.Context) error {
+	if l.baseURL == "" {
+		return fmt.Errorf("lyrics: music.lyricsURL is not set in config")
+	}
+	query := `SELECT id, path, artist, album, title, length FROM song
+	          WHERE deleted IS NULL AND artist != '' AND title != ''
+	            AND NOT EXISTS (SELECT 1 FROM lyrics WHERE lyrics.songId = song.id
+	                            AND lyrics.source NOT IN ('` + LyricsOffset + `'`
+	if l.opts.Force {
+		query += `, '` + LyricsNone + `', '` + LyricsLRCLIB + `'`
+	}
+	query += `))`
+	rows, err := l.db.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("lyrics: query songs: %w", err)
+	}
+	type songRow struct {
+		id                   int64
+		path                 string
+		artist, album, title string
+		length               float64
+	}
+	var songs []songRow
+	for rows.Next() {
+		var sr songRow
+		if err := rows.Scan(&sr.id, &sr.path, &sr.artist, &sr.album, &sr.title, &sr.length); err != nil {
+			rows.Close()
+			return fmt.Errorf("lyrics: scan: %w", err)
+		}
+		if _, err := os.Stat(lrcPath(sr.path)); err == nil {
+			continue
+		}
+		songs = append(songs, sr)
+	}
+	rows.Close()
+
+	if len(songs) == 0 {
+		log.Println("lyrics: no songs to process")
+		return nil
+	}
+	log.Printf("lyrics: processing %d songs", len(songs))
+
+	found := 0
+	for i, sr := range songs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		t, err := l.fetch(ctx, sr.artist, sr.album, sr.title, sr.length)
+		if err != nil {
+			// Not cached: network trouble shouldn't stop a later retry.
+			log.Printf("lyrics: [%d/%d] %s - %s: %v", i+1, len(songs), sr.artist, sr.title, err)
+			continue
+		}
+		source, synced, text := LyricsNone, false, ""
+		switch {
+		case t == nil || t.Instrumental:
+		case strings.TrimSpace(t.SyncedLyrics) != "":
+			source, synced, text = LyricsLRCLIB, true, strings.TrimSpace(t.SyncedLyrics)
+		case strings.TrimSpace(t.PlainLyrics) != "":
+			source, text = LyricsLRCLIB, strings.TrimSpace(t.PlainLyrics)
+		}
+		if _, err := l.db.db.ExecContext(ctx, `
+			INSERT INTO lyrics(songId, source, synced, text) VALUES(?, ?, ?, ?)
+			ON CONFLICT(songId) DO UPDATE SET source=excluded.source, synced=excluded.synced, text=excluded.text, updated=CURRENT_TIMESTAMP`,
+			sr.id, source, synced, text); err != nil {
+			return fmt.Errorf("lyrics: store %d: %w", sr.id, err)
+		}
+		if source == LyricsLRCLIB {
+			found++
+			kind := "plain"
+			if synced {
+				kind = "synced"
+			}
+			log.Printf("lyrics: [%d/%d] %s - %s: %s", i+1, len(songs), sr.artist, sr.title, kind)
+		}
+	}
+	log.Printf("lyrics: found lyrics for %d of %d songs", found, len(songs))
+	return nil
+}
+
+// fetch asks the API for a track's lyrics: an exact /api/get by artist,
+// title, album and duration first, then an /api/search for the closest
+// duration. Returns nil if nothing suitable exists.
+func (l *LyricsLookup) fetch(ctx context.Context, artist, album, title string, length float64) (*lrclibTrack, error) {
+	params := url.Values{
+		"artist_name": {artist},
+		"track_name":  {title},
+		"album_name":  {album},
+		"duration":    {strconv.Itoa(int(math.Round(length)))},
+	}
+	var t lrclibTrack
+	ok, err := l.get(ctx, "/api/get?"+params.Encode(), &t)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &t, nil
+	}
+
+	var results []lrclibTrack
+	params = url.Values{"artist_name": {artist}, "track_name": {title}}
+	if ok, err := l.get(ctx, "/api/search?"+params.Encode(), &results); err != nil || !ok {
+		return nil, err
+	}
+	var best *lrclibTrack
+	for i := range results {
+		r := &results[i]
+		diff := math.Abs(r.Duration - length)
+		if length > 0 && diff > lrclibMaxLengthDiff {
+			continue
+		}
+		// Prefer synced lyrics, then the closest duration.
+		switch {
+		case best == nil:
+			best = r
+		case (r.SyncedLyrics != "") != (best.SyncedLyrics != ""):
+			if r.SyncedLyrics != "" {
+				best = r
+			}
+		case diff < math.Abs(best.Duration-length):
+			best = r
+		}
+	}
+	return best, nil
+}
+
+// get fetches path from the API into v, reporting false for a 404.
+func (l *LyricsLookup) get(ctx context.Context, path string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "velocipi/1.0 (github.com/vincent99/velocipi)")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("decode: %w", err)
+	}
+	return true, nil
+}
@@ -3,11 +3,30 @@ package music
 import (
 	"bufio"
 	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+
+	"github.com/dhowden/tag"
+)
+
+// Lyrics sources (lyrics.source).
+const (
+	LyricsFile     = "file"     // companion .lrc file; never stored
+	LyricsEmbedded = "embedded" // USLT/SYLT/LYRICS tag, extracted by sync
+	LyricsLRCLIB   = "lrclib"   // fetched by the lyrics lookup
+	LyricsEdited   = "edited"   // set through the API
+	LyricsNone     = "none"     // looked up, nothing found
+	LyricsOffset   = "offset"   // only an offset is stored; not looked up yet
 )
 
 // LyricLine is one timed lyric entry parsed from an LRC file.
@@ -62,3 +81,250 @@ func parseLRC(data []byte) []LyricLine {
 	sort.Slice(out, func(i, j int) bool { return out[i].TimeSec < out[j].TimeSec })
 	return out
 }
+
+// hasLRCTimestamps reports whether text is synced LRC rather than plain lyrics.
+func hasLRCTimestamps(text string) bool {
+	return lrcTimestampRe.MatchString(text)
+}
+
+// formatLRC writes lines back out as LRC text.
+func formatLRC(lines []LyricLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		cs := int(l.TimeSec*100 + 0.5)
+		fmt.Fprintf(&b, "[%02d:%02d.%02d]%s\n", cs/6000, cs/100%60, cs%100, l.Text)
+	}
+	return b.String()
+}
+
+// plainLines splits unsynced lyrics into lines, all at time 0.
+func plainLines(text string) []LyricLine {
+	out := []LyricLine{}
+	for _, l := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		out = append(out, LyricLine{Text: strings.TrimSpace(l)})
+	}
+	for len(out) > 0 && out[len(out)-1].Text == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// embeddedLyrics returns the lyrics stored in a file's tags, preferring
+// synced ones: an ID3 SYLT frame (converted to LRC), then USLT or Vorbis
+// LYRICS/UNSYNCEDLYRICS, which some taggers fill with LRC text.
+func embeddedLyrics(t tag.Metadata) (text string, synced bool) {
+	raw := t.Raw()
+	if b, ok := raw["SYLT"].([]byte); ok {
+		if lines := parseSYLT(b); len(lines) > 0 {
+			return formatLRC(lines), true
+		}
+	}
+	text = strings.TrimSpace(t.Lyrics())
+	if text == "" {
+		if v, ok := raw["unsyncedlyrics"].(string); ok {
+			text = strings.TrimSpace(v)
+		}
+	}
+	return text, text != "" && hasLRCTimestamps(text)
+}
+
+// ID3v2 text encodings.
+const (
+	id3Latin1    = 0
+	id3UTF16BOM  = 1
+	id3UTF16BE   = 2
+	id3UTF8      = 3
+	syltMillisec = 2 // SYLT timestamp format: absolute milliseconds
+)
+
+// parseSYLT decodes an ID3v2 SYLT (synchronised lyrics) frame body:
+// encoding, language, timestamp format, content type and a descriptor,
+// then text + 32-bit timestamp pairs. Only millisecond timestamps are
+// supported; MPEG frame counts need the stream's frame rate.
+func parseSYLT(b []byte) []LyricLine {
+	if len(b) < 6 || b[4] != syltMillisec {
+		return nil
+	}
+	enc := b[0]
+	b = b[6:]
+	_, b = splitID3Text(b, enc) // content descriptor
+	var out []LyricLine
+	for len(b) > 0 {
+		var text string
+		text, b = splitID3Text(b, enc)
+		if len(b) < 4 {
+			break
+		}
+		ms := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		out = append(out, LyricLine{TimeSec: float64(ms) / 1000, Text: strings.TrimSpace(text)})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TimeSec < out[j].TimeSec })
+	return out
+}
+
+// splitID3Text reads one null-terminated string in the given ID3v2 encoding
+// from the front of b, returning it and the rest of b.
+func splitID3Text(b []byte, enc byte) (string, []byte) {
+	if enc != id3UTF16BOM && enc != id3UTF16BE {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			i = len(b)
+		}
+		s := b[:i]
+		rest := b[min(i+1, len(b)):]
+		if enc == id3Latin1 {
+			r := make([]rune, len(s))
+			for j, c := range s {
+				r[j] = rune(c)
+			}
+			return string(r), rest
+		}
+		return string(s), rest
+	}
+	i := 0
+	for ; i+1 < len(b) && (b[i] != 0 || b[i+1] != 0); i += 2 {
+	}
+	s := b[:min(i, len(b))]
+	rest := b[min(i+2, len(b)):]
+	order := binary.ByteOrder(binary.BigEndian)
+	if len(s) >= 2 {
+		switch {
+		case s[0] == 0xff && s[1] == 0xfe:
+			order, s = binary.LittleEndian, s[2:]
+		case s[0] == 0xfe && s[1] == 0xff:
+			s = s[2:]
+		}
+	}
+	u := make([]uint16, len(s)/2)
+	for j := range u {
+		u[j] = order.Uint16(s[2*j:])
+	}
+	return string(utf16.Decode(u)), rest
+}
+
+// storeEmbeddedLyrics records the lyrics sync found in a song's tags. It
+// never replaces hand-edited lyrics, and only replaces fetched ones when
+// the file now has lyrics of its own. Lyrics that disappeared from the tags
+// are dropped.
+func (d *DB) storeEmbeddedLyrics(songID int64, text string, synced bool) error {
+	var err error
+	if text == "" {
+		_, err = d.db.Exec(`DELETE FROM lyrics WHERE songId=? AND source=?`, songID, LyricsEmbedded)
+	} else {
+		_, err = d.db.Exec(`
+			INSERT INTO lyrics(songId, source, synced, text) VALUES(?, ?, ?, ?)
+			ON CONFLICT(songId) DO UPDATE SET source=excluded.source, synced=excluded.synced, text=excluded.text, updated=CURRENT_TIMESTAMP
+			WHERE lyrics.source != ?`,
+			songID, LyricsEmbedded, synced, text, LyricsEdited)
+	}
+	return err
+}
+
+// Lyrics is the API representation of a song's lyrics.
+type Lyrics struct {
+	Source   string      `json:"source"` // "" when the song has none
+	Synced   bool        `json:"synced"`
+	OffsetMs int         `json:"offsetMs"`
+	Lines    []LyricLine `json:"lines"` // offset already applied
+}
+
+// songLyrics returns the lyrics to show for a song: a companion .lrc file
+// wins, then whatever is stored in the database. offsetMs is applied.
+func (d *DB) songLyrics(songID int64, songPath string) (Lyrics, error) {
+	var source, text string
+	var synced bool
+	var offsetMs int
+	err := d.db.QueryRow(`SELECT source, synced, text, offsetMs FROM lyrics WHERE songId=?`, songID).
+		Scan(&source, &synced, &text, &offsetMs)
+	if err != nil && err != sql.ErrNoRows {
+		return Lyrics{}, err
+	}
+	if data, err := os.ReadFile(lrcPath(songPath)); err == nil {
+		source, text, synced = LyricsFile, string(data), true
+	}
+
+	l := Lyrics{Source: source, Synced: synced, OffsetMs: offsetMs, Lines: []LyricLine{}}
+	switch {
+	case source == "" || source == LyricsNone || source == LyricsOffset:
+		l.Source = ""
+	case synced:
+		l.Lines = parseLRC([]byte(text))
+		for i := range l.Lines {
+			l.Lines[i].TimeSec = max(l.Lines[i].TimeSec+float64(offsetMs)/1000, 0)
+		}
+	default:
+		l.Lines = plainLines(text)
+	}
+	return l, nil
+}
+
+// handleSongLyrics handles /music/songs/{id}/lyrics. GET returns the song's
+// Lyrics; PUT {"offsetMs": 250, "text": "..."} adjusts the timing offset
+// (positive shows lines later) and/or replaces the stored lyrics. An empty
+// text clears them. Text can't be set while a companion .lrc file is what's
+// shown (409 Conflict); edit that file instead.
+func (a *musicAPI) handleSongLyrics(w http.ResponseWriter, r *http.Request, id int64) {
+	var songPath string
+	err := a.db.db.QueryRow(`SELECT path FROM song WHERE id=? AND deleted IS NULL`, id).Scan(&songPath)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			OffsetMs *int    `json:"offsetMs"`
+			Text     *string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Text != nil {
+			if _, err := os.Stat(lrcPath(songPath)); err == nil {
+				http.Error(w, "lyrics come from "+filepath.Base(lrcPath(songPath))+"; edit that file instead", http.StatusConflict)
+				return
+			}
+		}
+		// A new row holds just the offset, so it doesn't stop a lookup.
+		if _, err := a.db.db.Exec(`INSERT INTO lyrics(songId, source) VALUES(?, ?) ON CONFLICT(songId) DO NOTHING`, id, LyricsOffset); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if body.OffsetMs != nil {
+			if _, err := a.db.db.Exec(`UPDATE lyrics SET offsetMs=?, updated=CURRENT_TIMESTAMP WHERE songId=?`, *body.OffsetMs, id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if body.Text != nil {
+			text := strings.TrimSpace(*body.Text)
+			source := LyricsEdited
+			if text == "" {
+				source = LyricsNone
+			}
+			if _, err := a.db.db.Exec(`UPDATE lyrics SET source=?, synced=?, text=?, updated=CURRENT_TIMESTAMP WHERE songId=?`,
+				source, hasLRCTimestamps(text), text, id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	l, err := a.db.songLyrics(id, songPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, l)
+}
@@ -0,0 +1,191 @@
+package music
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// sylt builds a SYLT frame body with millisecond timestamps.
+func sylt(enc byte, entries []LyricLine) []byte {
+	text := func(s string) []byte {
+		if enc == id3UTF16BOM {
+			b := []byte{0xff, 0xfe}
+			for _, u := range utf16.Encode([]rune(s)) {
+				b = binary.LittleEndian.AppendUint16(b, u)
+			}
+			return append(b, 0, 0)
+		}
+		return append([]byte(s), 0)
+	}
+	b := append([]byte{enc, 'e', 'n', 'g', syltMillisec, 1}, text("")...)
+	for _, e := range entries {
+		b = append(b, text(e.Text)...)
+		b = binary.BigEndian.AppendUint32(b, uint32(e.TimeSec*1000))
+	}
+	return b
+}
+
+func TestParseSYLT(t *testing.T) {
+	want := []LyricLine{{TimeSec: 1.5, Text: "Hello"}, {TimeSec: 12.25, Text: "Wörld"}}
+	for _, enc := range []byte{id3UTF8, id3UTF16BOM} {
+		got := parseSYLT(sylt(enc, want))
+		if len(got) != len(want) {
+			t.Fatalf("enc %d: got %+v, want %+v", enc, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("enc %d: line %d = %+v, want %+v", enc, i, got[i], want[i])
+			}
+		}
+	}
+	// MPEG frame timestamps aren't supported.
+	b := sylt(id3UTF8, want)
+	b[4] = 1
+	if got := parseSYLT(b); got != nil {
+		t.Errorf("frame timestamps: got %+v, want nil", got)
+	}
+	if lrc := formatLRC(want); lrc != "[00:01.50]Hello\n[00:12.25]Wörld\n" {
+		t.Errorf("formatLRC = %q", lrc)
+	}
+}
+
+func TestLyricsLookup(t *testing.T) {
+	lrclib := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/api/get" && q.Get("track_name") == "Exact" && q.Get("duration") == "200":
+			w.Write([]byte(`{"trackName":"Exact","duration":200,"syncedLyrics":"[00:01.00]one\n[00:02.00]two"}`))
+		case r.URL.Path == "/api/search" && q.Get("track_name") == "Searched":
+			w.Write([]byte(`[
+				{"trackName":"Searched","duration":260,"syncedLyrics":"[00:01.00]too long"},
+				{"trackName":"Searched","duration":191,"plainLyrics":"plain"},
+				{"trackName":"Searched","duration":194,"syncedLyrics":"[00:03.00]synced"}
+			]`))
+		case r.URL.Path == "/api/search":
+			w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer lrclib.Close()
+
+	dir := t.TempDir()
+	d, err := Open(filepath.Join(dir, "music.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Migrate("../../schemas", dir); err != nil {
+		t.Fatal(err)
+	}
+	for i, title := range []string{"Exact", "Searched", "Missing", "Embedded"} {
+		if _, err := d.db.Exec(`INSERT INTO song(id, path, hash, artist, title, length) VALUES(?, ?, ?, 'A', ?, ?)`,
+			i+1, filepath.Join(dir, title+".mp3"), title, title, 200-i*9); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.storeEmbeddedLyrics(4, "just words", false); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLyricsLookup(d, MusicConfig{LyricsURL: lrclib.URL + "/"}, LookupOptions{})
+	if err := l.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     int64
+		source string
+		synced bool
+		first  LyricLine
+	}{
+		{1, LyricsLRCLIB, true, LyricLine{TimeSec: 1, Text: "one"}},
+		{2, LyricsLRCLIB, true, LyricLine{TimeSec: 3, Text: "synced"}},
+		{3, "", false, LyricLine{}},
+		{4, LyricsEmbedded, false, LyricLine{Text: "just words"}},
+	}
+	for _, tt := range tests {
+		got, err := d.songLyrics(tt.id, filepath.Join(dir, "none.mp3"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Source != tt.source || got.Synced != tt.synced {
+			t.Errorf("song %d: source %q synced %v, want %q %v", tt.id, got.Source, got.Synced, tt.source, tt.synced)
+		}
+		if (len(got.Lines) > 0) != (tt.source != "") || len(got.Lines) > 0 && got.Lines[0] != tt.first {
+			t.Errorf("song %d: lines %+v, want first %+v", tt.id, got.Lines, tt.first)
+		}
+	}
+
+	// The miss is cached: nothing is left to look up.
+	var n int
+	d.db.QueryRow(`SELECT COUNT(*) FROM lyrics WHERE songId=3 AND source=?`, LyricsNone).Scan(&n)
+	if n != 1 {
+		t.Errorf("miss not cached")
+	}
+
+	// The offset shifts synced lines.
+	d.db.Exec(`UPDATE lyrics SET offsetMs=-500 WHERE songId=1`)
+	got, _ := d.songLyrics(1, "")
+	if got.Lines[0].TimeSec != 0.5 || got.Lines[1].TimeSec != 1.5 {
+		t.Errorf("offset lines = %+v", got.Lines)
+	}
+}
+
+func TestSongLyricsPut(t *testing.T) {
+	lrclib := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"trackName":"T","duration":200,"syncedLyrics":"[00:01.00]one"}`))
+	}))
+	defer lrclib.Close()
+
+	d := openMemDB(t)
+	dir := t.TempDir()
+	for i, name := range []string{"plain", "sidecar"} {
+		if _, err := d.db.Exec(`INSERT INTO song(id, path, hash, artist, title, length) VALUES(?, ?, ?, 'A', 'T', 200)`,
+			i+1, filepath.Join(dir, name+".mp3"), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sidecar.lrc"), []byte("[00:02.00]file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &musicAPI{db: d}
+	put := func(id int64, body string) (int, Lyrics) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		a.handleSongLyrics(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)), id)
+		var l Lyrics
+		json.Unmarshal(w.Body.Bytes(), &l)
+		return w.Code, l
+	}
+
+	// An offset set before any lyrics are found doesn't count as a miss.
+	if code, _ := put(1, `{"offsetMs": 250}`); code != http.StatusOK {
+		t.Fatalf("offset: %d", code)
+	}
+	l := NewLyricsLookup(d, MusicConfig{LyricsURL: lrclib.URL + "/"}, LookupOptions{})
+	if err := l.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := d.songLyrics(1, filepath.Join(dir, "plain.mp3"))
+	if got.Source != LyricsLRCLIB || got.OffsetMs != 250 || len(got.Lines) != 1 || got.Lines[0].TimeSec != 1.25 {
+		t.Errorf("after lookup: %+v", got)
+	}
+
+	// The .lrc file is what's shown, so its text can't be edited here, but
+	// its offset can.
+	if code, _ := put(2, `{"text": "[00:01.00]edited"}`); code != http.StatusConflict {
+		t.Errorf("text over .lrc: %d, want 409", code)
+	}
+	code, got := put(2, `{"offsetMs": -500}`)
+	if code != http.StatusOK || got.Source != LyricsFile || got.Lines[0].TimeSec != 1.5 {
+		t.Errorf("offset over .lrc: %d %+v", code, got)
+	}
+}
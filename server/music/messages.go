@@ -3,14 +3,20 @@ package music
 // MusicStateMsg is broadcast over WebSocket to all clients whenever the player
 // state changes. It is also returned to newly-connected clients on join.
 type MusicStateMsg struct {
-	Type          string  `json:"type"`          // always "musicState"
-	CurrentSongID *int64  `json:"currentSongId"` // nil when stopped
-	QueueIndex    int     `json:"queueIndex"`
-	Status        string  `json:"status"` // "playing" | "paused" | "stopped"
-	Shuffle       bool    `json:"shuffle"`
-	Repeat        string  `json:"repeat"` // "off" | "song" | "queue"
-	ElapsedSec    float64 `json:"elapsedSec"`
-	QueueLength   int     `json:"queueLength"`
+	Type          string     `json:"type"`          // always "musicState"
+	Zone          string     `json:"zone"`          // zone ID
+	ZoneName      string     `json:"zoneName"`      // zone display name
+	CurrentSongID *int64     `json:"currentSongId"` // nil when stopped
+	QueueIndex    int        `json:"queueIndex"`
+	Status        string     `json:"status"` // "playing" | "paused" | "stopped"
+	Shuffle       bool       `json:"shuffle"`
+	Repeat        string     `json:"repeat"` // "off" | "song" | "queue"
+	ElapsedSec    float64    `json:"elapsedSec"`
+	QueueLength   int        `json:"queueLength"`
+	Ducked        string     `json:"ducked,omitempty"` // "volume" | "paused" while ducked for radio traffic
+	AutoDJ        bool       `json:"autoDJ"`
+	AutoDJSeed    AutoDJSeed `json:"autoDJSeed"`
+	Volume        int        `json:"volume"` // 0-100, before ducking
 }
 
 // MusicQueueEntry is one entry in the pushed queue snapshot.
@@ -24,6 +30,7 @@ type MusicQueueEntry struct {
 // Clients use this to replace their local queue state without polling.
 type MusicQueueMsg struct {
 	Type         string            `json:"type"` // always "musicQueue"
+	Zone         string            `json:"zone"` // zone ID
 	CurrentIndex int               `json:"currentIndex"`
 	Entries      []MusicQueueEntry `json:"entries"`
 }
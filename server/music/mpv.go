@@ -0,0 +1,214 @@
+package music
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	mpvDialTimeout    = 5 * time.Second
+	mpvRequestTimeout = time.Second
+)
+
+// mpvEvent is an asynchronous event from one mpv instance. Only the events
+// the player acts on are forwarded: "end-file", and "_exit" (synthesised
+// when the process dies).
+type mpvEvent struct {
+	deck   *mpv
+	Event  string `json:"event"`
+	Reason string `json:"reason"` // end-file: eof|stop|quit|error|redirect
+	Error  string `json:"file_error"`
+}
+
+// mpvReply is the response to one IPC request.
+type mpvReply struct {
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// mpv is a long-lived mpv process in idle mode, driven over its JSON IPC
+// socket. Requests and replies share one connection; events are forwarded
+// to the channel given to startMpv.
+type mpv struct {
+	socket string
+	cmd    *exec.Cmd
+	conn   net.Conn
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan mpvReply
+}
+
+// startMpv launches mpv listening on socket and connects to it. The process
+// lives until ctx is cancelled or it exits; either way an "_exit" event is
+// sent on events.
+func startMpv(ctx context.Context, socket string, cfg MusicConfig, gapless bool, events chan<- mpvEvent) (*mpv, error) {
+	os.Remove(socket)
+	args := []string{
+		"--idle=yes",
+		"--no-video",
+		fmt.Sprintf("--volume=%d", cfg.Volume),
+		"--input-ipc-server=" + socket,
+		"--input-terminal=no",
+		"--really-quiet",
+	}
+	if gapless {
+		args = append(args, "--gapless-audio=yes", "--prefetch-playlist=yes")
+	} else {
+		args = append(args, "--gapless-audio=no")
+	}
+	if cfg.AudioDevice != "" && cfg.AudioDevice != "auto" {
+		args = append(args, "--audio-device="+cfg.AudioDevice)
+	}
+	cmd := exec.CommandContext(ctx, "mpv", args...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// mpv creates the socket shortly after starting.
+	var conn net.Conn
+	deadline := time.Now().Add(mpvDialTimeout)
+	for {
+		c, err := net.Dial("unix", socket)
+		if err == nil {
+			conn = c
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("mpv: connect %s: %w", socket, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	m := &mpv{socket: socket, cmd: cmd, conn: conn, pending: make(map[int]chan mpvReply)}
+	go m.readLoop(events)
+	return m, nil
+}
+
+// readLoop dispatches replies to waiting requests and forwards events until
+// the connection closes, then reaps the process.
+func (m *mpv) readLoop(events chan<- mpvEvent) {
+	sc := bufio.NewScanner(m.conn)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		var probe struct {
+			Event     string `json:"event"`
+			RequestID *int   `json:"request_id"`
+		}
+		if json.Unmarshal(line, &probe) != nil {
+			continue
+		}
+		switch {
+		case probe.Event == "end-file":
+			ev := mpvEvent{deck: m}
+			if json.Unmarshal(line, &ev) == nil {
+				ev.deck = m
+				events <- ev
+			}
+		case probe.Event == "" && probe.RequestID != nil:
+			var r mpvReply
+			if json.Unmarshal(line, &r) != nil {
+				continue
+			}
+			m.mu.Lock()
+			ch := m.pending[r.RequestID]
+			delete(m.pending, r.RequestID)
+			m.mu.Unlock()
+			if ch != nil {
+				ch <- r
+			}
+		}
+	}
+	m.conn.Close()
+	m.cmd.Wait()
+	events <- mpvEvent{deck: m, Event: "_exit"}
+}
+
+// request sends one IPC command and waits for its reply.
+func (m *mpv) request(args ...any) (json.RawMessage, error) {
+	return m.send(args, args[0])
+}
+
+// send sends command (positional args, or a map of named args) and waits
+// for its reply. name is used in errors.
+func (m *mpv) send(command, name any) (json.RawMessage, error) {
+	ch := make(chan mpvReply, 1)
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	m.pending[id] = ch
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, id)
+		m.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(map[string]any{"command": command, "request_id": id})
+	if err != nil {
+		return nil, err
+	}
+	m.writeMu.Lock()
+	m.conn.SetWriteDeadline(time.Now().Add(mpvRequestTimeout))
+	_, err = m.conn.Write(append(data, '\n'))
+	m.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-ch:
+		if r.Error != "success" {
+			return nil, fmt.Errorf("mpv %v: %s", name, r.Error)
+		}
+		return r.Data, nil
+	case <-time.After(mpvRequestTimeout):
+		return nil, fmt.Errorf("mpv %v: timeout", name)
+	}
+}
+
+// command sends one IPC command, discarding any reply data.
+func (m *mpv) command(args ...any) error {
+	_, err := m.request(args...)
+	return err
+}
+
+// loadfile loads path ("replace" or "append" flags) with gain dB applied to
+// that file only. Named arguments keep this working across mpv versions
+// (0.38 inserted an index argument before the per-file options).
+func (m *mpv) loadfile(path, flags string, gain float64) error {
+	cmd := map[string]any{"name": "loadfile", "url": path, "flags": flags}
+	if gain != 0 {
+		cmd["options"] = map[string]string{"af": fmt.Sprintf("lavfi=[volume=%.2fdB]", gain)}
+	}
+	_, err := m.send(cmd, "loadfile")
+	return err
+}
+
+// float reads a numeric property. ok is false if the property is
+// unavailable (e.g. time-pos while idle).
+func (m *mpv) float(name string) (v float64, ok bool) {
+	data, err := m.request("get_property", name)
+	if err != nil || json.Unmarshal(data, &v) != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// close asks mpv to quit and closes the connection.
+func (m *mpv) close() {
+	m.command("quit")
+	m.conn.Close()
+}
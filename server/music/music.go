@@ -8,6 +8,9 @@ import "github.com/vincent99/velocipi/server/config"
 // without importing config directly everywhere.
 type MusicConfig = config.MusicConfig
 
+// ZoneConfig is an alias of config.ZoneConfig.
+type ZoneConfig = config.ZoneConfig
+
 // Broadcaster is satisfied by *hub.Hub in the main package, allowing the
 // music player to broadcast WebSocket messages without an import cycle.
 type Broadcaster interface {
@@ -18,16 +21,18 @@ type Broadcaster interface {
 // hub.go can dispatch inbound musicControl WebSocket messages without
 // importing concrete player types.
 type PlayerController interface {
+	// Control routes msg to the zone it names (the default zone if none).
 	Control(msg ControlMsg)
-	// StateMsg returns the current player state as a MusicStateMsg ready for broadcast.
-	StateMsg() MusicStateMsg
-	// QueueMsg returns the full queue snapshot as a MusicQueueMsg ready for broadcast.
-	QueueMsg() MusicQueueMsg
+	// StateMsgs returns every zone's player state as a MusicStateMsg ready for broadcast.
+	StateMsgs() []MusicStateMsg
+	// QueueMsgs returns every zone's queue snapshot as a MusicQueueMsg ready for broadcast.
+	QueueMsgs() []MusicQueueMsg
 }
 
 // ControlMsg carries a player control action from a WebSocket client.
 type ControlMsg struct {
-	Action string  // play|pause|stop|next|prev|seek|skipForward|skipBack|setVolume|setShuffle|setRepeat
-	Value  float64 // seek: absolute sec; skipForward/skipBack: delta sec; setVolume: 0-100
+	Action string  // play|pause|stop|next|prev|seek|skipForward|skipBack|setVolume|adjustVolume|setShuffle|setRepeat
+	Value  float64 // seek: absolute sec; skipForward/skipBack: delta sec; setVolume: 0-100; adjustVolume: delta
 	Str    string  // setRepeat: "off"|"song"|"queue"
+	Zone   string  // zone ID; "" = the default zone
 }
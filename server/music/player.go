@@ -2,17 +2,24 @@ package music
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
-	"net"
+	"math/rand"
 	"os"
 	"os/exec"
+	"slices"
 	"sync"
 	"time"
 )
 
-const mpvSocket = "/tmp/velocipi-mpv.sock"
+const mpvPollInterval = 100 * time.Millisecond // position polling and crossfade ramp resolution
+
+// Track transition modes (MusicConfig.Transition).
+const (
+	TransitionGapless   = "gapless"
+	TransitionCrossfade = "crossfade"
+)
 
 // PlaybackStatus represents the current playback state.
 type PlaybackStatus string
@@ -23,31 +30,63 @@ const (
 	StatusPaused  PlaybackStatus = "paused"
 )
 
-// Player manages mpv and the playback queue.
+// Player manages mpv and the playback queue for one zone.
 type Player struct {
 	mu          sync.Mutex
 	db          *DB
-	cfg         MusicConfig
+	cfg         MusicConfig // with the zone's audio device and volume applied
+	zone        ZoneConfig
+	legacyState bool // first zone: fall back to the pre-zone "player.*" state keys
 	queue       *Queue
 	broadcaster Broadcaster
 
 	status        PlaybackStatus
 	elapsedSec    float64
-	currentSongID int64   // ID of the song currently loaded into mpv (0 = none)
-	currentLength float64 // duration in seconds of the current song
+	currentSongID int64      // ID of the song currently loaded into mpv (0 = none)
+	currentLength float64    // duration in seconds of the current song
+	ducked        string     // "volume" or "paused" while ducked for radio traffic, "" otherwise
+	autoDJ        bool       // keep the queue filled from autoDJSeed
+	autoDJSeed    AutoDJSeed // what auto-DJ picks from
 
 	controlCh chan ControlMsg
+	reconfig  *zoneUpdate   // config change waiting for the Run goroutine
+	reconfigC chan struct{} // signals Run that reconfig is set
+
+	// Owned by the Run goroutine.
+	decks      []*mpv               // one for gapless playback, two for crossfade
+	active     int                  // index into decks of the one playing currentSongID
+	loaded     bool                 // a song is loaded on the active deck
+	nextSongID int64                // song appended to mpv's playlist after the current one (0 = none)
+	fade       *crossfade           // in-progress crossfade, nil if none
+	events     chan mpvEvent        // end-file and exit events from every deck
+	duckUntil  map[string]time.Time // duck source → when its duck ends
+	duckGain   float64              // volume multiplier, ramped toward the duck target
+	duckPaused bool                 // mpv is paused for a duck (status unchanged)
+	play       *playEvent           // play being recorded, nil if none
+	flight     func() string        // current flight ID for play history (nil = none)
+	session    string               // this server run, recorded with each play
+	rnd        *rand.Rand           // auto-DJ picks
+	djMiss     autoDJKey            // queue and seed when auto-DJ last found nothing to add
+	djMissAt   time.Time            // when; zero once a top-up succeeds
 }
 
-// NewPlayer creates a Player. Call Run(ctx) in a goroutine to start it.
-func NewPlayer(db *DB, cfg MusicConfig, bc Broadcaster) *Player {
+// newPlayer creates the Player for zone. Call Run(ctx) in a goroutine to
+// start it.
+func newPlayer(db *DB, cfg MusicConfig, zone ZoneConfig, legacyState bool, bc Broadcaster) *Player {
+	cfg.AudioDevice = zone.AudioDevice
+	cfg.Volume = zone.Volume
 	p := &Player{
 		db:          db,
 		cfg:         cfg,
+		zone:        zone,
+		legacyState: legacyState,
 		queue:       NewQueue(),
 		broadcaster: bc,
 		status:      StatusStopped,
 		controlCh:   make(chan ControlMsg, 16),
+		reconfigC:   make(chan struct{}, 1),
+		duckGain:    1,
+		session:     time.Now().Format("20060102-150405"),
 	}
 	p.restore()
 	return p
@@ -61,22 +100,99 @@ func (p *Player) Control(msg ControlMsg) {
 	}
 }
 
+// zoneUpdate is a config change for a running player.
+type zoneUpdate struct {
+	cfg  MusicConfig
+	zone ZoneConfig
+}
+
+// reconfigure hands the Run goroutine new settings for this zone. Only the
+// latest change is kept if it's slow to pick them up.
+func (p *Player) reconfigure(cfg MusicConfig, zone ZoneConfig) {
+	p.mu.Lock()
+	p.reconfig = &zoneUpdate{cfg: cfg, zone: zone}
+	p.mu.Unlock()
+	select {
+	case p.reconfigC <- struct{}{}:
+	default:
+	}
+}
+
+// applyReconfig applies a pending config change: the player settings read
+// during playback, and the zone's name, output device, volume and duck
+// setting. The listener's volume is kept unless the configured volume
+// itself changed.
+func (p *Player) applyReconfig() {
+	p.mu.Lock()
+	u := p.reconfig
+	p.reconfig = nil
+	if u == nil {
+		p.mu.Unlock()
+		return
+	}
+	old := p.zone
+	cfg := u.cfg
+	cfg.AudioDevice = u.zone.AudioDevice
+	cfg.Volume = p.cfg.Volume
+	if u.zone.Volume != old.Volume {
+		cfg.Volume = u.zone.Volume
+	}
+	volumeChanged := cfg.Volume != p.cfg.Volume
+	p.cfg = cfg
+	p.zone.Name = u.zone.Name
+	p.zone.AudioDevice = u.zone.AudioDevice
+	p.zone.Volume = u.zone.Volume
+	p.zone.IgnoreDuck = u.zone.IgnoreDuck
+	p.mu.Unlock()
+
+	if u.zone.AudioDevice != old.AudioDevice {
+		dev := u.zone.AudioDevice
+		if dev == "" {
+			dev = "auto"
+		}
+		for _, d := range p.decks {
+			if err := d.command("set_property", "audio-device", dev); err != nil {
+				log.Println("music: mpv set audio-device:", err)
+			}
+		}
+	}
+	if volumeChanged {
+		if p.fade == nil && len(p.decks) > 0 {
+			if err := p.deck().command("set_property", "volume", p.volume()); err != nil {
+				log.Println("music: mpv setVolume:", err)
+			}
+		}
+		p.db.SetState(p.stateKey("volume"), cfg.Volume)
+	}
+	p.broadcast()
+}
+
 // StateMsg returns a snapshot suitable for WebSocket broadcast.
 func (p *Player) StateMsg() MusicStateMsg {
 	p.mu.Lock()
 	status := string(p.status)
 	elapsed := p.elapsedSec
+	ducked := p.ducked
+	autoDJ, seed := p.autoDJ, p.autoDJSeed
+	volume := p.cfg.Volume
+	zoneName := p.zone.Name
 	p.mu.Unlock()
 
 	qs := p.queue.State()
 	msg := MusicStateMsg{
 		Type:        "musicState",
+		Zone:        p.zone.ID,
+		ZoneName:    zoneName,
+		Volume:      volume,
 		QueueIndex:  qs.CurrentIndex,
 		Status:      status,
 		Shuffle:     qs.Shuffle,
 		Repeat:      string(qs.Repeat),
 		ElapsedSec:  elapsed,
 		QueueLength: len(qs.Entries),
+		Ducked:      ducked,
+		AutoDJ:      autoDJ,
+		AutoDJSeed:  seed,
 	}
 	if len(qs.Entries) > 0 && qs.CurrentIndex >= 0 && qs.CurrentIndex < len(qs.Entries) {
 		id := qs.Entries[qs.CurrentIndex].SongID
@@ -91,6 +207,12 @@ func (p *Player) QueueSnapshot() QueueState {
 }
 
 // Run is the main player loop. It blocks until ctx is cancelled.
+//
+// One long-lived mpv instance (a "deck") plays the whole session. In
+// gapless mode the next queue entry is appended to mpv's playlist as soon as
+// the current one starts, so mpv moves on without a gap. In crossfade mode a
+// second deck loads the next entry CrossfadeSec before the current one ends
+// and the two volumes are ramped across.
 func (p *Player) Run(ctx context.Context) {
 	if _, err := exec.LookPath("mpv"); err != nil {
 		log.Println("music: mpv not found in PATH — audio playback disabled")
@@ -98,127 +220,73 @@ func (p *Player) Run(ctx context.Context) {
 		return
 	}
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	// cancelMpv cancels the context passed to the currently running mpv goroutine.
-	var cancelMpv context.CancelFunc
-	// mpvDone receives the error (or nil) when mpv exits.
-	var mpvDone chan error
-
-	// startCurrent launches mpv for the song at the current queue position.
-	// If no song is available, stops playback.
-	startCurrent := func() {
-		songID, currentIdx, ok := p.queue.Current()
-		if !ok {
-			if cancelMpv != nil {
-				cancelMpv()
-				cancelMpv = nil
-				mpvDone = nil
-			}
-			p.mu.Lock()
-			p.status = StatusStopped
-			p.elapsedSec = 0
-			p.currentSongID = 0
-			p.currentLength = 0
-			p.mu.Unlock()
-			p.saveState()
-			p.broadcast()
-			return
-		}
-
-		var path string
-		var length float64
-		err := p.db.db.QueryRow(`SELECT path, length FROM song WHERE id=? AND deleted IS NULL`, songID).Scan(&path, &length)
-		if err != nil || func() bool { _, e := os.Stat(path); return e != nil }() {
-			log.Printf("music: song %d unavailable — removing from queue: %v", songID, err)
-			p.queue.RemoveAt(currentIdx)
-			p.saveState()
-			p.broadcast()
-			p.broadcastQueue()
-			if p.queue.Len() > 0 {
-				// Recurse via controlCh to avoid stack overflow on long runs of bad files.
-				p.controlCh <- ControlMsg{Action: "_startCurrent"}
-			} else {
-				p.mu.Lock()
-				p.status = StatusStopped
-				p.elapsedSec = 0
-				p.currentSongID = 0
-				p.currentLength = 0
-				p.mu.Unlock()
-				p.saveState()
-				p.broadcast()
+	crossfading := p.cfg.Transition == TransitionCrossfade && p.cfg.CrossfadeSec > 0
+	sockets := []string{mpvSocketPath(p.zone.ID, "")}
+	if crossfading {
+		sockets = append(sockets, mpvSocketPath(p.zone.ID, "-b"))
+	}
+	p.events = make(chan mpvEvent, 16)
+	for _, sock := range sockets {
+		d, err := startMpv(ctx, sock, p.cfg, !crossfading, p.events)
+		if err != nil {
+			log.Println("music: start mpv:", err, "— audio playback disabled")
+			for _, d := range p.decks {
+				d.close()
 			}
+			<-ctx.Done()
 			return
 		}
-
-		if cancelMpv != nil {
-			cancelMpv()
-		}
-		mpvCtx, cancel := context.WithCancel(ctx)
-		cancelMpv = cancel
-		done := make(chan error, 1)
-		mpvDone = done
-
-		p.mu.Lock()
-		p.status = StatusPlaying
-		p.elapsedSec = 0
-		p.currentSongID = songID
-		p.currentLength = length
-		p.mu.Unlock()
-		p.saveState()
-		p.broadcast()
-
-		go func() {
-			done <- runMpv(mpvCtx, path, p.cfg.Volume, p.cfg.AudioDevice)
-		}()
+		p.decks = append(p.decks, d)
 	}
-
-	// killMpv stops the running mpv process and clears state.
-	killMpv := func() {
-		if cancelMpv != nil {
-			cancelMpv()
-			cancelMpv = nil
-			mpvDone = nil
+	defer func() {
+		for _, d := range p.decks {
+			d.close()
 		}
-	}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	poll := time.NewTicker(mpvPollInterval)
+	defer poll.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			killMpv()
+			p.endPlay(false)
 			p.saveState()
 			return
 
-		case err := <-mpvDone:
-			mpvDone = nil
-			cancelMpv = nil
-			if err != nil && ctx.Err() == nil {
-				log.Println("music: mpv exited:", err)
-			}
-			// Song played to natural end — count as a full play.
-			p.mu.Lock()
-			finishedID := p.currentSongID
-			p.mu.Unlock()
-			p.incrementPlays(finishedID)
-			if _, ok := p.queue.Advance(); ok {
-				p.broadcastQueue()
-				startCurrent()
-			} else {
+		case ev := <-p.events:
+			switch {
+			case ev.Event == "_exit":
+				if ctx.Err() != nil {
+					break
+				}
+				p.restartDeck(ctx, ev.deck, !crossfading)
+			case ev.deck != p.deck():
+				// The outgoing deck of a crossfade finishing; nothing to do.
+			case ev.Reason == "eof":
+				// Song played to natural end — count as a full play.
+				p.finishCurrent(true)
+			case ev.Reason == "error":
 				p.mu.Lock()
-				p.status = StatusStopped
-				p.elapsedSec = 0
-				p.currentSongID = 0
-				p.currentLength = 0
+				failedID := p.currentSongID
 				p.mu.Unlock()
-				p.saveState()
-				p.broadcast()
+				log.Printf("music: mpv could not play song %d: %s", failedID, ev.Error)
+				p.play = nil // not a play or a skip
+				p.finishCurrent(false)
 			}
 
 		case msg := <-p.controlCh:
 			switch msg.Action {
 			case "_startCurrent":
-				startCurrent()
+				p.startCurrent()
+
+			case "_queueChanged":
+				p.preloadNext()
+
+			case "_duck":
+				p.setDuck(msg.Str, msg.Value != 0, time.Now())
 
 			case "play":
 				p.mu.Lock()
@@ -228,10 +296,13 @@ func (p *Player) Run(ctx context.Context) {
 				if isPlaying {
 					break
 				}
-				if isPaused && cancelMpv != nil {
-					// Resume paused mpv.
-					if err := mpvCommand(`{ "command": ["set_property", "pause", false] }`); err != nil {
-						log.Println("music: mpv resume:", err)
+				if isPaused && p.loaded {
+					// Resume paused mpv (unless a duck is holding it paused;
+					// it resumes when the duck ends).
+					if !p.duckPaused {
+						if err := p.deck().command("set_property", "pause", false); err != nil {
+							log.Println("music: mpv resume:", err)
+						}
 					}
 					p.mu.Lock()
 					p.status = StatusPlaying
@@ -239,23 +310,25 @@ func (p *Player) Run(ctx context.Context) {
 					p.saveState()
 					p.broadcast()
 				} else {
-					startCurrent()
+					p.startCurrent()
 				}
 
 			case "pause":
+				p.finishFade()
 				p.mu.Lock()
 				if p.status == StatusPlaying {
 					p.status = StatusPaused
 				}
 				p.mu.Unlock()
-				if err := mpvCommand(`{ "command": ["set_property", "pause", true] }`); err != nil {
+				if err := p.deck().command("set_property", "pause", true); err != nil {
 					log.Println("music: mpv pause:", err)
 				}
 				p.saveState()
 				p.broadcast()
 
 			case "stop":
-				killMpv()
+				p.endPlay(false)
+				p.unload()
 				p.mu.Lock()
 				p.status = StatusStopped
 				p.elapsedSec = 0
@@ -265,99 +338,106 @@ func (p *Player) Run(ctx context.Context) {
 
 			case "next":
 				p.countPlayIfHalfway()
-				killMpv()
+				p.fillAutoDJ()
 				if _, ok := p.queue.Advance(); ok {
 					p.broadcastQueue()
-					startCurrent()
+					p.startCurrent()
 				} else {
-					p.mu.Lock()
-					p.status = StatusStopped
-					p.elapsedSec = 0
-					p.currentSongID = 0
-					p.currentLength = 0
-					p.mu.Unlock()
-					p.saveState()
-					p.broadcast()
+					p.unload()
+					p.stopped()
 				}
 
 			case "prev":
 				p.countPlayIfHalfway()
-				killMpv()
 				if _, ok := p.queue.Prev(); ok {
 					p.broadcastQueue()
-					startCurrent()
+					p.startCurrent()
 				}
 
 			case "jumpToIndex":
 				p.countPlayIfHalfway()
 				if p.queue.JumpTo(int(msg.Value)) {
-					killMpv()
-					p.mu.Lock()
-					p.elapsedSec = 0
-					p.mu.Unlock()
 					p.broadcastQueue()
-					startCurrent()
-					p.saveState()
-					p.broadcast()
+					p.startCurrent()
 				}
 
 			case "seek":
+				p.finishFade()
 				p.mu.Lock()
 				p.elapsedSec = msg.Value
 				p.mu.Unlock()
-				cmd := fmt.Sprintf(`{ "command": ["seek", %.3f, "absolute"] }`, msg.Value)
-				if err := mpvCommand(cmd); err != nil {
+				if err := p.deck().command("seek", msg.Value, "absolute"); err != nil {
 					log.Println("music: mpv seek:", err)
 				}
 				p.saveState()
 				p.broadcast()
 
 			case "skipForward":
+				p.finishFade()
 				p.mu.Lock()
 				p.elapsedSec += msg.Value
 				p.mu.Unlock()
-				cmd := fmt.Sprintf(`{ "command": ["seek", %.3f, "relative"] }`, msg.Value)
-				if err := mpvCommand(cmd); err != nil {
+				if err := p.deck().command("seek", msg.Value, "relative"); err != nil {
 					log.Println("music: mpv skipForward:", err)
 				}
 				p.saveState()
 				p.broadcast()
 
 			case "skipBack":
+				p.finishFade()
 				p.mu.Lock()
 				p.elapsedSec -= msg.Value
 				if p.elapsedSec < 0 {
 					p.elapsedSec = 0
 				}
 				p.mu.Unlock()
-				cmd := fmt.Sprintf(`{ "command": ["seek", %.3f, "relative"] }`, -msg.Value)
-				if err := mpvCommand(cmd); err != nil {
+				if err := p.deck().command("seek", -msg.Value, "relative"); err != nil {
 					log.Println("music: mpv skipBack:", err)
 				}
 				p.saveState()
 				p.broadcast()
 
-			case "setVolume":
+			case "setVolume", "adjustVolume":
+				p.mu.Lock()
+				if msg.Action == "adjustVolume" {
+					msg.Value = max(0, min(100, float64(p.cfg.Volume)+msg.Value))
+				}
 				p.cfg.Volume = int(msg.Value)
-				cmd := fmt.Sprintf(`{ "command": ["set_property", "volume", %.0f] }`, msg.Value)
-				if err := mpvCommand(cmd); err != nil {
-					log.Println("music: mpv setVolume:", err)
+				p.mu.Unlock()
+				if p.fade == nil {
+					if err := p.deck().command("set_property", "volume", p.volume()); err != nil {
+						log.Println("music: mpv setVolume:", err)
+					}
 				}
+				p.db.SetState(p.stateKey("volume"), int(msg.Value))
+				p.broadcast()
 
 			case "setShuffle":
 				on := msg.Str == "true"
 				p.queue.SetShuffle(on)
+				p.preloadNext()
 				p.saveState()
 				p.broadcast()
 				p.broadcastQueue()
 
 			case "setRepeat":
 				p.queue.SetRepeat(RepeatMode(msg.Str))
+				p.preloadNext()
 				p.saveState()
 				p.broadcast()
+				if p.fillAutoDJ() {
+					p.preloadNext()
+				}
+
+			case "setAutoDJ":
+				p.setAutoDJ(msg.Str == "true")
+
+			case "setAutoDJSeed":
+				p.setAutoDJSeed(msg.Str)
 
 			case "undoQueueChange":
 				if p.queue.UndoChange() {
+					p.preloadNext()
 					p.broadcastQueue()
 					p.saveState()
 					p.broadcast()
@@ -369,14 +449,14 @@ func (p *Player) Run(ctx context.Context) {
 					p.queue.Replace(ids)
 				}
 				p.broadcastQueue()
-				killMpv()
-				startCurrent()
+				p.startCurrent()
 
 			case "enqueue":
 				var ids []int64
 				if err := json.Unmarshal([]byte(msg.Str), &ids); err == nil {
 					p.queue.EnqueueAfterCurrent(ids)
 				}
+				p.preloadNext()
 				p.saveState()
 				p.broadcast()
 				p.broadcastQueue()
@@ -386,6 +466,7 @@ func (p *Player) Run(ctx context.Context) {
 				if err := json.Unmarshal([]byte(msg.Str), &ids); err == nil {
 					p.queue.Append(ids)
 				}
+				p.preloadNext()
 				p.saveState()
 				p.broadcast()
 				p.broadcastQueue()
@@ -398,26 +479,318 @@ func (p *Player) Run(ctx context.Context) {
 				if err := json.Unmarshal([]byte(msg.Str), &payload); err == nil {
 					p.queue.InsertAt(payload.Index, payload.IDs)
 				}
+				p.preloadNext()
 				p.saveState()
 				p.broadcast()
 				p.broadcastQueue()
 			}
 
-		case <-ticker.C:
+		case <-p.reconfigC:
+			p.applyReconfig()
+
+		case <-poll.C:
+			p.applyDuck(time.Now())
 			p.mu.Lock()
-			if p.status == StatusPlaying {
-				p.elapsedSec++
+			playing := p.status == StatusPlaying && p.loaded
+			p.mu.Unlock()
+			if !playing {
+				break
 			}
+			if pos, ok := p.deck().float("time-pos"); ok {
+				p.mu.Lock()
+				p.elapsedSec = pos
+				p.mu.Unlock()
+			}
+			if p.fade != nil {
+				p.stepFade()
+			} else if crossfading && !p.duckPaused {
+				if left, ok := p.deck().float("time-remaining"); ok && left > 0 && left <= p.cfg.CrossfadeSec {
+					p.beginFade(left)
+				}
+			}
+
+		case <-ticker.C:
+			p.mu.Lock()
 			elapsed := p.elapsedSec
+			playing := p.status == StatusPlaying
 			p.mu.Unlock()
+			if playing && p.fillAutoDJ() {
+				p.preloadNext()
+			}
 			p.broadcast()
 			if int(elapsed)%5 == 0 {
-				p.db.SetState("player.elapsedSec", elapsed)
+				p.db.SetState(p.stateKey("elapsedSec"), elapsed)
 			}
 		}
 	}
 }
 
+// The methods below drive mpv and are only called from the Run goroutine.
+
+// mpvSocketPath returns the IPC socket for one of a zone's decks.
+func mpvSocketPath(zone, deck string) string {
+	return "/tmp/velocipi-mpv-" + zone + deck + ".sock"
+}
+
+// stateKey returns the state table key for one of this zone's settings.
+func (p *Player) stateKey(name string) string {
+	return "player." + p.zone.ID + "." + name
+}
+
+// deck returns the mpv instance playing the current song.
+func (p *Player) deck() *mpv {
+	return p.decks[p.active]
+}
+
+// songFile looks up a playable song's path, length and ReplayGain
+// adjustment (dB) for the configured mode.
+func (p *Player) songFile(songID int64) (path string, length, gain float64, err error) {
+	var trackGain, trackPeak, albumGain, albumPeak sql.NullFloat64
+	err = p.db.db.QueryRow(`SELECT path, length, trackGain, trackPeak, albumGain, albumPeak FROM song WHERE id=? AND deleted IS NULL`, songID).
+		Scan(&path, &length, &trackGain, &trackPeak, &albumGain, &albumPeak)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	gain = playbackGain(p.cfg.ReplayGain, p.cfg.ReplayGainPreamp, trackGain, trackPeak, albumGain, albumPeak)
+	return path, length, gain, err
+}
+
+// startCurrent loads the song at the current queue position onto the active
+// deck, replacing whatever was playing. If no song is available, stops
+// playback.
+func (p *Player) startCurrent() {
+	p.finishFade()
+	songID, currentIdx, ok := p.queue.Current()
+	if !ok {
+		p.unload()
+		p.stopped()
+		return
+	}
+
+	path, length, gain, err := p.songFile(songID)
+	if err != nil {
+		log.Printf("music: song %d unavailable — removing from queue: %v", songID, err)
+		p.queue.RemoveAt(currentIdx)
+		p.saveState()
+		p.broadcast()
+		p.broadcastQueue()
+		if p.queue.Len() > 0 {
+			// Recurse via controlCh to avoid stack overflow on long runs of bad files.
+			p.controlCh <- ControlMsg{Action: "_startCurrent"}
+		} else {
+			p.unload()
+			p.stopped()
+		}
+		return
+	}
+
+	d := p.deck()
+	d.command("set_property", "volume", p.volume())
+	d.command("set_property", "pause", p.duckPaused)
+	if err := d.loadfile(path, "replace", gain); err != nil {
+		log.Printf("music: mpv load song %d: %v", songID, err)
+	}
+	p.loaded = true
+	p.nextSongID = 0
+
+	p.playing(songID, length)
+	p.preloadNext()
+}
+
+// playing records songID as the song now playing from the start.
+func (p *Player) playing(songID int64, length float64) {
+	p.beginPlay(songID)
+	p.mu.Lock()
+	p.status = StatusPlaying
+	p.elapsedSec = 0
+	p.currentSongID = songID
+	p.currentLength = length
+	p.mu.Unlock()
+	p.saveState()
+	p.broadcast()
+}
+
+// stopped clears the current song after the queue runs out.
+func (p *Player) stopped() {
+	p.endPlay(false)
+	p.mu.Lock()
+	p.status = StatusStopped
+	p.elapsedSec = 0
+	p.currentSongID = 0
+	p.currentLength = 0
+	p.mu.Unlock()
+	p.saveState()
+	p.broadcast()
+}
+
+// unload stops every deck and clears mpv's playlist.
+func (p *Player) unload() {
+	p.finishFade()
+	if err := p.deck().command("stop"); err != nil {
+		log.Println("music: mpv stop:", err)
+	}
+	p.loaded = false
+	p.nextSongID = 0
+}
+
+// finishCurrent handles the active deck reaching the end of the current
+// song: counts the play, advances the queue and either adopts the song mpv
+// has already moved on to (gapless) or loads the next one.
+func (p *Player) finishCurrent(countPlay bool) {
+	p.mu.Lock()
+	finishedID := p.currentSongID
+	p.mu.Unlock()
+	if countPlay {
+		p.incrementPlays(finishedID)
+		p.endPlay(true)
+	}
+
+	p.fillAutoDJ()
+	preloaded := p.nextSongID
+	p.nextSongID = 0
+	songID, ok := p.queue.Advance()
+	if !ok {
+		p.loaded = false
+		p.stopped()
+		return
+	}
+	p.broadcastQueue()
+	if preloaded == 0 || songID != preloaded {
+		p.startCurrent()
+		return
+	}
+	_, length, _, _ := p.songFile(songID)
+	p.playing(songID, length)
+	p.preloadNext()
+}
+
+// preloadNext appends the song after the current one to mpv's playlist so
+// it plays without a gap. Anything previously preloaded is dropped first, so
+// this is safe to call after any queue change. No-op in crossfade mode.
+func (p *Player) preloadNext() {
+	if len(p.decks) != 1 || !p.loaded {
+		return
+	}
+	songID, ok := p.queue.Peek()
+	if ok && songID == p.nextSongID {
+		return
+	}
+	d := p.deck()
+	if p.nextSongID != 0 {
+		// Removes every playlist entry except the one playing.
+		if err := d.command("playlist-clear"); err != nil {
+			log.Println("music: mpv playlist-clear:", err)
+		}
+		p.nextSongID = 0
+	}
+	if !ok {
+		return
+	}
+	path, _, gain, err := p.songFile(songID)
+	if err != nil {
+		// startCurrent drops it from the queue when it's reached.
+		return
+	}
+	if err := d.loadfile(path, "append", gain); err != nil {
+		log.Printf("music: mpv preload song %d: %v", songID, err)
+		return
+	}
+	p.nextSongID = songID
+}
+
+// crossfade is an in-progress transition from one deck to the other.
+type crossfade struct {
+	from  *mpv
+	start time.Time
+	dur   time.Duration
+}
+
+// beginFade starts the next song on the idle deck and ramps it in over the
+// remaining left seconds of the current one, which counts as fully played.
+// If the next song can't be started now, the current one plays out and
+// finishCurrent takes over.
+func (p *Player) beginFade(left float64) {
+	songID, ok := p.queue.Peek()
+	if !ok {
+		return
+	}
+	path, length, gain, err := p.songFile(songID)
+	if err != nil {
+		return
+	}
+	next := p.decks[1-p.active]
+	next.command("set_property", "volume", 0)
+	next.command("set_property", "pause", false)
+	if err := next.loadfile(path, "replace", gain); err != nil {
+		log.Printf("music: mpv crossfade to song %d: %v", songID, err)
+		return
+	}
+
+	p.mu.Lock()
+	finishedID := p.currentSongID
+	p.mu.Unlock()
+	p.incrementPlays(finishedID)
+	p.endPlay(true)
+	p.queue.Advance()
+	p.broadcastQueue()
+
+	p.fade = &crossfade{from: p.deck(), start: time.Now(), dur: time.Duration(left * float64(time.Second))}
+	p.active = 1 - p.active
+	p.playing(songID, length)
+}
+
+// stepFade moves the crossfade volumes along, finishing it when done.
+func (p *Player) stepFade() {
+	t := float64(time.Since(p.fade.start)) / float64(p.fade.dur)
+	if t >= 1 {
+		p.finishFade()
+		return
+	}
+	vol := p.volume()
+	p.deck().command("set_property", "volume", vol*t)
+	p.fade.from.command("set_property", "volume", vol*(1-t))
+}
+
+// finishFade completes any in-progress crossfade immediately: the outgoing
+// deck is stopped and the incoming one brought to full volume.
+func (p *Player) finishFade() {
+	if p.fade == nil {
+		return
+	}
+	p.fade.from.command("stop")
+	p.fade = nil
+	p.deck().command("set_property", "volume", p.volume())
+}
+
+// restartDeck replaces an mpv instance that exited unexpectedly, restarting
+// the current song if it was playing on it.
+func (p *Player) restartDeck(ctx context.Context, dead *mpv, gapless bool) {
+	i := slices.Index(p.decks, dead)
+	if i < 0 {
+		return
+	}
+	log.Printf("music: mpv on %s exited — restarting", dead.socket)
+	if p.fade != nil && p.fade.from == dead {
+		p.fade = nil
+	}
+	d, err := startMpv(ctx, dead.socket, p.cfg, gapless, p.events)
+	if err != nil {
+		log.Println("music: restart mpv:", err)
+		return
+	}
+	p.decks[i] = d
+	if i == p.active && p.loaded {
+		p.mu.Lock()
+		wasPlaying := p.status == StatusPlaying
+		p.mu.Unlock()
+		p.loaded = false
+		p.nextSongID = 0
+		if wasPlaying {
+			p.startCurrent()
+		}
+	}
+}
+
 // broadcast sends the current state to all WebSocket clients.
 func (p *Player) broadcast() {
 	if p.broadcaster != nil {
@@ -430,6 +803,7 @@ func (p *Player) QueueMsg() MusicQueueMsg {
 	qs := p.queue.State()
 	msg := MusicQueueMsg{
 		Type:         "musicQueue",
+		Zone:         p.zone.ID,
 		CurrentIndex: qs.CurrentIndex,
 		Entries:      make([]MusicQueueEntry, 0, len(qs.Entries)),
 	}
@@ -467,12 +841,12 @@ func (p *Player) saveState() {
 	p.mu.Unlock()
 
 	qs := p.queue.State()
-	p.db.SetState("player.status", string(status))
-	p.db.SetState("player.elapsedSec", elapsed)
-	p.db.SetState("player.shuffle", qs.Shuffle)
-	p.db.SetState("player.repeat", string(qs.Repeat))
-	p.db.SetState("player.queueEntries", qs.Entries)
-	p.db.SetState("player.queueIndex", qs.CurrentIndex)
+	p.db.SetState(p.stateKey("status"), string(status))
+	p.db.SetState(p.stateKey("elapsedSec"), elapsed)
+	p.db.SetState(p.stateKey("shuffle"), qs.Shuffle)
+	p.db.SetState(p.stateKey("repeat"), string(qs.Repeat))
+	p.db.SetState(p.stateKey("queueEntries"), qs.Entries)
+	p.db.SetState(p.stateKey("queueIndex"), qs.CurrentIndex)
 }
 
 // incrementPlays increments the plays counter for the given song.
@@ -493,11 +867,7 @@ func (p *Player) countPlayIfHalfway() {
 	elapsed := p.elapsedSec
 	length := p.currentLength
 	p.mu.Unlock()
-	pct := p.cfg.PlayedRequiredPercent
-	if pct <= 0 {
-		pct = 50
-	}
-	threshold := length * float64(pct) / 100.0
+	threshold := length * float64(p.playedRequiredPercent()) / 100.0
 	if songID > 0 && length > 0 && elapsed >= threshold {
 		p.incrementPlays(songID)
 	}
@@ -511,13 +881,28 @@ func (p *Player) restore() {
 	var repeat string
 	var entries []QueueEntry
 	var queueIndex int
+	var volume *int
 
-	p.db.GetState("player.status", &status)
-	p.db.GetState("player.elapsedSec", &elapsed)
-	p.db.GetState("player.shuffle", &shuffle)
-	p.db.GetState("player.repeat", &repeat)
-	p.db.GetState("player.queueEntries", &entries)
-	p.db.GetState("player.queueIndex", &queueIndex)
+	// GetState leaves dest alone for a missing key, so the zone's own keys
+	// override the pre-zone ones where both exist.
+	get := func(name string, dest any) {
+		if p.legacyState {
+			p.db.GetState("player."+name, dest)
+		}
+		p.db.GetState(p.stateKey(name), dest)
+	}
+	get("status", &status)
+	get("elapsedSec", &elapsed)
+	get("shuffle", &shuffle)
+	get("repeat", &repeat)
+	get("queueEntries", &entries)
+	get("queueIndex", &queueIndex)
+	get("autoDJ", &p.autoDJ)
+	get("autoDJSeed", &p.autoDJSeed)
+	get("volume", &volume)
+	if volume != nil {
+		p.cfg.Volume = *volume
+	}
 
 	if entries == nil {
 		entries = []QueueEntry{}
@@ -540,33 +925,3 @@ func (p *Player) restore() {
 	p.elapsedSec = elapsed
 	p.mu.Unlock()
 }
-
-// runMpv launches mpv for the given file and waits for it to finish.
-func runMpv(ctx context.Context, path string, volume int, audioDevice string) error {
-	args := []string{
-		"--no-video",
-		"--gapless-audio=yes",
-		fmt.Sprintf("--volume=%d", volume),
-		"--input-ipc-server=" + mpvSocket,
-		"--input-terminal=no",
-		"--really-quiet",
-	}
-	if audioDevice != "" && audioDevice != "auto" {
-		args = append(args, "--audio-device="+audioDevice)
-	}
-	args = append(args, path)
-	cmd := exec.CommandContext(ctx, "mpv", args...)
-	return cmd.Run()
-}
-
-// mpvCommand sends a JSON IPC command to the running mpv instance.
-func mpvCommand(jsonCmd string) error {
-	conn, err := net.Dial("unix", mpvSocket)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-	conn.SetDeadline(time.Now().Add(500 * time.Millisecond))
-	_, err = fmt.Fprintf(conn, "%s\n", jsonCmd)
-	return err
-}
@@ -0,0 +1,608 @@
+package music
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// playlistExtensions are the playlist formats import understands.
+var playlistExtensions = map[string]bool{
+	".m3u":  true,
+	".m3u8": true,
+	".pls":  true,
+	".xspf": true,
+}
+
+// PlaylistEntry is one track reference read from a playlist file. Any of
+// the fields may be empty, depending on the format and the writer.
+type PlaylistEntry struct {
+	Location string  `json:"location"`
+	Artist   string  `json:"artist,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Duration float64 `json:"duration,omitempty"` // seconds
+}
+
+// ImportResult reports a playlist import.
+type ImportResult struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Matched   int             `json:"matched"`
+	Unmatched []PlaylistEntry `json:"unmatched"`
+}
+
+// parsePlaylist reads an M3U/M3U8, PLS or XSPF playlist. The format is taken
+// from name's extension, falling back to sniffing the content.
+func parsePlaylist(name string, data []byte) ([]PlaylistEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	trimmed := bytes.TrimSpace(data)
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".xspf" || bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<playlist")):
+		return parseXSPF(data)
+	case ext == ".pls" || bytes.HasPrefix(bytes.ToLower(trimmed), []byte("[playlist]")):
+		return parsePLS(data), nil
+	}
+	return parseM3U(data), nil
+}
+
+// parseM3U reads a plain or extended M3U playlist. #EXTINF lines give the
+// duration and "Artist - Title" of the entry that follows.
+func parseM3U(data []byte) []PlaylistEntry {
+	var out []PlaylistEntry
+	var pending PlaylistEntry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			dur, display, _ := strings.Cut(info, ",")
+			// Attributes (tvg-id="…") may follow the duration.
+			if f := strings.Fields(dur); len(f) > 0 {
+				pending.Duration, _ = strconv.ParseFloat(f[0], 64)
+				pending.Duration = max(pending.Duration, 0)
+			}
+			pending.Artist, pending.Title = splitArtistTitle(display)
+		case strings.HasPrefix(line, "#"):
+		default:
+			pending.Location = line
+			out = append(out, pending)
+			pending = PlaylistEntry{}
+		}
+	}
+	return out
+}
+
+// parsePLS reads a PLS playlist (FileN=, TitleN=, LengthN=).
+func parsePLS(data []byte) []PlaylistEntry {
+	byNum := map[int]*PlaylistEntry{}
+	var order []int
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		lower := strings.ToLower(key)
+		var field string
+		for _, f := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(lower, f) {
+				field = f
+				break
+			}
+		}
+		n, err := strconv.Atoi(lower[len(field):])
+		if field == "" || err != nil {
+			continue
+		}
+		e := byNum[n]
+		if e == nil {
+			e = &PlaylistEntry{}
+			byNum[n] = e
+			order = append(order, n)
+		}
+		switch field {
+		case "file":
+			e.Location = val
+		case "title":
+			e.Artist, e.Title = splitArtistTitle(val)
+		case "length":
+			if d, err := strconv.ParseFloat(val, 64); err == nil && d > 0 {
+				e.Duration = d
+			}
+		}
+	}
+	var out []PlaylistEntry
+	for _, n := range order {
+		if e := byNum[n]; e.Location != "" {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// xspfPlaylist is the subset of XSPF read and written here.
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string `xml:"location,omitempty"`
+	Creator    string `xml:"creator,omitempty"`
+	Album      string `xml:"album,omitempty"`
+	Title      string `xml:"title,omitempty"`
+	TrackNum   int    `xml:"trackNum,omitempty"`
+	Duration   int64  `xml:"duration,omitempty"` // milliseconds
+	Identifier string `xml:"identifier,omitempty"`
+}
+
+func parseXSPF(data []byte) ([]PlaylistEntry, error) {
+	var pl xspfPlaylist
+	if err := xml.Unmarshal(data, &pl); err != nil {
+		return nil, fmt.Errorf("xspf: %w", err)
+	}
+	out := make([]PlaylistEntry, 0, len(pl.Tracks))
+	for _, t := range pl.Tracks {
+		out = append(out, PlaylistEntry{
+			Location: strings.TrimSpace(t.Location),
+			Artist:   strings.TrimSpace(t.Creator),
+			Title:    strings.TrimSpace(t.Title),
+			Duration: float64(t.Duration) / 1000,
+		})
+	}
+	return out, nil
+}
+
+// splitArtistTitle splits an "Artist - Title" display string. Without a
+// separator the whole string is the title.
+func splitArtistTitle(s string) (artist, title string) {
+	s = strings.TrimSpace(s)
+	if a, t, ok := strings.Cut(s, " - "); ok {
+		return strings.TrimSpace(a), strings.TrimSpace(t)
+	}
+	return "", s
+}
+
+// playlistSong is the song data needed to resolve and export playlists.
+type playlistSong struct {
+	id     int64
+	path   string
+	hash   string
+	artist string
+	album  string
+	title  string
+	track  int
+	length float64
+	mbid   string
+}
+
+// playlistResolver matches playlist entries to songs in the library.
+type playlistResolver struct {
+	musicDir string
+	byPath   map[string]*playlistSong
+	byHash   map[string]*playlistSong
+	byTitle  map[string][]*playlistSong // normalized title
+	songs    []*playlistSong
+}
+
+// newPlaylistResolver loads the library's (non-deleted) songs.
+func (d *DB) newPlaylistResolver(musicDir string) (*playlistResolver, error) {
+	rows, err := d.db.Query(`SELECT id, path, hash, artist, album, title, trackNumber, length, mbid FROM song WHERE deleted IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r := &playlistResolver{
+		musicDir: musicDir,
+		byPath:   map[string]*playlistSong{},
+		byHash:   map[string]*playlistSong{},
+		byTitle:  map[string][]*playlistSong{},
+	}
+	for rows.Next() {
+		s := &playlistSong{}
+		if err := rows.Scan(&s.id, &s.path, &s.hash, &s.artist, &s.album, &s.title, &s.track, &s.length, &s.mbid); err != nil {
+			return nil, err
+		}
+		r.songs = append(r.songs, s)
+		r.byPath[filepath.Clean(s.path)] = s
+		r.byHash[s.hash] = s
+		t := normalizeMatch(s.title)
+		r.byTitle[t] = append(r.byTitle[t], s)
+	}
+	return r, rows.Err()
+}
+
+// resolve finds the song for e: by path (relative to baseDir, the music
+// directory, or as a trailing artist/album/file match), then by the hash
+// of the file it points at, then by fuzzy artist/title (and duration).
+func (r *playlistResolver) resolve(e PlaylistEntry, baseDir string) *playlistSong {
+	loc := e.Location
+	if u, err := url.Parse(loc); err == nil && u.Scheme == "file" {
+		loc = u.Path
+	} else if err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		loc = "" // http:// etc.; only the tags can match
+	} else if p, err := url.PathUnescape(loc); err == nil && strings.Contains(loc, "%") {
+		loc = p
+	}
+	loc = filepath.FromSlash(strings.ReplaceAll(loc, `\`, "/"))
+
+	if loc != "" {
+		var candidates []string
+		if filepath.IsAbs(loc) {
+			candidates = append(candidates, loc)
+		} else {
+			candidates = append(candidates, filepath.Join(baseDir, loc), filepath.Join(r.musicDir, loc))
+		}
+		for _, c := range candidates {
+			if s := r.byPath[filepath.Clean(c)]; s != nil {
+				return s
+			}
+		}
+		// A playlist written on another machine: match the trailing
+		// artist/album/file part of the path.
+		parts := strings.Split(filepath.ToSlash(filepath.Clean(loc)), "/")
+		for n := min(3, len(parts)); n >= 2; n-- {
+			tail := "/" + strings.Join(parts[len(parts)-n:], "/")
+			var found *playlistSong
+			for _, s := range r.songs {
+				if strings.HasSuffix(filepath.ToSlash(s.path), tail) {
+					if found != nil {
+						found = nil // ambiguous
+						break
+					}
+					found = s
+				}
+			}
+			if found != nil {
+				return found
+			}
+		}
+		for _, c := range candidates {
+			if s := r.byHash[r.hashFile(c)]; s != nil {
+				return s
+			}
+		}
+	}
+
+	artist, title := e.Artist, e.Title
+	if title == "" && loc != "" {
+		// Fall back to "Artist - Title.ext" or "NN - Title.ext" file names.
+		base := strings.TrimSuffix(filepath.Base(loc), filepath.Ext(loc))
+		a, t := splitArtistTitle(base)
+		if _, err := strconv.Atoi(a); err == nil {
+			a = ""
+		}
+		artist, title = a, t
+	}
+	return r.fuzzy(artist, title, e.Duration)
+}
+
+// maxHashBytes caps the files resolve will hash; no song is this large.
+const maxHashBytes = 2 << 30
+
+// hashFile returns the hex SHA-256 of path, or "" unless path is a regular
+// file inside the music directory and no larger than maxHashBytes. The
+// paths come from an uploaded playlist, so anything else (an absolute or
+// "../" path elsewhere, a device, a symlink) is never opened.
+func (r *playlistResolver) hashFile(path string) string {
+	rel, err := filepath.Rel(r.musicDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	fi, err := os.Lstat(path)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxHashBytes {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if n, err := io.Copy(h, io.LimitReader(f, maxHashBytes+1)); err != nil || n > maxHashBytes {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fuzzy matches by normalized title, then artist (equal or one containing the
+// other), preferring the closest duration. A known duration more than 10s
+// off rules a song out.
+func (r *playlistResolver) fuzzy(artist, title string, duration float64) *playlistSong {
+	if title == "" {
+		return nil
+	}
+	na := normalizeMatch(artist)
+	var best *playlistSong
+	bestScore := 0.0
+	for _, s := range r.byTitle[normalizeMatch(title)] {
+		score := 1.0
+		if na != "" {
+			sa := normalizeMatch(s.artist)
+			switch {
+			case sa == na:
+				score += 2
+			case sa != "" && (strings.Contains(sa, na) || strings.Contains(na, sa)):
+				score += 1
+			default:
+				continue
+			}
+		}
+		if duration > 0 && s.length > 0 {
+			diff := duration - s.length
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > 10 {
+				continue
+			}
+			score += 1 - diff/10
+		}
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best
+}
+
+var (
+	matchBrackets = regexp.MustCompile(`\s*[\(\[][^\)\]]*[\)\]]`)
+	matchArticle  = regexp.MustCompile(`^(the|a|an) `)
+)
+
+// normalizeMatch reduces an artist or title to a form that survives
+// differences in case, punctuation, "(Remastered)"-style suffixes, leading
+// articles and "&" versus "and".
+func normalizeMatch(s string) string {
+	s = strings.ToLower(matchBrackets.ReplaceAllString(s, ""))
+	s = strings.ReplaceAll(s, "&", " and ")
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case !space && b.Len() > 0:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return matchArticle.ReplaceAllString(strings.TrimSpace(b.String()), "")
+}
+
+// ImportPlaylist resolves the entries of a playlist file and saves them as
+// playlist name, replacing its items if it already exists. baseDir is where
+// relative paths in the file are relative to.
+func (d *DB) ImportPlaylist(musicDir, baseDir, name string, entries []PlaylistEntry) (ImportResult, error) {
+	res := ImportResult{Name: name, Unmatched: []PlaylistEntry{}}
+	resolver, err := d.newPlaylistResolver(musicDir)
+	if err != nil {
+		return res, fmt.Errorf("playlist import: %w", err)
+	}
+	items := []int64{}
+	for _, e := range entries {
+		if s := resolver.resolve(e, baseDir); s != nil {
+			items = append(items, s.id)
+		} else {
+			res.Unmatched = append(res.Unmatched, e)
+		}
+	}
+	res.Matched = len(items)
+	itemsJSON, _ := json.Marshal(items)
+	err = d.db.QueryRow(
+		`INSERT INTO playlist(name, items) VALUES(?, ?) ON CONFLICT(name) DO UPDATE SET items=excluded.items RETURNING id`,
+		name, string(itemsJSON),
+	).Scan(&res.ID)
+	if err != nil {
+		return res, fmt.Errorf("playlist import: %w", err)
+	}
+	return res, nil
+}
+
+// playlistSongs returns playlist id's name and songs in order, skipping
+// deleted songs.
+func (d *DB) playlistSongs(id int64) (string, []playlistSong, error) {
+	var name, itemsJSON string
+	if err := d.db.QueryRow(`SELECT name, items FROM playlist WHERE id=?`, id).Scan(&name, &itemsJSON); err != nil {
+		return "", nil, err
+	}
+	rows, err := d.db.Query(
+		`SELECT song.id, song.path, song.hash, song.artist, song.album, song.title, song.trackNumber, song.length, song.mbid
+		   FROM json_each(?) AS item JOIN song ON song.id = item.value
+		  WHERE song.deleted IS NULL ORDER BY item.key`, itemsJSON)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var songs []playlistSong
+	for rows.Next() {
+		var s playlistSong
+		if err := rows.Scan(&s.id, &s.path, &s.hash, &s.artist, &s.album, &s.title, &s.track, &s.length, &s.mbid); err != nil {
+			return "", nil, err
+		}
+		songs = append(songs, s)
+	}
+	return name, songs, rows.Err()
+}
+
+// exportLocation is a song's path relative to the music directory (forward
+// slashes), so exported playlists work wherever the library is copied.
+func exportLocation(musicDir, path string) string {
+	if rel, err := filepath.Rel(musicDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// writeM3U8 writes an extended M3U playlist (UTF-8).
+func writeM3U8(w io.Writer, musicDir string, songs []playlistSong) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	for _, s := range songs {
+		display := s.title
+		if s.artist != "" {
+			display = s.artist + " - " + s.title
+		}
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n%s\n", int(s.length+0.5), display, exportLocation(musicDir, s.path))
+	}
+	return bw.Flush()
+}
+
+// writeXSPF writes an XSPF playlist.
+func writeXSPF(w io.Writer, musicDir, name string, songs []playlistSong) error {
+	pl := xspfPlaylist{Version: "1", XMLNS: "http://xspf.org/ns/0/", Title: name, Tracks: []xspfTrack{}}
+	for _, s := range songs {
+		loc := (&url.URL{Path: exportLocation(musicDir, s.path)}).EscapedPath()
+		t := xspfTrack{
+			Location: loc,
+			Creator:  s.artist,
+			Album:    s.album,
+			Title:    s.title,
+			TrackNum: s.track,
+			Duration: int64(s.length * 1000),
+		}
+		if s.mbid != "" {
+			t.Identifier = "https://musicbrainz.org/recording/" + s.mbid
+		}
+		pl.Tracks = append(pl.Tracks, t)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(pl)
+}
+
+// handlePlaylistExport handles GET /music/playlists/{id}/export?format=m3u8|xspf.
+func (a *musicAPI) handlePlaylistExport(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "m3u8"
+	}
+	if format != "m3u8" && format != "xspf" {
+		http.Error(w, "format must be m3u8 or xspf", http.StatusBadRequest)
+		return
+	}
+	name, songs, err := a.db.playlistSongs(id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := safeFilename(name) + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "xspf" {
+		w.Header().Set("Content-Type", "application/xspf+xml")
+		err = writeXSPF(w, a.musicDir, name, songs)
+	} else {
+		w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+		err = writeM3U8(w, a.musicDir, songs)
+	}
+	if err != nil {
+		log.Println("music: playlist export:", err)
+	}
+}
+
+// handlePlaylistImport handles POST /music/playlists/import?name=&filename= —
+// the body is an M3U/M3U8, PLS or XSPF file. Relative paths are taken as
+// relative to the music directory. Responds with an ImportResult listing
+// the entries that couldn't be matched.
+func (a *musicAPI) handlePlaylistImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4<<20))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := r.URL.Query().Get("filename")
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if name == "" || name == "." {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	entries, err := parsePlaylist(filename, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "no playlist entries found", http.StatusBadRequest)
+		return
+	}
+	res, err := a.db.ImportPlaylist(a.musicDir, a.musicDir, name, entries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, res)
+}
+
+// importPlaylists imports playlist files found in the music directory during
+// a sync, naming each playlist after its file. A file whose entries all
+// matched is removed afterwards; one with unmatched entries is kept so a
+// later sync (after the missing music is added) can try again.
+func (s *Syncer) importPlaylists(paths []string) {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("music sync: playlist %s: %v", s.relPath(path), err)
+			continue
+		}
+		entries, err := parsePlaylist(path, data)
+		if err != nil {
+			log.Printf("music sync: playlist %s: %v", s.relPath(path), err)
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		res, err := s.db.ImportPlaylist(s.musicDir, filepath.Dir(path), name, entries)
+		if err != nil {
+			log.Printf("music sync: playlist %s: %v", s.relPath(path), err)
+			continue
+		}
+		log.Printf("music sync: imported playlist %q from %s: %d matched, %d unmatched", name, s.relPath(path), res.Matched, len(res.Unmatched))
+		for _, e := range res.Unmatched {
+			log.Printf("music sync:   unmatched: %s (%s - %s)", e.Location, e.Artist, e.Title)
+		}
+		if len(res.Unmatched) == 0 {
+			if err := os.Remove(path); err != nil {
+				log.Printf("music sync: remove imported playlist %s: %v", s.relPath(path), err)
+			}
+		}
+	}
+}
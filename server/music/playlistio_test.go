@@ -0,0 +1,162 @@
+package music
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []PlaylistEntry
+	}{
+		{"mix.m3u8", "#EXTM3U\n#EXTINF:215,Queen - Bohemian Rhapsody\nQ/Queen/A Night at the Opera/11.flac\n\nplain.mp3\n", []PlaylistEntry{
+			{Location: "Q/Queen/A Night at the Opera/11.flac", Artist: "Queen", Title: "Bohemian Rhapsody", Duration: 215},
+			{Location: "plain.mp3"},
+		}},
+		{"mix.pls", "[playlist]\nFile1=a.mp3\nTitle1=Artist - Song\nLength1=-1\nFile2=b.mp3\nNumberOfEntries=2\n", []PlaylistEntry{
+			{Location: "a.mp3", Artist: "Artist", Title: "Song"},
+			{Location: "b.mp3"},
+		}},
+		{"mix.xspf", `<?xml version="1.0"?><playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList>
+			<track><location>file:///music/a.flac</location><creator>X</creator><title>Y</title><duration>61500</duration></track>
+			</trackList></playlist>`, []PlaylistEntry{
+			{Location: "file:///music/a.flac", Artist: "X", Title: "Y", Duration: 61.5},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := parsePlaylist(tt.name, []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d entries, want %d: %+v", tt.name, len(got), len(tt.want), got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s[%d] = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestPlaylistRoundTrip(t *testing.T) {
+	songs := []playlistSong{
+		{path: filepath.Join("music", "Q", "Queen", "Opera", "11.flac"), artist: "Queen", title: "Bohemian Rhapsody", length: 354.3},
+		{path: filepath.Join("music", "B", "Björk", "Post", "01 & 02.mp3"), title: "Army of Me", length: 234},
+	}
+	for _, format := range []string{"m3u8", "xspf"} {
+		var buf bytes.Buffer
+		var err error
+		if format == "xspf" {
+			err = writeXSPF(&buf, "music", "Mix", songs)
+		} else {
+			err = writeM3U8(&buf, "music", songs)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, err := parsePlaylist("mix."+format, buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		r := &playlistResolver{musicDir: "music", byPath: map[string]*playlistSong{}}
+		for i := range songs {
+			r.byPath[filepath.Clean(songs[i].path)] = &songs[i]
+		}
+		for i, e := range got {
+			if s := r.resolve(e, "music"); s != &songs[i] {
+				t.Errorf("%s: entry %+v did not resolve to %s", format, e, songs[i].path)
+			}
+		}
+	}
+}
+
+func TestResolveFuzzy(t *testing.T) {
+	songs := []*playlistSong{
+		{id: 1, path: "music/a.flac", artist: "The Beatles", title: "Let It Be (Remastered 2009)", length: 243},
+		{id: 2, path: "music/b.flac", artist: "Aretha Franklin", title: "Let It Be", length: 211},
+		{id: 3, path: "music/c.flac", artist: "Simon & Garfunkel", title: "The Boxer", length: 308},
+	}
+	r := &playlistResolver{musicDir: "music", byPath: map[string]*playlistSong{}, byHash: map[string]*playlistSong{}, byTitle: map[string][]*playlistSong{}}
+	for _, s := range songs {
+		r.songs = append(r.songs, s)
+		r.byTitle[normalizeMatch(s.title)] = append(r.byTitle[normalizeMatch(s.title)], s)
+	}
+	tests := []struct {
+		e    PlaylistEntry
+		want int64
+	}{
+		{PlaylistEntry{Location: "/elsewhere/x.mp3", Artist: "Beatles", Title: "let it be"}, 1},
+		{PlaylistEntry{Location: "/elsewhere/x.mp3", Title: "Let It Be", Duration: 212}, 2},
+		{PlaylistEntry{Location: "/elsewhere/Simon and Garfunkel - The Boxer.mp3"}, 3},
+		{PlaylistEntry{Location: "/elsewhere/07 - The Boxer.mp3"}, 3},
+		{PlaylistEntry{Location: "http://example.com/stream", Title: "Unknown"}, 0},
+		{PlaylistEntry{Title: "The Boxer", Duration: 200}, 0},
+	}
+	for _, tt := range tests {
+		var got int64
+		if s := r.resolve(tt.e, "music"); s != nil {
+			got = s.id
+		}
+		if got != tt.want {
+			t.Errorf("resolve(%+v) = %d, want %d", tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestResolveHashStaysInMusicDir(t *testing.T) {
+	root := t.TempDir()
+	musicDir := filepath.Join(root, "music")
+	write := func(path, data string) string {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(h[:])
+	}
+	// A renamed copy of song 1 inside the library, and a file outside it
+	// whose hash matches song 2.
+	inside := write(filepath.Join(musicDir, "renamed.flac"), "song one")
+	outside := write(filepath.Join(root, "secret.txt"), "not music")
+	r := &playlistResolver{musicDir: musicDir, byPath: map[string]*playlistSong{}, byTitle: map[string][]*playlistSong{}}
+	r.byHash = map[string]*playlistSong{
+		inside:  {id: 1, path: filepath.Join(musicDir, "a.flac")},
+		outside: {id: 2, path: filepath.Join(musicDir, "b.flac")},
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(musicDir, "link.flac")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		loc  string
+		want int64
+	}{
+		{"renamed.flac", 1},
+		{filepath.Join(musicDir, "renamed.flac"), 1},
+		{filepath.Join(root, "secret.txt"), 0},
+		{"../secret.txt", 0},
+		{"link.flac", 0},
+		{"/dev/zero", 0},
+	}
+	for _, tt := range tests {
+		var got int64
+		if s := r.resolve(PlaylistEntry{Location: tt.loc}, musicDir); s != nil {
+			got = s.id
+		}
+		if got != tt.want {
+			t.Errorf("resolve(%q) = %d, want %d", tt.loc, got, tt.want)
+		}
+	}
+}
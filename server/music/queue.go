@@ -178,6 +178,27 @@ func (q *Queue) Advance() (songID int64, ok bool) {
 	}
 }
 
+// Peek returns the song Advance would move to, without moving. ok is false
+// if playback would stop, or if the next song can't be known in advance
+// (wrapping a shuffled repeat-queue reshuffles it).
+func (q *Queue) Peek() (songID int64, ok bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if len(q.entries) == 0 {
+		return 0, false
+	}
+	cur := min(max(q.currentIndex, 0), len(q.entries)-1)
+	switch {
+	case q.repeat == RepeatSong:
+		return q.entries[cur].SongID, true
+	case cur+1 < len(q.entries):
+		return q.entries[cur+1].SongID, true
+	case q.repeat == RepeatQueue && !q.shuffle:
+		return q.entries[0].SongID, true
+	}
+	return 0, false
+}
+
 // Prev moves to the previous song. Returns (songID, true) if available.
 func (q *Queue) Prev() (songID int64, ok bool) {
 	q.mu.Lock()
@@ -223,6 +244,13 @@ func (q *Queue) Len() int {
 	return len(q.entries)
 }
 
+// Remaining returns the number of entries after the current one.
+func (q *Queue) Remaining() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return max(len(q.entries)-max(q.currentIndex, 0)-1, 0)
+}
+
 // Shuffle returns the current shuffle state.
 func (q *Queue) Shuffle() bool {
 	q.mu.RLock()
@@ -0,0 +1,558 @@
+package music
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Smart searches are stored as a small query language and compiled to a
+// parameterized SQL condition on the song table, e.g.
+//
+//	artist:"Pink Floyd" year>=1970 genre:rock plays<3 -favorite
+//
+// Terms are ANDed by juxtaposition; OR, NOT, "-" and parentheses combine
+// them. A term is one of:
+//
+//	field:value    contains (text), equals (number/bool), range with a..b
+//	field=value    equals (case-insensitive for text); also != < <= > >=
+//	favorite       a bare boolean field is true
+//	"free text"    a bare word or quoted phrase matches title, artist or album
+//
+// Dates (added, updated) accept 2006-01-02 or an age like 30d, 2w, 6m or 1y;
+// added<30d means added within the last 30 days. length accepts seconds or
+// m:ss.
+
+// QueryError is a smart search parse or validation error. Pos is the byte
+// offset into the query where the problem was found.
+type QueryError struct {
+	Pos int    `json:"pos"`
+	Msg string `json:"error"`
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Smart search field kinds.
+const (
+	kindText = iota
+	kindGenre
+	kindNumber
+	kindLength
+	kindDate
+	kindBool
+)
+
+// queryFields maps query field names to song columns.
+var queryFields = map[string]struct {
+	column string
+	kind   int
+}{
+	"title":    {"title", kindText},
+	"artist":   {"artist", kindText},
+	"album":    {"album", kindText},
+	"format":   {"format", kindText},
+	"path":     {"path", kindText},
+	"genre":    {"genre", kindGenre},
+	"year":     {"year", kindNumber},
+	"plays":    {"plays", kindNumber},
+	"bitrate":  {"bitrate", kindNumber},
+	"disc":     {"discNumber", kindNumber},
+	"track":    {"trackNumber", kindNumber},
+	"length":   {"length", kindLength},
+	"added":    {"added", kindDate},
+	"updated":  {"updated", kindDate},
+	"favorite": {"favorite", kindBool},
+	"marked":   {"marked", kindBool},
+}
+
+// CompileQuery parses a smart search query and returns an SQL condition on
+// the song table with its bind arguments. Errors are *QueryError.
+func CompileQuery(q string) (where string, args []any, err error) {
+	return compileQueryAt(q, time.Now())
+}
+
+func compileQueryAt(q string, now time.Time) (string, []any, error) {
+	toks, err := lexQuery(q)
+	if err != nil {
+		return "", nil, err
+	}
+	p := &queryParser{toks: toks, now: now}
+	if p.peek().kind == tokEOF {
+		return "", nil, &QueryError{Pos: 0, Msg: "empty query"}
+	}
+	where, err := p.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return "", nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	return where, p.args, nil
+}
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokMinus
+)
+
+type queryToken struct {
+	kind int
+	pos  int
+	text string
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexQuery splits q into tokens. A "-" is a negation only where a term can
+// start (not directly after an operator, so year>-5 still lexes as a value).
+func lexQuery(q string) ([]queryToken, error) {
+	var toks []queryToken
+	i := 0
+	for i < len(q) {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, queryToken{tokLParen, i, "("})
+			i++
+		case c == ')':
+			toks = append(toks, queryToken{tokRParen, i, ")"})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(q) {
+					return nil, &QueryError{Pos: start, Msg: "unterminated string"}
+				}
+				if q[i] == '\\' && i+1 < len(q) {
+					sb.WriteByte(q[i+1])
+					i += 2
+					continue
+				}
+				if q[i] == c {
+					i++
+					break
+				}
+				sb.WriteByte(q[i])
+				i++
+			}
+			toks = append(toks, queryToken{tokString, start, sb.String()})
+		case strings.IndexByte(":=<>!", c) >= 0:
+			start := i
+			op := string(c)
+			if i+1 < len(q) && q[i+1] == '=' && c != ':' && c != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &QueryError{Pos: start, Msg: `expected "!="`}
+			}
+			i += len(op)
+			toks = append(toks, queryToken{tokOp, start, op})
+		case c == '-' && (len(toks) == 0 || toks[len(toks)-1].kind != tokOp):
+			toks = append(toks, queryToken{tokMinus, i, "-"})
+			i++
+		default:
+			// A value may contain ":" (length>4:30); a field name can't.
+			stop := " \t\n\r()\"':=<>!"
+			if len(toks) > 0 && toks[len(toks)-1].kind == tokOp {
+				stop = " \t\n\r()"
+			}
+			start := i
+			for i < len(q) && !strings.ContainsRune(stop, rune(q[i])) {
+				i++
+			}
+			toks = append(toks, queryToken{tokWord, start, q[start:i]})
+		}
+	}
+	return append(toks, queryToken{tokEOF, len(q), ""}), nil
+}
+
+type queryParser struct {
+	toks []queryToken
+	i    int
+	args []any
+	now  time.Time
+}
+
+func (p *queryParser) peek() queryToken { return p.toks[p.i] }
+
+func (p *queryParser) next() queryToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *queryParser) isKeyword(t queryToken, kw string) bool {
+	return t.kind == tokWord && t.text == kw
+}
+
+// parseOr: and { OR and }
+func (p *queryParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.isKeyword(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+// parseAnd: unary { [AND] unary }
+func (p *queryParser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokEOF || t.kind == tokRParen || p.isKeyword(t, "OR") {
+			return left, nil
+		}
+		if p.isKeyword(t, "AND") {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+}
+
+// parseUnary: (- | NOT) unary | "(" or ")" | term
+func (p *queryParser) parseUnary() (string, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokMinus || p.isKeyword(t, "NOT"):
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + inner, nil
+	case t.kind == tokLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return "", &QueryError{Pos: c.pos, Msg: fmt.Sprintf("expected \")\", found %s", c)}
+		}
+		return inner, nil
+	}
+	return p.parseTerm()
+}
+
+// parseTerm: field op value | boolean field | free text
+func (p *queryParser) parseTerm() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return p.freeText(t.text), nil
+	case tokWord:
+	default:
+		return "", &QueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	if t.text == "AND" || t.text == "OR" {
+		return "", &QueryError{Pos: t.pos, Msg: fmt.Sprintf("%s needs a term on both sides", t.text)}
+	}
+
+	if p.peek().kind != tokOp {
+		if f, ok := queryFields[strings.ToLower(t.text)]; ok && f.kind == kindBool {
+			return f.column + " = 1", nil
+		}
+		return p.freeText(t.text), nil
+	}
+
+	name := strings.ToLower(t.text)
+	f, ok := queryFields[name]
+	if !ok {
+		return "", &QueryError{Pos: t.pos, Msg: fmt.Sprintf("unknown field %q", t.text)}
+	}
+	op := p.next()
+	v := p.next()
+	if v.kind != tokWord && v.kind != tokString {
+		return "", &QueryError{Pos: v.pos, Msg: fmt.Sprintf("expected a value after %s%s, found %s", t.text, op.text, v)}
+	}
+	return p.compare(name, f.column, f.kind, op, v)
+}
+
+// freeText matches a word or phrase in title, artist or album.
+func (p *queryParser) freeText(s string) string {
+	like := "%" + escapeLike(s) + "%"
+	p.args = append(p.args, like, like, like)
+	return `(title LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\')`
+}
+
+// compare compiles one field comparison.
+func (p *queryParser) compare(name, col string, kind int, op, v queryToken) (string, error) {
+	bad := func(msg string, args ...any) error {
+		return &QueryError{Pos: v.pos, Msg: fmt.Sprintf(msg, args...)}
+	}
+	badOp := func() error {
+		return &QueryError{Pos: op.pos, Msg: fmt.Sprintf("%s can't be used with %s", op.text, name)}
+	}
+
+	switch kind {
+	case kindText, kindGenre:
+		var cond string
+		switch op.text {
+		case ":":
+			p.args = append(p.args, "%"+escapeLike(v.text)+"%")
+			cond = `LIKE ? ESCAPE '\'`
+		case "=", "!=":
+			p.args = append(p.args, v.text)
+			cond = "= ? COLLATE NOCASE"
+		default:
+			return "", badOp()
+		}
+		expr := col + " " + cond
+		if kind == kindGenre {
+			expr = "EXISTS (SELECT 1 FROM json_each(song.genre) WHERE value " + cond + ")"
+		}
+		if op.text == "!=" {
+			expr = "NOT " + expr
+		}
+		return expr, nil
+
+	case kindBool:
+		var b bool
+		switch strings.ToLower(v.text) {
+		case "1", "true", "yes":
+			b = true
+		case "0", "false", "no":
+		default:
+			return "", bad("%s is true or false, not %q", name, v.text)
+		}
+		switch op.text {
+		case ":", "=":
+		case "!=":
+			b = !b
+		default:
+			return "", badOp()
+		}
+		if b {
+			return col + " = 1", nil
+		}
+		return col + " = 0", nil
+
+	case kindNumber, kindLength:
+		parse := func(s string) (float64, error) {
+			if kind == kindLength {
+				if m, sec, ok := strings.Cut(s, ":"); ok {
+					mi, err1 := strconv.Atoi(m)
+					si, err2 := strconv.ParseFloat(sec, 64)
+					if err1 == nil && err2 == nil {
+						return float64(mi)*60 + si, nil
+					}
+				}
+			}
+			return strconv.ParseFloat(s, 64)
+		}
+		if lo, hi, ok := strings.Cut(v.text, ".."); ok && op.text == ":" {
+			a, err1 := parse(lo)
+			b, err2 := parse(hi)
+			if err1 != nil || err2 != nil {
+				return "", bad("%s range must be two numbers, like 1970..1979", name)
+			}
+			p.args = append(p.args, a, b)
+			return col + " BETWEEN ? AND ?", nil
+		}
+		n, err := parse(v.text)
+		if err != nil {
+			return "", bad("%s must be a number, not %q", name, v.text)
+		}
+		p.args = append(p.args, n)
+		return col + " " + sqlOp(op.text) + " ?", nil
+
+	case kindDate:
+		if age, ok := parseAge(v.text); ok {
+			// Compare the age: added<30d is "added after now-30d".
+			cutoff := p.now.UTC().Add(-age).Format("2006-01-02 15:04:05")
+			flip := map[string]string{":": ">=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+			sop, ok := flip[op.text]
+			if !ok {
+				return "", badOp()
+			}
+			p.args = append(p.args, cutoff)
+			return col + " " + sop + " ?", nil
+		}
+		if _, err := time.Parse("2006-01-02", v.text); err != nil {
+			return "", bad("%s must be a date like 2024-01-31 or an age like 30d", name)
+		}
+		p.args = append(p.args, v.text)
+		return "date(" + col + ") " + sqlOp(op.text) + " ?", nil
+	}
+	return "", bad("unsupported field %s", name)
+}
+
+// sqlOp maps a query comparison operator to SQL; ":" means equals.
+func sqlOp(op string) string {
+	switch op {
+	case ":":
+		return "="
+	case "!=":
+		return "<>"
+	}
+	return op
+}
+
+// parseAge parses 30d, 2w, 6m (months) or 1y.
+func parseAge(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	day := 24 * time.Hour
+	unit := map[byte]time.Duration{'d': day, 'w': 7 * day, 'm': 30 * day, 'y': 365 * day}[s[len(s)-1]]
+	if unit == 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// escapeLike escapes LIKE wildcards for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// legacyToQuery converts a pre-query-language smart search (a raw SQL WHERE
+// fragment such as "length > 300 AND artist LIKE '%floyd%'") into the query
+// language. Only simple comparisons joined by AND/OR/NOT are understood;
+// ok is false for anything else.
+func legacyToQuery(sql string) (q string, ok bool) {
+	columns := make(map[string]string, len(queryFields))
+	for name, f := range queryFields {
+		columns[strings.ToLower(f.column)] = name
+	}
+
+	var out []string
+	s := strings.TrimSpace(sql)
+	for len(s) > 0 {
+		switch {
+		case s[0] == '(' || s[0] == ')':
+			out = append(out, s[:1])
+			s = strings.TrimSpace(s[1:])
+			continue
+		}
+		word := s
+		if i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }); i >= 0 {
+			word = s[:i]
+		}
+		switch strings.ToUpper(word) {
+		case "AND":
+			s = strings.TrimSpace(s[len(word):])
+			continue
+		case "OR":
+			out = append(out, "OR")
+			s = strings.TrimSpace(s[len(word):])
+			continue
+		case "NOT":
+			out = append(out, "-")
+			s = strings.TrimSpace(s[len(word):])
+			continue
+		}
+		name, known := columns[strings.ToLower(word)]
+		if word == "" || !known {
+			return "", false
+		}
+		s = strings.TrimSpace(s[len(word):])
+
+		// Operator.
+		var op string
+		for _, cand := range []string{"<>", "!=", "<=", ">=", "==", "=", "<", ">"} {
+			if strings.HasPrefix(s, cand) {
+				op = cand
+				break
+			}
+		}
+		like := false
+		switch {
+		case op != "":
+			s = strings.TrimSpace(s[len(op):])
+		case len(s) >= 4 && strings.EqualFold(s[:4], "LIKE"):
+			like = true
+			s = strings.TrimSpace(s[4:])
+		default:
+			return "", false
+		}
+		switch op {
+		case "<>":
+			op = "!="
+		case "==":
+			op = "="
+		}
+
+		// Value: 'string' or a bare number.
+		var val string
+		if strings.HasPrefix(s, "'") {
+			end := strings.Index(s[1:], "'")
+			if end < 0 {
+				return "", false
+			}
+			val, s = s[1:end+1], strings.TrimSpace(s[end+2:])
+		} else {
+			i := strings.IndexAny(s, " \t\n()")
+			if i < 0 {
+				i = len(s)
+			}
+			val, s = s[:i], strings.TrimSpace(s[i:])
+			if _, err := strconv.ParseFloat(val, 64); err != nil {
+				return "", false
+			}
+		}
+		if like {
+			inner := strings.TrimSuffix(strings.TrimPrefix(val, "%"), "%")
+			if inner == val || strings.ContainsAny(inner, "%_") {
+				return "", false
+			}
+			out = append(out, name+":"+strconv.Quote(inner))
+			continue
+		}
+		if strings.ContainsAny(val, ` "`) || val == "" {
+			val = strconv.Quote(val)
+		}
+		out = append(out, name+op+val)
+	}
+
+	q = strings.Join(out, " ")
+	q = strings.ReplaceAll(strings.ReplaceAll(q, "( ", "("), " )", ")")
+	q = strings.ReplaceAll(q, "- ", "-")
+	if _, _, err := CompileQuery(q); err != nil {
+		return "", false
+	}
+	return q, true
+}
@@ -0,0 +1,99 @@
+package music
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompileQuery(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		q     string
+		where string
+		args  []any
+	}{
+		{`favorite`, `favorite = 1`, nil},
+		{`-marked`, `NOT marked = 1`, nil},
+		{`year>=1970 plays<3`, `(year >= ? AND plays < ?)`, []any{1970.0, 3.0}},
+		{`artist:"Pink Floyd"`, `artist LIKE ? ESCAPE '\'`, []any{"%Pink Floyd%"}},
+		{`album="The Wall"`, `album = ? COLLATE NOCASE`, []any{"The Wall"}},
+		{`genre:rock`, `EXISTS (SELECT 1 FROM json_each(song.genre) WHERE value LIKE ? ESCAPE '\')`, []any{"%rock%"}},
+		{`year:1970..1979`, `year BETWEEN ? AND ?`, []any{1970.0, 1979.0}},
+		{`length>4:30`, `length > ?`, []any{270.0}},
+		{`added<30d`, `added > ?`, []any{"2026-09-18 12:00:00"}},
+		{`added>=2024-01-31`, `date(added) >= ?`, []any{"2024-01-31"}},
+		{`favorite:false`, `favorite = 0`, nil},
+		{`title:100%`, `title LIKE ? ESCAPE '\'`, []any{`%100\%%`}},
+		{
+			`(artist:queen OR artist:bowie) NOT favorite`,
+			`((artist LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\') AND NOT favorite = 1)`,
+			[]any{"%queen%", "%bowie%"},
+		},
+		{
+			`a OR b c`,
+			`((title LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\') OR ((title LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\') AND (title LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\')))`,
+			[]any{"%a%", "%a%", "%a%", "%b%", "%b%", "%b%", "%c%", "%c%", "%c%"},
+		},
+	}
+	for _, c := range cases {
+		where, args, err := compileQueryAt(c.q, now)
+		if err != nil {
+			t.Errorf("%q: %v", c.q, err)
+			continue
+		}
+		if where != c.where || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%q:\n got  %s %v\n want %s %v", c.q, where, args, c.where, c.args)
+		}
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	cases := []struct {
+		q   string
+		pos int
+	}{
+		{``, 0},
+		{`bogus:1`, 0},
+		{`year>abc`, 5},
+		{`artist:"unterminated`, 7},
+		{`(favorite`, 9},
+		{`plays<`, 6},
+		{`favorite<1`, 8},
+		{`OR favorite`, 0},
+	}
+	for _, c := range cases {
+		_, _, err := CompileQuery(c.q)
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("%q: err = %v, want *QueryError", c.q, err)
+			continue
+		}
+		if qe.Pos != c.pos {
+			t.Errorf("%q: pos = %d (%s), want %d", c.q, qe.Pos, qe.Msg, c.pos)
+		}
+	}
+}
+
+func TestLegacyToQuery(t *testing.T) {
+	cases := []struct {
+		sql, q string
+		ok     bool
+	}{
+		{`marked = 1`, `marked=1`, true},
+		{`plays > 5`, `plays>5`, true},
+		{`length > 300 AND year >= 1990`, `length>300 year>=1990`, true},
+		{`artist = 'Radiohead'`, `artist=Radiohead`, true},
+		{`artist LIKE '%pink floyd%' OR NOT (favorite = 1)`, `artist:"pink floyd" OR -(favorite=1)`, true},
+		{`trackNumber <> 1`, `track!=1`, true},
+		{`path GLOB '*.flac'`, ``, false},
+		{`plays > (SELECT 1)`, ``, false},
+	}
+	for _, c := range cases {
+		q, ok := legacyToQuery(c.sql)
+		if ok != c.ok || q != c.q {
+			t.Errorf("legacyToQuery(%q) = %q, %v; want %q, %v", c.sql, q, ok, c.q, c.ok)
+		}
+	}
+}
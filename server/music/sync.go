@@ -56,6 +56,7 @@ type Syncer struct {
 	backupDir string
 	cfg       MusicConfig
 	opts      SyncOptions
+	changed   []int64 // songs added or updated this run, for loudness analysis and fingerprinting
 }
 
 // NewSyncer creates a Syncer.
@@ -93,6 +94,8 @@ type songMeta struct {
 	CoverData   []byte
 	CoverType   string
 	CoverHash   string
+	Lyrics      string // embedded lyrics, LRC text when LyricsSync
+	LyricsSync  bool
 }
 
 // Run walks the music directory and synchronises it with the database.
@@ -107,6 +110,7 @@ func (s *Syncer) Run(ctx context.Context) error {
 	found := map[string]bool{}
 	var toProcess []string
 	var toDelete []string
+	var playlists []string
 	err := filepath.WalkDir(s.musicDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			log.Println("music sync: walk error:", err)
@@ -123,6 +127,10 @@ func (s *Syncer) Run(ctx context.Context) error {
 			return nil
 		}
 		ext := strings.ToLower(filepath.Ext(path))
+		if playlistExtensions[ext] {
+			playlists = append(playlists, path)
+			return nil
+		}
 		if !musicExtensions[ext] {
 			if ext != ".lrc" {
 				toDelete = append(toDelete, path)
@@ -190,6 +198,19 @@ func (s *Syncer) Run(ctx context.Context) error {
 
 	log.Printf("music sync: done — added=%d updated=%d skipped=%d", added, updated, skipped)
 
+	// Import playlist files dropped into the music directory.
+	s.importPlaylists(playlists)
+
+	// Measure loudness of new and changed files for ReplayGain.
+	if err := s.measureSongs(ctx, s.changed); err != nil {
+		log.Println("music sync: loudness warning:", err)
+	}
+	// Fingerprint them for duplicate detection.
+	if err := s.fingerprintSongs(ctx, s.changed); err != nil {
+		log.Println("music sync: fingerprint warning:", err)
+	}
+	s.changed = nil
+
 	// Always clean up after sync.
 	if err := s.Clean(ctx); err != nil {
 		log.Println("music sync: clean warning:", err)
@@ -417,6 +438,8 @@ func extractMeta(path string, data []byte, hash string) (*songMeta, error) {
 		Bitrate:     bitrate,
 	}
 
+	meta.Lyrics, meta.LyricsSync = embeddedLyrics(t)
+
 	if pic := t.Picture(); pic != nil {
 		meta.CoverData = pic.Data
 		meta.CoverType = pic.MIMEType
@@ -559,7 +582,7 @@ func (s *Syncer) findOrCreateCover(hash, contentType string, data []byte) (*int6
 // insertSong adds a new song row, storing the file mtime in the updated field.
 func (s *Syncer) insertSong(meta *songMeta, coverID *int64, mtime time.Time) error {
 	genreJSON, _ := json.Marshal(meta.Genre)
-	_, err := s.db.db.Exec(`
+	res, err := s.db.db.Exec(`
 		INSERT INTO song(path,hash,coverId,updated,artist,album,artistSort,albumSort,title,discNumber,trackNumber,trackTotal,genre,length,year,format,bitrate)
 		VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		meta.Path, meta.Hash, coverID, mtime.Format(time.RFC3339),
@@ -567,18 +590,29 @@ func (s *Syncer) insertSong(meta *songMeta, coverID *int64, mtime time.Time) err
 		meta.DiscNumber, meta.TrackNumber, meta.TrackTotal,
 		string(genreJSON), meta.Length, meta.Year, meta.Format, meta.Bitrate,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		s.changed = append(s.changed, id)
+		if err := s.db.storeEmbeddedLyrics(id, meta.Lyrics, meta.LyricsSync); err != nil {
+			log.Printf("music sync: lyrics for %s: %v", s.relPath(meta.Path), err)
+		}
+	}
+	return nil
 }
 
-// updateSong updates all metadata fields for an existing song row, storing mtime in updated.
+// updateSong updates all metadata fields for an existing song row, storing
+// mtime in updated. The loudness and fingerprint are cleared to be measured again.
 func (s *Syncer) updateSong(id int64, meta *songMeta, coverID *int64, mtime time.Time) error {
 	genreJSON, _ := json.Marshal(meta.Genre)
+	s.changed = append(s.changed, id)
 	_, err := s.db.db.Exec(`
 		UPDATE song SET
 			hash=?, coverId=?, updated=?, deleted=NULL,
 			artist=?, album=?, artistSort=?, albumSort=?, title=?,
 			discNumber=?, trackNumber=?, trackTotal=?, genre=?, length=?, year=?,
-			format=?, bitrate=?
+			format=?, bitrate=?, loudness=NULL, trackGain=NULL, trackPeak=NULL, fingerprint=NULL
 		WHERE id=?`,
 		meta.Hash, coverID, mtime.Format(time.RFC3339),
 		meta.Artist, meta.Album, meta.ArtistSort, meta.AlbumSort, meta.Title,
@@ -586,7 +620,10 @@ func (s *Syncer) updateSong(id int64, meta *songMeta, coverID *int64, mtime time
 		string(genreJSON), meta.Length, meta.Year, meta.Format, meta.Bitrate,
 		id,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.db.storeEmbeddedLyrics(id, meta.Lyrics, meta.LyricsSync)
 }
 
 // Clean removes songs that are marked deleted and not referenced by any
@@ -0,0 +1,200 @@
+package music
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sync"
+
+	"github.com/vincent99/velocipi/server/config"
+)
+
+// DefaultZone is the ID of the single zone used when music.zones is empty.
+const DefaultZone = "main"
+
+// zoneIDPattern limits zone IDs to characters safe in socket paths and URLs.
+var zoneIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Zones is the set of audio zones, each an independent Player with its own
+// queue, mpv instance, output and volume. Messages that don't name a zone go
+// to the first one.
+type Zones struct {
+	players []*Player
+}
+
+// NewZones creates a Player for each configured zone. Call Run(ctx) in a
+// goroutine to start them.
+func NewZones(db *DB, cfg MusicConfig, bc Broadcaster) *Zones {
+	z := &Zones{}
+	for i, zc := range zoneConfigs(cfg) {
+		// The first zone inherits the state saved before zones existed.
+		z.players = append(z.players, newPlayer(db, cfg, zc, i == 0, bc))
+	}
+	return z
+}
+
+// zoneConfigs resolves music.zones, filling unset fields from the global
+// settings and dropping zones with a missing, invalid or repeated ID. Always
+// returns at least one zone.
+func zoneConfigs(cfg MusicConfig) []ZoneConfig {
+	var zones []ZoneConfig
+	seen := make(map[string]bool)
+	for _, zc := range cfg.Zones {
+		if !zoneIDPattern.MatchString(zc.ID) || seen[zc.ID] {
+			log.Printf("music: ignoring zone with invalid or duplicate id %q", zc.ID)
+			continue
+		}
+		seen[zc.ID] = true
+		zones = append(zones, zc)
+	}
+	if len(zones) == 0 {
+		zones = []ZoneConfig{{ID: DefaultZone, Name: "Main"}}
+	}
+	for i := range zones {
+		if zones[i].Name == "" {
+			zones[i].Name = zones[i].ID
+		}
+		if zones[i].AudioDevice == "" {
+			zones[i].AudioDevice = cfg.AudioDevice
+		}
+		if zones[i].Volume == 0 {
+			zones[i].Volume = cfg.Volume
+		}
+	}
+	return zones
+}
+
+// Zone returns the player for a zone ID, the default zone for "", or nil if
+// there's no such zone.
+func (z *Zones) Zone(id string) *Player {
+	if id == "" {
+		return z.players[0]
+	}
+	for _, p := range z.players {
+		if p.zone.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Configs returns the resolved configuration of every zone.
+func (z *Zones) Configs() []ZoneConfig {
+	out := make([]ZoneConfig, len(z.players))
+	for i, p := range z.players {
+		p.mu.Lock()
+		out[i] = p.zone
+		p.mu.Unlock()
+	}
+	return out
+}
+
+// startupSettings are the music settings only read at startup, by the
+// database, sync and lookup code, or when a zone's mpv decks are created.
+type startupSettings struct {
+	Transition           string
+	CrossfadeSec         float64
+	AlbumRequiredPercent int
+	MinDbVersion         int
+	MaxBitrate           int
+	TranscodeFormat      string
+	AcoustIDKey          string
+	AcoustIDMinScore     float64
+	LyricsURL            string
+}
+
+func startupOnly(cfg MusicConfig) startupSettings {
+	return startupSettings{
+		Transition:           cfg.Transition,
+		CrossfadeSec:         cfg.CrossfadeSec,
+		AlbumRequiredPercent: cfg.AlbumRequiredPercent,
+		MinDbVersion:         cfg.MinDbVersion,
+		MaxBitrate:           cfg.MaxBitrate,
+		TranscodeFormat:      cfg.TranscodeFormat,
+		AcoustIDKey:          cfg.AcoustIDKey,
+		AcoustIDMinScore:     cfg.AcoustIDMinScore,
+		LyricsURL:            cfg.LyricsURL,
+	}
+}
+
+// Reconfigure applies changed music settings to the running zones: each
+// zone's name, output device, volume and duck setting, plus the settings
+// players read during playback (duck, auto-DJ, ReplayGain, scrobbling).
+// Adding, removing or reordering zones, or changing a startup-only setting,
+// returns config.ErrRestartRequired.
+func (z *Zones) Reconfigure(old, cfg MusicConfig) error {
+	zones := zoneConfigs(cfg)
+	if len(zones) != len(z.players) {
+		return config.ErrRestartRequired
+	}
+	for i, zc := range zones {
+		if zc.ID != z.players[i].zone.ID {
+			return config.ErrRestartRequired
+		}
+	}
+	for i, p := range z.players {
+		p.reconfigure(cfg, zones[i])
+	}
+	if startupOnly(old) != startupOnly(cfg) {
+		return config.ErrRestartRequired
+	}
+	return nil
+}
+
+// Control sends msg to the zone it names. Unknown zones are ignored.
+func (z *Zones) Control(msg ControlMsg) {
+	if p := z.Zone(msg.Zone); p != nil {
+		p.Control(msg)
+	}
+}
+
+// StateMsgs returns the player state of every zone.
+func (z *Zones) StateMsgs() []MusicStateMsg {
+	out := make([]MusicStateMsg, len(z.players))
+	for i, p := range z.players {
+		out[i] = p.StateMsg()
+	}
+	return out
+}
+
+// QueueMsgs returns the queue snapshot of every zone.
+func (z *Zones) QueueMsgs() []MusicQueueMsg {
+	out := make([]MusicQueueMsg, len(z.players))
+	for i, p := range z.players {
+		out[i] = p.QueueMsg()
+	}
+	return out
+}
+
+// Duck passes a duck signal to every zone that doesn't ignore radio traffic.
+func (z *Zones) Duck(source string, active bool) {
+	for _, p := range z.players {
+		p.mu.Lock()
+		ignore := p.zone.IgnoreDuck
+		p.mu.Unlock()
+		if !ignore {
+			p.Duck(source, active)
+		}
+	}
+}
+
+// SetFlight sets the current flight function on every zone. Call before Run.
+func (z *Zones) SetFlight(fn func() string) {
+	for _, p := range z.players {
+		p.SetFlight(fn)
+	}
+}
+
+// Run runs every zone's player loop. It blocks until ctx is cancelled and
+// they have all stopped.
+func (z *Zones) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range z.players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Run(ctx)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,79 @@
+package music
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestZoneConfigs(t *testing.T) {
+	cfg := MusicConfig{AudioDevice: "auto", Volume: 80}
+	zones := zoneConfigs(cfg)
+	if len(zones) != 1 || zones[0] != (ZoneConfig{ID: DefaultZone, Name: "Main", AudioDevice: "auto", Volume: 80}) {
+		t.Errorf("no zones configured: got %+v", zones)
+	}
+
+	cfg.Zones = []ZoneConfig{
+		{ID: "pilot", Name: "Pilot", AudioDevice: "alsa/intercom"},
+		{ID: "cabin", Volume: 60, IgnoreDuck: true},
+		{ID: "pilot"},     // duplicate
+		{ID: "../escape"}, // unsafe in a socket path
+		{Name: "No ID"},   // missing
+	}
+	zones = zoneConfigs(cfg)
+	want := []ZoneConfig{
+		{ID: "pilot", Name: "Pilot", AudioDevice: "alsa/intercom", Volume: 80},
+		{ID: "cabin", Name: "cabin", AudioDevice: "auto", Volume: 60, IgnoreDuck: true},
+	}
+	if len(zones) != len(want) {
+		t.Fatalf("got %d zones %+v, want %d", len(zones), zones, len(want))
+	}
+	for i := range want {
+		if zones[i] != want[i] {
+			t.Errorf("zone %d: got %+v, want %+v", i, zones[i], want[i])
+		}
+	}
+}
+
+func TestZonesRestoreState(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open(filepath.Join(dir, "music.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Migrate("../../schemas", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// State saved before zones existed belongs to the first zone.
+	d.SetState("player.queueEntries", []QueueEntry{{SongID: 7}, {SongID: 8}})
+	d.SetState("player.repeat", "queue")
+	// The cabin zone has its own saved queue and volume.
+	d.SetState("player.cabin.queueEntries", []QueueEntry{{SongID: 9}})
+	d.SetState("player.cabin.volume", 35)
+
+	z := NewZones(d, MusicConfig{Volume: 100, Zones: []ZoneConfig{{ID: "pilot"}, {ID: "cabin"}}}, nil)
+	pilot, cabin := z.Zone(""), z.Zone("cabin")
+	if pilot == nil || pilot.zone.ID != "pilot" || cabin == nil || z.Zone("nope") != nil {
+		t.Fatal("zone lookup")
+	}
+	if qs := pilot.QueueSnapshot(); len(qs.Entries) != 2 || qs.Repeat != RepeatQueue {
+		t.Errorf("pilot queue: %+v", qs)
+	}
+	if qs := cabin.QueueSnapshot(); len(qs.Entries) != 1 || qs.Entries[0].SongID != 9 || qs.Repeat == RepeatQueue {
+		t.Errorf("cabin queue: %+v", qs)
+	}
+
+	states := z.StateMsgs()
+	if len(states) != 2 || states[0].Zone != "pilot" || states[0].Volume != 100 || states[1].Zone != "cabin" || states[1].Volume != 35 {
+		t.Errorf("states: %+v", states)
+	}
+
+	// Once the first zone saves, its own keys take precedence.
+	pilot.queue.Replace([]int64{1})
+	pilot.saveState()
+	again := NewZones(d, MusicConfig{Zones: []ZoneConfig{{ID: "pilot"}, {ID: "cabin"}}}, nil).Zone("pilot")
+	if qs := again.QueueSnapshot(); len(qs.Entries) != 1 || qs.Entries[0].SongID != 1 {
+		t.Errorf("pilot queue after save: %+v", qs)
+	}
+}
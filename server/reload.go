@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"reflect"
+
+	"github.com/vincent99/velocipi/server/auth"
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/dvr"
+	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/hardware/brightness"
+	"github.com/vincent99/velocipi/server/hardware/oled"
+	"github.com/vincent99/velocipi/server/music"
+)
+
+// registerConfigSections registers how each subsystem applies its config
+// section live. Anything not registered here (or whose apply function
+// reports config.ErrRestartRequired) takes effect on the next start.
+// zones and display may be nil.
+func registerConfigSections(bus *config.Bus, hub *Hub, authn *auth.Auth, dvrManager *dvr.Manager, zones *music.Zones, display oled.Display) {
+	// UI settings and the keymap are read by browsers from /config (and by
+	// the panel input loop through hub.cfg), so tell them to re-fetch.
+	config.Register(bus, "tailNumber", func(c *config.Config) string { return c.TailNumber },
+		func(_, _ string) error {
+			hub.broadcastAll(ConfigChangedMsg{Type: "configChanged"})
+			return nil
+		})
+	config.Register(bus, "ui", func(c *config.Config) config.UIConfig { return c.UI },
+		func(old, new config.UIConfig) error {
+			hub.broadcastAll(ConfigChangedMsg{Type: "configChanged"})
+			// The OLED and screencast are sized at startup.
+			if old.Panel.Width != new.Panel.Width || old.Panel.Height != new.Panel.Height {
+				return config.ErrRestartRequired
+			}
+			return nil
+		})
+
+	config.Register(bus, "brightness",
+		func(c *config.Config) brightness.Config {
+			return brightness.Config{
+				Delay:  c.BrightnessDelayDur,
+				Speed:  c.BrightnessSpeedDur,
+				MinLux: c.Brightness.MinLux,
+				MaxLux: c.Brightness.MaxLux,
+			}
+		},
+		func(_, new brightness.Config) error {
+			hardware.Brightness().Reconfigure(new)
+			return nil
+		})
+
+	config.Register(bus, "hardware.knob", func(c *config.Config) config.KnobConfig { return c.Hardware.Knob },
+		func(old, new config.KnobConfig) error {
+			if old.Device != new.Device {
+				return config.ErrRestartRequired
+			}
+			if k := hardware.Knob(); k != nil {
+				k.SetRange(new.MinBrightness, new.MaxBrightness)
+				if err := k.SetBrightness(hardware.Brightness().Current()); err != nil {
+					log.Println("brightness: knob setBrightness error:", err)
+				}
+			}
+			return nil
+		})
+
+	if display != nil {
+		config.Register(bus, "hardware.oled.flip", func(c *config.Config) bool { return c.Hardware.OLED.Flip },
+			func(_, flip bool) error {
+				display.SetFlip(flip)
+				return nil
+			})
+	}
+
+	config.Register(bus, "dvr", func(c *config.Config) config.DVRConfig { return c.DVR },
+		func(old, new config.DVRConfig) error {
+			if err := dvrManager.ApplyConfig(new); err != nil {
+				return err
+			}
+			// Siyi managers and PTZ controllers are created at startup.
+			if !reflect.DeepEqual(controlledCameras(old.Cameras), controlledCameras(new.Cameras)) {
+				return config.ErrRestartRequired
+			}
+			return nil
+		})
+
+	if zones != nil {
+		config.Register(bus, "music", func(c *config.Config) config.MusicConfig { return c.Music },
+			zones.Reconfigure)
+	}
+
+	// Presets and ffcTempDelta are read from hub.cfg on each use.
+	config.Register(bus, "hardware.thermal", func(c *config.Config) config.ThermalConfig { return c.Hardware.Thermal },
+		func(old, new config.ThermalConfig) error {
+			if old.Device != new.Device || old.PollInterval != new.PollInterval {
+				return config.ErrRestartRequired
+			}
+			return nil
+		})
+
+	config.Register(bus, "auth", func(c *config.Config) config.AuthConfig { return c.Auth },
+		func(_, new config.AuthConfig) error { return authn.Reconfigure(new) })
+}
+
+// controlledCameras returns the settings of the cameras that get a siyi
+// manager or PTZ controller at startup, keyed by name.
+func controlledCameras(cams []config.CameraConfig) map[string]config.CameraConfig {
+	out := make(map[string]config.CameraConfig)
+	for _, c := range cams {
+		if c.Driver != "siyi" && c.Driver != "onvif" {
+			continue
+		}
+		// Presets are read from the config on use.
+		c.Presets = nil
+		c.Sort = nil
+		c.Retention = nil
+		out[c.Name] = c
+	}
+	return out
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/hardware/led"
 )
 
 // pngToImage opens a PNG file and returns it as an image.
@@ -155,10 +156,9 @@ func (h *Hub) runScreencastLoop(ctx context.Context) {
 			}
 		}
 		if e := hardware.Expander(); e != nil {
-			hardware.LEDRed().Off(e)
-			hardware.LEDWhite().Off(e)
-			hardware.LEDBlue().Off(e)
-			hardware.LEDYellow().Off(e)
+			for _, l := range []*led.Controller{hardware.LEDRed(), hardware.LEDWhite(), hardware.LEDBlue(), hardware.LEDYellow()} {
+				l.Release(e, "startup")
+			}
 		}
 	}()
 
@@ -60,6 +60,7 @@ func (h *Hub) runAirSensorLoop(ctx context.Context) {
 				continue
 			}
 			last = r
+			h.lastAirReading.Store(r)
 			data, err := json.Marshal(AirReadingMsg{Type: "airReading", Reading: *r})
 			if err != nil {
 				continue
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/vincent99/velocipi/server/snaps"
+)
+
+// registerSnapsRoutes wires the snaps gallery and SD card sync endpoints.
+// ctx bounds syncs started over HTTP (they outlive the request).
+func registerSnapsRoutes(ctx context.Context, mux *http.ServeMux, lib *snaps.Library, syncer *snaps.Syncer) {
+	// /snaps — GET lists the library, newest first.
+	// Optional filters: ?camera=, ?flight=, ?kind=photo|video.
+	mux.HandleFunc("/snaps", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		items, err := lib.List(snaps.Filter{Camera: q.Get("camera"), Flight: q.Get("flight"), Kind: q.Get("kind")})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	})
+
+	// /snaps/flights — GET groups the library by flight.
+	mux.HandleFunc("/snaps/flights", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flights, err := lib.Flights()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(flights)
+	})
+
+	// /snaps/sync — GET returns per-camera sync status; POST starts a sync of
+	// every siyi camera (admin only). Progress is also broadcast as
+	// "snapsSync" websocket messages.
+	mux.HandleFunc("/snaps/sync", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(syncer.Status())
+		case http.MethodPost:
+			if !isAdmin(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			syncer.SyncAll(ctx)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// /snaps/file/{path} — GET serves a media file; DELETE removes it along
+	// with its sidecar and thumbnail (admin only).
+	mux.HandleFunc("/snaps/file/", func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(r.URL.Path, "/snaps/file/")
+		switch r.Method {
+		case http.MethodGet:
+			path, err := lib.Resolve(rel)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.ServeFile(w, r, path)
+		case http.MethodDelete:
+			if !isAdmin(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			if err := lib.Delete(rel); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// /snaps/thumb/{path} — GET serves a JPEG thumbnail, generating it on
+	// first request.
+	mux.HandleFunc("/snaps/thumb/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		thumb, err := lib.Thumbnail(r.Context(), strings.TrimPrefix(r.URL.Path, "/snaps/thumb/"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		http.ServeFile(w, r, thumb)
+	})
+}
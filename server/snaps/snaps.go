@@ -0,0 +1,341 @@
+// Package snaps manages the photo/video library in storage.snaps: media
+// synced from camera SD cards, each tagged with the aircraft position and
+// flight at capture time, browsable as a gallery with cached thumbnails.
+//
+// Layout:
+//
+//	{snaps}/{camera}/{file}        synced media
+//	{snaps}/{camera}/{file}.json   Meta sidecar
+//	{snaps}/{camera}/.synced       names already synced (so deletes stick)
+//	{snaps}/.thumbs/{path}.jpg     generated thumbnails
+//	{snaps}/.track/{date}.jsonl    aircraft track log
+//
+// Files dropped in by hand (no sidecar) are still listed, dated by mtime.
+package snaps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaSuffix = ".json"
+	thumbsDir  = ".thumbs"
+	trackDir   = ".track"
+)
+
+// Media kinds.
+const (
+	KindPhoto = "photo"
+	KindVideo = "video"
+)
+
+var mediaKinds = map[string]string{
+	".jpg": KindPhoto, ".jpeg": KindPhoto, ".png": KindPhoto,
+	".mp4": KindVideo, ".mov": KindVideo, ".mkv": KindVideo,
+}
+
+// Meta is the sidecar stored next to each synced file.
+type Meta struct {
+	Camera     string    `json:"camera"`
+	Source     string    `json:"source"` // file name on the camera
+	CapturedAt time.Time `json:"capturedAt"`
+	SyncedAt   time.Time `json:"syncedAt"`
+	Position   *Sample   `json:"position,omitempty"` // nearest track sample; nil if none within range
+}
+
+// Item is one gallery entry.
+type Item struct {
+	Path       string    `json:"path"` // slash-separated, relative to the snaps dir
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"` // "photo" or "video"
+	Size       int64     `json:"size"`
+	Camera     string    `json:"camera,omitempty"`
+	CapturedAt time.Time `json:"capturedAt"`
+	Flight     string    `json:"flight,omitempty"`
+	Position   *Sample   `json:"position,omitempty"`
+}
+
+// Flight summarises the items captured during one flight.
+type Flight struct {
+	ID     string    `json:"id"`
+	Origin string    `json:"origin,omitempty"`
+	Dest   string    `json:"dest,omitempty"`
+	Start  time.Time `json:"start"` // first capture
+	End    time.Time `json:"end"`   // last capture
+	Photos int       `json:"photos"`
+	Videos int       `json:"videos"`
+}
+
+// Filter narrows List; empty fields match everything.
+type Filter struct {
+	Camera string
+	Flight string
+	Kind   string
+}
+
+// Library is the snaps directory.
+type Library struct {
+	dir         string
+	thumbHeight int
+	track       *Track
+
+	thumbMu sync.Mutex // serialises thumbnail generation
+}
+
+// NewLibrary opens the library rooted at dir. The track log lives in
+// dir/.track.
+func NewLibrary(dir string, thumbHeight int) *Library {
+	if thumbHeight <= 0 {
+		thumbHeight = 240
+	}
+	return &Library{dir: dir, thumbHeight: thumbHeight, track: NewTrack(filepath.Join(dir, trackDir))}
+}
+
+// Track returns the aircraft track log used for tagging.
+func (l *Library) Track() *Track {
+	return l.track
+}
+
+// List returns matching items, newest capture first.
+func (l *Library) List(f Filter) ([]Item, error) {
+	items := []Item{}
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == l.dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != l.dir {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		kind, ok := mediaKinds[strings.ToLower(filepath.Ext(d.Name()))]
+		if !ok || (f.Kind != "" && kind != f.Kind) {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.dir, path)
+		it, err := l.item(filepath.ToSlash(rel), kind, d)
+		if err != nil {
+			return nil
+		}
+		if (f.Camera == "" || it.Camera == f.Camera) && (f.Flight == "" || it.Flight == f.Flight) {
+			items = append(items, it)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].CapturedAt.After(items[j].CapturedAt) })
+	return items, err
+}
+
+// item builds an Item from a media file and its sidecar (if any).
+func (l *Library) item(rel, kind string, d fs.DirEntry) (Item, error) {
+	info, err := d.Info()
+	if err != nil {
+		return Item{}, err
+	}
+	it := Item{Path: rel, Name: d.Name(), Kind: kind, Size: info.Size(), CapturedAt: info.ModTime().UTC()}
+	if dir := filepath.Dir(filepath.FromSlash(rel)); dir != "." {
+		it.Camera = filepath.Base(dir)
+	}
+	if m, err := l.readMeta(rel); err == nil {
+		it.Camera = m.Camera
+		it.CapturedAt = m.CapturedAt
+		it.Position = m.Position
+		if m.Position != nil {
+			it.Flight = m.Position.Flight
+		}
+	}
+	return it, nil
+}
+
+// Flights groups the library by flight, most recent first. Items captured
+// on the ground are not included.
+func (l *Library) Flights() ([]Flight, error) {
+	items, err := l.List(Filter{})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Flight)
+	for _, it := range items {
+		if it.Flight == "" {
+			continue
+		}
+		fl := byID[it.Flight]
+		if fl == nil {
+			fl = &Flight{ID: it.Flight, Origin: it.Position.Origin, Dest: it.Position.Dest, Start: it.CapturedAt, End: it.CapturedAt}
+			byID[it.Flight] = fl
+		}
+		if it.CapturedAt.Before(fl.Start) {
+			fl.Start = it.CapturedAt
+		}
+		if it.CapturedAt.After(fl.End) {
+			fl.End = it.CapturedAt
+		}
+		if it.Kind == KindVideo {
+			fl.Videos++
+		} else {
+			fl.Photos++
+		}
+	}
+	out := make([]Flight, 0, len(byID))
+	for _, fl := range byID {
+		out = append(out, *fl)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.After(out[j].Start) })
+	return out, nil
+}
+
+// Resolve maps a library-relative path to an absolute one, rejecting paths
+// that escape the library or name hidden files.
+func (l *Library) Resolve(rel string) (string, error) {
+	clean, err := cleanRel(rel)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
+
+// thumbPath returns where rel's thumbnail is cached.
+func (l *Library) thumbPath(rel string) (string, error) {
+	clean, err := cleanRel(rel)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, thumbsDir, filepath.FromSlash(clean)+".jpg"), nil
+}
+
+// cleanRel normalises a library-relative media path (no leading slash).
+func cleanRel(rel string) (string, error) {
+	clean := strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+rel)), "/")
+	if clean == "" {
+		return "", fmt.Errorf("snaps: empty path")
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("snaps: invalid path %q", rel)
+		}
+	}
+	if _, ok := mediaKinds[strings.ToLower(filepath.Ext(clean))]; !ok {
+		return "", fmt.Errorf("snaps: not a media file: %q", rel)
+	}
+	return clean, nil
+}
+
+// Thumbnail returns the path of rel's JPEG thumbnail, generating it with
+// ffmpeg on first request (first frame for photos, 1s in for videos).
+func (l *Library) Thumbnail(ctx context.Context, rel string) (string, error) {
+	src, err := l.Resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	thumb, err := l.thumbPath(rel)
+	if err != nil {
+		return "", err
+	}
+
+	l.thumbMu.Lock()
+	defer l.thumbMu.Unlock()
+	if fi, err := os.Stat(thumb); err == nil && !fi.ModTime().Before(srcInfo.ModTime()) {
+		return thumb, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(thumb), 0o755); err != nil {
+		return "", err
+	}
+	args := []string{"-y", "-loglevel", "error"}
+	if mediaKinds[strings.ToLower(filepath.Ext(src))] == KindVideo {
+		args = append(args, "-ss", "1")
+	}
+	args = append(args, "-i", src, "-frames:v", "1", "-vf", "scale=-2:"+strconv.Itoa(l.thumbHeight), "-q:v", "4", thumb)
+	if out, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput(); err != nil {
+		os.Remove(thumb)
+		return "", fmt.Errorf("snaps: thumbnail %s: %v: %s", rel, err, strings.TrimSpace(string(out)))
+	}
+	return thumb, nil
+}
+
+// Delete removes a file with its sidecar and thumbnail. The file stays in
+// its camera's .synced list, so the next sync won't bring it back.
+func (l *Library) Delete(rel string) error {
+	path, err := l.Resolve(rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	os.Remove(path + metaSuffix)
+	if thumb, err := l.thumbPath(rel); err == nil {
+		os.Remove(thumb)
+	}
+	return nil
+}
+
+// tag writes the sidecar for a freshly synced file, dating it from a
+// timestamp in its name or, failing that, its mtime (set from the camera's
+// Last-Modified), and attaching the nearest track sample.
+func (l *Library) tag(path, camera, source string) (Meta, error) {
+	m := Meta{Camera: camera, Source: source, SyncedAt: time.Now().UTC()}
+	if t, ok := timeFromName(source); ok {
+		m.CapturedAt = t
+	} else if fi, err := os.Stat(path); err == nil {
+		m.CapturedAt = fi.ModTime().UTC()
+	}
+	if s, ok := l.track.Nearest(m.CapturedAt); ok {
+		m.Position = &s
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	return m, os.WriteFile(path+metaSuffix, data, 0o644)
+}
+
+// readMeta loads the sidecar for rel.
+func (l *Library) readMeta(rel string) (Meta, error) {
+	var m Meta
+	data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(rel)) + metaSuffix)
+	if err != nil {
+		return m, err
+	}
+	return m, json.Unmarshal(data, &m)
+}
+
+// nameTimeRe matches a yyyymmdd[_-]hhmmss timestamp in a camera file name.
+var nameTimeRe = regexp.MustCompile(`(\d{8})[_-]?(\d{6})`)
+
+// timeFromName extracts a capture time from a name like
+// "IMG_20261018_140233.jpg", interpreted in the local time zone (the camera
+// clock is set from the same GPS time the Pi uses).
+func timeFromName(name string) (time.Time, bool) {
+	m := nameTimeRe.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102150405", m[1]+m[2], time.Local)
+	if err != nil || t.Year() < 2000 {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
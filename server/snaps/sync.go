@@ -0,0 +1,246 @@
+package snaps
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/siyi"
+)
+
+const syncedList = ".synced"
+
+// SyncStatus reports one camera's SD card sync.
+type SyncStatus struct {
+	Camera     string    `json:"camera"`
+	Running    bool      `json:"running"`
+	LastRun    time.Time `json:"lastRun"` // start of the last completed run; zero if never
+	LastError  string    `json:"lastError,omitempty"`
+	OnCard     int       `json:"onCard"`     // media files seen on the card in the last listing
+	Pending    int       `json:"pending"`    // new files still to download in the current run
+	Downloaded int       `json:"downloaded"` // files downloaded in the current/last run
+}
+
+// cameraSync is one camera's sync state.
+type cameraSync struct {
+	name   string
+	dl     *siyi.Downloader
+	status SyncStatus
+}
+
+// Syncer copies new media from siyi camera SD cards into the library.
+type Syncer struct {
+	lib      *Library
+	onStatus func(SyncStatus)
+
+	mu   sync.Mutex
+	cams map[string]*cameraSync
+}
+
+// NewSyncer creates a Syncer for lib. onStatus (may be nil) is called on
+// every status change.
+func NewSyncer(lib *Library, onStatus func(SyncStatus)) *Syncer {
+	return &Syncer{lib: lib, onStatus: onStatus, cams: make(map[string]*cameraSync)}
+}
+
+// AddCamera registers a siyi camera whose card should be synced into
+// {snaps}/{name}.
+func (s *Syncer) AddCamera(name, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cams[name] = &cameraSync{
+		name:   name,
+		dl:     siyi.NewDownloader(host, filepath.Join(s.lib.dir, name)),
+		status: SyncStatus{Camera: name},
+	}
+}
+
+// Status returns every camera's sync status, sorted by camera name.
+func (s *Syncer) Status() []SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SyncStatus, 0, len(s.cams))
+	for _, c := range s.cams {
+		out = append(out, c.status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Camera < out[j].Camera })
+	return out
+}
+
+// SyncAll starts a sync of every camera that isn't already syncing and
+// returns immediately.
+func (s *Syncer) SyncAll(ctx context.Context) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.cams))
+	for name := range s.cams {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	for _, name := range names {
+		go func() {
+			if err := s.Sync(ctx, name); err != nil {
+				log.Printf("snaps: sync %s: %v", name, err)
+			}
+		}()
+	}
+}
+
+// Sync copies every file on the camera's card that hasn't been synced
+// before. Interrupted downloads resume on the next run. Returns nil without
+// doing anything if a sync of this camera is already running.
+func (s *Syncer) Sync(ctx context.Context, name string) error {
+	c, err := s.begin(name)
+	if err != nil || c == nil {
+		return err
+	}
+	started := time.Now().UTC()
+	err = s.run(ctx, c)
+	s.update(c, func(st *SyncStatus) {
+		st.Running = false
+		st.Pending = 0
+		st.LastRun = started
+		st.LastError = ""
+		if err != nil {
+			st.LastError = err.Error()
+		}
+	})
+	return err
+}
+
+// begin marks the camera as running; nil means it already was.
+func (s *Syncer) begin(name string) (*cameraSync, error) {
+	s.mu.Lock()
+	c := s.cams[name]
+	if c == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("snaps: no syncable camera %q", name)
+	}
+	if c.status.Running {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	c.status.Running = true
+	c.status.Downloaded = 0
+	st := c.status
+	s.mu.Unlock()
+	if s.onStatus != nil {
+		s.onStatus(st)
+	}
+	return c, nil
+}
+
+func (s *Syncer) run(ctx context.Context, c *cameraSync) error {
+	photos, err := c.dl.ListPhotos(ctx)
+	if err != nil {
+		return fmt.Errorf("list photos: %w", err)
+	}
+	videos, err := c.dl.ListVideos(ctx)
+	if err != nil {
+		return fmt.Errorf("list videos: %w", err)
+	}
+	all := append(photos, videos...)
+
+	dir := filepath.Join(s.lib.dir, c.name)
+	synced := readSynced(dir)
+	var todo []siyi.MediaFile
+	for _, f := range all {
+		if !synced[f.Name] {
+			todo = append(todo, f)
+		}
+	}
+	s.update(c, func(st *SyncStatus) { st.OnCard = len(all); st.Pending = len(todo) })
+
+	for _, f := range todo {
+		if _, err := s.fetch(ctx, c, f); err != nil {
+			return err
+		}
+		s.update(c, func(st *SyncStatus) { st.Pending--; st.Downloaded++ })
+	}
+	return nil
+}
+
+// Fetch downloads one file from the named camera (even if it was synced
+// before) and tags it.
+func (s *Syncer) Fetch(ctx context.Context, name string, f siyi.MediaFile) (Item, error) {
+	s.mu.Lock()
+	c := s.cams[name]
+	s.mu.Unlock()
+	if c == nil {
+		return Item{}, fmt.Errorf("snaps: no syncable camera %q", name)
+	}
+	return s.fetch(ctx, c, f)
+}
+
+// fetch downloads, tags and records one file.
+func (s *Syncer) fetch(ctx context.Context, c *cameraSync, f siyi.MediaFile) (Item, error) {
+	path, err := c.dl.Download(ctx, f)
+	if err != nil {
+		return Item{}, fmt.Errorf("download %s: %w", f.Name, err)
+	}
+	m, err := s.lib.tag(path, c.name, f.Name)
+	if err != nil {
+		return Item{}, fmt.Errorf("tag %s: %w", f.Name, err)
+	}
+	if err := appendSynced(filepath.Dir(path), f.Name); err != nil {
+		return Item{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Item{}, err
+	}
+	rel, _ := filepath.Rel(s.lib.dir, path)
+	it := Item{
+		Path: filepath.ToSlash(rel), Name: filepath.Base(path), Kind: mediaKinds[strings.ToLower(filepath.Ext(path))],
+		Size: fi.Size(), Camera: c.name, CapturedAt: m.CapturedAt, Position: m.Position,
+	}
+	if m.Position != nil {
+		it.Flight = m.Position.Flight
+	}
+	return it, nil
+}
+
+// update applies fn to the camera's status and notifies onStatus.
+func (s *Syncer) update(c *cameraSync, fn func(*SyncStatus)) {
+	s.mu.Lock()
+	fn(&c.status)
+	st := c.status
+	s.mu.Unlock()
+	if s.onStatus != nil {
+		s.onStatus(st)
+	}
+}
+
+// readSynced loads the set of card file names already synced into dir.
+func readSynced(dir string) map[string]bool {
+	out := make(map[string]bool)
+	f, err := os.Open(filepath.Join(dir, syncedList))
+	if err != nil {
+		return out
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if name := strings.TrimSpace(sc.Text()); name != "" {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+// appendSynced records name as synced into dir.
+func appendSynced(dir, name string) error {
+	f, err := os.OpenFile(filepath.Join(dir, syncedList), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, name)
+	return err
+}
@@ -0,0 +1,154 @@
+package snaps
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vincent99/velocipi/server/hardware/siyi"
+)
+
+// fakeCard serves a siyi camera's media API over a set of photos and
+// videos, counting downloads.
+type fakeCard struct {
+	mu      sync.Mutex
+	photos  []string
+	videos  []string
+	failing map[string]bool
+	gets    map[string]int
+}
+
+func (c *fakeCard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := c.photos
+	if r.URL.Query().Get("media_type") == "1" {
+		files = c.videos
+	}
+	switch r.URL.Path {
+	case "/cgi-bin/media.cgi/api/v1/getdirectories":
+		w.Write([]byte(`{"success":true,"data":{"directories":[{"path":"/DCIM"}]}}`))
+	case "/cgi-bin/media.cgi/api/v1/getmedialist":
+		type entry struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		}
+		list := []entry{}
+		for _, name := range files {
+			// The camera names itself in URLs; the downloader fixes the host.
+			list = append(list, entry{Name: name, URL: "http://192.168.144.25:82/DCIM/" + name})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "data": map[string]any{"list": list}})
+	default:
+		name := strings.TrimPrefix(r.URL.Path, "/DCIM/")
+		c.gets[name]++
+		if c.failing[name] {
+			http.Error(w, "card busy", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("media " + name))
+	}
+}
+
+func newSyncRig(t *testing.T) (*fakeCard, *Library, *Syncer, string) {
+	t.Helper()
+	card := &fakeCard{
+		photos:  []string{"IMG_20261018_140233.jpg"},
+		videos:  []string{"VID_20261018_141000.mp4"},
+		failing: make(map[string]bool),
+		gets:    make(map[string]int),
+	}
+	srv := httptest.NewServer(card)
+	t.Cleanup(srv.Close)
+
+	lib := NewLibrary(t.TempDir(), 0)
+	s := NewSyncer(lib, nil)
+	s.AddCamera("zoom", srv.Listener.Addr().String())
+	return card, lib, s, srv.URL
+}
+
+func TestSync(t *testing.T) {
+	card, lib, s, url := newSyncRig(t)
+
+	if err := s.Sync(t.Context(), "zoom"); err != nil {
+		t.Fatal(err)
+	}
+	st := s.Status()
+	if len(st) != 1 || st[0].Running || st[0].OnCard != 2 || st[0].Downloaded != 2 || st[0].Pending != 0 || st[0].LastRun.IsZero() {
+		t.Errorf("status after sync: %+v", st)
+	}
+	items, err := lib.List(Filter{Camera: "zoom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Kind != KindVideo || items[1].Kind != KindPhoto {
+		t.Fatalf("library: %+v", items)
+	}
+	if got, _ := os.ReadFile(filepath.Join(lib.dir, "zoom", "VID_20261018_141000.mp4")); string(got) != "media VID_20261018_141000.mp4" {
+		t.Errorf("video contents %q", got)
+	}
+
+	// Deleting from the library sticks; only new card files come down.
+	if err := lib.Delete(items[1].Path); err != nil {
+		t.Fatal(err)
+	}
+	card.mu.Lock()
+	card.photos = append(card.photos, "IMG_20261018_150000.jpg")
+	card.mu.Unlock()
+	if err := s.Sync(t.Context(), "zoom"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{
+		"IMG_20261018_140233.jpg": 1,
+		"VID_20261018_141000.mp4": 1,
+		"IMG_20261018_150000.jpg": 1,
+	} {
+		if card.gets[name] != want {
+			t.Errorf("%s downloaded %d times, want %d", name, card.gets[name], want)
+		}
+	}
+	if st := s.Status()[0]; st.OnCard != 3 || st.Downloaded != 1 {
+		t.Errorf("status after second sync: %+v", st)
+	}
+
+	// Fetch downloads again on request.
+	it, err := s.Fetch(t.Context(), "zoom", siyi.MediaFile{Name: "IMG_20261018_140233.jpg", URL: url + "/DCIM/IMG_20261018_140233.jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.gets["IMG_20261018_140233.jpg"] != 2 || it.Path != "zoom/IMG_20261018_140233.jpg" || it.Camera != "zoom" || it.CapturedAt.IsZero() {
+		t.Errorf("fetched item: %+v", it)
+	}
+}
+
+func TestSyncFailureRetries(t *testing.T) {
+	card, _, s, _ := newSyncRig(t)
+	card.failing["VID_20261018_141000.mp4"] = true
+
+	if err := s.Sync(t.Context(), "zoom"); err == nil {
+		t.Fatal("no error from a failed download")
+	}
+	if st := s.Status()[0]; st.LastError == "" || st.Running {
+		t.Errorf("status after failure: %+v", st)
+	}
+
+	delete(card.failing, "VID_20261018_141000.mp4")
+	if err := s.Sync(t.Context(), "zoom"); err != nil {
+		t.Fatal(err)
+	}
+	if card.gets["VID_20261018_141000.mp4"] != 2 || card.gets["IMG_20261018_140233.jpg"] != 1 {
+		t.Errorf("downloads: %v", card.gets)
+	}
+	if st := s.Status()[0]; st.LastError != "" || st.Downloaded != 1 {
+		t.Errorf("status after retry: %+v", st)
+	}
+
+	if err := s.Sync(t.Context(), "wide"); err == nil {
+		t.Error("unknown camera synced")
+	}
+}
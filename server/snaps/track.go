@@ -0,0 +1,190 @@
+package snaps
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/axis"
+)
+
+const (
+	takeoffKts   = 50               // airborne once ground speed exceeds this
+	landedKts    = 30               // landed once ground speed stays below this...
+	landedHold   = time.Minute      // ...for this long
+	sampleEvery  = 5 * time.Second  // track log resolution
+	recentWindow = 48 * time.Hour   // samples kept in memory
+	maxGap       = 10 * time.Minute // Nearest ignores samples further away than this
+	trackDateFmt = "2006-01-02"     // one JSONL file per UTC day
+	flightIDFmt  = "20060102-1504"  // takeoff time, UTC
+)
+
+// Sample is one logged aircraft position.
+type Sample struct {
+	Time     time.Time `json:"t"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	AltFt    float64   `json:"altFt"`
+	Heading  float64   `json:"heading"`
+	SpeedKts float64   `json:"speedKts"`
+	Flight   string    `json:"flight,omitempty"` // flight ID while airborne, e.g. "20261018-1402"
+	Origin   string    `json:"origin,omitempty"`
+	Dest     string    `json:"dest,omitempty"`
+}
+
+// Track logs the aircraft position every few seconds to dir/{date}.jsonl,
+// detects takeoff and landing from ground speed, and answers "where was the
+// aircraft at time t" for tagging synced media.
+type Track struct {
+	dir string
+
+	mu        sync.Mutex
+	recent    []Sample // ascending by time, trimmed to recentWindow
+	flight    string   // current flight ID, "" on the ground
+	slowSince time.Time
+	onLanding func(flight string)
+}
+
+// NewTrack opens the track log in dir, reloading the last two days so a
+// restart mid-flight continues the same flight.
+func NewTrack(dir string) *Track {
+	t := &Track{dir: dir}
+	now := time.Now().UTC()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		t.recent = append(t.recent, t.loadDay(day)...)
+	}
+	if n := len(t.recent); n > 0 {
+		last := t.recent[n-1]
+		if last.Flight != "" && now.Sub(last.Time) < landedHold {
+			t.flight = last.Flight
+		}
+	}
+	return t
+}
+
+// OnLanding registers fn to be called (in its own goroutine) when a flight
+// ends.
+func (t *Track) OnLanding(fn func(flight string)) {
+	t.mu.Lock()
+	t.onLanding = fn
+	t.mu.Unlock()
+}
+
+// Flight returns the current flight ID, or "" on the ground.
+func (t *Track) Flight() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.flight
+}
+
+// Record feeds one avionics state update. Takeoff/landing detection runs on
+// every call; a sample is logged at most every sampleEvery.
+func (t *Track) Record(s axis.State, now time.Time) {
+	t.mu.Lock()
+	var landed string
+	switch {
+	case t.flight == "" && s.SpeedKts > takeoffKts:
+		t.flight = now.UTC().Format(flightIDFmt)
+		t.slowSince = time.Time{}
+	case t.flight != "" && s.SpeedKts < landedKts:
+		if t.slowSince.IsZero() {
+			t.slowSince = now
+		} else if now.Sub(t.slowSince) >= landedHold {
+			landed = t.flight
+			t.flight = ""
+		}
+	case t.flight != "":
+		t.slowSince = time.Time{}
+	}
+
+	var sample *Sample
+	if n := len(t.recent); n == 0 || now.Sub(t.recent[n-1].Time) >= sampleEvery || landed != "" {
+		sample = &Sample{
+			Time: now.UTC(), Lat: s.Lat, Lon: s.Lon, AltFt: s.AltFt, Heading: s.Heading, SpeedKts: s.SpeedKts,
+			Flight: t.flight, Origin: s.Origin, Dest: s.Dest,
+		}
+		if landed != "" {
+			sample.Flight = landed // the touchdown sample still belongs to the flight
+		}
+		t.recent = append(t.recent, *sample)
+		cut := sort.Search(len(t.recent), func(i int) bool { return now.Sub(t.recent[i].Time) < recentWindow })
+		t.recent = t.recent[cut:]
+	}
+	cb := t.onLanding
+	t.mu.Unlock()
+
+	if sample != nil {
+		t.append(*sample)
+	}
+	if landed != "" && cb != nil {
+		go cb(landed)
+	}
+}
+
+// Nearest returns the logged sample closest to at, or false if none lies
+// within maxGap.
+func (t *Track) Nearest(at time.Time) (Sample, bool) {
+	t.mu.Lock()
+	samples := t.recent
+	t.mu.Unlock()
+	if len(samples) == 0 || at.Before(samples[0].Time.Add(-maxGap)) {
+		// Older than memory: read the day files around at.
+		at := at.UTC()
+		samples = append(t.loadDay(at.AddDate(0, 0, -1)), t.loadDay(at)...)
+		samples = append(samples, t.loadDay(at.AddDate(0, 0, 1))...)
+	}
+	return nearest(samples, at)
+}
+
+// nearest finds the sample in ascending samples closest to at within maxGap.
+func nearest(samples []Sample, at time.Time) (Sample, bool) {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(at) })
+	best, bestGap := Sample{}, maxGap+1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(samples) {
+			continue
+		}
+		gap := samples[j].Time.Sub(at).Abs()
+		if gap < bestGap {
+			best, bestGap = samples[j], gap
+		}
+	}
+	return best, bestGap <= maxGap
+}
+
+// append writes one sample to its day file. Errors are ignored: the track
+// is best-effort metadata.
+func (t *Track) append(s Sample) {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(t.dir, s.Time.Format(trackDateFmt)+".jsonl"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	data, _ := json.Marshal(s)
+	f.Write(append(data, '\n'))
+}
+
+// loadDay reads the samples logged on the given UTC day.
+func (t *Track) loadDay(day time.Time) []Sample {
+	f, err := os.Open(filepath.Join(t.dir, day.UTC().Format(trackDateFmt)+".jsonl"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []Sample
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s Sample
+		if json.Unmarshal(sc.Bytes(), &s) == nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
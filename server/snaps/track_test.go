@@ -0,0 +1,74 @@
+package snaps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vincent99/velocipi/server/hardware/axis"
+)
+
+func TestTrackFlightDetection(t *testing.T) {
+	tr := NewTrack(t.TempDir())
+	landed := make(chan string, 1)
+	tr.OnLanding(func(f string) { landed <- f })
+
+	t0 := time.Date(2026, 10, 18, 14, 2, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+
+	tr.Record(axis.State{SpeedKts: 10}, at(0))
+	if f := tr.Flight(); f != "" {
+		t.Fatalf("taxiing: flight = %q, want none", f)
+	}
+	tr.Record(axis.State{SpeedKts: 70}, at(time.Minute))
+	want := "20261018-1403"
+	if f := tr.Flight(); f != want {
+		t.Fatalf("after takeoff: flight = %q, want %q", f, want)
+	}
+
+	// A brief slow patch (e.g. slow flight) doesn't end the flight.
+	tr.Record(axis.State{SpeedKts: 20}, at(10*time.Minute))
+	tr.Record(axis.State{SpeedKts: 90}, at(10*time.Minute+30*time.Second))
+	tr.Record(axis.State{SpeedKts: 20}, at(20*time.Minute))
+	if f := tr.Flight(); f != want {
+		t.Fatalf("after slow patch: flight = %q, want %q", f, want)
+	}
+
+	tr.Record(axis.State{SpeedKts: 5}, at(21*time.Minute))
+	if f := tr.Flight(); f != "" {
+		t.Fatalf("after landing: flight = %q, want none", f)
+	}
+	select {
+	case f := <-landed:
+		if f != want {
+			t.Errorf("OnLanding got %q, want %q", f, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnLanding not called")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	t0 := time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: t0, Lat: 1},
+		{Time: t0.Add(time.Minute), Lat: 2},
+		{Time: t0.Add(2 * time.Minute), Lat: 3},
+	}
+	cases := []struct {
+		at      time.Time
+		wantLat float64
+		wantOK  bool
+	}{
+		{t0.Add(50 * time.Second), 2, true},
+		{t0.Add(10 * time.Second), 1, true},
+		{t0.Add(-5 * time.Minute), 1, true},
+		{t0.Add(-maxGap - time.Second), 0, false},
+		{t0.Add(2*time.Minute + maxGap + time.Second), 0, false},
+	}
+	for _, c := range cases {
+		s, ok := nearest(samples, c.at)
+		if ok != c.wantOK || s.Lat != c.wantLat {
+			t.Errorf("nearest(%v) = %v/%v, want lat %v/%v", c.at.Sub(t0), s.Lat, ok, c.wantLat, c.wantOK)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincent99/velocipi/server/config"
+	"github.com/vincent99/velocipi/server/hardware"
+	"github.com/vincent99/velocipi/server/hardware/thermalcam"
+)
+
+// sendThermalState sends the last thermal camera state to a newly-connected client.
+func (h *Hub) sendThermalState(c *client) {
+	ctl := hardware.Thermal()
+	if ctl == nil {
+		return
+	}
+	s, ok := ctl.State()
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(ThermalStateMsg{Type: "thermalState", State: s})
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// runThermalLoop polls the thermal camera, broadcasts state changes to all
+// WS clients and runs a flat-field calibration when the air temperature
+// changes by hardware.thermal.ffcTempDelta.
+func (h *Hub) runThermalLoop(ctx context.Context) {
+	ctl := hardware.Thermal()
+	if ctl == nil {
+		log.Println("thermalcam: not configured, skipping")
+		return
+	}
+	ctl.OnChange(func(s thermalcam.State) {
+		h.broadcastAll(ThermalStateMsg{Type: "thermalState", State: s})
+	})
+	go h.runThermalFFCLoop(ctx, ctl)
+	ctl.Run(ctx, h.cfg.ThermalPollIntervalDur)
+}
+
+// runThermalFFCLoop watches the air sensor readings and triggers an FFC
+// whenever the temperature has moved ffcTempDelta °C since the last one;
+// the camera's own temperature-delta shutter mode only sees its sensor
+// temperature, which lags the airframe.
+func (h *Hub) runThermalFFCLoop(ctx context.Context, ctl *thermalcam.Controller) {
+	ticker := time.NewTicker(h.cfg.AirSensorIntervalDur)
+	defer ticker.Stop()
+
+	base := math.NaN() // air temperature at the last FFC
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		delta := h.cfg.Hardware.Thermal.FFCTempDelta
+		r := h.lastAirReading.Load()
+		if delta <= 0 || r == nil {
+			continue
+		}
+		t := float64(r.TempC)
+		if math.IsNaN(base) {
+			base = t
+			continue
+		}
+		if math.Abs(t-base) < delta {
+			continue
+		}
+		log.Printf("thermalcam: air temperature %.1f°C -> %.1f°C, running FFC", base, t)
+		if err := ctl.Do((*thermalcam.ThermalCam).FFC); err != nil {
+			log.Println("thermalcam: FFC error:", err)
+			continue
+		}
+		base = t
+	}
+}
+
+// registerThermalRoutes registers the /thermal/* control endpoints.
+func registerThermalRoutes(mux *http.ServeMux, cfg *config.Config, bus *config.Bus) {
+	mux.HandleFunc("/thermal/", func(w http.ResponseWriter, r *http.Request) {
+		ctl := hardware.Thermal()
+		if ctl == nil {
+			http.Error(w, "thermal camera not configured", http.StatusServiceUnavailable)
+			return
+		}
+		action, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/thermal/"), "/")
+		switch action {
+		case "state":
+			thermalStateHandler(w, r, ctl, cfg)
+		case "set":
+			thermalSetHandler(w, r, ctl)
+		case "command":
+			thermalCommandHandler(w, r, ctl)
+		case "presets":
+			thermalPresetsHandler(w, r, ctl, cfg, bus, rest)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// thermalStateHandler serves GET /thermal/state: the last state read and
+// the saved presets.
+func thermalStateHandler(w http.ResponseWriter, r *http.Request, ctl *thermalcam.Controller, cfg *config.Config) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var state *thermalcam.State
+	if s, ok := ctl.State(); ok {
+		state = &s
+	}
+	presets := cfg.Hardware.Thermal.Presets
+	if presets == nil {
+		presets = []config.ThermalPreset{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"state":   state,
+		"presets": presets,
+	})
+}
+
+// thermalSetHandler writes a single setting. Body: {"field":"palette","value":"iron-red-1"}
+func thermalSetHandler(w http.ResponseWriter, r *http.Request, ctl *thermalcam.Controller) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Field string `json:"field"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var set func(t *thermalcam.ThermalCam) error
+	level := func(fn func(*thermalcam.ThermalCam, uint8) error) {
+		v, err := strconv.ParseUint(body.Value, 10, 8)
+		if err != nil || v > 100 {
+			return
+		}
+		set = func(t *thermalcam.ThermalCam) error { return fn(t, uint8(v)) }
+	}
+	switch body.Field {
+	case "brightness":
+		level((*thermalcam.ThermalCam).SetBrightness)
+	case "contrast":
+		level((*thermalcam.ThermalCam).SetContrast)
+	case "detailEnhancement":
+		level((*thermalcam.ThermalCam).SetDetailEnhancement)
+	case "staticDenoising":
+		level((*thermalcam.ThermalCam).SetStaticDenoising)
+	case "dynamicDenoising":
+		level((*thermalcam.ThermalCam).SetDynamicDenoising)
+	case "palette":
+		if p, ok := thermalcam.ParsePalette(body.Value); ok {
+			set = func(t *thermalcam.ThermalCam) error { return t.SetPalette(p) }
+		}
+	case "mirroring":
+		if m, ok := thermalcam.ParseMirrorMode(body.Value); ok {
+			set = func(t *thermalcam.ThermalCam) error { return t.SetMirroring(m) }
+		}
+	case "shutterMode":
+		if m, ok := thermalcam.ParseShutterMode(body.Value); ok {
+			set = func(t *thermalcam.ThermalCam) error { return t.SetShutterMode(m) }
+		}
+	case "shutterInterval":
+		if v, err := strconv.ParseUint(body.Value, 10, 16); err == nil {
+			set = func(t *thermalcam.ThermalCam) error { return t.SetShutterInterval(uint16(v)) }
+		}
+	default:
+		http.Error(w, "unknown field: "+body.Field, http.StatusBadRequest)
+		return
+	}
+	if set == nil {
+		http.Error(w, fmt.Sprintf("invalid %s value %q", body.Field, body.Value), http.StatusBadRequest)
+		return
+	}
+
+	if err := ctl.Do(set); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// thermalCommands are the actions accepted by POST /thermal/command.
+var thermalCommands = map[string]func(t *thermalcam.ThermalCam) error{
+	"ffc":                  (*thermalcam.ThermalCam).FFC,
+	"backgroundCorrection": (*thermalcam.ThermalCam).BackgroundCorrection,
+	"vignettingCorrection": (*thermalcam.ThermalCam).VignettingCorrection,
+	"saveSettings":         (*thermalcam.ThermalCam).SaveSettings,
+	"factoryReset":         (*thermalcam.ThermalCam).FactoryReset,
+	"cursorOn":             func(t *thermalcam.ThermalCam) error { return t.SetCursorDisplay(true) },
+	"cursorOff":            func(t *thermalcam.ThermalCam) error { return t.SetCursorDisplay(false) },
+	"cursorCenter":         func(t *thermalcam.ThermalCam) error { return t.MoveCursor(thermalcam.CursorCenter) },
+	"addDefectivePixel":    (*thermalcam.ThermalCam).AddDefectivePixel,
+	"removeDefectivePixel": (*thermalcam.ThermalCam).RemoveDefectivePixel,
+}
+
+// adminThermalCommands are the commands that change what the camera keeps
+// across power cycles, which only admins may run.
+var adminThermalCommands = map[string]bool{
+	"saveSettings": true,
+	"factoryReset": true,
+}
+
+// cursorDirs maps the cursor move commands to their direction.
+var cursorDirs = map[string]thermalcam.CursorDir{
+	"cursorUp":    thermalcam.CursorUp,
+	"cursorDown":  thermalcam.CursorDown,
+	"cursorLeft":  thermalcam.CursorLeft,
+	"cursorRight": thermalcam.CursorRight,
+}
+
+// thermalCommandHandler runs a one-shot command. Body: {"command":"ffc"},
+// or {"command":"cursorLeft","n":5} to move the defective-pixel cursor n
+// pixels (default 1). saveSettings and factoryReset are admin only.
+func thermalCommandHandler(w http.ResponseWriter, r *http.Request, ctl *thermalcam.Controller) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Command string `json:"command"`
+		N       uint8  `json:"n"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cmd, ok := thermalCommands[body.Command]
+	if dir, isMove := cursorDirs[body.Command]; isMove {
+		ok = true
+		cmd = func(t *thermalcam.ThermalCam) error {
+			if body.N <= 1 {
+				return t.MoveCursor(dir)
+			}
+			return t.MoveCursorN(dir, body.N)
+		}
+	}
+	if !ok {
+		http.Error(w, "unknown command: "+body.Command, http.StatusBadRequest)
+		return
+	}
+	if adminThermalCommands[body.Command] && !isAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err := ctl.Do(cmd); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// thermalPresetsHandler serves /thermal/presets[/{name}[/apply]]:
+//
+//	GET                        list the presets
+//	POST   {"name":"night"}    save the camera's current settings as a preset (admin)
+//	DELETE /{name}             delete a preset (admin)
+//	POST   /{name}/apply       apply a preset; ?save=true also stores it in the camera (admin)
+func thermalPresetsHandler(w http.ResponseWriter, r *http.Request, ctl *thermalcam.Controller, cfg *config.Config, bus *config.Bus, rest string) {
+	escaped, action, _ := strings.Cut(rest, "/")
+	name, _ := url.PathUnescape(escaped)
+	presets := cfg.Hardware.Thermal.Presets
+	idx := slices.IndexFunc(presets, func(p config.ThermalPreset) bool { return p.Name == name })
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		if presets == nil {
+			presets = []config.ThermalPreset{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(presets)
+
+	case r.Method == http.MethodPost && action == "apply":
+		if idx < 0 {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		save := r.URL.Query().Get("save") == "true"
+		if save && !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		settings := presetSettings(presets[idx])
+		err := ctl.Do(func(t *thermalcam.ThermalCam) error {
+			if err := t.ApplySettings(settings); err != nil {
+				return err
+			}
+			if save {
+				return t.SaveSettings()
+			}
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPost && name == "":
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		s, ok := ctl.State()
+		if !ok {
+			http.Error(w, "no state from camera yet", http.StatusConflict)
+			return
+		}
+		preset := settingsPreset(body.Name, s.Settings())
+		updated := slices.Clone(presets)
+		if i := slices.IndexFunc(updated, func(p config.ThermalPreset) bool { return p.Name == preset.Name }); i >= 0 {
+			updated[i] = preset
+		} else {
+			updated = append(updated, preset)
+		}
+		if err := saveThermalPresets(cfg, bus, updated); err != nil {
+			http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preset)
+
+	case r.Method == http.MethodDelete && name != "" && action == "":
+		if !isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if idx < 0 {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		if err := saveThermalPresets(cfg, bus, slices.Delete(slices.Clone(presets), idx, idx+1)); err != nil {
+			http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveThermalPresets replaces the thermal presets and writes config.yaml.
+func saveThermalPresets(cfg *config.Config, bus *config.Bus, presets []config.ThermalPreset) error {
+	updated := *cfg
+	updated.Hardware.Thermal.Presets = presets
+	_, err := bus.Update(updated)
+	return err
+}
+
+func presetSettings(p config.ThermalPreset) thermalcam.Settings {
+	return thermalcam.Settings{
+		Brightness:         p.Brightness,
+		Contrast:           p.Contrast,
+		DetailEnhancement:  p.DetailEnhancement,
+		StaticDenoising:    p.StaticDenoising,
+		DynamicDenoising:   p.DynamicDenoising,
+		Palette:            p.Palette,
+		Mirroring:          p.Mirroring,
+		ShutterMode:        p.ShutterMode,
+		ShutterIntervalMin: p.ShutterIntervalMin,
+	}
+}
+
+func settingsPreset(name string, s thermalcam.Settings) config.ThermalPreset {
+	return config.ThermalPreset{
+		Name:               name,
+		Brightness:         s.Brightness,
+		Contrast:           s.Contrast,
+		DetailEnhancement:  s.DetailEnhancement,
+		StaticDenoising:    s.StaticDenoising,
+		DynamicDenoising:   s.DynamicDenoising,
+		Palette:            s.Palette,
+		Mirroring:          s.Mirroring,
+		ShutterMode:        s.ShutterMode,
+		ShutterIntervalMin: s.ShutterIntervalMin,
+	}
+}
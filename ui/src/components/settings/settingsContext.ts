@@ -1,10 +1,12 @@
 import type { InjectionKey } from 'vue';
+import type { ConfigSchema } from '@/types/config';
 
 export interface SettingsContext {
   isModified: (path: string) => boolean;
   reset: (path: string) => void;
   getPath: (path: string) => unknown;
   setPath: (path: string, value: unknown) => void;
+  schema: (path: string) => ConfigSchema | undefined;
 }
 
 export const settingsKey = Symbol() as InjectionKey<SettingsContext>;
@@ -1,6 +1,7 @@
+import { useAuth } from '@/composables/useAuth';
+
 export function useAdmin() {
-  const isAdmin = document.cookie
-    .split(';')
-    .some((c) => c.trim() === 'admin=true');
+  const { me } = useAuth();
+  const isAdmin = me.value.role === 'admin';
   return { isAdmin };
 }
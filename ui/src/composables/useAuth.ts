@@ -0,0 +1,66 @@
+import { ref } from 'vue';
+
+export type Role = '' | 'viewer' | 'passenger' | 'pilot' | 'admin';
+
+export type AuthGroup =
+  | 'music'
+  | 'dvr'
+  | 'recordings'
+  | 'config'
+  | 'siyi'
+  | 'aircon'
+  | 'camera'
+  | 'panel';
+
+// Mirrors auth.Access on the server.
+export const ACCESS_READ = 1;
+export const ACCESS_WRITE = 2;
+
+export interface Me {
+  name: string; // "" if not logged in
+  role: Role; // "" = no access at all
+  permissions: Partial<Record<AuthGroup, number>>;
+}
+
+const me = ref<Me>({ name: '', role: '', permissions: {} });
+
+// loadAuth fetches who we are. main.ts awaits it before mounting so that
+// role checks can be synchronous everywhere else.
+export async function loadAuth(): Promise<void> {
+  try {
+    const r = await fetch('/auth/me');
+    if (r.ok) {
+      me.value = (await r.json()) as Me;
+    }
+  } catch (err) {
+    console.error('useAuth: failed to load /auth/me', err);
+  }
+}
+
+// can reports whether the current role has at least the given access
+// (default read) to a group.
+function can(group: AuthGroup, access = ACCESS_READ): boolean {
+  return (me.value.permissions[group] ?? 0) >= access;
+}
+
+async function login(name: string, password: string): Promise<void> {
+  const r = await fetch('/auth/login', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ name, password }),
+  });
+  if (!r.ok) {
+    throw new Error((await r.text()).trim() || `HTTP ${r.status}`);
+  }
+  me.value = (await r.json()) as Me;
+}
+
+async function logout(): Promise<void> {
+  await fetch('/auth/logout', { method: 'POST' });
+  // Reload so every page and the websocket pick up the anonymous role.
+  window.location.href = '/remote/login';
+}
+
+export function useAuth() {
+  return { me, can, login, logout };
+}
@@ -2,7 +2,7 @@ import { ref } from 'vue';
 
 export interface CameraInfo {
   name: string;
-  driver: string; // "rtsp" (default/empty) or "siyi"
+  driver: string; // "rtsp" (default/empty), "siyi" or "onvif"
   audio: boolean;
 }
 
@@ -19,6 +19,12 @@ function load(): Promise<void> {
   return fetchPromise;
 }
 
+// reloadConfig re-fetches /config, e.g. after the server reports a change.
+export function reloadConfig(): Promise<void> {
+  fetchPromise = null;
+  return load();
+}
+
 export function useConfig() {
   load();
   return { config };
@@ -1,5 +1,7 @@
-import { ref, reactive } from 'vue';
+import { ref, reactive, computed } from 'vue';
 import { useWebSocket } from '@/composables/useWebSocket';
+import { useLocalPref } from '@/composables/useLocalPreferences';
+import { reloadConfig } from '@/composables/useConfig';
 import type {
   AirReading,
   LEDStateMsg,
@@ -10,9 +12,11 @@ import type {
   DiskSpaceMsg,
   Tire,
   AxisStateMsg,
-  SiyiAttitudeMsg,
+  CameraAttitudeMsg,
+  CameraCapabilities,
   AirConState,
   AirConTempSample,
+  ThermalState,
   InboundWsMsg,
   LogicalKey,
 } from '@/types/ws';
@@ -29,10 +33,25 @@ const cameraRecording = reactive<Map<string, boolean>>(new Map());
 const lastRecordingReady = ref<RecordingReadyMsg | null>(null);
 // localCamera: the camera currently shown on the local display
 const localCamera = ref<string>('');
-// musicState: current music player state broadcast from server
-const musicState = ref<MusicStateMsg | null>(null);
-// musicQueue: full queue snapshot pushed from server on every queue change
-const musicQueue = ref<MusicQueueMsg | null>(null);
+// musicStates: per-zone music player state broadcast from server (zone ID → state)
+const musicStates = reactive<Map<string, MusicStateMsg>>(new Map());
+// musicQueues: per-zone queue snapshot pushed from server on every queue change
+const musicQueues = reactive<Map<string, MusicQueueMsg>>(new Map());
+// musicZone: the audio zone this client controls ('' = the server's default)
+const musicZone = useLocalPref<string>('music.zone', '');
+// musicZones: every known zone, in server order
+const musicZones = computed(() =>
+  [...musicStates.values()].map((s) => ({ id: s.zone, name: s.zoneName }))
+);
+// The selected zone if the server still has it, otherwise the first one.
+const activeZone = computed(() =>
+  musicStates.has(musicZone.value)
+    ? musicZone.value
+    : (musicZones.value[0]?.id ?? '')
+);
+// musicState/musicQueue: the active zone's player state and queue
+const musicState = computed(() => musicStates.get(activeZone.value) ?? null);
+const musicQueue = computed(() => musicQueues.get(activeZone.value) ?? null);
 // destTimezone: IANA timezone for the "Dest" clock on the panel
 const destTimezone = ref<string>('America/New_York');
 // dvrState: overall DVR recording state
@@ -41,12 +60,16 @@ const dvrState = ref<DVRRecordingState | null>(null);
 const diskSpace = ref<DiskSpaceMsg | null>(null);
 // axisState: most recent Axis avionics state
 const axisState = ref<AxisStateMsg | null>(null);
-// siyiAttitude: per-camera Siyi gimbal attitude (camera name → message)
-const siyiAttitude = reactive<Map<string, SiyiAttitudeMsg>>(new Map());
+// cameraAttitude: per-camera gimbal attitude (camera name → message)
+const cameraAttitude = reactive<Map<string, CameraAttitudeMsg>>(new Map());
+// cameraCapabilities: controls supported by each controllable camera
+const cameraCapabilities = reactive<Map<string, CameraCapabilities>>(new Map());
 // airConState: current aircon controller state
 const airConState = ref<AirConState | null>(null);
 // airConHistory: temperature history samples
 const airConHistory = ref<AirConTempSample[]>([]);
+// thermalState: thermal camera settings and versions
+const thermalState = ref<ThermalState | null>(null);
 
 // Key echo: tracks which logical keys are currently "active" for visual feedback.
 // Encoder keys (tap-only) auto-clear after 150ms; held keys clear on keyup.
@@ -134,26 +157,38 @@ function init() {
         localCamera.value = msg.camera;
         break;
       case 'musicState':
-        musicState.value = msg;
+        musicStates.set(msg.zone, msg);
         break;
       case 'musicQueue':
-        musicQueue.value = msg;
+        musicQueues.set(msg.zone, msg);
         break;
       case 'axisState':
         axisState.value = msg;
         break;
-      case 'siyiAttitude':
-        siyiAttitude.set(msg.camera, msg);
+      case 'cameraAttitude':
+        cameraAttitude.set(msg.camera, msg);
+        break;
+      case 'cameraCapabilities':
+        cameraCapabilities.clear();
+        for (const [name, caps] of Object.entries(msg.cameras)) {
+          cameraCapabilities.set(name, caps);
+        }
         break;
       case 'airConState':
         airConState.value = msg.state;
         break;
+      case 'thermalState':
+        thermalState.value = msg.state;
+        break;
       case 'airConHistory':
         airConHistory.value = msg.history;
         break;
       case 'airConSample':
         airConHistory.value = [...airConHistory.value, msg.sample];
         break;
+      case 'configChanged':
+        reloadConfig();
+        break;
     }
   });
 
@@ -177,11 +212,16 @@ export function useDeviceState() {
     destTimezone,
     musicState,
     musicQueue,
+    musicZone,
+    musicZones,
+    activeZone,
     dvrState,
     diskSpace,
     axisState,
-    siyiAttitude,
+    cameraAttitude,
+    cameraCapabilities,
     airConState,
+    thermalState,
     airConHistory,
   };
 }
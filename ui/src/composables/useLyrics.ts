@@ -1,14 +1,22 @@
 import { ref, computed, watch } from 'vue';
 import { useDeviceState } from '@/composables/useDeviceState';
-import type { LyricLine } from '@/types/music';
+import type { LyricLine, LyricsResponse } from '@/types/music';
 
 // Module-level singleton state
 const lines = ref<LyricLine[]>([]);
+const synced = ref(false);
+const offsetMs = ref(0);
 const currentIndex = ref(-1);
 const loading = ref(false);
-const lyricsCache = new Map<number, LyricLine[]>();
+const lyricsCache = new Map<number, LyricsResponse>();
 let lyricsInitialised = false;
 
+function show(data: LyricsResponse) {
+  lines.value = data.lines ?? [];
+  synced.value = data.synced;
+  offsetMs.value = data.offsetMs;
+}
+
 function initLyrics() {
   if (lyricsInitialised) {
     return;
@@ -26,6 +34,8 @@ function initLyrics() {
       // Always clear immediately so stale lyrics from the previous song
       // are never shown for the incoming song.
       lines.value = [];
+      synced.value = false;
+      offsetMs.value = 0;
       currentIndex.value = -1;
       loading.value = false;
 
@@ -34,7 +44,7 @@ function initLyrics() {
       }
 
       if (lyricsCache.has(id)) {
-        lines.value = lyricsCache.get(id)!;
+        show(lyricsCache.get(id)!);
         return;
       }
 
@@ -44,10 +54,9 @@ function initLyrics() {
         if (!r.ok) {
           return;
         }
-        const data: { lines: LyricLine[] } = await r.json();
-        const fetched = data.lines ?? [];
-        lyricsCache.set(id, fetched);
-        lines.value = fetched;
+        const data: LyricsResponse = await r.json();
+        lyricsCache.set(id, data);
+        show(data);
       } catch {
         // leave lines empty — "No lyrics" will be shown
       } finally {
@@ -64,7 +73,7 @@ function initLyrics() {
         ? musicState.value.elapsedSec
         : null,
     (elapsed) => {
-      if (elapsed == null || lines.value.length === 0) {
+      if (elapsed == null || lines.value.length === 0 || !synced.value) {
         return;
       }
       let lo = 0;
@@ -84,6 +93,29 @@ function initLyrics() {
   );
 }
 
+// adjustOffset shifts the current song's lyric timing by deltaMs (positive
+// shows lines later) and saves it on the server.
+async function adjustOffset(deltaMs: number) {
+  const { musicState } = useDeviceState();
+  const id = musicState.value?.currentSongId;
+  if (id == null) {
+    return;
+  }
+  const r = await fetch(`/music/songs/${id}/lyrics`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ offsetMs: offsetMs.value + deltaMs }),
+  });
+  if (!r.ok) {
+    return;
+  }
+  const data: LyricsResponse = await r.json();
+  lyricsCache.set(id, data);
+  if (musicState.value?.currentSongId === id) {
+    show(data);
+  }
+}
+
 export function useLyrics() {
   initLyrics();
   const { musicState } = useDeviceState();
@@ -93,6 +125,9 @@ export function useLyrics() {
     currentLines: lines,
     currentIndex,
     hasLyrics,
+    synced,
+    offsetMs,
+    adjustOffset,
     loading,
     isPlaying,
   };
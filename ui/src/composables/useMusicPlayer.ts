@@ -2,7 +2,7 @@ import { ref, computed, watch } from 'vue';
 import { useDeviceState } from '@/composables/useDeviceState';
 import { useWebSocket } from '@/composables/useWebSocket';
 import { useSongStore } from '@/composables/useSongStore';
-import type { MusicControlMsg } from '@/types/ws';
+import type { AutoDJSeed, MusicControlMsg } from '@/types/ws';
 import type { Song } from '@/types/music';
 
 // Module-level singleton — raw fetched song for the currently playing track.
@@ -46,10 +46,18 @@ function initPlayer() {
   );
 }
 
+// zoneUrl adds the active audio zone to a player API path.
+export function zoneUrl(path: string): string {
+  const { activeZone } = useDeviceState();
+  return activeZone.value
+    ? `${path}?zone=${encodeURIComponent(activeZone.value)}`
+    : path;
+}
+
 export function useMusicPlayer() {
   initPlayer();
 
-  const { musicState } = useDeviceState();
+  const { musicState, musicZone, musicZones, activeZone } = useDeviceState();
   const { send } = useWebSocket();
   const { resolve, patch } = useSongStore();
 
@@ -64,6 +72,9 @@ export function useMusicPlayer() {
     str?: string
   ) {
     const msg: MusicControlMsg = { type: 'musicControl', action };
+    if (activeZone.value) {
+      msg.zone = activeZone.value;
+    }
     if (value !== undefined) {
       msg.value = value;
     }
@@ -75,6 +86,9 @@ export function useMusicPlayer() {
 
   return {
     musicState,
+    musicZone,
+    musicZones,
+    activeZone,
     currentSong,
     control,
     play: () => control('play'),
@@ -90,27 +104,33 @@ export function useMusicPlayer() {
     setRepeat: (mode: 'off' | 'song' | 'queue') =>
       control('setRepeat', undefined, mode),
     jumpToIndex: (index: number) => control('jumpToIndex', index),
+    setAutoDJ: (on: boolean) => control('setAutoDJ', undefined, String(on)),
+    // Sets the seed and turns auto-DJ on.
+    startAutoDJ: (seed: AutoDJSeed) => {
+      control('setAutoDJSeed', undefined, JSON.stringify(seed));
+      control('setAutoDJ', undefined, 'true');
+    },
     undoQueueChange: () => control('undoQueueChange'),
     clearQueue: () =>
-      fetch('/music/queue', {
+      fetch(zoneUrl('/music/queue'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ songIds: [] }),
       }),
     replaceQueue: (ids: number[]) =>
-      fetch('/music/queue', {
+      fetch(zoneUrl('/music/queue'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ songIds: ids }),
       }),
     enqueue: (ids: number[]) =>
-      fetch('/music/queue/enqueue', {
+      fetch(zoneUrl('/music/queue/enqueue'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ songIds: ids }),
       }),
     appendQueue: (ids: number[]) =>
-      fetch('/music/queue/append', {
+      fetch(zoneUrl('/music/queue/append'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ songIds: ids }),
@@ -146,13 +166,13 @@ export function useMusicPlayer() {
       return r;
     },
     removeFromQueue: (index: number) =>
-      fetch('/music/queue/remove', {
+      fetch(zoneUrl('/music/queue/remove'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ index }),
       }),
     moveInQueue: (from: number, to: number) =>
-      fetch('/music/queue/move', {
+      fetch(zoneUrl('/music/queue/move'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ from, to }),
@@ -1,6 +1,7 @@
 import { ref, computed } from 'vue';
 import { useDeviceState } from '@/composables/useDeviceState';
 import { useLocalPref } from '@/composables/useLocalPreferences';
+import { zoneUrl } from '@/composables/useMusicPlayer';
 
 export type QueueAction = 'playNow' | 'queueNext' | 'queueLater' | 'append';
 
@@ -35,7 +36,7 @@ export function useQueueActions() {
   }
 
   async function playNow(ids: number[]): Promise<void> {
-    await fetch('/music/queue', {
+    await fetch(zoneUrl('/music/queue'), {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ songIds: ids }),
@@ -45,7 +46,7 @@ export function useQueueActions() {
 
   async function queueNext(ids: number[]): Promise<void> {
     const preFetchQueueIndex = musicState.value?.queueIndex ?? 0;
-    await fetch('/music/queue/enqueue', {
+    await fetch(zoneUrl('/music/queue/enqueue'), {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ songIds: ids }),
@@ -55,7 +56,7 @@ export function useQueueActions() {
 
   async function queueLater(ids: number[]): Promise<void> {
     const index = enqueueIndex.value;
-    await fetch('/music/queue/insert-at', {
+    await fetch(zoneUrl('/music/queue/insert-at'), {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ songIds: ids, index }),
@@ -64,7 +65,7 @@ export function useQueueActions() {
   }
 
   async function appendToQueue(ids: number[]): Promise<void> {
-    await fetch('/music/queue/append', {
+    await fetch(zoneUrl('/music/queue/append'), {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ songIds: ids }),
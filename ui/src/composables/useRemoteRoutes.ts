@@ -1,6 +1,7 @@
 import type { Component } from 'vue';
 import type { PanelMeta } from '@/types/config';
 import { useAdmin } from '@/composables/useAdmin';
+import { useAuth } from '@/composables/useAuth';
 
 export interface RemoteRoute {
   path: string;
@@ -16,6 +17,7 @@ export interface RemoteRoute {
 const modules = import.meta.glob('../routes/remote/**/*.vue', { eager: true });
 
 const { isAdmin } = useAdmin();
+const { can } = useAuth();
 
 const routes: RemoteRoute[] = Object.entries(modules)
   .map(([file, mod]) => {
@@ -40,6 +42,9 @@ const routes: RemoteRoute[] = Object.entries(modules)
     if (meta.admin && !isAdmin) {
       return null;
     }
+    if (meta.group && !can(meta.group)) {
+      return null;
+    }
 
     const headerComponent = (mod as { headerComponent?: Component })
       .headerComponent;
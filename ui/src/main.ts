@@ -7,6 +7,7 @@ import '@fontsource/roboto/700.css';
 import '@flaticon/flaticon-uicons/css/solid/rounded.css';
 import '@flaticon/flaticon-uicons/css/regular/rounded.css';
 import App from '@/App.vue';
+import { loadAuth, useAuth } from '@/composables/useAuth';
 
 const modules = import.meta.glob('./routes/**/*.vue');
 
@@ -76,4 +77,17 @@ const router = createRouter({
   routes,
 });
 
-createApp(App).use(router).mount('#app');
+// With no role (anonymous access disabled and not logged in) the only
+// reachable remote page is the login page.
+router.beforeEach((to) => {
+  const { me } = useAuth();
+  if (
+    me.value.role === '' &&
+    to.path.startsWith('/remote') &&
+    to.path !== '/remote/login'
+  ) {
+    return '/remote/login';
+  }
+});
+
+loadAuth().then(() => createApp(App).use(router).mount('#app'));
@@ -1,3 +1,5 @@
+import type { AuthGroup } from '@/composables/useAuth';
+
 export type TimeFormat = string;
 
 export interface PanelConfig {
@@ -36,6 +38,31 @@ export interface KeyMapConfig {
   outerRight: string;
 }
 
+export interface GestureBinding {
+  gesture: string; // e.g. 'enter.long', 'up+left', 'enter+inner-right'
+  route: string; // panel page relative to /panel/; '' = every page
+  action: 'key' | 'music' | 'led' | 'bookmark' | 'camera' | 'navigate' | 'aircon';
+  target: string;
+  value: number;
+  mode:
+    | ''
+    | 'on'
+    | 'off'
+    | 'blink'
+    | 'heartbeat'
+    | 'double-blink'
+    | 'sos'
+    | 'fade'
+    | 'toggle'
+    | 'clear';
+}
+
+export interface GestureConfig {
+  longPressMs: number;
+  doublePressMs: number;
+  bindings: GestureBinding[];
+}
+
 // UIConfig — subset served by GET /config (no ?full=true).
 // tailNumber is a top-level config key surfaced alongside the UI subset here.
 export interface Config {
@@ -46,6 +73,7 @@ export interface Config {
   panel: PanelConfig;
   navMenu: NavMenuConfig;
   keyMap: KeyMapConfig;
+  gestures: GestureConfig;
 }
 
 // Full config — served by GET /config?full=true and accepted by POST /config
@@ -75,8 +103,28 @@ export interface ExpanderBits {
 
 export interface ExpanderConfig {
   address: number;
-  interval: string;
+  interval: string; // poll interval when interrupts aren't wired
+  interruptPin: number; // GPIO pin wired to INTA/INTB; 0 = poll instead
+  gpioChip: string;
   bits: ExpanderBits;
+  encoders: EncodersConfig;
+}
+
+export interface AccelPoint {
+  speed: number; // detents per second
+  steps: number; // steps per detent at that speed
+}
+
+export interface EncoderConfig {
+  transitionsPerDetent: number; // 1, 2 or 4
+  invert: boolean;
+  acceleration: AccelPoint[];
+}
+
+export interface EncodersConfig {
+  inner: EncoderConfig;
+  outer: EncoderConfig;
+  joy: EncoderConfig;
 }
 
 export interface OLEDConfig {
@@ -103,22 +151,33 @@ export interface StorageConfig {
 
 export interface CameraConfig {
   name: string;
-  driver: string; // "rtsp" (default/empty) or "siyi"
+  driver: string; // "rtsp" (default/empty), "siyi" or "onvif"
   host: string;
   port: number;
+  path?: string; // RTSP path, e.g. "/live/main"
   username: string;
   password: string;
   audio: boolean;
   record?: boolean;
   sort?: number;
   siyiAIHost: string; // IP of AI tracking module; empty = disabled
+  onvifURL?: string; // ONVIF device service URL (driver "onvif")
+  onvifProfile?: string; // ONVIF media profile token; empty = first PTZ profile
+  presets?: CameraPreset[]; // named gimbal positions
+}
+
+export interface CameraPreset {
+  name: string;
+  yaw: number; // degrees relative to the airframe, positive = right
+  pitch: number; // degrees, positive = up
+  zoom?: number; // zoom factor; 0/absent = leave unchanged
 }
 
 export interface DVRConfig {
   segmentDuration: number; // seconds
   thumbnailHeight: number;
   record: boolean; // enable recording on startup
-  minFreeDisk: number; // minimum free disk space in GB; 0 = disabled
+  minFreeDisk: number; // minimum free disk space in GiB (1024³ bytes); 0 = disabled
   diskSpacePoll: string; // poll interval, e.g. "1m"
   cameras: CameraConfig[];
 }
@@ -132,6 +191,8 @@ export interface TireAddresses {
 export interface MusicConfig {
   volume: number;
   audioDevice: string; // mpv --audio-device value; "auto" = let mpv choose
+  transition: 'gapless' | 'crossfade';
+  crossfadeSec: number; // crossfade overlap; only used when transition is "crossfade"
   albumRequiredPercent: number;
   minDbVersion: number;
   maxBitrate: number; // kbps; 0 = no limit
@@ -139,6 +200,34 @@ export interface MusicConfig {
   playedRequiredPercent: number; // % elapsed before a skip counts as a play
   acoustidKey: string; // AcoustID API key (register free at acoustid.org)
   acoustidMinScore: number; // minimum AcoustID match score (0.0–1.0) to accept a result
+  duck: DuckConfig;
+  replayGain: 'track' | 'album' | 'off'; // loudness normalisation
+  replayGainPreamp: number; // dB added to the ReplayGain adjustment
+  scrobble: boolean; // queue plays for export via /music/scrobbles
+  autoDJ: AutoDJConfig;
+  lyricsURL: string; // LRCLIB-compatible lyrics API used by musicsync -lyrics; "" = off
+  zones: ZoneConfig[]; // independent outputs; empty = one "main" zone on audioDevice
+}
+
+export interface ZoneConfig {
+  id: string;
+  name: string;
+  audioDevice: string; // "" = music.audioDevice
+  volume: number; // 0 = music.volume
+  ignoreDuck: boolean; // keep playing through radio traffic and PTT
+}
+
+export interface AutoDJConfig {
+  minQueue: number; // top up when fewer songs than this are left
+  batch: number; // songs appended per top-up
+  avoidHours: number; // don't repeat songs played this recently
+}
+
+export interface DuckConfig {
+  mode: 'volume' | 'pause' | 'off';
+  level: number; // % of normal volume while ducked
+  hangoverSec: number; // hold the duck this long after traffic stops
+  rampSec: number; // volume ramp time in and out of the duck
 }
 
 export interface AirConConfig {
@@ -172,6 +261,21 @@ export interface FullConfig {
   ui: Config;
   music: MusicConfig;
   airCon: AirConConfig;
+  auth: AuthConfig;
+}
+
+export interface UserConfig {
+  name: string;
+  role: 'viewer' | 'passenger' | 'pilot' | 'admin';
+  hash: string; // from `go run ./cmd/passwd`
+}
+
+export interface AuthConfig {
+  users: UserConfig[];
+  anonymousRole: string; // role without logging in; "" = must log in
+  localRole: string; // role for requests from this machine (kiosk, intercom-stt)
+  sessionHours: number;
+  keyFile: string; // session signing key, shared with intercom-stt
 }
 
 export interface FullConfigResponse {
@@ -179,11 +283,29 @@ export interface FullConfigResponse {
   defaults: FullConfig;
 }
 
+// ConfigSchema is a node of the JSON Schema served by /config/schema,
+// generated from the Go config structs.
+export interface ConfigSchema {
+  description?: string;
+  type?: 'object' | 'array' | 'string' | 'integer' | 'number' | 'boolean';
+  format?: string; // "duration" or "frequency"
+  pattern?: string;
+  enum?: string[];
+  minimum?: number;
+  maximum?: number;
+  'x-unit'?: string;
+  default?: unknown;
+  properties?: Record<string, ConfigSchema>;
+  items?: ConfigSchema;
+  anyOf?: ConfigSchema[];
+}
+
 export interface PanelMeta {
   name: string;
   icon: string;
   iconStyle?: string; // uicons style prefix: 'sr' (default), 'rr', 'ss', 'rs', 'br', 'bs', etc.
   sort?: number;
   headerScreen?: boolean; // default true
-  admin?: boolean; // if true, only shown when admin cookie is present
+  admin?: boolean; // if true, only shown to the admin role
+  group?: AuthGroup; // if set, only shown to roles that can read this group
 }
@@ -57,6 +57,26 @@ export interface Playlist {
   items: number[];
 }
 
+export interface PlaylistEntry {
+  location: string;
+  artist?: string;
+  title?: string;
+  duration?: number;
+}
+
+export interface PlaylistImportResult {
+  id: number;
+  name: string;
+  matched: number;
+  unmatched: PlaylistEntry[];
+}
+
+export interface DuplicateGroup {
+  keeperId: number;
+  reasons: string[];
+  songs: Song[];
+}
+
 export interface SmartSearch {
   id: number;
   name: string;
@@ -74,6 +94,9 @@ export interface LyricLine {
 }
 
 export interface LyricsResponse {
+  source: '' | 'file' | 'embedded' | 'lrclib' | 'edited';
+  synced: boolean; // false: plain text, every timeSec is 0
+  offsetMs: number; // already applied to timeSec
   lines: LyricLine[];
 }
 
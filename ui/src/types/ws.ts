@@ -58,11 +58,19 @@ export interface TpmsMsg {
   tire: Tire;
 }
 
-export type LEDMode = 'off' | 'on' | 'blink';
+export type LEDMode =
+  | 'off'
+  | 'on'
+  | 'blink'
+  | 'heartbeat'
+  | 'double-blink'
+  | 'sos'
+  | 'fade';
 
 export interface LEDChannel {
   mode: LEDMode;
-  rate?: number;
+  rate?: number; // pattern unit in ms
+  owner?: string; // who requested the pattern showing
 }
 
 export interface LEDStateMsg {
@@ -144,9 +152,7 @@ export interface AxisStateMsg {
   oatCelsius: number;
 }
 
-export interface SiyiAttitudeMsg {
-  type: 'siyiAttitude';
-  camera: string;
+export interface CameraAttitude {
   yaw: number;
   pitch: number;
   roll: number;
@@ -155,8 +161,50 @@ export interface SiyiAttitudeMsg {
   rollRate: number;
 }
 
+export interface CameraAttitudeMsg extends CameraAttitude {
+  type: 'cameraAttitude';
+  camera: string;
+}
+
+export type CameraFollowMode = 'lock' | 'follow' | 'fpv';
+
+export interface CameraCapabilities {
+  move: boolean;
+  point: boolean;
+  zoom: boolean;
+  zoomTo: boolean;
+  focus: boolean;
+  photo: boolean;
+  record: boolean;
+  home: boolean;
+  attitude: boolean;
+  modes?: CameraFollowMode[];
+  minPitch?: number;
+  maxPitch?: number;
+  maxYaw?: number;
+  maxZoomLevel?: number;
+}
+
+export interface CameraCapabilitiesMsg {
+  type: 'cameraCapabilities';
+  cameras: Record<string, CameraCapabilities>;
+}
+
+export interface SnapsSyncMsg {
+  type: 'snapsSync';
+  camera: string;
+  running: boolean;
+  lastRun: string; // ISO timestamp; zero time if never run
+  lastError?: string;
+  onCard: number;
+  pending: number;
+  downloaded: number;
+}
+
 export interface MusicStateMsg {
   type: 'musicState';
+  zone: string; // audio zone ID
+  zoneName: string;
   currentSongId: number | null;
   queueIndex: number;
   status: 'playing' | 'paused' | 'stopped';
@@ -164,10 +212,20 @@ export interface MusicStateMsg {
   repeat: 'off' | 'song' | 'queue';
   elapsedSec: number;
   queueLength: number;
+  ducked?: 'volume' | 'paused'; // set while ducked for radio traffic
+  autoDJ: boolean; // keep the queue filled from autoDJSeed
+  autoDJSeed: AutoDJSeed;
+  volume: number; // 0-100, before ducking
+}
+
+export interface AutoDJSeed {
+  kind: 'library' | 'artist' | 'genre' | 'decade' | 'smart' | 'playlist' | '';
+  value?: string; // artist, genre, first year of decade, or smart search/playlist ID
 }
 
 export interface MusicQueueMsg {
   type: 'musicQueue';
+  zone: string; // audio zone ID
   currentIndex: number;
   entries: QueueEntryResponse[];
 }
@@ -207,6 +265,30 @@ export interface AirConStateMsg {
   state: AirConState;
 }
 
+export interface ThermalState {
+  model: string;
+  fpgaVersion: string;
+  fpgaCompileTime: string;
+  swVersion: string;
+  swCompileTime: string;
+  calibrationDate: string;
+  ispVersion: number;
+  shutterMode: string; // "off" | "timing" | "temp-delta" | "full-auto"
+  shutterIntervalMin: number;
+  brightness: number; // 0-100
+  contrast: number; // 0-100
+  detailEnhancement: number; // 0-100
+  staticDenoising: number; // 0-100
+  dynamicDenoising: number; // 0-100
+  palette: string;
+  mirroring: string; // "none" | "center" | "left-right" | "up-down"
+}
+
+export interface ThermalStateMsg {
+  type: 'thermalState';
+  state: ThermalState;
+}
+
 export interface AirConHistoryMsg {
   type: 'airConHistory';
   history: AirConTempSample[];
@@ -217,6 +299,11 @@ export interface AirConSampleMsg {
   sample: AirConTempSample;
 }
 
+// Sent after a config change the UI should pick up by re-fetching /config.
+export interface ConfigChangedMsg {
+  type: 'configChanged';
+}
+
 export type InboundWsMsg =
   | PingMsg
   | AirReadingMsg
@@ -231,12 +318,16 @@ export type InboundWsMsg =
   | DiskSpaceMsg
   | LocalCameraMsg
   | AxisStateMsg
-  | SiyiAttitudeMsg
+  | CameraAttitudeMsg
+  | CameraCapabilitiesMsg
+  | SnapsSyncMsg
   | MusicStateMsg
   | MusicQueueMsg
   | AirConStateMsg
   | AirConHistoryMsg
-  | AirConSampleMsg;
+  | AirConSampleMsg
+  | ThermalStateMsg
+  | ConfigChangedMsg;
 
 // Outbound messages (client → server, sent on /ws)
 
@@ -253,8 +344,9 @@ export interface KeyMsg {
 export interface LEDControlMsg {
   type: 'led';
   channel: 'r' | 'w' | 'b' | 'y';
-  state: LEDMode;
+  state: LEDMode | 'toggle' | 'clear';
   rate?: number;
+  ttl?: number; // ms until the request lapses
 }
 
 export interface NavigateMsg {
@@ -279,12 +371,47 @@ export interface MusicControlMsg {
     | 'skipForward'
     | 'skipBack'
     | 'setVolume'
+    | 'adjustVolume'
     | 'setShuffle'
     | 'setRepeat'
     | 'jumpToIndex'
-    | 'undoQueueChange';
-  value?: number; // seek: absolute seconds; skipForward/skipBack: delta seconds; setVolume: 0-100
-  str?: string; // setRepeat: 'off'|'song'|'queue'; setShuffle: 'true'|'false'
+    | 'undoQueueChange'
+    | 'setAutoDJ'
+    | 'setAutoDJSeed';
+  value?: number; // seek: absolute seconds; skipForward/skipBack: delta seconds; setVolume: 0-100; adjustVolume: delta
+  str?: string; // setRepeat: 'off'|'song'|'queue'; setShuffle/setAutoDJ: 'true'|'false'; setAutoDJSeed: JSON AutoDJSeed
+  zone?: string; // audio zone ID; omitted = the default zone
+}
+
+export interface CameraControlMsg {
+  type: 'cameraControl';
+  camera: string;
+  action:
+    | 'move'
+    | 'point'
+    | 'zoom'
+    | 'zoomTo'
+    | 'focus'
+    | 'autoFocus'
+    | 'photo'
+    | 'record'
+    | 'mode'
+    | 'stop'
+    | 'home'
+    | 'preset'
+    | 'lookAt';
+  pan?: number; // move: -1..1
+  tilt?: number; // move: -1..1
+  yaw?: number; // point: degrees
+  pitch?: number; // point: degrees
+  rate?: number; // zoom: -1..1
+  level?: number; // zoomTo: zoom factor
+  direction?: number; // focus: -1 near, 0 stop, 1 far
+  mode?: CameraFollowMode;
+  preset?: string; // preset: stored preset name
+  lat?: number; // lookAt: target latitude
+  lon?: number; // lookAt: target longitude
+  altFt?: number; // lookAt: target altitude, feet MSL
 }
 
 export type OutboundWsMsg =
@@ -293,4 +420,5 @@ export type OutboundWsMsg =
   | LEDControlMsg
   | NavigateMsg
   | SetLocalCameraMsg
+  | CameraControlMsg
   | MusicControlMsg;
@@ -13,29 +13,36 @@ export default defineConfig({
     port: 8081,
     allowedHosts: true,
     // NOTE: every Go API route prefix must be listed here so the dev server
-    // forwards it to Go instead of serving the SPA's index.html.
+    // forwards it to Go instead of serving the SPA's index.html. xfwd adds
+    // X-Forwarded-For, without which Go would give every dev client the
+    // local role.
     proxy: {
       '/ws': {
         target: 'ws://localhost:8080',
         ws: true,
         changeOrigin: false,
+        xfwd: true,
       },
       '/screen': {
         target: 'ws://localhost:8080',
         ws: true,
         changeOrigin: false,
+        xfwd: true,
       },
       '/config': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/cameras': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/mpegts': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
         selfHandleResponse: true,
         configure: (proxy) => {
           proxy.on(
@@ -54,30 +61,42 @@ export default defineConfig({
       '/music': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/recordings': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/admin': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
+      },
+      '/auth': {
+        target: 'http://localhost:8080',
+        changeOrigin: false,
+        xfwd: true,
       },
       '/dvr': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/siyi': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/aircon': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
       },
       '/snapshot': {
         target: 'http://localhost:8080',
         changeOrigin: false,
+        xfwd: true,
         // Disable response buffering so multipart/x-mixed-replace frames
         // are forwarded to the browser as they arrive rather than being
         // held until the connection closes.